@@ -0,0 +1,494 @@
+// aggregate implements a library of common aggregates which can be used to
+// summarize groups of tuples, without having to write a grouping function.
+
+package rel
+
+import (
+	"reflect"
+	"strings"
+)
+
+// aggKind identifies the calculation an aggregate performs
+type aggKind int
+
+const (
+	aggSum aggKind = iota
+	aggCount
+	aggAvg
+	aggMin
+	aggMax
+	aggCountDistinct
+)
+
+// String returns the name of the aggregate calculation
+func (k aggKind) String() string {
+	switch k {
+	case aggSum:
+		return "Sum"
+	case aggCount:
+		return "Count"
+	case aggAvg:
+		return "Avg"
+	case aggMin:
+		return "Min"
+	case aggMax:
+		return "Max"
+	case aggCountDistinct:
+		return "CountDistinct"
+	}
+	return "Unknown"
+}
+
+// Aggregate is a calculation over the values of an attribute in a group of
+// tuples, which results in a new attribute.  Aggregates are created with the
+// Sum, Count, Avg, Min, Max, and CountDistinct functions, and are used in
+// Summarize.
+type Aggregate struct {
+	// kind is the calculation performed
+	kind aggKind
+
+	// att is the attribute the calculation is performed on.  It is blank
+	// for Count, which does not depend on any attribute.
+	att Attribute
+
+	// res is the attribute that the result is assigned to
+	res Attribute
+}
+
+// Sum is an aggregate which adds the values of attribute att in each group,
+// and assigns the result to attribute res.  The att attribute must be
+// numeric, and res must have the same type.
+func Sum(att, res Attribute) Aggregate {
+	return Aggregate{aggSum, att, res}
+}
+
+// Count is an aggregate which counts the tuples in each group, and assigns
+// the result to attribute res, which must be an int.
+func Count(res Attribute) Aggregate {
+	return Aggregate{aggCount, "", res}
+}
+
+// Avg is an aggregate which finds the mean of the values of attribute att in
+// each group, and assigns the result to attribute res.  The att attribute
+// must be numeric, and res must be a float64.
+func Avg(att, res Attribute) Aggregate {
+	return Aggregate{aggAvg, att, res}
+}
+
+// Min is an aggregate which finds the smallest value of attribute att in each
+// group, and assigns the result to attribute res.  The att attribute must be
+// numeric or a string, and res must have the same type.
+func Min(att, res Attribute) Aggregate {
+	return Aggregate{aggMin, att, res}
+}
+
+// Max is an aggregate which finds the largest value of attribute att in each
+// group, and assigns the result to attribute res.  The att attribute must be
+// numeric or a string, and res must have the same type.
+func Max(att, res Attribute) Aggregate {
+	return Aggregate{aggMax, att, res}
+}
+
+// CountDistinct is an aggregate which counts the distinct values of
+// attribute att in each group, and assigns the result to attribute res,
+// which must be an int.
+func CountDistinct(att, res Attribute) Aggregate {
+	return Aggregate{aggCountDistinct, att, res}
+}
+
+// Func returns the name of the calculation the aggregate performs, such as
+// "Sum" or "CountDistinct".
+func (a Aggregate) Func() string {
+	return a.kind.String()
+}
+
+// Attribute returns the attribute the calculation is performed on, which is
+// blank for Count.
+func (a Aggregate) Attribute() Attribute {
+	return a.att
+}
+
+// Result returns the attribute the result of the calculation is assigned to
+func (a Aggregate) Result() Attribute {
+	return a.res
+}
+
+// String returns a text representation of the aggregate, such as
+// "Sum(Qty) as TotalQty".
+func (a Aggregate) String() string {
+	return a.kind.String() + "(" + string(a.att) + ") as " + string(a.res)
+}
+
+// isNumeric returns true if values of the kind can be used in arithmetic
+// aggregates.  These are the same numeric kinds that the comparison
+// predicates support.
+func isNumeric(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// check returns an error if the aggregate can't be applied to an attribute
+// with type e1, or if its result can't be assigned to an attribute with type
+// e2.  e1 is nil for aggregates that do not depend on an attribute.
+func (a Aggregate) check(e1, e2 reflect.Type) error {
+	var want reflect.Type
+	switch a.kind {
+	case aggSum, aggAvg:
+		if k := e1.Kind(); !isNumeric(k) {
+			return &KindError{a.att, k}
+		}
+		want = e1
+		if a.kind == aggAvg {
+			want = reflect.TypeOf(float64(0))
+		}
+	case aggMin, aggMax:
+		if k := e1.Kind(); !isNumeric(k) && k != reflect.String {
+			return &KindError{a.att, k}
+		}
+		want = e1
+	case aggCount, aggCountDistinct:
+		want = reflect.TypeOf(int(0))
+	}
+	if e2 != want {
+		return &AttributeTypeError{a.res, want, e2}
+	}
+	return nil
+}
+
+// accumulator holds the partial result of an aggregate for a single group
+type accumulator interface {
+	// add includes a value in the aggregate.  For Count, the value is
+	// invalid.
+	add(v reflect.Value)
+
+	// merge includes the values of another accumulator of the same aggregate
+	// in this one.
+	merge(acc2 accumulator)
+
+	// result returns the value of the aggregate
+	result() reflect.Value
+}
+
+// newAccumulator creates an empty accumulator for the aggregate, where e1 is
+// the type of the attribute which the aggregate is applied to.
+func (a Aggregate) newAccumulator(e1 reflect.Type) accumulator {
+	switch a.kind {
+	case aggSum:
+		return &sumAcc{reflect.New(e1).Elem()}
+	case aggCount:
+		return &countAcc{}
+	case aggAvg:
+		return &avgAcc{}
+	case aggMin:
+		return &extremeAcc{less: true}
+	case aggMax:
+		return &extremeAcc{less: false}
+	case aggCountDistinct:
+		return &countDistinctAcc{make(map[interface{}]struct{})}
+	}
+	return nil
+}
+
+// sumAcc accumulates a sum in a value of the same type as the attribute
+type sumAcc struct {
+	sum reflect.Value
+}
+
+func (acc *sumAcc) add(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		acc.sum.SetInt(acc.sum.Int() + v.Int())
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		acc.sum.SetUint(acc.sum.Uint() + v.Uint())
+	case reflect.Float32, reflect.Float64:
+		acc.sum.SetFloat(acc.sum.Float() + v.Float())
+	}
+}
+
+func (acc *sumAcc) merge(acc2 accumulator) {
+	acc.add(acc2.(*sumAcc).sum)
+}
+
+func (acc *sumAcc) result() reflect.Value {
+	return acc.sum
+}
+
+// countAcc counts the values
+type countAcc struct {
+	n int
+}
+
+func (acc *countAcc) add(v reflect.Value) {
+	acc.n++
+}
+
+func (acc *countAcc) merge(acc2 accumulator) {
+	acc.n += acc2.(*countAcc).n
+}
+
+func (acc *countAcc) result() reflect.Value {
+	return reflect.ValueOf(acc.n)
+}
+
+// avgAcc accumulates the sum and count of the values as float64s
+type avgAcc struct {
+	sum float64
+	n   int
+}
+
+func (acc *avgAcc) add(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		acc.sum += float64(v.Int())
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		acc.sum += float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		acc.sum += v.Float()
+	}
+	acc.n++
+}
+
+func (acc *avgAcc) merge(acc2 accumulator) {
+	a2 := acc2.(*avgAcc)
+	acc.sum += a2.sum
+	acc.n += a2.n
+}
+
+func (acc *avgAcc) result() reflect.Value {
+	return reflect.ValueOf(acc.sum / float64(acc.n))
+}
+
+// extremeAcc retains the smallest (if less is true) or largest value
+type extremeAcc struct {
+	v    reflect.Value
+	less bool
+}
+
+func (acc *extremeAcc) add(v reflect.Value) {
+	if !acc.v.IsValid() {
+		acc.v = v
+		return
+	}
+	if c := compareValues(v, acc.v); (acc.less && c < 0) || (!acc.less && c > 0) {
+		acc.v = v
+	}
+}
+
+func (acc *extremeAcc) merge(acc2 accumulator) {
+	if v := acc2.(*extremeAcc).v; v.IsValid() {
+		acc.add(v)
+	}
+}
+
+func (acc *extremeAcc) result() reflect.Value {
+	return acc.v
+}
+
+// countDistinctAcc retains the distinct values
+type countDistinctAcc struct {
+	m map[interface{}]struct{}
+}
+
+func (acc *countDistinctAcc) add(v reflect.Value) {
+	acc.m[valueKey(v)] = struct{}{}
+}
+
+func (acc *countDistinctAcc) merge(acc2 accumulator) {
+	for k := range acc2.(*countDistinctAcc).m {
+		acc.m[k] = struct{}{}
+	}
+}
+
+func (acc *countDistinctAcc) result() reflect.Value {
+	return reflect.ValueOf(len(acc.m))
+}
+
+// summary is an Aggregator which computes a set of Aggregates.  Its partial
+// aggregates are []accumulator, with one accumulator per Aggregate.
+type summary struct {
+	aggs []Aggregate
+
+	// valType is the type of the tuples which are accumulated, and resType
+	// is the type of the tuples which hold the results of the aggregates, in
+	// the same order as aggs.
+	valType reflect.Type
+	resType reflect.Type
+
+	// valIdx are the positions of the values of each aggregate in the value
+	// tuples, or -1 if the aggregate does not depend on a value.
+	valIdx []int
+}
+
+// ValZero returns a blank value tuple
+func (s *summary) ValZero() interface{} {
+	return reflect.New(s.valType).Elem().Interface()
+}
+
+// ResZero returns a blank result tuple
+func (s *summary) ResZero() interface{} {
+	return reflect.New(s.resType).Elem().Interface()
+}
+
+// Init returns empty accumulators for each of the aggregates
+func (s *summary) Init() interface{} {
+	accs := make([]accumulator, len(s.aggs))
+	for i, a := range s.aggs {
+		var e1 reflect.Type
+		if s.valIdx[i] >= 0 {
+			e1 = s.valType.Field(s.valIdx[i]).Type
+		}
+		accs[i] = a.newAccumulator(e1)
+	}
+	return accs
+}
+
+// Accumulate adds a value tuple to each of the accumulators
+func (s *summary) Accumulate(acc, val interface{}) interface{} {
+	vtup := reflect.ValueOf(val)
+	for i, ai := range acc.([]accumulator) {
+		if s.valIdx[i] < 0 {
+			ai.add(reflect.Value{})
+		} else {
+			ai.add(vtup.Field(s.valIdx[i]))
+		}
+	}
+	return acc
+}
+
+// Merge combines the accumulators of two partial aggregates
+func (s *summary) Merge(acc1, acc2 interface{}) interface{} {
+	accs2 := acc2.([]accumulator)
+	for i, ai := range acc1.([]accumulator) {
+		ai.merge(accs2[i])
+	}
+	return acc1
+}
+
+// Result returns a tuple with the result of each aggregate
+func (s *summary) Result(acc interface{}) interface{} {
+	res := reflect.New(s.resType).Elem()
+	for i, ai := range acc.([]accumulator) {
+		res.Field(i).Set(ai.result())
+	}
+	return res.Interface()
+}
+
+// Summarize creates a new relation by grouping the tuples of r1 and computing
+// aggregates over each of the groups.  z2 is the resulting tuple type.  Each
+// of the aggregates assigns to one of its attributes, and the rest of its
+// attributes are used to determine the groups, and must also be attributes of
+// r1.  For example:
+//
+//	type qtyTup struct {
+//		PNO      int
+//		TotalQty int
+//		N        int
+//	}
+//	r2 := rel.Summarize(r1, qtyTup{}, rel.Sum("Qty", "TotalQty"), rel.Count("N"))
+//
+// The aggregates are computed with an Aggregator, so the groups are
+// accumulated in parallel.  If the attributes of the aggregates do not exist,
+// or if they have the wrong types, then the resulting Relation will have
+// non-nil Err().
+func Summarize(r1 Relation, z2 interface{}, aggs ...Aggregate) Relation {
+	if r1.Err() != nil {
+		// don't bother building the relation and just return the original
+		return r1
+	}
+	e1 := reflect.TypeOf(r1.Zero())
+	e2 := reflect.TypeOf(z2)
+	att1 := Heading(r1)
+	att2 := FieldNames(e2)
+	fail := func(err error) Relation {
+		return &groupByExpr{r1, z2, nil, nil, reflect.Value{}, &summary{aggs: aggs}, err}
+	}
+
+	// the attributes which the aggregates are applied to, and which they
+	// result in
+	var valAtt, resAtt []Attribute
+	for _, a := range aggs {
+		if IsSubDomain([]Attribute{a.res}, resAtt) {
+			return fail(&AttributeConflictError{a.res})
+		}
+		resAtt = append(resAtt, a.res)
+		if a.kind != aggCount && !IsSubDomain([]Attribute{a.att}, valAtt) {
+			valAtt = append(valAtt, a.att)
+		}
+	}
+	err := EnsureSubDomain(valAtt, att1)
+	if err == nil {
+		err = EnsureSubDomain(resAtt, att2)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	// the remaining attributes of the result determine the groups.  The
+	// grouping is done by groupByExpr, which determines the groups from the
+	// attributes of the result that are in the source but which are not
+	// values provided to the grouping function, so the roles can't overlap.
+	var groupAtt []Attribute
+	for _, att := range att2 {
+		if !IsSubDomain([]Attribute{att}, resAtt) {
+			groupAtt = append(groupAtt, att)
+		}
+	}
+	if err := EnsureSubDomain(groupAtt, att1); err != nil {
+		return fail(err)
+	}
+	for _, att := range groupAtt {
+		if IsSubDomain([]Attribute{att}, valAtt) {
+			return fail(&AttributeConflictError{att})
+		}
+	}
+	for _, att := range resAtt {
+		if IsSubDomain([]Attribute{att}, att1) && !IsSubDomain([]Attribute{att}, valAtt) {
+			return fail(&AttributeConflictError{att})
+		}
+	}
+
+	// construct the tuple types which are provided to and returned from the
+	// grouping function
+	valFields := make([]reflect.StructField, len(valAtt))
+	for i, att := range valAtt {
+		f := e1.FieldByIndex(attributeField(e1, att))
+		valFields[i] = reflect.StructField{Name: f.Name, Type: f.Type, Tag: f.Tag}
+	}
+	resFields := make([]reflect.StructField, len(resAtt))
+	for i, att := range resAtt {
+		f := e2.FieldByIndex(attributeField(e2, att))
+		resFields[i] = reflect.StructField{Name: f.Name, Type: f.Type, Tag: f.Tag}
+	}
+	valType := reflect.StructOf(valFields)
+	resType := reflect.StructOf(resFields)
+
+	// check the types, and determine where each aggregate finds its value
+	valIdx := make([]int, len(aggs))
+	for i, a := range aggs {
+		valIdx[i] = -1
+		var e reflect.Type
+		if a.kind != aggCount {
+			valIdx[i] = attributeField(valType, a.att)[0]
+			e = valType.Field(valIdx[i]).Type
+		}
+		if err := a.check(e, resFields[i].Type); err != nil {
+			return fail(err)
+		}
+	}
+	return NewGroupBy(r1, z2, &summary{aggs, valType, resType, valIdx})
+}
+
+// aggregateString returns a text representation of a set of aggregates,
+// formatted like "Sum(Qty) as TotalQty, Count() as N"
+func aggregateString(aggs []Aggregate) string {
+	s := make([]string, len(aggs))
+	for i, a := range aggs {
+		s[i] = a.String()
+	}
+	return strings.Join(s, ", ")
+}
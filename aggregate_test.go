@@ -0,0 +1,221 @@
+package rel
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// tests for the aggregate library
+func TestSummarize(t *testing.T) {
+	type qtyTup struct {
+		PNO      int
+		TotalQty int
+		N        int
+	}
+	r1 := Summarize(orders(), qtyTup{}, Sum("Qty", "TotalQty"), Count("N"))
+	wantRes := New([]qtyTup{
+		{1, 1300, 6},
+		{2, 700, 2},
+		{3, 200, 1},
+		{4, 900, 3},
+	}, [][]string{})
+	if Card(r1.Diff(wantRes)) != 0 || Card(wantRes.Diff(r1)) != 0 {
+		t.Errorf("Summarize(orders) = \"%s\", want (ignore order) \"%s\"", r1.GoString(), wantRes.GoString())
+	}
+
+	type statsTup struct {
+		SNO       int
+		AvgQty    float64
+		MinQty    int
+		MaxQty    int
+		DistParts int
+	}
+	r2 := Summarize(orders(), statsTup{}, Avg("Qty", "AvgQty"), Min("Qty", "MinQty"), Max("Qty", "MaxQty"), CountDistinct("PNO", "DistParts"))
+	wantRes = New([]statsTup{
+		{1, 300, 300, 300, 2},
+		{2, 250, 200, 400, 4},
+		{3, 400, 400, 400, 1},
+		{4, 250, 200, 300, 2},
+		{5, 250, 100, 400, 2},
+		{6, 100, 100, 100, 1},
+	}, [][]string{})
+	if Card(r2.Diff(wantRes)) != 0 || Card(wantRes.Diff(r2)) != 0 {
+		t.Errorf("Summarize(orders) = \"%s\", want (ignore order) \"%s\"", r2.GoString(), wantRes.GoString())
+	}
+
+	// summarizing without any groups results in a single tuple
+	type cityTup struct {
+		MinCity string
+		MaxCity string
+	}
+	r3 := Summarize(suppliers(), cityTup{}, Min("City", "MinCity"), Max("City", "MaxCity"))
+	wantRes = New([]cityTup{{"Athens", "Paris"}}, [][]string{})
+	if Card(r3.Diff(wantRes)) != 0 || Card(wantRes.Diff(r3)) != 0 {
+		t.Errorf("Summarize(suppliers) = \"%s\", want (ignore order) \"%s\"", r3.GoString(), wantRes.GoString())
+	}
+
+	// test the degrees, cardinality, and string representation
+	type weightTup struct {
+		City      string
+		Weight    float64
+		AvgWeight float64
+	}
+	var relTest = []struct {
+		rel          Relation
+		expectString string
+		expectDeg    int
+		expectCard   int
+	}{
+		{r1, "Relation(PNO, SNO, Qty).Summarize({PNO}->{Sum(Qty) as TotalQty, Count() as N})", 3, 4},
+		{r2, "Relation(PNO, SNO, Qty).Summarize({SNO}->{Avg(Qty) as AvgQty, Min(Qty) as MinQty, Max(Qty) as MaxQty, CountDistinct(PNO) as DistParts})", 5, 6},
+		{r3, "Relation(SNO, SName, Status, City).Summarize({}->{Min(City) as MinCity, Max(City) as MaxCity})", 2, 1},
+		{Summarize(parts(), weightTup{}, Sum("Weight", "Weight"), Avg("Weight", "AvgWeight")), "Relation(PNO, PName, Color, Weight, City).Summarize({City}->{Sum(Weight) as Weight, Avg(Weight) as AvgWeight})", 3, 3},
+		{r1.Restrict(Attribute("N").GT(1)), "σ{N > 1}(Relation(PNO, SNO, Qty).Summarize({PNO}->{Sum(Qty) as TotalQty, Count() as N}))", 3, 3},
+	}
+	for i, tt := range relTest {
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%d has Err() => %s", i, err.Error())
+			continue
+		}
+		if str := tt.rel.String(); str != tt.expectString {
+			t.Errorf("%d has String() => %v, want %v", i, str, tt.expectString)
+		}
+		if deg := Deg(tt.rel); deg != tt.expectDeg {
+			t.Errorf("%d %s has Deg() => %v, want %v", i, tt.expectString, deg, tt.expectDeg)
+		}
+		if card := Card(tt.rel); card != tt.expectCard {
+			t.Errorf("%d %s has Card() => %v, want %v", i, tt.expectString, card, tt.expectCard)
+		}
+	}
+
+	// the groups are a candidate key
+	if ck := r1.CKeys(); len(ck) != 1 || len(ck[0]) != 1 || ck[0][0] != "PNO" {
+		t.Errorf("%s has CKeys() => %v, want [[PNO]]", r1, ck)
+	}
+
+	// test construction errors
+	type badTypeTup struct {
+		PNO      int
+		TotalQty float64
+	}
+	type badCountTup struct {
+		PNO int
+		N   int64
+	}
+	type badGroupTup struct {
+		Foo      int
+		TotalQty int
+	}
+	type conflictTup struct {
+		PNO int
+		Qty int
+	}
+	type stringTup struct {
+		SNO  int
+		Name string
+	}
+	type dupTup struct {
+		PNO      int
+		TotalQty int
+	}
+	errTest := []struct {
+		rel Relation
+		err error
+	}{
+		{Summarize(orders(), badTypeTup{}, Sum("Qty", "TotalQty")), &AttributeTypeError{}},
+		{Summarize(orders(), badCountTup{}, Count("N")), &AttributeTypeError{}},
+		{Summarize(orders(), badTypeTup{}, Sum("Foo", "TotalQty")), &AttributeSubsetError{}},
+		{Summarize(orders(), qtyTup{}, Sum("Qty", "Total")), &AttributeSubsetError{}},
+		{Summarize(orders(), badGroupTup{}, Sum("Qty", "TotalQty")), &AttributeSubsetError{}},
+		{Summarize(orders(), conflictTup{}, Max("PNO", "Qty")), &AttributeConflictError{}},
+		{Summarize(orders(), qtyTup{}, Sum("PNO", "TotalQty"), Count("N")), &AttributeConflictError{}},
+		{Summarize(orders(), dupTup{}, Sum("Qty", "TotalQty"), Max("Qty", "TotalQty")), &AttributeConflictError{}},
+		{Summarize(suppliers(), stringTup{}, Sum("SName", "Name")), &KindError{}},
+		{Summarize(suppliers(), stringTup{}, Avg("SName", "Name")), &KindError{}},
+	}
+	for i, tt := range errTest {
+		if err := tt.rel.Err(); err == nil {
+			t.Errorf("%d did not produce a construction error", i)
+		} else if reflect.TypeOf(err) != reflect.TypeOf(tt.err) {
+			t.Errorf("%d has Err() => %T, want %T", i, err, tt.err)
+		}
+	}
+
+	// test cancellation
+	res := make(chan qtyTup)
+	cancel := r1.TupleChan(res)
+	close(cancel)
+	select {
+	case <-res:
+		t.Errorf("cancel did not end tuple generation")
+	default:
+		// passed test
+	}
+
+	// test error short circuit
+	err := fmt.Errorf("testing error")
+	r4 := orders().Restrict(Attribute("PNO").EQ(1)).(*restrictExpr)
+	r4.err = err
+	if r5 := Summarize(r4, qtyTup{}, Sum("Qty", "TotalQty"), Count("N")); r5.Err() != err {
+		t.Errorf("Summarize did not short circuit error")
+	}
+}
+
+// tests for aggregates on each of the numeric kinds
+func TestAggregateKinds(t *testing.T) {
+	kinds := []interface{}{
+		int(0), int8(0), int16(0), int32(0), int64(0),
+		uint8(0), uint16(0), uint32(0), uint64(0),
+		float32(0), float64(0),
+	}
+	for _, k := range kinds {
+		e := reflect.TypeOf(k)
+
+		// construct a relation with tuples {G, V}, where there are two
+		// groups, one with values 1, 2, 3 and the other with values 4, 5
+		e1 := reflect.StructOf([]reflect.StructField{
+			{Name: "G", Type: reflect.TypeOf(int(0))},
+			{Name: "V", Type: e},
+		})
+		tups := reflect.MakeSlice(reflect.SliceOf(e1), 0, 5)
+		for i := 1; i <= 5; i++ {
+			tup := reflect.New(e1).Elem()
+			tup.Field(0).SetInt(int64(i / 4))
+			tup.Field(1).Set(reflect.ValueOf(i).Convert(e))
+			tups = reflect.Append(tups, tup)
+		}
+		r1 := New(tups.Interface(), [][]string{})
+
+		e2 := reflect.StructOf([]reflect.StructField{
+			{Name: "G", Type: reflect.TypeOf(int(0))},
+			{Name: "Sum", Type: e},
+			{Name: "Avg", Type: reflect.TypeOf(float64(0))},
+			{Name: "Min", Type: e},
+			{Name: "Max", Type: e},
+		})
+		r2 := Summarize(r1, reflect.New(e2).Elem().Interface(), Sum("V", "Sum"), Avg("V", "Avg"), Min("V", "Min"), Max("V", "Max"))
+		if err := r2.Err(); err != nil {
+			t.Errorf("Summarize(%v) has Err() => %s", e, err)
+			continue
+		}
+		want := map[int64][]float64{
+			0: []float64{6, 2, 1, 3},
+			1: []float64{9, 4.5, 4, 5},
+		}
+		res := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e2), 0)
+		r2.TupleChan(res.Interface())
+		for {
+			tup, ok := res.Recv()
+			if !ok {
+				break
+			}
+			g := tup.Field(0).Int()
+			for i, w := range want[g] {
+				if v := tup.Field(i + 1).Convert(reflect.TypeOf(float64(0))).Float(); v != w {
+					t.Errorf("Summarize(%v) has %s => %v in group %d, want %v", e, e2.Field(i+1).Name, v, g, w)
+				}
+			}
+		}
+	}
+}
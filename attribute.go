@@ -6,6 +6,7 @@ package rel
 import (
 	"reflect"
 	"sort"
+	"strconv"
 )
 
 // Attribute represents a particular attribute's name in a relation
@@ -16,34 +17,186 @@ type Attribute string
 type CandKeys [][]Attribute
 
 // FieldIndex is used to map between attributes in different relations
-// that have the same name
+// that have the same name.  I and J are the positions of the attribute in
+// the headings of each of the relations.
 type FieldIndex struct {
 	I int
 	J int
 }
 
-// FieldNames takes a reflect.Type of a struct and returns field names in order
+// fieldIndex is used to map between the fields which hold attributes with
+// the same name in different tuple types.  I and J are the index sequences
+// of the struct fields in each of the types, which can be used with
+// reflect.Value.FieldByIndex.  They may differ from the positions of the
+// attribute if a field is excluded or embedded.
+type fieldIndex struct {
+	I []int
+	J []int
+}
+
+// FieldNames takes a reflect.Type of a struct and returns field names in order.
+// A field with a `rel:"name"` tag is given the name in the tag, and a field
+// with a `rel:"-"` tag is not an attribute, so it is left out.  The fields of
+// embedded structs without a rel tag are flattened into the result, in the
+// place of the embedded struct.
 func FieldNames(e reflect.Type) []Attribute {
-	n := e.NumField()
-	names := make([]Attribute, n)
-	for i := 0; i < n; i++ {
-		f := e.Field(i)
-		names[i] = Attribute(f.Name)
+	fs := attributeFields(e)
+	names := make([]Attribute, len(fs))
+	for i, f := range fs {
+		names[i] = f.name
 	}
 	return names
 }
 
 // FieldTypes takes a reflect.Type of a struct and returns field types in order
 func FieldTypes(e reflect.Type) []reflect.Type {
-	n := e.NumField()
-	types := make([]reflect.Type, n)
-	for i := 0; i < n; i++ {
-		f := e.Field(i)
-		types[i] = f.Type
+	fs := attributeFields(e)
+	types := make([]reflect.Type, len(fs))
+	for i, f := range fs {
+		types[i] = e.FieldByIndex(f.index).Type
 	}
 	return types
 }
 
+// AttributeFields takes a reflect.Type of a struct and returns the index
+// sequences of the fields which are attributes, in the same order as
+// FieldNames.  They can be used with reflect.Value.FieldByIndex to get the
+// values of the attributes of a tuple.
+func AttributeFields(e reflect.Type) [][]int {
+	fs := attributeFields(e)
+	idx := make([][]int, len(fs))
+	for i, f := range fs {
+		idx[i] = f.index
+	}
+	return idx
+}
+
+// attribute is the name of an attribute and the index sequence of the struct
+// field which holds it
+type attribute struct {
+	name  Attribute
+	index []int
+}
+
+// attributeFields returns the attributes held in the fields of a struct type.
+// Fields of embedded structs are promoted the same way that go promotes
+// them: a field hides the fields with the same name in more deeply embedded
+// structs, and if there are several at the same depth, the first one is
+// used.
+func attributeFields(e reflect.Type) []attribute {
+	var fs []attribute
+	var walk func(e reflect.Type, index []int)
+	walk = func(e reflect.Type, index []int) {
+		for i := 0; i < e.NumField(); i++ {
+			f := e.Field(i)
+			name, ok := attributeName(f)
+			if !ok {
+				continue
+			}
+			fi := append(append([]int(nil), index...), i)
+			if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("rel") == "" {
+				walk(f.Type, fi)
+				continue
+			}
+			fs = append(fs, attribute{name, fi})
+		}
+	}
+	walk(e, nil)
+
+	depth := make(map[Attribute]int, len(fs))
+	for _, f := range fs {
+		if d, ok := depth[f.name]; !ok || len(f.index) < d {
+			depth[f.name] = len(f.index)
+		}
+	}
+	res := fs[:0]
+	for _, f := range fs {
+		if d, ok := depth[f.name]; ok && len(f.index) == d {
+			res = append(res, f)
+			delete(depth, f.name)
+		}
+	}
+	return res
+}
+
+// attributeName returns the name of the attribute held in a struct field,
+// and false if the field is excluded with a `rel:"-"` tag.
+func attributeName(f reflect.StructField) (Attribute, bool) {
+	switch tag := f.Tag.Get("rel"); tag {
+	case "-":
+		return "", false
+	case "":
+		return Attribute(f.Name), true
+	default:
+		return Attribute(tag), true
+	}
+}
+
+// attributeField returns the index sequence of the struct field which holds
+// the attribute, or nil if the attribute is not in the tuple type.
+func attributeField(e reflect.Type, att Attribute) []int {
+	for _, f := range attributeFields(e) {
+		if f.name == att {
+			return f.index
+		}
+	}
+	return nil
+}
+
+// hasExcludedFields returns true if any of the fields of the tuple type are
+// excluded from its attributes, or are hidden by other fields.
+func hasExcludedFields(e reflect.Type) bool {
+	var count func(e reflect.Type) int
+	count = func(e reflect.Type) int {
+		n := 0
+		for i := 0; i < e.NumField(); i++ {
+			if f := e.Field(i); f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("rel") == "" {
+				n += count(f.Type)
+			} else {
+				n++
+			}
+		}
+		return n
+	}
+	return len(attributeFields(e)) != count(e)
+}
+
+// ClearExcluded returns a copy of the tuple which only has the values of its
+// attributes, with the fields that are excluded with a `rel:"-"` tag or
+// hidden by other fields set to their zero values.  It should be used to
+// implement new Relations which create tuples from their own input.
+func ClearExcluded(tup reflect.Value) reflect.Value {
+	return clearExcluded(tup)
+}
+
+// clearExcluded returns a copy of the tuple which only has the values of its
+// attributes, so that tuples which only differ in excluded fields are equal.
+func clearExcluded(tup reflect.Value) reflect.Value {
+	tup2 := reflect.Indirect(reflect.New(tup.Type()))
+	for _, i := range AttributeFields(tup.Type()) {
+		tup2.FieldByIndex(i).Set(tup.FieldByIndex(i))
+	}
+	return tup2
+}
+
+// tupleType constructs a tuple type from struct fields, which may come from
+// several different types.  The fields keep their names and tags, except
+// that fields which would have the same name as an earlier field are given a
+// numeric suffix.
+func tupleType(sfs []reflect.StructField) reflect.Type {
+	fields := make([]reflect.StructField, len(sfs))
+	used := make(map[string]bool)
+	for i, f := range sfs {
+		n := f.Name
+		for k := 2; used[n]; k++ {
+			n = f.Name + strconv.Itoa(k)
+		}
+		used[n] = true
+		fields[i] = reflect.StructField{Name: n, Type: f.Type, Tag: f.Tag}
+	}
+	return reflect.StructOf(fields)
+}
+
 // OrderCandidateKeys sorts candidate keys by number of attributes and then alphabetically.
 func OrderCandidateKeys(ckeys CandKeys) {
 	// first go through each set of keys and alphabetize
@@ -144,14 +297,30 @@ KeyLoop:
 }
 
 // FieldMap creates a map from fields of one struct type to the fields of another
-// the returned map's values have two fields i,j , which indicate the location of
-// the field name in the input types
+// the returned map's values have two fields i,j , which indicate the position
+// of the attribute in the headings of the input types, as in AttributeMap.
 // if the field is absent from either of the inputs, it is not returned.
 func FieldMap(e1, e2 reflect.Type) map[Attribute]FieldIndex {
-	// TODO(jonlawlor): we might want to exclude unexported fields?
-	fn1 := FieldNames(e1)
-	fn2 := FieldNames(e2)
-	return AttributeMap(fn1, fn2)
+	return AttributeMap(FieldNames(e1), FieldNames(e2))
+}
+
+// fieldMap creates a map from the fields of one struct type to the fields of
+// another which hold the same attributes.  The returned map's values hold the
+// index sequences of the struct fields in each of the types.  if the
+// attribute is absent from either of the inputs, it is not returned.
+func fieldMap(e1, e2 reflect.Type) map[Attribute]fieldIndex {
+	fs1 := attributeFields(e1)
+	fs2 := attributeFields(e2)
+	m := make(map[Attribute]fieldIndex)
+	for _, f1 := range fs1 {
+		for _, f2 := range fs2 {
+			if f1.name == f2.name {
+				m[f1.name] = fieldIndex{f1.index, f2.index}
+				break
+			}
+		}
+	}
+	return m
 }
 
 // AttributeMap creates a map from positions of one set of attributes to another.
@@ -194,6 +363,10 @@ SubLoop:
 // The reason we have to put zero values is that we can't make derived types.
 // returns the results as an interface instead of as reflect.Value's
 func PartialProject(tup reflect.Value, ltyp, rtyp reflect.Type, lFieldMap, rFieldMap map[Attribute]FieldIndex) (reflect.Value, reflect.Value) {
+	return partialProject(tup, ltyp, rtyp, fieldPaths(tup.Type(), ltyp, lFieldMap), fieldPaths(tup.Type(), rtyp, rFieldMap))
+}
+
+func partialProject(tup reflect.Value, ltyp, rtyp reflect.Type, lFieldMap, rFieldMap map[Attribute]fieldIndex) (reflect.Value, reflect.Value) {
 	// assign fields from the old relation to fields in the new
 	ltup := reflect.Indirect(reflect.New(ltyp))
 	rtup := reflect.Indirect(reflect.New(rtyp))
@@ -202,13 +375,13 @@ func PartialProject(tup reflect.Value, ltyp, rtyp reflect.Type, lFieldMap, rFiel
 	// in ltup will retain the zero value
 	for lname, lfm := range lFieldMap {
 		if _, exists := rFieldMap[lname]; !exists {
-			tupf := ltup.Field(lfm.J)
-			tupf.Set(tup.Field(lfm.I))
+			tupf := ltup.FieldByIndex(lfm.J)
+			tupf.Set(tup.FieldByIndex(lfm.I))
 		}
 	}
 	for _, rfm := range rFieldMap {
-		tupf := rtup.Field(rfm.J)
-		tupf.Set(tup.Field(rfm.I))
+		tupf := rtup.FieldByIndex(rfm.J)
+		tupf.Set(tup.FieldByIndex(rfm.I))
 	}
 	return ltup, rtup
 }
@@ -217,15 +390,19 @@ func PartialProject(tup reflect.Value, ltyp, rtyp reflect.Type, lFieldMap, rFiel
 // takes fields from the right tuple if possible, otherwise takes fields from
 // the left tuple.
 func CombineTuples(ltup, rtup reflect.Value, ltyp reflect.Type, fMap map[Attribute]FieldIndex) reflect.Value {
+	return combineTuples(ltup, rtup, ltyp, fieldPaths(ltyp, rtup.Type(), fMap))
+}
+
+func combineTuples(ltup, rtup reflect.Value, ltyp reflect.Type, fMap map[Attribute]fieldIndex) reflect.Value {
 	tup2 := reflect.Indirect(reflect.New(ltyp))
 	leftNames := FieldNames(ltyp)
-	for i, leftName := range leftNames {
-		lf := tup2.Field(i)
-		if fm, isRight := fMap[leftName]; isRight {
+	for i, j := range AttributeFields(ltyp) {
+		lf := tup2.FieldByIndex(j)
+		if fm, isRight := fMap[leftNames[i]]; isRight {
 			// take the values from the right
-			lf.Set(rtup.Field(fm.J))
+			lf.Set(rtup.FieldByIndex(fm.J))
 		} else {
-			lf.Set(ltup.Field(i))
+			lf.Set(ltup.FieldByIndex(j))
 		}
 	}
 	return tup2
@@ -236,9 +413,13 @@ func CombineTuples(ltup, rtup reflect.Value, ltyp reflect.Type, fMap map[Attribu
 // TODO(jonlawlor): figure out how to combine with CombineTuples, or rename
 // this func.  Very ugly.
 func CombineTuples2(to *reflect.Value, from reflect.Value, fMap map[Attribute]FieldIndex) {
+	combineTuples2(to, from, fieldPaths(to.Type(), from.Type(), fMap))
+}
+
+func combineTuples2(to *reflect.Value, from reflect.Value, fMap map[Attribute]fieldIndex) {
 	for _, fm := range fMap {
-		tof := to.Field(fm.I)
-		tof.Set(from.Field(fm.J))
+		tof := to.FieldByIndex(fm.I)
+		tof.Set(from.FieldByIndex(fm.J))
 	}
 	return
 }
@@ -246,10 +427,22 @@ func CombineTuples2(to *reflect.Value, from reflect.Value, fMap map[Attribute]Fi
 // PartialEquals returns true when two tuples have equal values in the attributes
 // with the same names.
 func PartialEquals(tup1 reflect.Value, tup2 reflect.Value, fmap map[Attribute]FieldIndex) bool {
-	for _, fm := range fmap {
-		if tup1.Field(fm.I).Interface() != tup2.Field(fm.J).Interface() {
+	for _, fm := range fieldPaths(tup1.Type(), tup2.Type(), fmap) {
+		if tup1.FieldByIndex(fm.I).Interface() != tup2.FieldByIndex(fm.J).Interface() {
 			return false
 		}
 	}
 	return true
 }
+
+// fieldPaths converts a map of attribute positions in the headings of e1 and
+// e2 into a map of the struct fields which hold them.
+func fieldPaths(e1, e2 reflect.Type, fMap map[Attribute]FieldIndex) map[Attribute]fieldIndex {
+	fs1 := AttributeFields(e1)
+	fs2 := AttributeFields(e2)
+	m := make(map[Attribute]fieldIndex, len(fMap))
+	for name, fm := range fMap {
+		m[name] = fieldIndex{fs1[fm.I], fs2[fm.J]}
+	}
+	return m
+}
@@ -0,0 +1,304 @@
+package rel
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// taggedPartTup has the same attributes as partTup, but it has different
+// field names and order, and a field which is not an attribute
+type taggedPartTup struct {
+	Number int    `json:"number" rel:"PNO"`
+	note   string `rel:"-"`
+	Name   string `rel:"PName"`
+	Color  string
+	Weight float64 `json:"weight"`
+	City   string
+}
+
+func taggedParts() Relation {
+	return New([]taggedPartTup{
+		{1, "a", "Nut", "Red", 12.0, "London"},
+		{2, "b", "Bolt", "Green", 17.0, "Paris"},
+		{3, "c", "Screw", "Blue", 17.0, "Oslo"},
+		{4, "d", "Screw", "Red", 14.0, "London"},
+		{5, "e", "Cam", "Blue", 12.0, "Paris"},
+		{6, "f", "Cog", "Red", 19.0, "London"},
+		{6, "g", "Cog", "Red", 19.0, "London"},
+	}, nil)
+}
+
+// tests for the attributes of tagged structs
+func TestFieldNames(t *testing.T) {
+	e := reflect.TypeOf(taggedPartTup{})
+	if names, want := FieldNames(e), Heading(parts()); !reflect.DeepEqual(names, want) {
+		t.Errorf("FieldNames() => %v, want %v", names, want)
+	}
+	if types, want := FieldTypes(e), FieldTypes(reflect.TypeOf(partTup{})); !reflect.DeepEqual(types, want) {
+		t.Errorf("FieldTypes() => %v, want %v", types, want)
+	}
+	if idx, want := AttributeFields(e), [][]int{{0}, {2}, {3}, {4}, {5}}; !reflect.DeepEqual(idx, want) {
+		t.Errorf("AttributeFields() => %v, want %v", idx, want)
+	}
+	want := map[Attribute]FieldIndex{
+		"PNO":    {0, 0},
+		"PName":  {1, 1},
+		"Color":  {2, 2},
+		"Weight": {3, 3},
+		"City":   {4, 4},
+	}
+	if fMap := FieldMap(e, reflect.TypeOf(partTup{})); !reflect.DeepEqual(fMap, want) {
+		t.Errorf("FieldMap() => %v, want %v", fMap, want)
+	}
+	wantPaths := map[Attribute]fieldIndex{
+		"PNO":    {[]int{0}, []int{0}},
+		"PName":  {[]int{2}, []int{1}},
+		"Color":  {[]int{3}, []int{2}},
+		"Weight": {[]int{4}, []int{3}},
+		"City":   {[]int{5}, []int{4}},
+	}
+	if fMap := fieldMap(e, reflect.TypeOf(partTup{})); !reflect.DeepEqual(fMap, wantPaths) {
+		t.Errorf("fieldMap() => %v, want %v", fMap, wantPaths)
+	}
+
+	// the exported tuple helpers take attribute positions
+	to := reflect.Indirect(reflect.New(e))
+	from := reflect.ValueOf(partTup{1, "Nut", "Red", 12.0, "London"})
+	CombineTuples2(&to, from, FieldMap(e, from.Type()))
+	if tup, want := to.Interface(), (taggedPartTup{1, "", "Nut", "Red", 12.0, "London"}); tup != want {
+		t.Errorf("CombineTuples2() => %v, want %v", tup, want)
+	}
+	if !PartialEquals(to, from, FieldMap(e, from.Type())) {
+		t.Errorf("PartialEquals() => false, want true")
+	}
+}
+
+// tests for relational operations on tuples with tagged fields
+func TestTaggedFields(t *testing.T) {
+	type pnoTup struct {
+		PNO int
+	}
+	type nameTup struct {
+		N     int  `rel:"PNO"`
+		Skip  bool `rel:"-"`
+		PName string
+	}
+	type joinTup struct {
+		PNO  int
+		SNO  int
+		Qty  int
+		Part string `rel:"PName"`
+	}
+	type renameTup struct {
+		A int `rel:"Part"`
+		B string
+		C string
+		D float64
+		E string `rel:"Town"`
+	}
+	type weightTup struct {
+		Town   string  `rel:"City"`
+		Weight float64 `rel:"TotalWeight"`
+	}
+	type nameOnlyTup struct {
+		PName string
+		Extra int `rel:"-"`
+	}
+	mapName := func(tup nameTup) nameOnlyTup {
+		return nameOnlyTup{tup.PName, tup.N}
+	}
+
+	var tagTests = []struct {
+		name         string
+		rel          Relation
+		expectString string
+		expectDeg    int
+		expectCard   int
+	}{
+		{"literal", taggedParts(), "Relation(PNO, PName, Color, Weight, City)", 5, 6},
+		{"restrict", taggedParts().Restrict(Attribute("PName").EQ("Screw")), "σ{PName == Screw}(Relation(PNO, PName, Color, Weight, City))", 5, 2},
+		{"restrict attributes", taggedParts().Restrict(Attribute("Color").LT(Attribute("City"))), "", 5, 3},
+		{"project", taggedParts().Project(nameTup{}), "π{PNO, PName}(Relation(PNO, PName, Color, Weight, City))", 2, 6},
+		{"project names", taggedParts().Project(pnoTup{}).Join(parts(), partTup{}), "", 5, 6},
+		{"join", taggedParts().Join(orders(), joinTup{}), "", 4, 12},
+		{"semijoin", taggedParts().SemiJoin(orders().Restrict(Attribute("Qty").GT(300))), "", 5, 3},
+		{"semidiff", taggedParts().SemiDiff(orders()), "", 5, 2},
+		{"rename", taggedParts().Rename(renameTup{}).Restrict(Attribute("Town").EQ("Paris")), "", 5, 2},
+		{"summarize", Summarize(taggedParts(), weightTup{}, Sum("Weight", "TotalWeight")), "", 2, 3},
+		{"map", taggedParts().Map(mapName, nil), "", 1, 5},
+		{"union", taggedParts().Union(taggedParts().Restrict(Attribute("PNO").GT(4))), "", 5, 6},
+		{"diff", taggedParts().Diff(taggedParts().Restrict(Attribute("PNO").GT(4))), "", 5, 4},
+	}
+	for _, tt := range tagTests {
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%s has Err() => %s", tt.name, err.Error())
+			continue
+		}
+		if tt.expectString != "" {
+			if str := tt.rel.String(); str != tt.expectString {
+				t.Errorf("%s has String() => %v, want %v", tt.name, str, tt.expectString)
+			}
+		}
+		if deg := Deg(tt.rel); deg != tt.expectDeg {
+			t.Errorf("%s has Deg() => %v, want %v", tt.name, deg, tt.expectDeg)
+		}
+		if card := Card(tt.rel); card != tt.expectCard {
+			t.Errorf("%s has Card() => %v, want %v", tt.name, card, tt.expectCard)
+		}
+	}
+
+	// the values of the attributes end up in the right fields
+	var res []weightTup
+	if err := Summarize(taggedParts(), weightTup{}, Sum("Weight", "TotalWeight")).Order("City").TupleSlice(&res); err != nil {
+		t.Fatal(err)
+	}
+	want := []weightTup{{"London", 45}, {"Oslo", 17}, {"Paris", 29}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Summarize() => %v, want %v", res, want)
+	}
+	var joined []joinTup
+	if err := taggedParts().Join(orders(), joinTup{}).Restrict(Attribute("SNO").EQ(4)).Order("PNO").TupleSlice(&joined); err != nil {
+		t.Fatal(err)
+	}
+	wantJoin := []joinTup{{1, 4, 200, "Nut"}, {4, 4, 300, "Screw"}}
+	if !reflect.DeepEqual(joined, wantJoin) {
+		t.Errorf("Join() => %v, want %v", joined, wantJoin)
+	}
+	var names []nameOnlyTup
+	if err := taggedParts().Map(mapName, nil).Order("PName").TupleSlice(&names); err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 5 || names[0] != (nameOnlyTup{PName: "Bolt"}) {
+		t.Errorf("Map() => %v, want excluded fields to be cleared", names)
+	}
+}
+
+// tests for the text representations of tuples with tagged fields
+func TestTaggedStrings(t *testing.T) {
+	r := taggedParts().Restrict(Attribute("PNO").EQ(1))
+	gs := r.GoString()
+	for _, want := range []string{"Number int `rel:\"PNO\"`", "Color string", "{1, \"Nut\", \"Red\", 12, \"London\", }"} {
+		if !strings.Contains(collapseSpace(gs), want) {
+			t.Errorf("GoString() => %v, want it to contain %v", gs, want)
+		}
+	}
+	if strings.Contains(gs, "note") {
+		t.Errorf("GoString() => %v, want it to leave out excluded fields", gs)
+	}
+	pp := collapseSpace(PrettyPrint(r))
+	for _, want := range []string{"| PNO | PName | Color | Weight | City |", "| 1 | Nut | Red | 12 | London |"} {
+		if !strings.Contains(pp, want) {
+			t.Errorf("PrettyPrint() => %v, want it to contain %v", pp, want)
+		}
+	}
+}
+
+// orderLineTup embeds a supplier, along with the part and quantity of an
+// order
+type orderLineTup struct {
+	supplierTup
+	PNO int
+	Qty int
+}
+
+func orderLines() Relation {
+	return New([]orderLineTup{
+		{supplierTup{1, "Smith", 20, "London"}, 1, 300},
+		{supplierTup{2, "Jones", 10, "Paris"}, 1, 200},
+		{supplierTup{2, "Jones", 10, "Paris"}, 2, 400},
+		{supplierTup{4, "Clark", 20, "London"}, 4, 300},
+	}, [][]string{
+		[]string{"SNO", "PNO"},
+	})
+}
+
+// tests for the attributes of structs with embedded structs
+func TestEmbeddedFields(t *testing.T) {
+	e := reflect.TypeOf(orderLineTup{})
+	want := []Attribute{"SNO", "SName", "Status", "City", "PNO", "Qty"}
+	if names := FieldNames(e); !reflect.DeepEqual(names, want) {
+		t.Errorf("FieldNames() => %v, want %v", names, want)
+	}
+	if idx, want := AttributeFields(e), [][]int{{0, 0}, {0, 1}, {0, 2}, {0, 3}, {1}, {2}}; !reflect.DeepEqual(idx, want) {
+		t.Errorf("AttributeFields() => %v, want %v", idx, want)
+	}
+
+	// a field hides the fields with the same name in embedded structs
+	type hidingTup struct {
+		supplierTup
+		City string `rel:"Town"`
+		SNO  int
+	}
+	want = []Attribute{"SName", "Status", "City", "Town", "SNO"}
+	if names := FieldNames(reflect.TypeOf(hidingTup{})); !reflect.DeepEqual(names, want) {
+		t.Errorf("FieldNames() => %v, want %v", names, want)
+	}
+	if idx := AttributeFields(reflect.TypeOf(hidingTup{})); !reflect.DeepEqual(idx[4], []int{2}) {
+		t.Errorf("AttributeFields() => %v, want SNO to be the outer field", idx)
+	}
+
+	// FieldMap has the positions of the attributes, while fieldMap has the
+	// index sequences of the fields that hold them
+	if fm := FieldMap(e, reflect.TypeOf(orderTup{})); !reflect.DeepEqual(fm["Qty"], FieldIndex{5, 2}) {
+		t.Errorf("FieldMap() => %v, want Qty at {5 2}", fm)
+	}
+	if fm := fieldMap(e, reflect.TypeOf(supplierTup{})); !reflect.DeepEqual(fm["City"], fieldIndex{[]int{0, 3}, []int{3}}) {
+		t.Errorf("fieldMap() => %v, want City at {[0 3] [3]}", fm)
+	}
+
+	type snoTup struct {
+		SNO int
+	}
+	type joinTup struct {
+		PNO int
+		SNO int
+		Qty int
+		orderLineTup
+	}
+	var embedTests = []struct {
+		name       string
+		rel        Relation
+		expectDeg  int
+		expectCard int
+	}{
+		{"literal", orderLines(), 6, 4},
+		{"restrict", orderLines().Restrict(Attribute("City").EQ("London")), 6, 2},
+		{"project", orderLines().Project(snoTup{}), 1, 3},
+		{"semidiff", orderLines().SemiDiff(orders().Restrict(Attribute("Qty").GT(200))), 6, 1},
+		{"join", orderLines().Join(orders(), joinTup{}), 6, 4},
+		{"semijoin", suppliers().SemiJoin(orderLines()), 4, 3},
+		{"order", orderLines().Order("SName", "PNO"), 6, 4},
+	}
+	for _, tt := range embedTests {
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%s has Err() => %s", tt.name, err.Error())
+			continue
+		}
+		if deg := Deg(tt.rel); deg != tt.expectDeg {
+			t.Errorf("%s has Deg() => %v, want %v", tt.name, deg, tt.expectDeg)
+		}
+		if card := Card(tt.rel); card != tt.expectCard {
+			t.Errorf("%s has Card() => %v, want %v", tt.name, card, tt.expectCard)
+		}
+	}
+
+	// the values of the embedded attributes end up in the right fields
+	var res []orderLineTup
+	if err := suppliers().Join(orders().Restrict(Attribute("PNO").EQ(4)), orderLineTup{}).Order("SNO").TupleSlice(&res); err != nil {
+		t.Fatal(err)
+	}
+	wantTups := []orderLineTup{
+		{supplierTup{2, "Jones", 10, "Paris"}, 4, 200},
+		{supplierTup{4, "Clark", 20, "London"}, 4, 300},
+		{supplierTup{5, "Adams", 30, "Athens"}, 4, 400},
+	}
+	if !reflect.DeepEqual(res, wantTups) {
+		t.Errorf("Join() => %v, want %v", res, wantTups)
+	}
+}
+
+// collapseSpace replaces each run of white space with a single space
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -1,85 +1,339 @@
+// chan_mem implements a relation which remembers the tuples of its source as
+// they are produced, so that they can be replayed any number of times.  This
+// is useful for sources that can only be consumed once, like channels, when
+// they are used more than once in a query, as in a self join.
+// h/t dominikh in #go-nuts on irc for the original design
+
 package rel
 
-/*  for future use in a relation Copy method
-import "sync"
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// memory holds the tuples that have been received from a source relation.
+// The source is only consumed when a reader asks for a tuple that has not
+// been received yet, and only one reader receives from the source at a time.
+type memory struct {
+	sync.Mutex
+
+	// the tuples received from the source so far
+	tups []reflect.Value
+
+	// body is the channel that the source sends tuples on, which is nil until
+	// the first reader needs a tuple
+	body reflect.Value
+
+	// fetching is true if a reader is currently receiving from the source
+	fetching bool
 
-// TODO(jonlawlor) incorporate this in functions such as join, union, and
-// setdiff which may require re-reading the data from a channel.
-// h/t dominikh in #go-nuts on irc
+	// more is closed (and replaced) whenever a tuple is added to the memory,
+	// or the source completes, to notify waiting readers
+	more chan struct{}
 
-type request struct {
-	i   int    // index of the element requested
-	got chan T // channel to respond on
+	// done is true once the source has completed
+	done bool
+
+	// err is the error from the source, once it has completed
+	err error
 }
 
-type Memory struct {
-	in  chan T
-	req chan request
-	sync.RWMutex
-	memory []T
+// replayExpr is a relation that sends the tuples of its source, which are
+// buffered on the first evaluation, so that it can be evaluated any number
+// of times, including concurrently.
+// This is one of the operations which consumes memory.
+type replayExpr struct {
+	// the input relation, which is only evaluated once
+	source1 Relation
+
+	// the tuples that have been received from the source so far
+	mem *memory
+
+	// err is the first error encountered during construction or evaluation,
+	// other than errors from the source, which are kept in mem.
+	err error
 }
 
-func Replay(in chan T) Memory {
-	m := Memory{in: in, req: make(chan request)}
-	go m.Feed()
-	return m
+// Replay creates a new relation which buffers the tuples of the input as
+// they are produced, and which can then be evaluated any number of times,
+// including concurrently, without evaluating the input again.  Each
+// evaluation can be cancelled independently of the others.  The input is
+// only consumed as far as the evaluations have requested, so cancelling all
+// of them leaves the input partially consumed, and it will resume the next
+// time the relation is evaluated.
+//
+// Because the input is only evaluated once, queries on the result are not
+// rewritten into queries on the input.
+func Replay(r1 Relation) Relation {
+	if r1.Err() != nil {
+		// don't bother building the relation and just return the original
+		return r1
+	}
+	return &replayExpr{source1: r1, mem: &memory{more: make(chan struct{})}}
 }
 
-func (m *Memory) Feed() {
-	for req := range m.req {
-		// req.i can at most be one higher than the current max index
-		if req.i <= len(m.memory)-1 {
-			req.got <- m.memory[req.i]
-			continue
+// next returns the tuple at index i, waiting for it to be received from the
+// source if needed.  The result is false if the source has completed before
+// sending that many tuples, or if cancel is closed while waiting.
+func (m *memory) next(i int, source Relation, cancel <-chan struct{}) (reflect.Value, bool) {
+	for {
+		m.Lock()
+		if i < len(m.tups) {
+			tup := m.tups[i]
+			m.Unlock()
+			return tup, true
+		}
+		if m.done {
+			m.Unlock()
+			return reflect.Value{}, false
+		}
+		if m.fetching {
+			// another reader is receiving from the source, so wait for it
+			more := m.more
+			m.Unlock()
+			select {
+			case <-more:
+				continue
+			case <-cancel:
+				return reflect.Value{}, false
+			}
 		}
-		v, ok := <-m.in
-		if !ok {
-			close(req.got)
-			continue
+		if !m.body.IsValid() {
+			m.body = reflect.MakeChan(reflect.ChanOf(reflect.BothDir, reflect.TypeOf(source.Zero())), 0)
+			source.TupleChan(m.body.Interface())
 		}
+		m.fetching = true
+		body := m.body
+		m.Unlock()
+
+		chosen, rtup, ok := reflect.Select([]reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)},
+			{Dir: reflect.SelectRecv, Chan: body},
+		})
+
 		m.Lock()
-		m.memory = append(m.memory, v)
+		m.fetching = false
+		if chosen == 0 {
+			// leave the source for another reader to continue, and wake up
+			// any readers that are waiting so that one of them takes over
+			close(m.more)
+			m.more = make(chan struct{})
+			m.Unlock()
+			return reflect.Value{}, false
+		}
+		if ok {
+			m.tups = append(m.tups, rtup)
+		} else {
+			m.done = true
+			m.err = source.Err()
+		}
+		close(m.more)
+		m.more = make(chan struct{})
 		m.Unlock()
-		req.got <- v
 	}
 }
 
-func (m *Memory) Copy(to chan T) {
-	got := make(chan T)
-	var i int
+// TupleChan sends each tuple in the relation to a channel
+func (r1 *replayExpr) TupleChan(t interface{}) chan<- struct{} {
+	cancel := make(chan struct{})
+	// reflect on the channel
+	chv := reflect.ValueOf(t)
+	err := EnsureChan(chv.Type(), r1.Zero())
+	if err != nil {
+		r1.err = err
+		return cancel
+	}
+	if r1.err != nil {
+		chv.Close()
+		return cancel
+	}
 
-	i = m.copyFromMem(i, to)
-	for {
-		// if a different copy gets ahead of this one and then blocks, it is
-		// possible that this request will block until the other request
-		// returns, even though this request may be asking for an element that
-		// is before the end of the memory.
-		// TODO(jonlawlor): fix this by repeating the copyFromMem function? We
-		// might have to return something more in the response.
-		m.req <- request{i, got}
-		resp, ok := <-got
-		if !ok {
-			close(to)
-			return
+	go func(res reflect.Value) {
+		// output channels
+		canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+		resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+		for i := 0; ; i++ {
+			tup, ok := r1.mem.next(i, r1.source1, cancel)
+			if !ok {
+				break
+			}
+			resSel.Send = tup
+			chosen, _, _ := reflect.Select([]reflect.SelectCase{canSel, resSel})
+			if chosen == 0 {
+				// cancellation is not relayed to the source, because other
+				// evaluations may still need it
+				return
+			}
 		}
-		to <- resp
-		i++
-	}
+		select {
+		case <-cancel:
+		default:
+			res.Close()
+		}
+	}(chv)
+	return cancel
 }
 
-func (m *Memory) copyFromMem(i int, to chan T) int {
-	// maybe this should be cancellable?
-	m.RLock()
-	if len(m.memory) == 0 {
-		m.RUnlock()
-		return i
-	}
-	mem := m.memory[i:]
-	m.RUnlock()
-	for _, v := range mem {
-		to <- v
-		i++
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *replayExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *replayExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *replayExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
+// Zero returns the zero value of the relation (a blank tuple)
+func (r1 *replayExpr) Zero() interface{} {
+	return r1.source1.Zero()
+}
+
+// CKeys is the set of candidate keys in the relation
+func (r1 *replayExpr) CKeys() CandKeys {
+	return r1.source1.CKeys()
+}
+
+// SortOrder is the set of attributes that the relation is sorted on.  The
+// tuples are replayed in the order they were received.
+func (r1 *replayExpr) SortOrder() []Attribute {
+	return SortOrder(r1.source1)
+}
+
+// GoString returns a text representation of the Relation
+func (r1 *replayExpr) GoString() string {
+	return r1.source1.GoString() + ".Replay()"
+}
+
+// String returns a text representation of the Relation
+func (r1 *replayExpr) String() string {
+	return r1.source1.String() + ".Replay()"
+}
+
+// Project creates a new relation with less than or equal degree
+// t2 has to be a new type which is a subdomain of r.
+func (r1 *replayExpr) Project(z2 interface{}) Relation {
+	return Rewrite(r1, NewProject(r1, z2))
+}
+
+// Restrict creates a new relation with less than or equal cardinality
+// p has to be a func(tup T) bool where tup is a subdomain of the input r.
+func (r1 *replayExpr) Restrict(p Predicate) Relation {
+	return Rewrite(r1, NewRestrict(r1, p))
+}
+
+// Rename creates a new relation with new column names
+// z2 has to be a struct with the same number of fields as the input relation
+func (r1 *replayExpr) Rename(z2 interface{}) Relation {
+	return Rewrite(r1, NewRename(r1, z2))
+}
+
+// Union creates a new relation by unioning the bodies of both inputs
+func (r1 *replayExpr) Union(r2 Relation) Relation {
+	return Rewrite(r1, NewUnion(r1, r2))
+}
+
+// Diff creates a new relation by set minusing the two inputs
+func (r1 *replayExpr) Diff(r2 Relation) Relation {
+	return Rewrite(r1, NewDiff(r1, r2))
+}
+
+// Join creates a new relation by performing a natural join on the inputs
+func (r1 *replayExpr) Join(r2 Relation, zero interface{}) Relation {
+	return Rewrite(r1, NewJoin(r1, r2, zero))
+}
+
+// GroupBy creates a new relation by grouping and applying a user defined func
+func (r1 *replayExpr) GroupBy(t2, gfcn interface{}) Relation {
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
+}
+
+// Map creates a new relation by applying a function to tuples in the source
+func (r1 *replayExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *replayExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *replayExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *replayExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *replayExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *replayExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *replayExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *replayExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *replayExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *replayExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *replayExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
+}
+
+// Err returns an error encountered during construction or computation
+func (r1 *replayExpr) Err() error {
+	if r1.err != nil {
+		return r1.err
 	}
-	return i
+	r1.mem.Lock()
+	defer r1.mem.Unlock()
+	return r1.mem.err
+}
+
+// Op is the operation that produces the relation
+func (r1 *replayExpr) Op() OpKind {
+	return OpReplay
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *replayExpr) Children() []Relation {
+	return []Relation{r1.source1}
 }
-*/
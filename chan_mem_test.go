@@ -0,0 +1,150 @@
+package rel
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// tests for replayed relations
+func TestReplay(t *testing.T) {
+	type fooTup struct {
+		Foo int
+	}
+	type joinTup struct {
+		Foo int
+		Bar string
+	}
+
+	r := Replay(New(exampleRelChan2(10), [][]string{[]string{"Foo"}}))
+	if str := r.String(); str != "Relation(Foo, Bar).Replay()" {
+		t.Errorf("Replay has String() => %q, want %q", str, "Relation(Foo, Bar).Replay()")
+	}
+
+	// the relation can be evaluated more than once, and in the same query
+	var replayTests = []struct {
+		rel  Relation
+		card int
+	}{
+		{r, 10},
+		{r, 10},
+		{r.Union(r.Restrict(Attribute("Foo").LT(5))), 10},
+		{r.Diff(r.Restrict(Attribute("Foo").LT(5))), 5},
+		{r.Join(r, joinTup{}), 10},
+		{r.Project(fooTup{}), 10},
+		{r.SemiJoin(r.Restrict(Attribute("Foo").GE(8))), 2},
+	}
+	for i, tt := range replayTests {
+		if c := Card(tt.rel); c != tt.card {
+			t.Errorf("%d %s has Card() => %d, want %d", i, tt.rel, c, tt.card)
+		}
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%d %s has Err() => %v", i, tt.rel, err)
+		}
+	}
+
+	// concurrent readers all get all of the tuples
+	r = Replay(New(exampleRelChan2(100), [][]string{[]string{"Foo"}}))
+	var wg sync.WaitGroup
+	cards := make([]int, 8)
+	for i := range cards {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cards[i] = Card(r)
+		}(i)
+	}
+	wg.Wait()
+	for i, c := range cards {
+		if c != 100 {
+			t.Errorf("reader %d has Card() => %d, want %d", i, c, 100)
+		}
+	}
+
+	// cancellation of one reader does not affect the others
+	r = Replay(New(exampleRelChan2(10), [][]string{[]string{"Foo"}}))
+	ch1 := make(chan exTup2)
+	cancel1 := r.TupleChan(ch1)
+	ch2 := make(chan exTup2)
+	_ = r.TupleChan(ch2)
+	<-ch1
+	close(cancel1)
+	n := 0
+	for _ = range ch2 {
+		n++
+	}
+	if n != 10 {
+		t.Errorf("Replay sent %d tuples after another reader was cancelled, want %d", n, 10)
+	}
+
+	// a reader that is cancelled before the source completes leaves the rest
+	// of the source for later readers
+	r = Replay(New(exampleRelChan2(10), [][]string{[]string{"Foo"}}))
+	ch3 := make(chan exTup2)
+	cancel3 := r.TupleChan(ch3)
+	<-ch3
+	<-ch3
+	close(cancel3)
+	if c := Card(r); c != 10 {
+		t.Errorf("Replay has Card() => %d after cancellation, want %d", c, 10)
+	}
+
+	// a reader that is cancelled while it is receiving from the source wakes
+	// up the readers that are waiting for it, so that one of them continues
+	src := make(chan exTup2)
+	r = Replay(New(src, [][]string{[]string{"Foo"}}))
+	mem := r.(*replayExpr).mem
+	ch4 := make(chan exTup2)
+	cancel4 := r.TupleChan(ch4)
+	for fetching := false; !fetching; {
+		mem.Lock()
+		fetching = mem.fetching
+		mem.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	ch5 := make(chan exTup2)
+	_ = r.TupleChan(ch5)
+	time.Sleep(10 * time.Millisecond)
+	close(cancel4)
+	go func() {
+		for _, tup := range exampleRelSlice2(10) {
+			src <- tup
+		}
+		close(src)
+	}()
+	n = 0
+	timeout := time.After(5 * time.Second)
+Recv:
+	for {
+		select {
+		case _, ok := <-ch5:
+			if !ok {
+				break Recv
+			}
+			n++
+		case <-timeout:
+			t.Fatalf("Replay blocked a waiting reader after the fetching reader was cancelled")
+		}
+	}
+	if n != 10 {
+		t.Errorf("Replay sent %d tuples after the fetching reader was cancelled, want %d", n, 10)
+	}
+
+	// errors from the source are retained
+	r = Replay(&errorRel{exTup2{}, 1, nil})
+	if c := Card(r); c != 1 {
+		t.Errorf("Replay has Card() => %d from an errorRel, want %d", c, 1)
+	}
+	if err := r.Err(); err == nil {
+		t.Errorf("Replay did not retain the error from its source")
+	}
+	if c := Card(r); c != 0 || r.Err() == nil {
+		t.Errorf("Replay has Card() => %d and Err() => %v after replay, want 0 and an error", c, r.Err())
+	}
+
+	// as are errors during construction
+	errRel := parts().Project(orderTup{})
+	if r = Replay(errRel); r != errRel {
+		t.Errorf("Replay did not short circuit a construction error")
+	}
+}
@@ -7,6 +7,7 @@
 package rel
 
 import (
+	"context"
 	"reflect"
 )
 
@@ -47,6 +48,8 @@ func (r1 *chanLiteral) TupleChan(t interface{}) chan<- struct{} {
 		chv.Close()
 		return cancel
 	}
+	// tuples which only differ in their excluded fields are the same tuple
+	excluded := hasExcludedFields(reflect.TypeOf(r1.zero))
 	if r1.sourceDistinct {
 		go func(rbody, res reflect.Value) {
 			// input channel
@@ -68,6 +71,9 @@ func (r1 *chanLiteral) TupleChan(t interface{}) chan<- struct{} {
 					// source channel was closed
 					break
 				}
+				if excluded {
+					tup = clearExcluded(tup)
+				}
 				resSel.Send = tup
 				outCases := []reflect.SelectCase{canSel, resSel}
 				chosen, _, _ = reflect.Select(outCases)
@@ -86,8 +92,10 @@ func (r1 *chanLiteral) TupleChan(t interface{}) chan<- struct{} {
 	// which can transform an input relation to be distinct.  On the other
 	// hand, that would produce an additional level of reflection and chan
 	// communication.
-	mem := map[interface{}]struct{}{}
 	go func(rbody, res reflect.Value) {
+		mem := newDistinctSet(rbody.Type().Elem())
+		defer mem.close()
+
 		sourceSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: rbody}
 		canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
 		inCases := []reflect.SelectCase{canSel, sourceSel}
@@ -104,23 +112,46 @@ func (r1 *chanLiteral) TupleChan(t interface{}) chan<- struct{} {
 				// source channel was closed
 				break
 			}
+			if excluded {
+				rtup = clearExcluded(rtup)
+			}
 
-			tup := interface{}(rtup.Interface())
-			if _, dup := mem[tup]; !dup {
+			if mem.add(rtup) {
 				resSel.Send = rtup
 				chosen, _, ok = reflect.Select([]reflect.SelectCase{canSel, resSel})
 				if chosen == 0 {
 					// cancel has been closed, so close the results
 					return
 				}
-				mem[tup] = struct{}{}
 			}
 		}
+		// send the tuples that were deferred if the memory budget was
+		// exceeded
+		if !mem.flush(func(tup reflect.Value) bool { return sendTuple(res, cancel, tup) }) && mem.err == nil {
+			return
+		}
+		r1.err = mem.err
 		res.Close()
 	}(r1.rbody, chv)
 	return cancel
 }
 
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *chanLiteral) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *chanLiteral) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *chanLiteral) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
 // Zero returns the zero value of the relation (a blank tuple)
 func (r1 *chanLiteral) Zero() interface{} {
 	return r1.zero
@@ -144,47 +175,116 @@ func (r1 *chanLiteral) String() string {
 // Project creates a new relation with less than or equal degree
 // t2 has to be a new type which is a subdomain of r.
 func (r1 *chanLiteral) Project(z2 interface{}) Relation {
-	return NewProject(r1, z2)
+	return Rewrite(r1, NewProject(r1, z2))
 }
 
 // Restrict creates a new relation with less than or equal cardinality
 // p has to be a func(tup T) bool where tup is a subdomain of the input r.
 func (r1 *chanLiteral) Restrict(p Predicate) Relation {
-	return NewRestrict(r1, p)
+	return Rewrite(r1, NewRestrict(r1, p))
 }
 
 // Rename creates a new relation with new column names
 // z2 has to be a struct with the same number of fields as the input relation
 func (r1 *chanLiteral) Rename(z2 interface{}) Relation {
-	return NewRename(r1, z2)
+	return Rewrite(r1, NewRename(r1, z2))
 }
 
 // Union creates a new relation by unioning the bodies of both inputs
 func (r1 *chanLiteral) Union(r2 Relation) Relation {
-	return NewUnion(r1, r2)
+	return Rewrite(r1, NewUnion(r1, r2))
 }
 
 // Diff creates a new relation by set minusing the two inputs
 func (r1 *chanLiteral) Diff(r2 Relation) Relation {
-	return NewDiff(r1, r2)
+	return Rewrite(r1, NewDiff(r1, r2))
 }
 
 // Join creates a new relation by performing a natural join on the inputs
 func (r1 *chanLiteral) Join(r2 Relation, zero interface{}) Relation {
-	return NewJoin(r1, r2, zero)
+	return Rewrite(r1, NewJoin(r1, r2, zero))
 }
 
 // GroupBy creates a new relation by grouping and applying a user defined func
 func (r1 *chanLiteral) GroupBy(t2, gfcn interface{}) Relation {
-	return NewGroupBy(r1, t2, gfcn)
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
 }
 
 // Map creates a new relation by applying a function to tuples in the source
 func (r1 *chanLiteral) Map(mfcn interface{}, ckeystr [][]string) Relation {
-	return NewMap(r1, mfcn, ckeystr)
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *chanLiteral) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *chanLiteral) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *chanLiteral) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *chanLiteral) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *chanLiteral) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *chanLiteral) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *chanLiteral) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *chanLiteral) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *chanLiteral) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *chanLiteral) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
 }
 
 // Err returns an error encountered during construction or computation
 func (r1 *chanLiteral) Err() error {
 	return r1.err
 }
+
+// Op is the operation that produces the relation
+func (r1 *chanLiteral) Op() OpKind {
+	return OpLiteral
+}
+
+// Children are the relations that the operation is performed on, which is
+// empty for literals
+func (r1 *chanLiteral) Children() []Relation {
+	return nil
+}
@@ -0,0 +1,540 @@
+// Package csv implements relations which read their tuples from comma
+// separated values, and a function to write relations as comma separated
+// values.
+//
+// The first record of the input is a header with the names of the columns,
+// which are matched to the attributes of the tuples by name.  Each of the
+// values is parsed according to the kind of the attribute, which can be a
+// string, bool, integer, or floating point number.  Columns which do not
+// correspond to an attribute are ignored.
+package csv
+
+import (
+	"context"
+	stdcsv "encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/jonlawlor/rel"
+)
+
+// ErrConsumed is the error that results from evaluating a relation a second
+// time when its input can't be read again.  The input can be read by any
+// number of evaluations at once if it is an io.ReaderAt, such as a
+// strings.Reader, a bytes.Reader, or an os.File.  If it is only an
+// io.Seeker, then it can be read more than once, but only by one evaluation
+// at a time, so self joins and other expressions which evaluate it
+// concurrently result in this error.  Otherwise, rel.Replay can be used to
+// remember the tuples.
+var ErrConsumed = errors.New("rel/csv: input has already been read")
+
+// ParseError represents an error that occurs when a value in the input can't
+// be parsed as the type of its attribute.  Rows and columns are numbered
+// from 1, and the header is row 1.  Rows count records rather than lines, so
+// they differ from the line numbers of encoding/csv when there are blank
+// lines or quoted newlines in the input.  Malformed input, such as a record
+// with the wrong number of fields, is reported as an *encoding/csv.ParseError
+// instead.
+type ParseError struct {
+	Row       int
+	Column    int
+	Attribute rel.Attribute
+	Err       error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("rel/csv: row %d, column %d (%s): %v", e.Row, e.Column, e.Attribute, e.Err)
+}
+
+// Unwrap returns the underlying parse error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// HeaderError represents an error that occurs when the header of the input
+// does not have a column for one of the attributes.
+type HeaderError struct {
+	Attribute rel.Attribute
+}
+
+func (e *HeaderError) Error() string {
+	return "rel/csv: no column for attribute '" + string(e.Attribute) + "'"
+}
+
+// csvRel is a relation with tuples read from comma separated values
+type csvRel struct {
+	// src is the input
+	src io.Reader
+
+	// set of candidate keys
+	cKeys rel.CandKeys
+
+	// the type of the tuples contained within the relation
+	zero interface{}
+
+	// sourceDistinct indicates if the input was already distinct or if a
+	// distinct has to be performed when sending tuples
+	sourceDistinct bool
+
+	// mu protects read, reading, and err, which are used by concurrent
+	// evaluations
+	mu sync.Mutex
+
+	// read is true once src has been read
+	read bool
+
+	// reading is true while an evaluation is reading a src which can't be
+	// shared
+	reading bool
+
+	// err holds the first error encountered during construction or
+	// evaluation.
+	err error
+}
+
+// New creates a new Relation with tuples of the same type as zero, which are
+// read from comma separated values.  See rel.New for a description of the
+// candidate keys.  If no candidate keys are provided, then duplicate records
+// are removed.
+//
+// If zero has attributes with kinds that can't be parsed, then the Err()
+// method of the resulting Relation will be non-nil.  Errors in the input are
+// reported by Err() after the relation has been evaluated.
+func New(r io.Reader, zero interface{}, ckeys [][]string) rel.Relation {
+	r1 := &csvRel{src: r, zero: zero}
+	if len(ckeys) == 0 {
+		r1.cKeys = rel.DefaultKeys(zero)
+	} else {
+		r1.cKeys = rel.String2CandKeys(ckeys)
+		r1.sourceDistinct = true
+	}
+	rel.OrderCandidateKeys(r1.cKeys)
+
+	e := reflect.TypeOf(zero)
+	names := rel.FieldNames(e)
+	for i, t := range rel.FieldTypes(e) {
+		if k := t.Kind(); !isParsable(k) {
+			r1.err = &rel.KindError{Attribute: names[i], Found: k}
+			break
+		}
+	}
+	return r1
+}
+
+// isParsable returns true if values of the kind can be parsed from strings
+func isParsable(k reflect.Kind) bool {
+	switch k {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// parseValue sets v to the value parsed from s
+func parseValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	}
+	return nil
+}
+
+// formatValue returns the string representation of v
+func formatValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// columns returns the position of the column in the header for each of the
+// attributes of the tuple type e
+func columns(header []string, e reflect.Type) ([]int, error) {
+	pos := make(map[string]int, len(header))
+	for j, name := range header {
+		pos[name] = j
+	}
+	names := rel.FieldNames(e)
+	idx := make([]int, len(names))
+	for i, name := range names {
+		j, ok := pos[string(name)]
+		if !ok {
+			return nil, &HeaderError{name}
+		}
+		idx[i] = j
+	}
+	return idx, nil
+}
+
+// Write writes the tuples of the relation to w as comma separated values,
+// with a header containing the attribute names.  It returns the first error
+// encountered while evaluating the relation or writing its tuples.
+func Write(w io.Writer, r rel.Relation) error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+	cw := stdcsv.NewWriter(w)
+	heading := rel.Heading(r)
+	rec := make([]string, len(heading))
+	for i, att := range heading {
+		rec[i] = string(att)
+	}
+	if err := cw.Write(rec); err != nil {
+		return err
+	}
+
+	fields := rel.AttributeFields(reflect.TypeOf(r.Zero()))
+	tups, errf := rel.Tuples(r)
+	var werr error
+	for tup := range tups {
+		rtup := reflect.ValueOf(tup)
+		for i, f := range fields {
+			rec[i] = formatValue(rtup.FieldByIndex(f))
+		}
+		if werr = cw.Write(rec); werr != nil {
+			break
+		}
+	}
+	cw.Flush()
+	if werr != nil {
+		return werr
+	}
+	if err := errf(); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// open returns a reader over the input from the beginning, and a func which
+// has to be called once it is no longer needed.  Each evaluation of an
+// io.ReaderAt input gets its own section reader, so they can read it at the
+// same time.  An io.Seeker input shares a single offset, so it is rewound,
+// and only one evaluation can read it at a time.  Other input can only be
+// read once.
+func (r1 *csvRel) open() (io.Reader, func(), error) {
+	if ra, ok := r1.src.(io.ReaderAt); ok {
+		return io.NewSectionReader(ra, 0, math.MaxInt64), func() {}, nil
+	}
+	r1.mu.Lock()
+	defer r1.mu.Unlock()
+	if r1.reading {
+		return nil, nil, ErrConsumed
+	}
+	if s, ok := r1.src.(io.Seeker); ok {
+		if _, err := s.Seek(0, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+	} else if r1.read {
+		return nil, nil, ErrConsumed
+	}
+	r1.read = true
+	r1.reading = true
+	return r1.src, func() {
+		r1.mu.Lock()
+		r1.reading = false
+		r1.mu.Unlock()
+	}, nil
+}
+
+// setErr records the first error encountered during evaluation
+func (r1 *csvRel) setErr(err error) {
+	r1.mu.Lock()
+	if r1.err == nil {
+		r1.err = err
+	}
+	r1.mu.Unlock()
+}
+
+// TupleChan sends each tuple in the relation to a channel
+func (r1 *csvRel) TupleChan(t interface{}) chan<- struct{} {
+	cancel := make(chan struct{})
+	// reflect on the channel
+	chv := reflect.ValueOf(t)
+	err := rel.EnsureChan(chv.Type(), r1.zero)
+	if err != nil {
+		r1.setErr(err)
+		return cancel
+	}
+	if r1.Err() != nil {
+		chv.Close()
+		return cancel
+	}
+	src, done, err := r1.open()
+	if err != nil {
+		r1.setErr(err)
+		chv.Close()
+		return cancel
+	}
+
+	go func(res reflect.Value) {
+		e := reflect.TypeOf(r1.zero)
+		names := rel.FieldNames(e)
+		fields := rel.AttributeFields(e)
+		cr := stdcsv.NewReader(src)
+		cr.ReuseRecord = true
+
+		header, err := cr.Read()
+		if err == io.EOF {
+			err = errors.New("rel/csv: missing header")
+		}
+		var idx []int
+		if err == nil {
+			idx, err = columns(header, e)
+		}
+		if err != nil {
+			done()
+			r1.setErr(err)
+			res.Close()
+			return
+		}
+
+		var mem map[interface{}]struct{}
+		if !r1.sourceDistinct {
+			mem = make(map[interface{}]struct{})
+		}
+
+		// output channels
+		canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+		resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+		var rerr error
+		row := 1
+		for {
+			rec, err := cr.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				rerr = err
+				break
+			}
+			row++
+			rtup := reflect.Indirect(reflect.New(e))
+			for i, j := range idx {
+				if err := parseValue(rtup.FieldByIndex(fields[i]), rec[j]); err != nil {
+					rerr = &ParseError{row, j + 1, names[i], err}
+					break
+				}
+			}
+			if rerr != nil {
+				break
+			}
+			if mem != nil {
+				if _, dup := mem[rtup.Interface()]; dup {
+					continue
+				}
+				mem[rtup.Interface()] = struct{}{}
+			}
+			resSel.Send = rtup
+			chosen, _, _ := reflect.Select([]reflect.SelectCase{canSel, resSel})
+			if chosen == 0 {
+				// cancel has been closed, so stop reading
+				done()
+				return
+			}
+		}
+		// the input is released before the channel is closed, so that it
+		// can be evaluated again as soon as this evaluation is complete
+		done()
+		if rerr != nil {
+			r1.setErr(rerr)
+		}
+		res.Close()
+	}(chv)
+	return cancel
+}
+
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *csvRel) TupleChanContext(ctx context.Context, t interface{}) error {
+	return rel.TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *csvRel) TupleSlice(t interface{}) error {
+	return rel.TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *csvRel) TupleMap(t interface{}) error {
+	return rel.TupleMap(r1, t)
+}
+
+// Zero returns the zero value of the relation (a blank tuple)
+func (r1 *csvRel) Zero() interface{} {
+	return r1.zero
+}
+
+// CKeys is the set of candidate keys in the relation
+func (r1 *csvRel) CKeys() rel.CandKeys {
+	return r1.cKeys
+}
+
+// GoString returns a text representation of the Relation
+func (r1 *csvRel) GoString() string {
+	return "csv.New(" + rel.HeadingString(r1) + ")"
+}
+
+// String returns a text representation of the Relation
+func (r1 *csvRel) String() string {
+	return "Relation(" + rel.HeadingString(r1) + ")"
+}
+
+// Project creates a new relation with less than or equal degree
+// t2 has to be a new type which is a subdomain of r.
+func (r1 *csvRel) Project(z2 interface{}) rel.Relation {
+	return rel.Rewrite(r1, rel.NewProject(r1, z2))
+}
+
+// Restrict creates a new relation with less than or equal cardinality
+// p has to be a func(tup T) bool where tup is a subdomain of the input r.
+func (r1 *csvRel) Restrict(p rel.Predicate) rel.Relation {
+	return rel.Rewrite(r1, rel.NewRestrict(r1, p))
+}
+
+// Rename creates a new relation with new column names
+// z2 has to be a struct with the same number of fields as the input relation
+func (r1 *csvRel) Rename(z2 interface{}) rel.Relation {
+	return rel.Rewrite(r1, rel.NewRename(r1, z2))
+}
+
+// Union creates a new relation by unioning the bodies of both inputs
+func (r1 *csvRel) Union(r2 rel.Relation) rel.Relation {
+	return rel.Rewrite(r1, rel.NewUnion(r1, r2))
+}
+
+// Diff creates a new relation by set minusing the two inputs
+func (r1 *csvRel) Diff(r2 rel.Relation) rel.Relation {
+	return rel.Rewrite(r1, rel.NewDiff(r1, r2))
+}
+
+// Join creates a new relation by performing a natural join on the inputs
+func (r1 *csvRel) Join(r2 rel.Relation, zero interface{}) rel.Relation {
+	return rel.Rewrite(r1, rel.NewJoin(r1, r2, zero))
+}
+
+// GroupBy creates a new relation by grouping and applying a user defined func
+func (r1 *csvRel) GroupBy(t2, gfcn interface{}) rel.Relation {
+	return rel.Rewrite(r1, rel.NewGroupBy(r1, t2, gfcn))
+}
+
+// Map creates a new relation by applying a function to tuples in the source
+func (r1 *csvRel) Map(mfcn interface{}, ckeystr [][]string) rel.Relation {
+	return rel.Rewrite(r1, rel.NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *csvRel) Extend(z2, efcn interface{}) rel.Relation {
+	return rel.Rewrite(r1, rel.NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *csvRel) Order(att ...rel.Attribute) rel.Relation {
+	return rel.Rewrite(r1, rel.NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *csvRel) SemiJoin(r2 rel.Relation) rel.Relation {
+	return rel.Rewrite(r1, rel.NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *csvRel) SemiDiff(r2 rel.Relation) rel.Relation {
+	return rel.Rewrite(r1, rel.NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *csvRel) Wrap(z2 interface{}, name rel.Attribute) rel.Relation {
+	return rel.Rewrite(r1, rel.NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *csvRel) Unwrap(name rel.Attribute) rel.Relation {
+	return rel.Rewrite(r1, rel.NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *csvRel) Group(z2 interface{}, name rel.Attribute) rel.Relation {
+	return rel.Rewrite(r1, rel.NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *csvRel) Ungroup(name rel.Attribute) rel.Relation {
+	return rel.Rewrite(r1, rel.NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *csvRel) Divide(r2, per rel.Relation) rel.Relation {
+	return rel.Rewrite(r1, rel.NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *csvRel) TClose() rel.Relation {
+	return rel.Rewrite(r1, rel.NewTClose(r1))
+}
+
+// Err returns an error encountered during construction or computation
+func (r1 *csvRel) Err() error {
+	r1.mu.Lock()
+	defer r1.mu.Unlock()
+	return r1.err
+}
+
+// Op is the operation that produces the relation, which is a literal
+func (r1 *csvRel) Op() rel.OpKind {
+	return rel.OpLiteral
+}
+
+// Children are the relations that the operation is performed on, which is
+// empty for literals
+func (r1 *csvRel) Children() []rel.Relation {
+	return nil
+}
@@ -0,0 +1,304 @@
+package csv
+
+import (
+	"bytes"
+	stdcsv "encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jonlawlor/rel"
+)
+
+type partTup struct {
+	PNO    int
+	PName  string
+	Color  string
+	Weight float64
+	City   string
+}
+
+const partsCSV = `PNO,PName,Color,Weight,City
+1,Nut,Red,12,London
+2,Bolt,Green,17,Paris
+3,Screw,Blue,17,Oslo
+4,Screw,Red,14,London
+5,Cam,Blue,12,Paris
+6,Cog,Red,19,London
+`
+
+// onlyReader hides any methods of the underlying reader other than Read
+type onlyReader struct {
+	io.Reader
+}
+
+// seekReader hides any methods of the underlying reader other than Read and
+// Seek
+type seekReader struct {
+	io.ReadSeeker
+}
+
+func TestNew(t *testing.T) {
+	type colorTup struct {
+		Color string
+	}
+	type taggedTup struct {
+		Number int    `rel:"PNO"`
+		Note   string `rel:"-"`
+		Name   string `rel:"PName"`
+	}
+	type flagTup struct {
+		Name  string
+		On    bool
+		Small int8
+		Count uint16
+		Ratio float32
+	}
+	var newTests = []struct {
+		in   string
+		zero interface{}
+		card int
+	}{
+		{partsCSV, partTup{}, 6},
+		// columns are matched by name, and extra columns are ignored
+		{"Extra,Color\nx,Red\ny,Blue\n", colorTup{}, 2},
+		// duplicate records are removed without candidate keys
+		{"Color\nRed\nRed\nBlue\n", colorTup{}, 2},
+		// quoted fields
+		{"Color\n\"Red, really\"\nBlue\n", colorTup{}, 2},
+		{"Ratio,Count,Small,On,Name\n0.5,65535,-128,true,a\n1e3,0,127,F,b\n", flagTup{}, 2},
+		{"Color\n", colorTup{}, 0},
+		// columns are matched by the names in rel tags
+		{partsCSV, taggedTup{}, 6},
+	}
+	for i, tt := range newTests {
+		r := New(strings.NewReader(tt.in), tt.zero, nil)
+		if c := rel.Card(r); c != tt.card {
+			t.Errorf("%d Card() => %d, want %d", i, c, tt.card)
+		}
+		if err := r.Err(); err != nil {
+			t.Errorf("%d has error %v", i, err)
+		}
+	}
+
+	// values are parsed by kind
+	var tups []partTup
+	r := New(strings.NewReader(partsCSV), partTup{}, [][]string{{"PNO"}})
+	if err := r.TupleSlice(&tups); err != nil {
+		t.Fatal(err)
+	}
+	if want := (partTup{4, "Screw", "Red", 14, "London"}); tups[3] != want {
+		t.Errorf("TupleSlice() => %v, want %v", tups[3], want)
+	}
+	if s := r.String(); s != "Relation(PNO, PName, Color, Weight, City)" {
+		t.Errorf("String() => %q", s)
+	}
+	if ck := r.CKeys(); !reflect.DeepEqual(ck, rel.CandKeys{{"PNO"}}) {
+		t.Errorf("CKeys() => %v, want %v", ck, rel.CandKeys{{"PNO"}})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	r := New(strings.NewReader(partsCSV), partTup{}, [][]string{{"PNO"}})
+	if c := rel.Card(r.Restrict(rel.Attribute("Color").EQ("Red"))); c != 3 {
+		t.Errorf("Restrict() has card %d, want %d", c, 3)
+	}
+
+	// seekable input can be evaluated any number of times
+	type cityTup struct {
+		City string
+	}
+	if c := rel.Card(r.Project(cityTup{})); c != 3 {
+		t.Errorf("Project() has card %d, want %d", c, 3)
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("re-evaluation has error %v", err)
+	}
+
+	// other input can only be evaluated once
+	r = New(onlyReader{strings.NewReader(partsCSV)}, partTup{}, [][]string{{"PNO"}})
+	if c := rel.Card(r); c != 6 {
+		t.Errorf("Card() => %d, want %d", c, 6)
+	}
+	rel.Card(r)
+	if err := r.Err(); err != ErrConsumed {
+		t.Errorf("second evaluation => %v, want %v", err, ErrConsumed)
+	}
+
+	// unless it is replayed
+	r = rel.Replay(New(onlyReader{strings.NewReader(partsCSV)}, partTup{}, [][]string{{"PNO"}}))
+	rel.Card(r)
+	if c := rel.Card(r); c != 6 || r.Err() != nil {
+		t.Errorf("replayed Card() => %d and %v, want %d and nil", c, r.Err(), 6)
+	}
+
+	// input which is only an io.Seeker can be evaluated again, but not
+	// while another evaluation is reading it
+	r = New(seekReader{strings.NewReader(partsCSV)}, partTup{}, [][]string{{"PNO"}})
+	rel.Card(r)
+	if c := rel.Card(r); c != 6 || r.Err() != nil {
+		t.Errorf("seeker Card() => %d and %v, want %d and nil", c, r.Err(), 6)
+	}
+	rel.Card(r.Union(r.Restrict(rel.Attribute("Color").EQ("Red"))))
+	if err := r.Err(); err != ErrConsumed {
+		t.Errorf("concurrent evaluation => %v, want %v", err, ErrConsumed)
+	}
+}
+
+// tests for expressions which evaluate the same input more than once at the
+// same time
+func TestSelfCombination(t *testing.T) {
+	type abTup struct {
+		A int
+		B int
+	}
+	var sb strings.Builder
+	sb.WriteString("A,B\n")
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&sb, "%d,%d\n", i, 2*i)
+	}
+	r := New(strings.NewReader(sb.String()), abTup{}, [][]string{{"A"}})
+	var selfTest = []struct {
+		rel  rel.Relation
+		card int
+	}{
+		{r.Union(r.Restrict(rel.Attribute("A").LT(1000))), 2000},
+		{r.Join(r.Restrict(rel.Attribute("B").LT(1000)), abTup{}), 500},
+		{r.Diff(r.Restrict(rel.Attribute("A").LT(1000))), 1000},
+	}
+	for i, tt := range selfTest {
+		if c := rel.Card(tt.rel); c != tt.card {
+			t.Errorf("%d %s has Card() => %d, want %d", i, tt.rel, c, tt.card)
+		}
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%d %s has Err() => %v", i, tt.rel, err)
+		}
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("self combination has Err() => %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	type funcTup struct {
+		Foo func()
+	}
+	r := New(strings.NewReader(partsCSV), funcTup{}, nil)
+	if err, ok := r.Err().(*rel.KindError); !ok || err.Attribute != "Foo" {
+		t.Errorf("unsupported kind => %v, want a KindError", r.Err())
+	}
+
+	var parseTests = []struct {
+		in  string
+		row int
+		col int
+		att rel.Attribute
+	}{
+		{"PNO,PName,Color,Weight,City\n1,Nut,Red,12,London\nx,Bolt,Green,17,Paris\n", 3, 1, "PNO"},
+		{"City,Weight,Color,PName,PNO\nLondon,heavy,Red,Nut,1\n", 2, 2, "Weight"},
+		{"PNO,PName,Color,Weight,City\n1,Nut,Red,12,London\n\n\n2,Bolt,Green,1e400,Paris\n", 3, 4, "Weight"},
+		{"PNO,PName,Color,Weight,City\n1,\"Nut\nBolt\",Red,12,London\n2,Bolt,Green,x,Paris\n", 3, 4, "Weight"},
+	}
+	for i, tt := range parseTests {
+		r := New(strings.NewReader(tt.in), partTup{}, nil)
+		rel.Card(r)
+		var err *ParseError
+		if !errors.As(r.Err(), &err) {
+			t.Errorf("%d Err() => %v, want a ParseError", i, r.Err())
+			continue
+		}
+		if err.Row != tt.row || err.Column != tt.col || err.Attribute != tt.att {
+			t.Errorf("%d Err() => %v, want row %d, column %d (%s)", i, err, tt.row, tt.col, tt.att)
+		}
+	}
+
+	// malformed input
+	r = New(strings.NewReader("PNO,PName,Color,Weight,City\n1,Nut\n"), partTup{}, nil)
+	rel.Card(r)
+	if _, ok := r.Err().(*stdcsv.ParseError); !ok {
+		t.Errorf("malformed input => %v, want a csv.ParseError", r.Err())
+	}
+
+	// missing column
+	r = New(strings.NewReader("PNO,PName\n1,Nut\n"), partTup{}, nil)
+	rel.Card(r)
+	if err, ok := r.Err().(*HeaderError); !ok || err.Attribute != "Color" {
+		t.Errorf("missing column => %v, want a HeaderError", r.Err())
+	}
+}
+
+func TestWrite(t *testing.T) {
+	r := New(strings.NewReader(partsCSV), partTup{}, [][]string{{"PNO"}})
+	var buf bytes.Buffer
+	if err := Write(&buf, r.Order("PNO")); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != partsCSV {
+		t.Errorf("Write() => %q, want %q", s, partsCSV)
+	}
+
+	// round trip with quoting and all of the parsable kinds
+	type allTup struct {
+		S   string
+		B   bool
+		I   int
+		I64 int64
+		U8  uint8
+		F32 float32
+		F64 float64
+	}
+	tups := []allTup{
+		{"a, \"b\"", true, -1, 1 << 40, 255, 0.1, 1.0 / 3},
+		{"line\nbreak", false, 0, 0, 0, 0, 0},
+	}
+	buf.Reset()
+	if err := Write(&buf, rel.New(tups, nil)); err != nil {
+		t.Fatal(err)
+	}
+	var res []allTup
+	if err := New(&buf, allTup{}, nil).TupleSlice(&res); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[allTup]struct{})
+	for _, tup := range res {
+		got[tup] = struct{}{}
+	}
+	for _, tup := range tups {
+		if _, ok := got[tup]; !ok || len(got) != len(tups) {
+			t.Errorf("round trip => %v, want %v", res, tups)
+			break
+		}
+	}
+
+	// the attribute names from rel tags are used in the header
+	type taggedTup struct {
+		Name   string `rel:"PName"`
+		Note   string `rel:"-"`
+		Number int    `rel:"PNO"`
+	}
+	buf.Reset()
+	if err := Write(&buf, rel.New([]taggedTup{{"Nut", "ignored", 1}}, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if s, want := buf.String(), "PName,PNO\nNut,1\n"; s != want {
+		t.Errorf("Write() => %q, want %q", s, want)
+	}
+
+	// errors in the relation are returned
+	type orderTup struct {
+		PNO int
+		SNO int
+		Qty int
+	}
+	buf.Reset()
+	if err := Write(&buf, r.Project(orderTup{})); err == nil {
+		t.Errorf("Write() of an invalid relation did not return an error")
+	}
+	r = New(strings.NewReader("PNO,PName,Color,Weight,City\nx,Nut,Red,12,London\n"), partTup{}, nil)
+	if err := Write(&buf, r); err == nil {
+		t.Errorf("Write() of an invalid input did not return an error")
+	}
+}
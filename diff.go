@@ -3,6 +3,7 @@
 package rel
 
 import (
+	"context"
 	"reflect"
 )
 
@@ -30,7 +31,6 @@ func (r1 *diffExpr) TupleChan(t interface{}) chan<- struct{} {
 		return cancel
 	}
 
-	mem := make(map[interface{}]struct{})
 	// TODO(jonlawlor): we could pull one value from the first relation, and then
 	// discard it if we recieve a match from the second.  Then we would have to
 	// go back through previously recieved values after receiving the from the
@@ -39,6 +39,7 @@ func (r1 *diffExpr) TupleChan(t interface{}) chan<- struct{} {
 	// tuples in both sides should have the same type, checked during
 	// construction
 	e := reflect.TypeOf(r1.source1.Zero())
+	mem := newDiffSet(e)
 
 	// create channels over the body of the source relations
 	body1 := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e), 0)
@@ -65,7 +66,7 @@ func (r1 *diffExpr) TupleChan(t interface{}) chan<- struct{} {
 				// cancel channel was closed
 				break
 			}
-			mem[tup.Interface()] = struct{}{}
+			mem.remove(tup)
 		}
 
 		inCases[1] = source1Sel
@@ -75,7 +76,7 @@ func (r1 *diffExpr) TupleChan(t interface{}) chan<- struct{} {
 				// cancel channel was closed
 				break
 			}
-			if _, rem := mem[tup.Interface()]; !rem {
+			if mem.keep(tup) {
 				resSel.Send = tup
 				chosen, _, ok = reflect.Select([]reflect.SelectCase{canSel, resSel})
 				if chosen == 0 {
@@ -85,13 +86,21 @@ func (r1 *diffExpr) TupleChan(t interface{}) chan<- struct{} {
 		}
 		select {
 		case <-cancel:
+			mem.close()
 			close(bcancel1)
 			close(bcancel2)
 		default:
+			// send the tuples that were deferred if the memory budget was
+			// exceeded
+			if !mem.flush(func(tup reflect.Value) bool { return sendTuple(res, cancel, tup) }) && mem.err == nil {
+				return
+			}
 			if err := r1.source1.Err(); err != nil {
 				r1.err = err
 			} else if err := r1.source2.Err(); err != nil {
 				r1.err = err
+			} else if mem.err != nil {
+				r1.err = mem.err
 			}
 			res.Close()
 		}
@@ -99,6 +108,22 @@ func (r1 *diffExpr) TupleChan(t interface{}) chan<- struct{} {
 	return cancel
 }
 
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *diffExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *diffExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *diffExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
 // Zero returns the zero value of the relation (a blank tuple)
 func (r1 *diffExpr) Zero() interface{} {
 	return r1.source1.Zero()
@@ -109,6 +134,17 @@ func (r1 *diffExpr) CKeys() CandKeys {
 	return r1.source1.CKeys()
 }
 
+// SortOrder is the set of attributes that the relation is sorted on.  The
+// tuples from the first source are sent in the order they are received,
+// unless a memory budget is in effect, in which case the tuples that were
+// spilled to disk are sent after the rest.
+func (r1 *diffExpr) SortOrder() []Attribute {
+	if tupleBudget(reflect.TypeOf(r1.source1.Zero())) > 0 {
+		return nil
+	}
+	return SortOrder(r1.source1)
+}
+
 // GoString returns a text representation of the Relation
 func (r1 *diffExpr) GoString() string {
 	return r1.source1.GoString() + ".Diff(" + r1.source2.GoString() + ")"
@@ -122,49 +158,117 @@ func (r1 *diffExpr) String() string {
 // Project creates a new relation with less than or equal degree
 // t2 has to be a new type which is a subdomain of r.
 func (r1 *diffExpr) Project(z2 interface{}) Relation {
-	return NewProject(r1, z2)
+	return Rewrite(r1, NewProject(r1, z2))
 }
 
 // Restrict creates a new relation with less than or equal cardinality
 // p has to be a func(tup T) bool where tup is a subdomain of the input r.
 // Restrict can be distributed through diff.
 func (r1 *diffExpr) Restrict(p Predicate) Relation {
-	return NewDiff(r1.source1.Restrict(p), r1.source2.Restrict(p))
+	return Rewrite(r1, NewRestrict(r1, p))
 }
 
 // Rename creates a new relation with new column names
 // z2 has to be a struct with the same number of fields as the input relation
 // Rename can be distributed through setdiff
 func (r1 *diffExpr) Rename(z2 interface{}) Relation {
-	return NewDiff(r1.source1.Rename(z2), r1.source2.Rename(z2))
+	return Rewrite(r1, NewRename(r1, z2))
 }
 
 // Union creates a new relation by unioning the bodies of both inputs
 func (r1 *diffExpr) Union(r2 Relation) Relation {
-	return NewUnion(r1, r2)
+	return Rewrite(r1, NewUnion(r1, r2))
 }
 
 // Diff creates a new relation by set minusing the two inputs
 func (r1 *diffExpr) Diff(r2 Relation) Relation {
-	return NewDiff(r1, r2)
+	return Rewrite(r1, NewDiff(r1, r2))
 }
 
 // Join creates a new relation by performing a natural join on the inputs
 func (r1 *diffExpr) Join(r2 Relation, zero interface{}) Relation {
-	return NewJoin(r1, r2, zero)
+	return Rewrite(r1, NewJoin(r1, r2, zero))
 }
 
 // GroupBy creates a new relation by grouping and applying a user defined func
 func (r1 *diffExpr) GroupBy(t2, gfcn interface{}) Relation {
-	return NewGroupBy(r1, t2, gfcn)
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
 }
 
 // Map creates a new relation by applying a function to tuples in the source
 func (r1 *diffExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
-	return NewMap(r1, mfcn, ckeystr)
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *diffExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *diffExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *diffExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *diffExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *diffExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *diffExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *diffExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *diffExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *diffExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *diffExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
 }
 
 // Err returns an error encountered during construction or computation
 func (r1 *diffExpr) Err() error {
 	return r1.err
 }
+
+// Op is the operation that produces the relation
+func (r1 *diffExpr) Op() OpKind {
+	return OpDiff
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *diffExpr) Children() []Relation {
+	return []Relation{r1.source1, r1.source2}
+}
@@ -0,0 +1,311 @@
+// divide implements a divide expression in relational algebra, which is
+// called DIVIDEBY by Date.
+
+package rel
+
+import (
+	"context"
+	"reflect"
+)
+
+// divideExpr represents the tuples in source1 which are related by per to
+// every tuple in source2.  This is Date's Small Divide: for a dividend with
+// attributes A, a divisor with attributes B, and a per relation with the
+// attributes of both, the result is the tuples of the dividend which appear
+// in per together with each tuple of the divisor.  It answers questions like
+// "which suppliers supply all of the parts?"  It is equivalent to
+//
+//	source1.Diff(source1.Join(source2, AB{}).Diff(per.Project(AB{})).Project(A{}))
+//
+// but it does not have to construct the joined tuples.  If the divisor is
+// empty, then every tuple of the dividend is in the result.
+// This is one of the operations which consumes memory.  In addition, no values
+// can be sent before all values from the divisor and per are consumed.
+type divideExpr struct {
+	// source1 is the dividend
+	source1 Relation
+
+	// source2 is the divisor
+	source2 Relation
+
+	// per relates the tuples of the dividend to the tuples of the divisor
+	per Relation
+
+	// err is the first error encountered during construction or evaluation.
+	err error
+}
+
+// TupleChan sends each tuple in the relation to a channel
+func (r1 *divideExpr) TupleChan(t interface{}) chan<- struct{} {
+	cancel := make(chan struct{})
+	// reflect on the channel
+	chv := reflect.ValueOf(t)
+	err := EnsureChan(chv.Type(), r1.Zero())
+	if err != nil {
+		r1.err = err
+		return cancel
+	}
+	if r1.err != nil {
+		chv.Close()
+		return cancel
+	}
+
+	// the attributes of per which hold the dividend and the divisor
+	e1 := reflect.TypeOf(r1.source1.Zero())
+	e2 := reflect.TypeOf(r1.source2.Zero())
+	e3 := reflect.TypeOf(r1.per.Zero())
+	idx1, idx31, keyType1 := joinIndex(fieldMap(e1, e3))
+	idx2, idx32, keyType2 := joinIndex(fieldMap(e2, e3))
+
+	// create channels over the body of the source relations
+	body1 := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e1), 0)
+	bcancel1 := r1.source1.TupleChan(body1.Interface())
+	body2 := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e2), 0)
+	bcancel2 := r1.source2.TupleChan(body2.Interface())
+	body3 := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e3), 0)
+	bcancel3 := r1.per.TupleChan(body3.Interface())
+
+	go func(b1, b2, b3, res reflect.Value) {
+		canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+
+		// cancelled relays cancellation to the sources
+		cancelled := func() {
+			close(bcancel1)
+			close(bcancel2)
+			close(bcancel3)
+		}
+
+		// first pull all of the values from the divisor
+		divisor := make(map[interface{}]struct{})
+		inCases := []reflect.SelectCase{canSel, {Dir: reflect.SelectRecv, Chan: b2}}
+		for {
+			chosen, rtup, ok := reflect.Select(inCases)
+			if chosen == 0 {
+				cancelled()
+				return
+			}
+			if !ok {
+				break
+			}
+			divisor[joinKey(rtup, idx2, keyType2)] = struct{}{}
+		}
+
+		// then find the divisor tuples that each dividend tuple is related
+		// to.  per can have more attributes than the dividend and divisor,
+		// so the same pair can occur more than once.
+		related := make(map[interface{}]map[interface{}]struct{})
+		inCases = []reflect.SelectCase{canSel, {Dir: reflect.SelectRecv, Chan: b3}}
+		for {
+			chosen, rtup, ok := reflect.Select(inCases)
+			if chosen == 0 {
+				cancelled()
+				return
+			}
+			if !ok {
+				break
+			}
+			k2 := joinKey(rtup, idx32, keyType2)
+			if _, ok := divisor[k2]; !ok {
+				continue
+			}
+			k1 := joinKey(rtup, idx31, keyType1)
+			if related[k1] == nil {
+				related[k1] = make(map[interface{}]struct{})
+			}
+			related[k1][k2] = struct{}{}
+		}
+
+		// the dividend tuples which are related to all of the divisor
+		// tuples are in the result.  They are sent in the order they are
+		// received.
+		inCases = []reflect.SelectCase{canSel, {Dir: reflect.SelectRecv, Chan: b1}}
+		resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+		for {
+			chosen, rtup, ok := reflect.Select(inCases)
+			if chosen == 0 {
+				cancelled()
+				return
+			}
+			if !ok {
+				break
+			}
+			if len(related[joinKey(rtup, idx1, keyType1)]) != len(divisor) {
+				continue
+			}
+			resSel.Send = rtup
+			chosen, _, _ = reflect.Select([]reflect.SelectCase{canSel, resSel})
+			if chosen == 0 {
+				cancelled()
+				return
+			}
+		}
+		for _, r := range []Relation{r1.source1, r1.source2, r1.per} {
+			if err := r.Err(); err != nil {
+				r1.err = err
+				break
+			}
+		}
+		res.Close()
+	}(body1, body2, body3, chv)
+	return cancel
+}
+
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *divideExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *divideExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *divideExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
+// Zero returns the zero value of the relation (a blank tuple)
+func (r1 *divideExpr) Zero() interface{} {
+	return r1.source1.Zero()
+}
+
+// CKeys is the set of candidate keys in the relation.  The result is a
+// subset of the dividend, so it has the same keys, regardless of the keys of
+// the divisor and per.
+func (r1 *divideExpr) CKeys() CandKeys {
+	return r1.source1.CKeys()
+}
+
+// SortOrder is the set of attributes that the relation is sorted on.  The
+// tuples from the dividend are sent in the order they are received.
+func (r1 *divideExpr) SortOrder() []Attribute {
+	return SortOrder(r1.source1)
+}
+
+// GoString returns a text representation of the Relation
+func (r1 *divideExpr) GoString() string {
+	return r1.source1.GoString() + ".Divide(" + r1.source2.GoString() + ", " + r1.per.GoString() + ")"
+}
+
+// String returns a text representation of the Relation
+func (r1 *divideExpr) String() string {
+	return r1.source1.String() + " ÷ " + r1.source2.String() + " per (" + r1.per.String() + ")"
+}
+
+// Project creates a new relation with less than or equal degree
+// t2 has to be a new type which is a subdomain of r.
+func (r1 *divideExpr) Project(z2 interface{}) Relation {
+	return Rewrite(r1, NewProject(r1, z2))
+}
+
+// Restrict creates a new relation with less than or equal cardinality
+// p has to be a func(tup T) bool where tup is a subdomain of the input r.
+func (r1 *divideExpr) Restrict(p Predicate) Relation {
+	return Rewrite(r1, NewRestrict(r1, p))
+}
+
+// Rename creates a new relation with new column names
+// z2 has to be a struct with the same number of fields as the input relation
+func (r1 *divideExpr) Rename(z2 interface{}) Relation {
+	return Rewrite(r1, NewRename(r1, z2))
+}
+
+// Union creates a new relation by unioning the bodies of both inputs
+func (r1 *divideExpr) Union(r2 Relation) Relation {
+	return Rewrite(r1, NewUnion(r1, r2))
+}
+
+// Diff creates a new relation by set minusing the two inputs
+func (r1 *divideExpr) Diff(r2 Relation) Relation {
+	return Rewrite(r1, NewDiff(r1, r2))
+}
+
+// Join creates a new relation by performing a natural join on the inputs
+func (r1 *divideExpr) Join(r2 Relation, zero interface{}) Relation {
+	return Rewrite(r1, NewJoin(r1, r2, zero))
+}
+
+// GroupBy creates a new relation by grouping and applying a user defined func
+func (r1 *divideExpr) GroupBy(t2, gfcn interface{}) Relation {
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
+}
+
+// Map creates a new relation by applying a function to tuples in the source
+func (r1 *divideExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *divideExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *divideExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *divideExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *divideExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *divideExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *divideExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *divideExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *divideExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *divideExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *divideExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
+}
+
+// Err returns an error encountered during construction or computation
+func (r1 *divideExpr) Err() error {
+	return r1.err
+}
+
+// Op is the operation that produces the relation
+func (r1 *divideExpr) Op() OpKind {
+	return OpDivide
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *divideExpr) Children() []Relation {
+	return []Relation{r1.source1, r1.source2, r1.per}
+}
@@ -0,0 +1,172 @@
+package rel
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// tests for divide
+func TestDivide(t *testing.T) {
+	type snoTup struct {
+		SNO int
+	}
+	type pnoTup struct {
+		PNO int
+	}
+	type pnoSnoTup struct {
+		PNO int
+		SNO int
+	}
+	sno := func() Relation {
+		return suppliers().Project(snoTup{})
+	}
+	pno := func() Relation {
+		return parts().Project(pnoTup{})
+	}
+
+	// suppliers who supply all parts
+	rel := sno().Divide(pno(), orders())
+
+	// divide is equivalent to a combination of diff, join and project
+	divide := func(r1, r2, per Relation) Relation {
+		return r1.Diff(r1.Join(r2, pnoSnoTup{}).Diff(per.Project(pnoSnoTup{})).Project(snoTup{}))
+	}
+
+	// test the degrees, cardinality, and string representation
+	var relTest = []struct {
+		rel          Relation
+		expectString string
+		expectDeg    int
+		expectCard   int
+	}{
+		{rel, "π{SNO}(Relation(SNO, SName, Status, City)) ÷ π{PNO}(Relation(PNO, PName, Color, Weight, City)) per (Relation(PNO, SNO, Qty))", 1, 0},
+		{sno().Divide(pno().Restrict(Attribute("PNO").LE(2)), orders()), "π{SNO}(Relation(SNO, SName, Status, City)) ÷ π{PNO}(σ{PNO <= 2}(Relation(PNO, PName, Color, Weight, City))) per (Relation(PNO, SNO, Qty))", 1, 2},
+		{sno().Divide(parts().Restrict(Attribute("Weight").EQ(17.0)).Project(pnoTup{}), orders()), "", 1, 1},
+		{sno().Divide(pno().Restrict(Attribute("PNO").GT(10)), orders()), "", 1, 5},
+		{pno().Divide(sno(), orders()), "π{PNO}(Relation(PNO, PName, Color, Weight, City)) ÷ π{SNO}(Relation(SNO, SName, Status, City)) per (Relation(PNO, SNO, Qty))", 1, 1},
+		{sno().Divide(pno().Restrict(Attribute("PNO").LE(2)), orders()).Restrict(Attribute("SNO").EQ(1)), "", 1, 1},
+		{sno().Divide(pno().Restrict(Attribute("PNO").LE(2)), orders().Restrict(Attribute("Qty").GT(200))), "", 1, 1},
+		{suppliers().SemiJoin(sno().Divide(pno().Restrict(Attribute("PNO").LE(2)), orders())), "", 4, 2},
+	}
+
+	for i, tt := range relTest {
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%d has Err() => %s", i, err.Error())
+			continue
+		}
+		if tt.expectString != "" {
+			if str := tt.rel.String(); str != tt.expectString {
+				t.Errorf("%d has String() => %v, want %v", i, str, tt.expectString)
+			}
+		}
+		if deg := Deg(tt.rel); deg != tt.expectDeg {
+			t.Errorf("%d %s has Deg() => %v, want %v", i, tt.rel, deg, tt.expectDeg)
+		}
+		if card := Card(tt.rel); card != tt.expectCard {
+			t.Errorf("%d %s has Card() => %v, want %v", i, tt.rel, card, tt.expectCard)
+		}
+	}
+
+	// the result is the same as the one built from other operations
+	divisors := []Relation{
+		pno(),
+		pno().Restrict(Attribute("PNO").LE(2)),
+		pno().Restrict(Attribute("PNO").EQ(4)),
+		pno().Restrict(Attribute("PNO").GT(10)),
+	}
+	for i, d := range divisors {
+		r1, r2 := sno().Divide(d, orders()), divide(sno(), d, orders())
+		if !Equal(r1, r2) {
+			t.Errorf("%d %s is not equal to %s", i, r1, r2)
+		}
+	}
+
+	// the candidate keys and sort order are those of the dividend
+	r2 := sno().Order("SNO").Divide(pno().Restrict(Attribute("PNO").LE(2)), orders())
+	if ck := r2.CKeys(); !reflect.DeepEqual(ck, CandKeys{{"SNO"}}) {
+		t.Errorf("%s has CKeys() => %v, want [[SNO]]", r2, ck)
+	}
+	if ord := SortOrder(r2); !reflect.DeepEqual(ord, []Attribute{"SNO"}) {
+		t.Errorf("%s has SortOrder() => %v, want [SNO]", r2, ord)
+	}
+	var tups []snoTup
+	if err := r2.TupleSlice(&tups); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tups, []snoTup{{1}, {2}}) {
+		t.Errorf("%s has tuples %v, want [{1} {2}]", r2, tups)
+	}
+
+	// test construction errors
+	type snoStrTup struct {
+		SNO string
+	}
+	constructTest := []struct {
+		rel Relation
+		err error
+	}{
+		{pno().Divide(orders().Project(pnoSnoTup{}), orders()), &AttributeConflictError{}},
+		{suppliers().Divide(pno(), orders()), &AttributeSubsetError{}},
+		{sno().Divide(suppliers().Project(struct{ City string }{}), orders()), &AttributeSubsetError{}},
+		{New([]snoStrTup{{"1"}}, nil).Divide(pno(), orders()), &AttributeTypeError{}},
+	}
+	for i, tt := range constructTest {
+		err := tt.rel.Err()
+		if err == nil {
+			t.Errorf("%d did not produce a construction error", i)
+			continue
+		}
+		if reflect.TypeOf(err) != reflect.TypeOf(tt.err) {
+			t.Errorf("%d has Err() => %T, want %T", i, err, tt.err)
+		}
+	}
+
+	// test cancellation
+	res := make(chan snoTup)
+	cancel := rel.TupleChan(res)
+	close(cancel)
+	select {
+	case <-res:
+		t.Errorf("cancel did not end tuple generation")
+	default:
+		// passed test
+	}
+
+	// test errors
+	err := fmt.Errorf("testing error")
+	rel1 := sno().Divide(pno(), orders()).(*divideExpr)
+	rel1.err = err
+	res = make(chan snoTup)
+	_ = rel1.TupleChan(res)
+	if _, ok := <-res; ok {
+		t.Errorf("divide did not short circuit TupleChan")
+	}
+	errTest := []Relation{
+		rel1.Project(snoTup{}),
+		rel1.Restrict(Attribute("SNO").EQ(1)),
+		rel1.Union(rel),
+		rel.Union(rel1),
+		rel1.Diff(rel),
+		rel1.SemiJoin(orders()),
+		rel1.Divide(pno(), orders()),
+		sno().Divide(rel1, orders()),
+		sno().Divide(pno(), rel1),
+	}
+	for i, errRel := range errTest {
+		if errRel.Err() != err {
+			t.Errorf("%d did not short circuit error", i)
+		}
+	}
+
+	// errors from the divisor and per should be reported after evaluation
+	for i, r3 := range []Relation{
+		sno().Divide(&errorRel{pnoTup{}, 1, nil}, orders()),
+		sno().Divide(pno(), &errorRel{orderTup{}, 1, nil}),
+	} {
+		Card(r3)
+		if r3.Err() == nil {
+			t.Errorf("%d divide did not report an error from a source", i)
+		}
+	}
+}
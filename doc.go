@@ -28,13 +28,68 @@
 // in the other where the names are the same.  This is sometimes called a
 // natural join.
 //
-// This package represents tuples as structs with no unexported or anonymous
-// fields.  The fields of the struct are the attributes of the tuple it
-// represents.
+// This package represents tuples as structs.  The fields of the struct are the attributes of the tuple it represents, and
+// they have to be exported.  A field with a `rel:"name"` tag holds the
+// attribute with the name in the tag instead of the field name, and a field
+// with a `rel:"-"` tag is not an attribute at all, so it can be unexported.
+// Excluded fields are left out of the heading, and they are cleared in the
+// tuples of literal relations, so tuples which only differ in excluded fields
+// are the same tuple.  The results of Map are cleared in the same way:
+//
+//	type supplier struct {
+//		ID     int    `json:"id" rel:"SNO"`
+//		Name   string `json:"name" rel:"SName"`
+//		cached string `rel:"-"`
+//	}
+//
+// The fields of embedded structs are promoted into the tuple the same way
+// that go promotes them, so an order line which embeds a supplier has all of
+// the supplier's attributes.  An embedded struct with a rel tag is a single
+// attribute instead.  Attributes which hold structs are tuple-valued, and
+// Wrap and Unwrap move attributes into and out of them:
+//
+//	type place struct {
+//		Color string
+//		City  string
+//	}
+//	type wrappedPart struct {
+//		PNO    int
+//		PName  string
+//		Weight float64
+//		Place  place
+//	}
+//	r := parts.Wrap(wrappedPart{}, "Place")
+//	flat := r.Unwrap("Place")
+//
+// Attributes of type Relation are relation-valued.  Group gathers the
+// attributes of each tuple which are not in its argument into a relation,
+// one for each distinct value of the rest, and Ungroup is its inverse:
+//
+//	type supplierOrders struct {
+//		SNO    int
+//		Orders rel.Relation
+//	}
+//	r := orders.Group(supplierOrders{}, "Orders")
+//	flat := r.Ungroup("Orders")
+//
+// Relations held in attributes are compared by their heading and body, in
+// the same way as rel.Equal compares relations.  Tuples which hold them are
+// always kept in memory, because they can't be spilled to disk.
+//
+// Divide finds the tuples of a relation which are related to every tuple
+// of another.  The suppliers who supply every part are:
+//
+//	sno := suppliers.Project(struct{ SNO int }{})
+//	pno := parts.Project(struct{ PNO int }{})
+//	r := sno.Divide(pno, orders)
+//
+// TClose finds the transitive closure of a relation with two attributes of
+// the same type.  If bom relates each part to its immediate components,
+// then bom.TClose() relates each part to all of its components.
 //
 // Attributes are strings with some additional methods that are useful for
-// constructing predicates and candidate keys.  They have to be valid field
-// names in go.
+// constructing predicates and candidate keys.  They are the field names of
+// the tuples, unless they are given by a rel tag.
 //
 // Predicates are functions which take a tuple and return a boolean, and are
 // used as an input for Restrict expressions.
@@ -51,17 +106,18 @@
 // Literal Relations can be defined using the rel.New function.  Given a slice,
 // map, or channel of tuples, the New function constructs a new "essential"
 // relation, with those values as tuples.  Other packages can create essential
-// relations from other sources of data, such as the github.com/jonlawlor/relcsv
-// package, or the github.com/jonlawlor/relsql package.
+// relations from other sources of data, such as the github.com/jonlawlor/rel/csv
+// package, or the github.com/jonlawlor/rel/sql package.
 //
 // Relational Expressions are generated when one of the methods Project,
-// Restrict, Union, Diff, Join, Rename, Map, or GroupBy.  During their
-// construction, the rel package checks to see if they can be distributed over
-// the source relations that they are being called on, and if so, it attempts
-// to push the expressions down the tree of relations as far as they can go,
-// with the end goal of getting pushed all the way to the "essential" source
-// relations.  In this way, relational expressions can (hopefully) reduce the
-// amount of computation done in total and / or done in the go runtime.
+// Restrict, Union, Diff, Join, Rename, Map, Extend, GroupBy, Order, SemiJoin,
+// or SemiDiff.  During their construction, the rel package checks to see if
+// they can be distributed over the source relations that they are being called
+// on, and if so, it attempts to push the expressions down the tree of
+// relations as far as they can go, with the end goal of getting pushed all the
+// way to the "essential" source relations.  In this way, relational expressions can
+// (hopefully) reduce the amount of computation done in total and / or done in
+// the go runtime.
 //
 package rel
 
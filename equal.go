@@ -0,0 +1,181 @@
+// equal defines the equality of tuples which have relation-valued
+// attributes.  Most tuples can be compared with == and used as map keys
+// directly, but a relation-valued attribute holds a Relation, which is
+// usually a pointer, so two relations with the same heading and body would
+// not be equal.  Those tuples are instead converted into keys where each
+// relation is replaced by a canonical string of its heading and body.
+
+package rel
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// relationType is the type of relation-valued attributes
+var relationType = reflect.TypeOf((*Relation)(nil)).Elem()
+
+// keyTypes caches the key type of each tuple type.  Tuple types which can
+// be used as keys directly are stored as their own key type.
+var keyTypes sync.Map
+
+// tupleKeyType returns the type of the keys of tuples of type e, which has a
+// field for each attribute of e.  Relation-valued attributes are replaced by
+// strings, and tuple-valued attributes by their own key types.  If e does not
+// have any relation-valued attributes, then it is returned instead.
+func tupleKeyType(e reflect.Type) reflect.Type {
+	if kt, ok := keyTypes.Load(e); ok {
+		return kt.(reflect.Type)
+	}
+	kt := e
+	if hasRelations(e) {
+		var fields []reflect.StructField
+		for i, f := range attributeFields(e) {
+			ft := e.FieldByIndex(f.index).Type
+			switch {
+			case ft == relationType:
+				ft = reflect.TypeOf("")
+			case ft.Kind() == reflect.Struct:
+				ft = tupleKeyType(ft)
+			}
+			fields = append(fields, reflect.StructField{Name: "F" + strconv.Itoa(i), Type: ft})
+		}
+		kt = reflect.StructOf(fields)
+	}
+	keyTypes.Store(e, kt)
+	return kt
+}
+
+// hasRelations returns true if the tuple type e has any relation-valued
+// attributes, either directly or in tuple-valued attributes.
+func hasRelations(e reflect.Type) bool {
+	for _, f := range attributeFields(e) {
+		ft := e.FieldByIndex(f.index).Type
+		if ft == relationType || (ft.Kind() == reflect.Struct && hasRelations(ft)) {
+			return true
+		}
+	}
+	return false
+}
+
+// TupleKey returns a comparable value which is equal for equal tuples, and
+// which can be used as a map key to remove duplicates.  Relation-valued
+// attributes are compared by value.  Fields which are not attributes are
+// compared too, so they should be cleared with ClearExcluded first.  It
+// should be used to implement new Relations.
+func TupleKey(rtup reflect.Value) interface{} {
+	return tupleKey(rtup)
+}
+
+// tupleKey returns a comparable value which is equal for equal tuples, and
+// which can be used as a map key.  Tuples without relation-valued attributes
+// are their own keys.
+func tupleKey(rtup reflect.Value) interface{} {
+	kt := tupleKeyType(rtup.Type())
+	if kt == rtup.Type() {
+		return rtup.Interface()
+	}
+	key := reflect.New(kt).Elem()
+	for i, f := range attributeFields(rtup.Type()) {
+		key.Field(i).Set(reflect.ValueOf(valueKey(rtup.FieldByIndex(f.index))))
+	}
+	return key.Interface()
+}
+
+// valueKey returns a comparable value for the value of an attribute, which
+// is equal for equal values.
+func valueKey(v reflect.Value) interface{} {
+	switch {
+	case v.Type() == relationType:
+		if v.IsNil() {
+			return relationKey(nil)
+		}
+		return relationKey(v.Interface().(Relation))
+	case v.Kind() == reflect.Struct:
+		return tupleKey(v)
+	}
+	return v.Interface()
+}
+
+// relationKey returns a canonical string of the heading and the body of the
+// relation, which is the same for equal relations, regardless of the order
+// of their attributes or tuples, or the types of their tuples.
+func relationKey(r Relation) string {
+	if r == nil {
+		return "<nil>"
+	}
+	heading := Heading(r)
+	fMap := make([][]int, len(heading))
+	e := reflect.TypeOf(r.Zero())
+	order := make([]int, len(heading))
+	for i := range order {
+		order[i] = i
+		fMap[i] = attributeField(e, heading[i])
+	}
+	sort.Slice(order, func(i, j int) bool { return heading[order[i]] < heading[order[j]] })
+
+	var body []string
+	tups, errf := Tuples(r)
+	for tup := range tups {
+		rtup := reflect.ValueOf(tup)
+		vals := make([]string, len(order))
+		for k, i := range order {
+			vals[k] = keyString(rtup.FieldByIndex(fMap[i]))
+		}
+		body = append(body, "{"+strings.Join(vals, ", ")+"}")
+	}
+	sort.Strings(body)
+
+	names := make([]string, len(order))
+	for k, i := range order {
+		names[k] = string(heading[i])
+	}
+	if err := errf(); err != nil {
+		// relations which could not be evaluated are only equal to other
+		// relations with the same error
+		return "(" + strings.Join(names, ", ") + ")<" + err.Error() + ">"
+	}
+	return "(" + strings.Join(names, ", ") + "){" + strings.Join(body, ", ") + "}"
+}
+
+// keyString returns a canonical string for the value of an attribute, which
+// is the same for equal values.  Tuple-valued attributes are written with
+// their attributes in order of their names, so that the names of their types
+// and fields do not matter, like the tuples of relations in relationKey.
+func keyString(v reflect.Value) string {
+	switch {
+	case v.Type() == relationType:
+		if v.IsNil() {
+			return relationKey(nil)
+		}
+		return relationKey(v.Interface().(Relation))
+	case v.Kind() == reflect.Struct:
+		e := v.Type()
+		fs := attributeFields(e)
+		sort.Slice(fs, func(i, j int) bool { return fs[i].name < fs[j].name })
+		vals := make([]string, len(fs))
+		for i, f := range fs {
+			vals[i] = string(f.name) + ": " + keyString(v.FieldByIndex(f.index))
+		}
+		return "{" + strings.Join(vals, ", ") + "}"
+	case v.Kind() == reflect.Array:
+		vals := make([]string, v.Len())
+		for i := range vals {
+			vals[i] = keyString(v.Index(i))
+		}
+		return "[" + strings.Join(vals, ", ") + "]"
+	}
+	return fmt.Sprintf("%#v", v.Interface())
+}
+
+// Equal returns true if the two relations have the same heading and the
+// same body.  The attributes of the relations can be in any order, and
+// their tuples can have different types.  Relation-valued attributes are
+// compared with Equal as well.  Both relations are evaluated.
+func Equal(r1, r2 Relation) bool {
+	return relationKey(r1) == relationKey(r2)
+}
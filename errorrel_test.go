@@ -1,6 +1,7 @@
 package rel
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 )
@@ -41,6 +42,22 @@ func (r1 *errorRel) TupleChan(t interface{}) chan<- struct{} {
 	return cancel
 }
 
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *errorRel) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *errorRel) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *errorRel) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
 // Zero returns the zero value of the relation (a blank tuple)
 func (r1 *errorRel) Zero() interface{} {
 	return r1.zero
@@ -101,6 +118,64 @@ func (r1 *errorRel) Map(mfcn interface{}, ckeystr [][]string) Relation {
 	return NewMap(r1, mfcn, ckeystr)
 }
 
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *errorRel) Extend(z2, efcn interface{}) Relation {
+	return NewExtend(r1, z2, efcn)
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *errorRel) Order(att ...Attribute) Relation {
+	return NewOrder(r1, att...)
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *errorRel) SemiJoin(r2 Relation) Relation {
+	return NewSemiJoin(r1, r2)
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *errorRel) SemiDiff(r2 Relation) Relation {
+	return NewSemiDiff(r1, r2)
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *errorRel) Wrap(z2 interface{}, name Attribute) Relation {
+	return NewWrap(r1, z2, name)
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *errorRel) Unwrap(name Attribute) Relation {
+	return NewUnwrap(r1, name)
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *errorRel) Group(z2 interface{}, name Attribute) Relation {
+	return NewGroup(r1, z2, name)
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *errorRel) Ungroup(name Attribute) Relation {
+	return NewUngroup(r1, name)
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *errorRel) Divide(r2, per Relation) Relation {
+	return NewDivide(r1, r2, per)
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *errorRel) TClose() Relation {
+	return NewTClose(r1)
+}
+
 // Err returns an error encountered during construction or computation
 func (r1 *errorRel) Err() error {
 	return r1.err
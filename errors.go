@@ -173,6 +173,28 @@ func EnsureGroupFunc(gfcn reflect.Type, inSuper, outSuper interface{}) (inTup, o
 	return
 }
 
+// EnsureAggregator returns an error if the value tuples of the aggregator are
+// not a subdomain of inSuper, or if its result tuples are not a subdomain of
+// outSuper.
+func EnsureAggregator(agg Aggregator, inSuper, outSuper interface{}) (inTup, outTup reflect.Type, err error) {
+	inTup = reflect.TypeOf(agg.ValZero())
+	outTup = reflect.TypeOf(agg.ResZero())
+
+	// check that the fields are subdomains
+	inDomain := FieldNames(reflect.TypeOf(inSuper))
+	if fn := FieldNames(inTup); !IsSubDomain(fn, inDomain) {
+		err = &InDomainError{inDomain, fn}
+		return
+	}
+
+	outDomain := FieldNames(reflect.TypeOf(outSuper))
+	if fn := FieldNames(outTup); !IsSubDomain(fn, outDomain) {
+		err = &OutDomainError{outDomain, fn}
+		return
+	}
+	return
+}
+
 // EnsureMapFunc returns an error if the input is not a function with only
 // one input and one output, where the input is a subdomain of given
 // tuple.
@@ -260,3 +282,63 @@ func EnsureSameDomain(sub, dom []Attribute) (err error) {
 	// sub and dom do not have any particular order.
 	return &DomainMismatchError{sub, dom}
 }
+
+// KindError represents an error that occurs when an attribute has a kind that
+// can't be used in an operation, such as ordering on a struct attribute.
+type KindError struct {
+	Attribute Attribute
+	Found     reflect.Kind
+}
+
+func (e *KindError) Error() string {
+	return "rel: unsupported kind '" + e.Found.String() + "' for attribute '" + string(e.Attribute) + "'"
+}
+
+// AttributeTypeError represents an error that occurs when an attribute has a
+// different type than an operation requires, such as the result of a Sum
+// aggregate having a different type than the attribute it sums.
+type AttributeTypeError struct {
+	Attribute Attribute
+	Expected  reflect.Type
+	Found     reflect.Type
+}
+
+func (e *AttributeTypeError) Error() string {
+	return "rel: expected type '" + e.Expected.String() + "' for attribute '" + string(e.Attribute) + "', found '" + e.Found.String() + "'"
+}
+
+// AttributeConflictError represents an error that occurs when an attribute
+// is given more than one role in an operation, such as when it is both a
+// grouping attribute and the input to an aggregate.
+type AttributeConflictError struct {
+	Attribute Attribute
+}
+
+func (e *AttributeConflictError) Error() string {
+	return "rel: conflicting uses of attribute '" + string(e.Attribute) + "'"
+}
+
+// HeadingError represents an error that occurs when the heading of the
+// relations held by a relation-valued attribute is not known, because the
+// zero tuple of the relation does not hold a relation in that attribute.  The
+// heading can be given explicitly with UngroupOf.
+type HeadingError struct {
+	Attribute Attribute
+}
+
+func (e *HeadingError) Error() string {
+	return "rel: unknown heading of relation-valued attribute '" + string(e.Attribute) + "', because the zero tuple does not hold a relation; use UngroupOf to provide it"
+}
+
+// UnsupportedError represents an error that occurs when a relation or a
+// predicate can't be translated, such as a Map or an AdHoc predicate in
+// ToSQL.  Node is the name of the operation, and Expr is the text
+// representation of the relation or predicate.
+type UnsupportedError struct {
+	Node string
+	Expr string
+}
+
+func (e *UnsupportedError) Error() string {
+	return "rel: unsupported " + e.Node + " in " + e.Expr
+}
@@ -752,3 +752,158 @@ func ExampleRelation_map() {
 	//  +------+------+-------+-------+
 
 }
+
+func ExampleRelation_order() {
+	type orderTup struct {
+		PNO int
+		SNO int
+		Qty int
+	}
+
+	r1 := rel.New([]orderTup{
+		{1, 1, 300},
+		{1, 2, 200},
+		{1, 3, 400},
+		{1, 4, 200},
+		{1, 5, 100},
+		{1, 6, 100},
+		{2, 1, 300},
+		{2, 2, 400},
+		{3, 2, 200},
+		{4, 2, 200},
+		{4, 4, 300},
+		{4, 5, 400},
+	}, [][]string{
+		[]string{"PNO", "SNO"},
+	})
+
+	// sort the orders by quantity, and then by supplier and part
+	r2 := r1.Order("Qty", "SNO", "PNO")
+
+	fmt.Println(r2)
+	fmt.Println(rel.PrettyPrint(r2))
+
+	// Output:
+	// τ{Qty, SNO, PNO}(Relation(PNO, SNO, Qty))
+	//  +------+------+------+
+	//  |  PNO |  SNO |  Qty |
+	//  +------+------+------+
+	//  |    1 |    5 |  100 |
+	//  |    1 |    6 |  100 |
+	//  |    1 |    2 |  200 |
+	//  |    3 |    2 |  200 |
+	//  |    4 |    2 |  200 |
+	//  |    1 |    4 |  200 |
+	//  |    1 |    1 |  300 |
+	//  |    2 |    1 |  300 |
+	//  |    4 |    4 |  300 |
+	//  |    2 |    2 |  400 |
+	//  |    1 |    3 |  400 |
+	//  |    4 |    5 |  400 |
+	//  +------+------+------+
+}
+
+func ExampleRelation_extend() {
+	type orderTup struct {
+		PNO int
+		SNO int
+		Qty int
+	}
+
+	r1 := rel.New([]orderTup{
+		{1, 1, 300},
+		{1, 2, 200},
+		{2, 1, 300},
+		{2, 2, 400},
+		{3, 2, 200},
+	}, [][]string{
+		[]string{"PNO", "SNO"},
+	})
+
+	type qtyTup struct {
+		Qty int
+	}
+	type cratesTup struct {
+		Crates int
+	}
+	type orderCratesTup struct {
+		PNO    int
+		SNO    int
+		Qty    int
+		Crates int
+	}
+
+	// the number of crates needed to ship each order, at 150 parts per crate
+	crates := func(tup1 qtyTup) cratesTup {
+		return cratesTup{(tup1.Qty + 149) / 150}
+	}
+
+	// unlike map, extend keeps the existing attributes and candidate keys
+	r2 := r1.Extend(orderCratesTup{}, crates).Order("PNO", "SNO")
+
+	fmt.Println(r2)
+	fmt.Println(r2.CKeys())
+	fmt.Println(rel.PrettyPrint(r2))
+
+	// Output:
+	// τ{PNO, SNO}(Relation(PNO, SNO, Qty).Extend({Qty}->{Crates}))
+	// [[PNO SNO]]
+	//  +------+------+------+---------+
+	//  |  PNO |  SNO |  Qty |  Crates |
+	//  +------+------+------+---------+
+	//  |    1 |    1 |  300 |       2 |
+	//  |    1 |    2 |  200 |       2 |
+	//  |    2 |    1 |  300 |       2 |
+	//  |    2 |    2 |  400 |       3 |
+	//  |    3 |    2 |  200 |       2 |
+	//  +------+------+------+---------+
+}
+
+func ExampleSummarize() {
+	type orderTup struct {
+		PNO int
+		SNO int
+		Qty int
+	}
+
+	r1 := rel.New([]orderTup{
+		{1, 1, 300},
+		{1, 2, 200},
+		{1, 3, 400},
+		{1, 4, 200},
+		{1, 5, 100},
+		{1, 6, 100},
+		{2, 1, 300},
+		{2, 2, 400},
+		{3, 2, 200},
+		{4, 2, 200},
+		{4, 4, 300},
+		{4, 5, 400},
+	}, [][]string{
+		[]string{"PNO", "SNO"},
+	})
+
+	// the attributes which are not the result of an aggregate determine the
+	// groups
+	type qtyTup struct {
+		PNO      int
+		TotalQty int
+		MaxQty   int
+		N        int
+	}
+	r2 := rel.Summarize(r1, qtyTup{}, rel.Sum("Qty", "TotalQty"), rel.Max("Qty", "MaxQty"), rel.Count("N"))
+
+	fmt.Println(r2)
+	fmt.Println(rel.PrettyPrint(r2.Order("PNO")))
+
+	// Output:
+	// Relation(PNO, SNO, Qty).Summarize({PNO}->{Sum(Qty) as TotalQty, Max(Qty) as MaxQty, Count() as N})
+	//  +------+-----------+---------+----+
+	//  |  PNO |  TotalQty |  MaxQty |  N |
+	//  +------+-----------+---------+----+
+	//  |    1 |      1300 |     400 |  6 |
+	//  |    2 |       700 |     400 |  2 |
+	//  |    3 |       200 |     200 |  1 |
+	//  |    4 |       900 |     400 |  3 |
+	//  +------+-----------+---------+----+
+}
@@ -0,0 +1,304 @@
+// explain implements the description of how relations are evaluated, as a
+// tree of plan nodes which correspond to the nodes of the expression.
+
+package rel
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Plan is a description of how a relation is evaluated, which is produced by
+// Explain.  Each plan node corresponds to a node of the expression.
+type Plan struct {
+	// Op is the operation that produces the relation
+	Op OpKind
+
+	// Detail describes the parameters of the operation, like the predicate
+	// of a restriction, or the name of a named relation.
+	Detail string
+
+	// Heading is the attributes of the relation
+	Heading []Attribute
+
+	// CKeys is the set of candidate keys of the relation
+	CKeys CandKeys
+
+	// Card is the estimated cardinality of the relation, or -1 if it can't
+	// be estimated.
+	Card int
+
+	// Algorithm describes how the operation is evaluated when there is more
+	// than one way to do it, like the algorithm that is chosen for a join.
+	Algorithm string
+
+	// Rewrites are the names of the rules which produced the node.  They are
+	// only known if a RuleTrace was set with SetRuleTracer while the
+	// relation was built.
+	Rewrites []string
+
+	// Stats are the counts and timings of the operation, which are set by
+	// Collector.Annotate, or nil if it has not been observed.
+	Stats *Stats
+
+	// Children are the plans of the relations that the operation is
+	// performed on
+	Children []*Plan
+
+	// rel is the relation that the plan describes
+	rel Relation
+}
+
+// Explain returns the plan of a relation.  The cardinalities in the plan are
+// estimated from the sizes of the literal relations, using fixed
+// selectivities for restrictions.
+func Explain(r Relation) *Plan {
+	var events []RuleEvent
+	rulesMu.RLock()
+	if t, ok := ruleTracer.(*RuleTrace); ok {
+		events = t.Events()
+	}
+	rulesMu.RUnlock()
+	return explain(r, events)
+}
+
+// explain returns the plan of a relation, with the rewrites in events which
+// produced its nodes
+func explain(r Relation, events []RuleEvent) *Plan {
+	p := &Plan{Heading: Heading(r), CKeys: r.CKeys(), rel: r}
+	if n, ok := r.(Node); ok {
+		p.Op = n.Op()
+		for _, c := range n.Children() {
+			p.Children = append(p.Children, explain(c, events))
+		}
+	}
+	for _, e := range events {
+		if sameRelation(e.After, r) {
+			p.Rewrites = append(p.Rewrites, e.Rule)
+		}
+	}
+	p.Detail = planDetail(r)
+	p.Algorithm = planAlgorithm(r)
+	p.Card = estimateCard(r, p.Children)
+	return p
+}
+
+// sameRelation returns true if r1 and r2 are the same relation
+func sameRelation(r1, r2 Relation) bool {
+	t := reflect.TypeOf(r1)
+	return t == reflect.TypeOf(r2) && t.Comparable() && r1 == r2
+}
+
+// planDetail describes the parameters of an operation
+func planDetail(r Relation) string {
+	switch r1 := r.(type) {
+	case PredicateNode:
+		return r1.Predicate().String()
+	case NamedNode:
+		return r1.Name()
+	case RenameNode:
+		var s []string
+		for from, to := range r1.Renames() {
+			if from != to {
+				s = append(s, string(from)+"->"+string(to))
+			}
+		}
+		sort.Strings(s)
+		return strings.Join(s, ", ")
+	case AggregateNode:
+		var s []string
+		for _, agg := range r1.Aggregates() {
+			s = append(s, string(agg.Result())+" = "+agg.Func()+"("+string(agg.Attribute())+")")
+		}
+		return strings.Join(s, ", ")
+	case *orderExpr:
+		return attributeString(r1.att)
+	case *wrapExpr:
+		return "{" + attributeString(r1.wrapped()) + "} as " + string(r1.name)
+	case *unwrapExpr:
+		return string(r1.name)
+	case *groupExpr:
+		return "{" + attributeString(r1.grouped()) + "} as " + string(r1.name)
+	case *ungroupExpr:
+		return string(r1.name)
+	}
+	return ""
+}
+
+// planAlgorithm describes how an operation is evaluated, for the operations
+// that have more than one way to do it
+func planAlgorithm(r Relation) string {
+	switch r1 := r.(type) {
+	case *sliceLiteral:
+		if !r1.sourceDistinct {
+			return "distinct"
+		}
+	case *chanLiteral:
+		if !r1.sourceDistinct {
+			return "distinct"
+		}
+	case *mapExpr:
+		if !r1.isDistinct {
+			return "distinct"
+		}
+	case *projectExpr:
+		fMap := FieldMap(reflect.TypeOf(r1.source1.Zero()), reflect.TypeOf(r1.zero))
+		if len(SubsetCandidateKeys(r1.source1.CKeys(), Heading(r1.source1), fMap)) == 0 {
+			return "distinct"
+		}
+	case *restrictExpr:
+		if SortOrder(r1.source1) != nil {
+			return "sequential"
+		}
+		return "parallel"
+	case *joinExpr:
+		return r1.algorithm().String()
+	case *groupByExpr:
+		if r1.agg != nil {
+			return "partial aggregation"
+		}
+		return "grouping function"
+	case *tcloseExpr:
+		return "semi-naive iteration"
+	}
+	return ""
+}
+
+// selectivity is the estimated fraction of tuples which satisfy a predicate
+func selectivity(p Predicate) float64 {
+	switch p1 := p.(type) {
+	case EQPred:
+		return 0.1
+	case NEPred:
+		return 0.9
+	case LTPred, LEPred, GTPred, GEPred:
+		return 1.0 / 3
+	case NotPred:
+		return 1 - selectivity(p1.P)
+	case AndPred:
+		return selectivity(p1.P1) * selectivity(p1.P2)
+	case OrPred:
+		s1, s2 := selectivity(p1.P1), selectivity(p1.P2)
+		return s1 + s2 - s1*s2
+	}
+	return 0.5
+}
+
+// estimateCard estimates the cardinality of a relation, given the plans of
+// its children.  It returns -1 if the cardinality can't be estimated.
+func estimateCard(r Relation, children []*Plan) int {
+	for _, c := range children {
+		if c.Card < 0 {
+			return -1
+		}
+	}
+	switch r1 := r.(type) {
+	case *sliceLiteral:
+		return r1.rbody.Len()
+	case *mapLiteral:
+		return r1.rbody.Len()
+	case *restrictExpr:
+		return int(math.Ceil(float64(children[0].Card) * selectivity(r1.p)))
+	case *unionExpr:
+		return children[0].Card + children[1].Card
+	case *joinExpr:
+		c1, c2 := children[0].Card, children[1].Card
+		switch r1.algorithm() {
+		case hashJoin1:
+			// each tuple in source2 matches at most one in source1
+			return c2
+		case hashJoin2:
+			return c1
+		}
+		if len(AttributeMap(Heading(r1.source1), Heading(r1.source2))) == 0 {
+			// cross product
+			return c1 * c2
+		}
+		if c1 > c2 {
+			return c1
+		}
+		return c2
+	}
+	if len(children) == 0 {
+		return -1
+	}
+	// the rest of the operations have at most as many tuples as their first
+	// source
+	return children[0].Card
+}
+
+// String returns the plan as an indented tree, with a line for each node
+func (p *Plan) String() string {
+	var b strings.Builder
+	p.writeText(&b, 0)
+	return b.String()
+}
+
+// writeText writes the plan as an indented tree
+func (p *Plan) writeText(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(p.summary(" "))
+	b.WriteString("\n")
+	for _, c := range p.Children {
+		c.writeText(b, depth+1)
+	}
+}
+
+// summary describes a plan node, with its properties separated by sep
+func (p *Plan) summary(sep string) string {
+	s := []string{p.Op.String() + "(" + attributeString(p.Heading) + ")"}
+	if p.Detail != "" {
+		s = append(s, "["+p.Detail+"]")
+	}
+	keys := make([]string, len(p.CKeys))
+	for i, ck := range p.CKeys {
+		keys[i] = "{" + attributeString(ck) + "}"
+	}
+	s = append(s, "keys: "+strings.Join(keys, ", "))
+	if p.Card < 0 {
+		s = append(s, "card: ?")
+	} else {
+		s = append(s, "card: "+strconv.Itoa(p.Card))
+	}
+	if p.Algorithm != "" {
+		s = append(s, "algorithm: "+p.Algorithm)
+	}
+	if len(p.Rewrites) > 0 {
+		s = append(s, "rewrites: "+strings.Join(p.Rewrites, ", "))
+	}
+	if p.Stats != nil {
+		s = append(s, "tuples: "+strconv.Itoa(p.Stats.Tuples),
+			"elapsed: "+p.Stats.Elapsed.String(),
+			"blocked: "+p.Stats.Blocked.String())
+	}
+	return strings.Join(s, sep)
+}
+
+// DOT returns the plan as a Graphviz graph, with edges from each node to
+// its children
+func (p *Plan) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph plan {\n\tnode [shape=box];\n")
+	id := 0
+	p.writeDOT(&b, &id)
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// writeDOT writes the nodes and edges of the plan, numbering the nodes in
+// depth first order, and returns the name of the node
+func (p *Plan) writeDOT(b *strings.Builder, id *int) string {
+	name := "n" + strconv.Itoa(*id)
+	*id++
+	label := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(p.summary("\n"))
+	label = strings.Replace(label, "\n", `\n`, -1)
+	b.WriteString("\t" + name + " [label=\"" + label + "\"];\n")
+	for _, c := range p.Children {
+		cname := c.writeDOT(b, id)
+		b.WriteString("\t" + name + " -> " + cname + ";\n")
+	}
+	return name
+}
@@ -0,0 +1,80 @@
+package rel
+
+import (
+	"testing"
+)
+
+// tests for the plans of relations
+func TestExplain(t *testing.T) {
+	type joinTup struct {
+		PNO    int
+		SNO    int
+		Qty    int
+		SName  string
+		Status int
+		City   string
+	}
+	type pnoTup struct {
+		PNO int
+	}
+	var planTest = []struct {
+		rel        Relation
+		expectText string
+	}{
+		{orders().Restrict(Attribute("Qty").GT(100)).Join(suppliers(), joinTup{}),
+			`Join(PNO, SNO, Qty, SName, Status, City) keys: {PNO, SNO} card: 4 algorithm: hash join
+  Restrict(PNO, SNO, Qty) [Qty > 100] keys: {PNO, SNO} card: 4 algorithm: parallel
+    Literal(PNO, SNO, Qty) keys: {PNO, SNO} card: 12
+  Literal(SNO, SName, Status, City) keys: {SNO} card: 5
+`},
+		{Named("orders", orders()).Project(pnoTup{}).Order("PNO"),
+			`Order(PNO) [PNO] keys: {PNO} card: 12
+  Project(PNO) keys: {PNO} card: 12 algorithm: distinct
+    Named(PNO, SNO, Qty) [orders] keys: {PNO, SNO} card: 12
+      Literal(PNO, SNO, Qty) keys: {PNO, SNO} card: 12
+`},
+		{New(make(chan exTup2), [][]string{}).Restrict(Attribute("Foo").EQ(1)),
+			`Restrict(Foo, Bar) [Foo == 1] keys: {Bar, Foo} card: ? algorithm: parallel
+  Literal(Foo, Bar) keys: {Bar, Foo} card: ? algorithm: distinct
+`},
+	}
+	for i, tt := range planTest {
+		if txt := Explain(tt.rel).String(); txt != tt.expectText {
+			t.Errorf("%d has Explain() =>\n%v\nwant\n%v", i, txt, tt.expectText)
+		}
+	}
+
+	// with a memory budget, joins which would be hash joins are evaluated
+	// with a symmetric hash join, which can spill to disk
+	func() {
+		defer SetMemoryBudget(SetMemoryBudget(1 << 20))
+		r := orders().Restrict(Attribute("Qty").GT(100)).Join(suppliers(), joinTup{})
+		p := Explain(r)
+		if p.Algorithm != "symmetric hash join" || p.Card != 5 {
+			t.Errorf("%s has Explain() => %v and card %d with a memory budget, want symmetric hash join and 5", r, p.Algorithm, p.Card)
+		}
+	}()
+
+	want := `digraph plan {
+	node [shape=box];
+	n0 [label="Restrict(PNO, PName, Color, Weight, City)\n[Color == Red]\nkeys: {PNO}\ncard: 1\nalgorithm: parallel"];
+	n1 [label="Literal(PNO, PName, Color, Weight, City)\nkeys: {PNO}\ncard: 6"];
+	n0 -> n1;
+}
+`
+	if dot := Explain(parts().Restrict(Attribute("Color").EQ("Red"))).DOT(); dot != want {
+		t.Errorf("DOT() =>\n%v\nwant\n%v", dot, want)
+	}
+}
+
+// tests for the rewrites in plans
+func TestExplainRewrites(t *testing.T) {
+	defer SetRuleTracer(SetRuleTracer(&RuleTrace{}))
+	p := Explain(parts().Union(parts()).Restrict(Attribute("Color").EQ("Red")))
+	if len(p.Rewrites) != 1 || p.Rewrites[0] != "RestrictUnion" {
+		t.Errorf("Explain() has Rewrites => %v, want [RestrictUnion]", p.Rewrites)
+	}
+	if len(p.Children) != 2 || len(p.Children[0].Rewrites) != 0 {
+		t.Errorf("Explain() has unexpected children %v", p.Children)
+	}
+}
@@ -0,0 +1,299 @@
+// extend implements an extend expression in relational algebra, which adds
+// attributes computed from the existing attributes of each tuple.
+
+package rel
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// extendExpr is a type that represents adding new attributes to each tuple in
+// a source relation, where the values of the new attributes are determined by
+// a function of the existing attributes.  Date calls this operation EXTEND.
+// Because the new attributes are functionally dependent on the old ones, the
+// candidate keys of the source are retained, and no duplicates can occur.
+type extendExpr struct {
+	// the input relation
+	source1 Relation
+
+	// zero is the resulting relation tuple type
+	zero interface{}
+
+	// valType is the tuple type of the values provided to the extending
+	// function.
+	valType reflect.Type
+
+	// resType is the tuple type of the values returned from the extending
+	// function, which contains only the new attributes.
+	resType reflect.Type
+
+	// the function that computes the new attributes
+	refcn reflect.Value
+
+	// err is the first error encountered during construction or evaluation
+	err error
+}
+
+// TupleChan sends each tuple in the relation to a channel
+func (r1 *extendExpr) TupleChan(t interface{}) chan<- struct{} {
+	cancel := make(chan struct{})
+	// reflect on the channel
+	chv := reflect.ValueOf(t)
+	err := EnsureChan(chv.Type(), r1.zero)
+	if err != nil {
+		r1.err = err
+		return cancel
+	}
+	if r1.err != nil {
+		chv.Close()
+		return cancel
+	}
+
+	mc := runtime.GOMAXPROCS(-1)
+	if SortOrder(r1.source1) != nil {
+		// concurrent evaluation would not retain the order of the source
+		mc = 1
+	}
+
+	e1 := reflect.TypeOf(r1.source1.Zero())
+	e2 := reflect.TypeOf(r1.zero)
+
+	// the positions of the function inputs in the source tuples, and the
+	// positions of the old and new attributes in the result tuples
+	valMap := fieldMap(e1, r1.valType)
+	oldMap := fieldMap(e1, e2)
+	newMap := fieldMap(r1.resType, e2)
+
+	body := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e1), 0)
+	bcancel := r1.source1.TupleChan(body.Interface())
+
+	var wg sync.WaitGroup
+	wg.Add(mc)
+	go func(res reflect.Value) {
+		wg.Wait()
+		// if we've been cancelled, send it up to the source
+		select {
+		case <-cancel:
+			close(bcancel)
+		default:
+			if err := r1.source1.Err(); err != nil {
+				r1.err = err
+			}
+			res.Close()
+		}
+	}(chv)
+
+	for i := 0; i < mc; i++ {
+		go func(body, res reflect.Value) {
+			defer wg.Done()
+			// input channels
+			sourceSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: body}
+			canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+			inCases := []reflect.SelectCase{canSel, sourceSel}
+
+			// output channels
+			resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+			for {
+				chosen, tup, ok := reflect.Select(inCases)
+				if chosen == 0 || !ok {
+					// cancel channel was closed, or the source completed
+					return
+				}
+
+				// construct the function input
+				fcnin := reflect.Indirect(reflect.New(r1.valType))
+				for _, fm := range valMap {
+					fcnin.FieldByIndex(fm.J).Set(tup.FieldByIndex(fm.I))
+				}
+				fcnout := r1.refcn.Call([]reflect.Value{fcnin})[0]
+
+				// combine the old and new attributes into the result
+				tup2 := reflect.Indirect(reflect.New(e2))
+				for _, fm := range oldMap {
+					tup2.FieldByIndex(fm.J).Set(tup.FieldByIndex(fm.I))
+				}
+				for _, fm := range newMap {
+					tup2.FieldByIndex(fm.J).Set(fcnout.FieldByIndex(fm.I))
+				}
+				resSel.Send = tup2
+				chosen, _, _ = reflect.Select([]reflect.SelectCase{canSel, resSel})
+				if chosen == 0 {
+					return
+				}
+			}
+		}(body, chv)
+	}
+	return cancel
+}
+
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *extendExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *extendExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *extendExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
+// Zero returns the zero value of the relation (a blank tuple)
+func (r1 *extendExpr) Zero() interface{} {
+	return r1.zero
+}
+
+// CKeys is the set of candidate keys in the relation
+func (r1 *extendExpr) CKeys() CandKeys {
+	return r1.source1.CKeys()
+}
+
+// SortOrder is the set of attributes that the relation is sorted on
+func (r1 *extendExpr) SortOrder() []Attribute {
+	return SortOrder(r1.source1)
+}
+
+// GoString returns a text representation of the Relation
+func (r1 *extendExpr) GoString() string {
+	return goStringTabTable(r1)
+}
+
+// String returns a text representation of the Relation
+func (r1 *extendExpr) String() string {
+	return r1.source1.String() + ".Extend({" + attributeString(FieldNames(r1.valType)) + "}->{" + attributeString(FieldNames(r1.resType)) + "})"
+}
+
+// Project creates a new relation with less than or equal degree
+// t2 has to be a new type which is a subdomain of r.
+// If none of the new attributes are retained then the extension can be
+// removed entirely.
+func (r1 *extendExpr) Project(z2 interface{}) Relation {
+	return Rewrite(r1, NewProject(r1, z2))
+}
+
+// Restrict creates a new relation with less than or equal cardinality
+// p has to be a func(tup T) bool where tup is a subdomain of the input r.
+// Restrict can be performed before the extension if it does not depend on the
+// new attributes.
+func (r1 *extendExpr) Restrict(p Predicate) Relation {
+	return Rewrite(r1, NewRestrict(r1, p))
+}
+
+// Rename creates a new relation with new column names
+// z2 has to be a struct with the same number of fields as the input relation
+func (r1 *extendExpr) Rename(z2 interface{}) Relation {
+	return Rewrite(r1, NewRename(r1, z2))
+}
+
+// Union creates a new relation by unioning the bodies of both inputs
+func (r1 *extendExpr) Union(r2 Relation) Relation {
+	return Rewrite(r1, NewUnion(r1, r2))
+}
+
+// Diff creates a new relation by set minusing the two inputs
+func (r1 *extendExpr) Diff(r2 Relation) Relation {
+	return Rewrite(r1, NewDiff(r1, r2))
+}
+
+// Join creates a new relation by performing a natural join on the inputs
+func (r1 *extendExpr) Join(r2 Relation, zero interface{}) Relation {
+	return Rewrite(r1, NewJoin(r1, r2, zero))
+}
+
+// GroupBy creates a new relation by grouping and applying a user defined func
+func (r1 *extendExpr) GroupBy(t2, gfcn interface{}) Relation {
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
+}
+
+// Map creates a new relation by applying a function to tuples in the source
+func (r1 *extendExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *extendExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *extendExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *extendExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *extendExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *extendExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *extendExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *extendExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *extendExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *extendExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *extendExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
+}
+
+// Err returns an error encountered during construction or computation
+func (r1 *extendExpr) Err() error {
+	return r1.err
+}
+
+// Op is the operation that produces the relation
+func (r1 *extendExpr) Op() OpKind {
+	return OpExtend
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *extendExpr) Children() []Relation {
+	return []Relation{r1.source1}
+}
+
+// Func is the function which computes the new attributes of each tuple
+func (r1 *extendExpr) Func() interface{} {
+	if !r1.refcn.IsValid() {
+		return nil
+	}
+	return r1.refcn.Interface()
+}
@@ -0,0 +1,236 @@
+package rel
+
+import (
+	"fmt"
+	"testing"
+)
+
+// tests for extend op
+func TestExtend(t *testing.T) {
+	type extTup struct {
+		PNO  int
+		SNO  int
+		Qty  int
+		Qty2 int
+	}
+	type qtyTup struct {
+		Qty int
+	}
+	type qty2Tup struct {
+		Qty2 int
+	}
+	doubleQty := func(tup1 qtyTup) qty2Tup {
+		return qty2Tup{tup1.Qty * 2}
+	}
+
+	// test the degrees, cardinality, and string representation
+	rel := orders().Extend(extTup{}, doubleQty)
+	type distinctTup struct {
+		PNO int
+		SNO int
+	}
+	type nonDistinctTup struct {
+		PNO  int
+		Qty2 int
+	}
+	type titleCaseTup struct {
+		Pno  int
+		Sno  int
+		Qty  int
+		Qty2 int
+	}
+	type joinTup struct {
+		PNO    int
+		SNO    int
+		Qty    int
+		Qty2   int
+		SName  string
+		Status int
+		City   string
+	}
+	type groupByTup struct {
+		PNO  int
+		Qty2 int
+	}
+	type valTup struct {
+		Qty2 int
+	}
+	groupFcn := func(val <-chan valTup) valTup {
+		res := valTup{}
+		for vi := range val {
+			res.Qty2 += vi.Qty2
+		}
+		return res
+	}
+	type mapRes struct {
+		PNO int
+		SNO int
+		Qty int
+	}
+	mapFcn := func(tup1 extTup) mapRes {
+		return mapRes{tup1.PNO, tup1.SNO, tup1.Qty2 - tup1.Qty}
+	}
+	mapKeys := [][]string{
+		[]string{"PNO", "SNO"},
+	}
+	type extTup2 struct {
+		PNO  int
+		SNO  int
+		Qty  int
+		Qty2 int
+		Qty4 int
+	}
+	type qty4Tup struct {
+		Qty4 int
+	}
+	doubleQty2 := func(tup1 qty2Tup) qty4Tup {
+		return qty4Tup{tup1.Qty2 * 2}
+	}
+
+	var relTest = []struct {
+		rel          Relation
+		expectString string
+		expectDeg    int
+		expectCard   int
+	}{
+		{rel, "Relation(PNO, SNO, Qty).Extend({Qty}->{Qty2})", 4, 12},
+		{rel.Restrict(Attribute("PNO").EQ(1)), "σ{PNO == 1}(Relation(PNO, SNO, Qty)).Extend({Qty}->{Qty2})", 4, 6},
+		{rel.Restrict(Attribute("Qty2").GT(500)), "σ{Qty2 > 500}(Relation(PNO, SNO, Qty).Extend({Qty}->{Qty2}))", 4, 6},
+		{rel.Project(distinctTup{}), "π{PNO, SNO}(Relation(PNO, SNO, Qty))", 2, 12},
+		{rel.Project(nonDistinctTup{}), "π{PNO, Qty2}(Relation(PNO, SNO, Qty).Extend({Qty}->{Qty2}))", 2, 10},
+		{rel.Rename(titleCaseTup{}), "ρ{Pno, Sno, Qty, Qty2}/{PNO, SNO, Qty, Qty2}(Relation(PNO, SNO, Qty).Extend({Qty}->{Qty2}))", 4, 12},
+		{rel.Diff(rel), "Relation(PNO, SNO, Qty).Extend({Qty}->{Qty2}) − Relation(PNO, SNO, Qty).Extend({Qty}->{Qty2})", 4, 0},
+		{rel.Union(rel), "Relation(PNO, SNO, Qty).Extend({Qty}->{Qty2}) ∪ Relation(PNO, SNO, Qty).Extend({Qty}->{Qty2})", 4, 12},
+		{rel.Join(suppliers(), joinTup{}), "Relation(PNO, SNO, Qty).Extend({Qty}->{Qty2}) ⋈ Relation(SNO, SName, Status, City)", 7, 11},
+		{rel.GroupBy(groupByTup{}, groupFcn), "Relation(PNO, SNO, Qty).Extend({Qty}->{Qty2}).GroupBy({PNO, Qty2}->{Qty2})", 2, 4},
+		{rel.Map(mapFcn, mapKeys), "Relation(PNO, SNO, Qty).Extend({Qty}->{Qty2}).Map({PNO, SNO, Qty, Qty2}->{PNO, SNO, Qty})", 3, 12},
+		{rel.Extend(extTup2{}, doubleQty2), "Relation(PNO, SNO, Qty).Extend({Qty}->{Qty2}).Extend({Qty2}->{Qty4})", 5, 12},
+		{rel.Extend(extTup2{}, doubleQty2).Restrict(Attribute("SNO").EQ(2)), "σ{SNO == 2}(Relation(PNO, SNO, Qty)).Extend({Qty}->{Qty2}).Extend({Qty2}->{Qty4})", 5, 4},
+		{rel.SemiJoin(suppliers().Restrict(Attribute("City").EQ("Paris"))), "Relation(PNO, SNO, Qty).Extend({Qty}->{Qty2}) ⋉ σ{City == Paris}(Relation(SNO, SName, Status, City))", 4, 5},
+	}
+
+	for i, tt := range relTest {
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%d has Err() => %s", i, err.Error())
+			continue
+		}
+		if str := tt.rel.String(); str != tt.expectString {
+			t.Errorf("%d has String() => %v, want %v", i, str, tt.expectString)
+		}
+		if deg := Deg(tt.rel); deg != tt.expectDeg {
+			t.Errorf("%d %s has Deg() => %v, want %v", i, tt.expectString, deg, tt.expectDeg)
+		}
+		if card := Card(tt.rel); card != tt.expectCard {
+			t.Errorf("%d %s has Card() => %v, want %v", i, tt.expectString, card, tt.expectCard)
+		}
+	}
+
+	// the candidate keys of the source are retained
+	if ck := rel.CKeys(); len(ck) != 1 || len(ck[0]) != 2 || ck[0][0] != "PNO" || ck[0][1] != "SNO" {
+		t.Errorf("%s has CKeys() => %v, want [[PNO SNO]]", rel, ck)
+	}
+
+	// the new attributes are computed from the old ones
+	tups := make(chan extTup)
+	rel.TupleChan(tups)
+	for tup := range tups {
+		if tup.Qty2 != tup.Qty*2 {
+			t.Errorf("%s has tuple %v, want Qty2 = %d", rel, tup, tup.Qty*2)
+		}
+	}
+
+	// test construction errors
+	type missingTup struct {
+		PNO  int
+		Qty  int
+		Qty2 int
+	}
+	type qty3Tup struct {
+		Qty3 int
+	}
+	type badInTup struct {
+		Foo int
+	}
+	constructTest := []Relation{
+		orders().Extend(extTup{}, 1),
+		orders().Extend(missingTup{}, doubleQty),
+		orders().Extend(extTup{}, func(tup1 qtyTup) qty3Tup { return qty3Tup{tup1.Qty} }),
+		orders().Extend(extTup{}, func(tup1 badInTup) qty2Tup { return qty2Tup{tup1.Foo} }),
+		orders().Extend(extTup2{}, doubleQty),
+	}
+	for i, errRel := range constructTest {
+		if errRel.Err() == nil {
+			t.Errorf("%d did not produce a construction error", i)
+		}
+	}
+
+	// attributes with different types are reported instead of panicking
+	// during evaluation
+	type strQty2Tup struct {
+		Qty2 string
+	}
+	type strQtyTup struct {
+		Qty string
+	}
+	type strExtTup struct {
+		PNO  int
+		SNO  int
+		Qty  string
+		Qty2 int
+	}
+	typeTest := []Relation{
+		orders().Extend(extTup{}, func(tup1 qtyTup) strQty2Tup { return strQty2Tup{fmt.Sprint(tup1.Qty)} }),
+		orders().Extend(extTup{}, func(tup1 strQtyTup) qty2Tup { return qty2Tup{len(tup1.Qty)} }),
+		orders().Extend(strExtTup{}, doubleQty),
+	}
+	for i, errRel := range typeTest {
+		if _, ok := errRel.Err().(*AttributeTypeError); !ok {
+			t.Errorf("%d has Err() => %v, want an AttributeTypeError", i, errRel.Err())
+		}
+	}
+
+	// test cancellation
+	res := make(chan extTup)
+	cancel := rel.TupleChan(res)
+	close(cancel)
+	select {
+	case <-res:
+		t.Errorf("cancel did not end tuple generation")
+	default:
+		// passed test
+	}
+	// test errors
+	err := fmt.Errorf("testing error")
+	r1 := orders().Extend(extTup{}, doubleQty).(*extendExpr)
+	r1.err = err
+	r2 := orders().Extend(extTup{}, doubleQty).(*extendExpr)
+	r2.err = err
+	res = make(chan extTup)
+	_ = r1.TupleChan(res)
+	if _, ok := <-res; ok {
+		t.Errorf("extend did not short circuit TupleChan")
+	}
+	errTest := []Relation{
+		r1.Project(distinctTup{}),
+		r1.Project(nonDistinctTup{}),
+		r1.Restrict(Attribute("PNO").EQ(1)),
+		r1.Restrict(Attribute("Qty2").EQ(1)),
+		r1.Rename(titleCaseTup{}),
+		r1.Union(r2),
+		rel.Union(r2),
+		r1.Diff(r2),
+		rel.Diff(r2),
+		r1.Join(r2, extTup{}),
+		rel.Join(r2, extTup{}),
+		r1.GroupBy(groupByTup{}, groupFcn),
+		r1.Map(mapFcn, mapKeys),
+		r1.Extend(extTup2{}, doubleQty2),
+		r1.SemiJoin(orders()),
+		r1.SemiDiff(orders()),
+	}
+	for i, errRel := range errTest {
+		if errRel.Err() != err {
+			t.Errorf("%d did not short circuit error", i)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+// group implements a group expression, which gathers the tuples of a
+// relation that have the same values for some of its attributes into a
+// relation-valued attribute.
+
+package rel
+
+import (
+	"context"
+	"reflect"
+)
+
+// groupExpr is a type that represents grouping the tuples of a source
+// relation by the attributes of the result other than name, where the rest
+// of the attributes of each group are gathered into a relation, which is the
+// value of the attribute name.  Date calls this operation GROUP.  Unlike
+// GroupBy, no function is applied to the groups.
+type groupExpr struct {
+	// the input relation
+	source1 Relation
+
+	// zero is the resulting relation tuple type.  The relation-valued
+	// attribute holds an empty relation with the grouped attributes, so that
+	// the heading of the groups is known before evaluation.
+	zero interface{}
+
+	// name is the relation-valued attribute which holds the groups
+	name Attribute
+
+	// err is the first error encountered during construction or evaluation
+	err error
+}
+
+// groupedRelation returns the relation held by the relation-valued attribute
+// name in the tuple z, or nil if it does not hold one.
+func groupedRelation(z interface{}, name Attribute) Relation {
+	e := reflect.TypeOf(z)
+	idx := attributeField(e, name)
+	if idx == nil || e.FieldByIndex(idx).Type != relationType {
+		return nil
+	}
+	r, _ := reflect.ValueOf(z).FieldByIndex(idx).Interface().(Relation)
+	return r
+}
+
+// zeroRelations returns the zero tuple z2, where each relation-valued
+// attribute which does not hold a relation is given the relation held by the
+// same attribute in the zero tuple of one of the sources, so that the
+// heading of the relations is retained by operations like Project and Join.
+func zeroRelations(z2 interface{}, sources ...Relation) interface{} {
+	e2 := reflect.TypeOf(z2)
+	if tupleKeyType(e2) == e2 {
+		// there are no relation-valued attributes
+		return z2
+	}
+	zero := reflect.New(e2).Elem()
+	zero.Set(reflect.ValueOf(z2))
+	for _, f := range attributeFields(e2) {
+		v := zero.FieldByIndex(f.index)
+		if v.Type() != relationType || !v.IsNil() {
+			continue
+		}
+		for _, r := range sources {
+			if inner := groupedRelation(r.Zero(), f.name); inner != nil {
+				v.Set(reflect.ValueOf(inner))
+				break
+			}
+		}
+	}
+	return zero.Interface()
+}
+
+// groupKeys determines the candidate keys of the groups from the candidate
+// keys of the source.  Within a group, the attributes that the tuples were
+// grouped by are the same, so the rest of the attributes of each key are
+// still unique.  Keys which only have grouping attributes are left out,
+// because they only allow a single tuple in each group, which the keys of
+// all of the attributes allow as well.
+func groupKeys(cKeys1 CandKeys, grouped []Attribute) [][]string {
+	var keys [][]string
+	for _, ck1 := range cKeys1 {
+		var ck2 []string
+		for _, att := range ck1 {
+			if IsSubDomain([]Attribute{att}, grouped) {
+				ck2 = append(ck2, string(att))
+			}
+		}
+		if len(ck2) > 0 {
+			keys = append(keys, ck2)
+		}
+	}
+	return keys
+}
+
+// grouped returns the attributes held in the relation-valued attribute
+func (r1 *groupExpr) grouped() []Attribute {
+	if r := groupedRelation(r1.zero, r1.name); r != nil {
+		return Heading(r)
+	}
+	return nil
+}
+
+// by returns the attributes that the tuples are grouped by
+func (r1 *groupExpr) by() []Attribute {
+	var att []Attribute
+	for _, a := range Heading(r1) {
+		if a != r1.name {
+			att = append(att, a)
+		}
+	}
+	return att
+}
+
+// TupleChan sends each tuple in the relation to a channel
+func (r1 *groupExpr) TupleChan(t interface{}) chan<- struct{} {
+	cancel := make(chan struct{})
+	// reflect on the channel
+	chv := reflect.ValueOf(t)
+	err := EnsureChan(chv.Type(), r1.zero)
+	if err != nil {
+		r1.err = err
+		return cancel
+	}
+	if r1.err != nil {
+		chv.Close()
+		return cancel
+	}
+
+	e1 := reflect.TypeOf(r1.source1.Zero())
+	e2 := reflect.TypeOf(r1.zero)
+	inner := groupedRelation(r1.zero, r1.name)
+	ei := reflect.TypeOf(inner.Zero())
+	keys := groupKeys(r1.source1.CKeys(), Heading(inner))
+
+	// the grouping attributes are taken from the source, and the rest go
+	// into the groups
+	outerMap := fieldMap(e1, e2)
+	delete(outerMap, r1.name)
+	innerMap := fieldMap(e1, ei)
+	nameIdx := attributeField(e2, r1.name)
+
+	body := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e1), 0)
+	bcancel := r1.source1.TupleChan(body.Interface())
+
+	go func(body, res reflect.Value) {
+		// input channels
+		sourceSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: body}
+		canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+		inCases := []reflect.SelectCase{canSel, sourceSel}
+
+		// all of the tuples have to be received before any of the groups are
+		// complete.  The groups are sent in the order they are first seen,
+		// which retains the order of the source on the grouping attributes.
+		groups := make(map[interface{}]int)
+		var gtups, bodies []reflect.Value
+		for {
+			chosen, tup, ok := reflect.Select(inCases)
+			if chosen == 0 {
+				// cancel has been closed, so close the source as well
+				close(bcancel)
+				return
+			}
+			if !ok {
+				// source channel was closed
+				break
+			}
+			gtup := reflect.Indirect(reflect.New(e2))
+			for _, fm := range outerMap {
+				gtup.FieldByIndex(fm.J).Set(tup.FieldByIndex(fm.I))
+			}
+			vtup := reflect.Indirect(reflect.New(ei))
+			for _, fm := range innerMap {
+				vtup.FieldByIndex(fm.J).Set(tup.FieldByIndex(fm.I))
+			}
+			gtupi := tupleKey(gtup)
+			i, exists := groups[gtupi]
+			if !exists {
+				i = len(gtups)
+				groups[gtupi] = i
+				gtups = append(gtups, gtup)
+				bodies = append(bodies, reflect.MakeSlice(reflect.SliceOf(ei), 0, 1))
+			}
+			bodies[i] = reflect.Append(bodies[i], vtup)
+		}
+		if err := r1.source1.Err(); err != nil {
+			r1.err = err
+			res.Close()
+			return
+		}
+
+		// output channels
+		resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+		for i, gtup := range gtups {
+			gtup.FieldByIndex(nameIdx).Set(reflect.ValueOf(New(bodies[i].Interface(), keys)))
+			resSel.Send = gtup
+			chosen, _, _ := reflect.Select([]reflect.SelectCase{canSel, resSel})
+			if chosen == 0 {
+				return
+			}
+		}
+		res.Close()
+	}(body, chv)
+	return cancel
+}
+
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *groupExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *groupExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *groupExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
+// Zero returns the zero value of the relation (a blank tuple)
+func (r1 *groupExpr) Zero() interface{} {
+	return r1.zero
+}
+
+// CKeys is the set of candidate keys in the relation.  There is one tuple
+// for each distinct value of the grouping attributes, so they are a key, as
+// are any keys of the source which only have grouping attributes.
+func (r1 *groupExpr) CKeys() CandKeys {
+	by := r1.by()
+	if len(by) == 0 {
+		// there is at most one group
+		return CandKeys{{r1.name}}
+	}
+	var cKeys CandKeys
+	for _, ck := range r1.source1.CKeys() {
+		if IsSubDomain(ck, by) {
+			cKeys = append(cKeys, ck)
+		}
+	}
+	if len(cKeys) == 0 {
+		cKeys = CandKeys{by}
+	}
+	OrderCandidateKeys(cKeys)
+	return cKeys
+}
+
+// SortOrder is the set of attributes that the relation is sorted on.  The
+// order of the source is retained up to the first grouped attribute.
+func (r1 *groupExpr) SortOrder() []Attribute {
+	return orderPrefix(SortOrder(r1.source1), r1.by())
+}
+
+// GoString returns a text representation of the Relation
+func (r1 *groupExpr) GoString() string {
+	return goStringTabTable(r1)
+}
+
+// String returns a text representation of the Relation
+func (r1 *groupExpr) String() string {
+	return r1.source1.String() + ".Group({" + attributeString(r1.grouped()) + "}->" + string(r1.name) + ")"
+}
+
+// Project creates a new relation with less than or equal degree
+// t2 has to be a new type which is a subdomain of r.
+func (r1 *groupExpr) Project(z2 interface{}) Relation {
+	return Rewrite(r1, NewProject(r1, z2))
+}
+
+// Restrict creates a new relation with less than or equal cardinality
+// p has to be a func(tup T) bool where tup is a subdomain of the input r.
+func (r1 *groupExpr) Restrict(p Predicate) Relation {
+	return Rewrite(r1, NewRestrict(r1, p))
+}
+
+// Rename creates a new relation with new column names
+// z2 has to be a struct with the same number of fields as the input relation
+func (r1 *groupExpr) Rename(z2 interface{}) Relation {
+	return Rewrite(r1, NewRename(r1, z2))
+}
+
+// Union creates a new relation by unioning the bodies of both inputs
+func (r1 *groupExpr) Union(r2 Relation) Relation {
+	return Rewrite(r1, NewUnion(r1, r2))
+}
+
+// Diff creates a new relation by set minusing the two inputs
+func (r1 *groupExpr) Diff(r2 Relation) Relation {
+	return Rewrite(r1, NewDiff(r1, r2))
+}
+
+// Join creates a new relation by performing a natural join on the inputs
+func (r1 *groupExpr) Join(r2 Relation, zero interface{}) Relation {
+	return Rewrite(r1, NewJoin(r1, r2, zero))
+}
+
+// GroupBy creates a new relation by grouping and applying a user defined func
+func (r1 *groupExpr) GroupBy(t2, gfcn interface{}) Relation {
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
+}
+
+// Map creates a new relation by applying a function to tuples in the source
+func (r1 *groupExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *groupExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *groupExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *groupExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *groupExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *groupExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *groupExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *groupExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *groupExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *groupExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *groupExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
+}
+
+// Err returns an error encountered during construction or computation
+func (r1 *groupExpr) Err() error {
+	return r1.err
+}
+
+// Op is the operation that produces the relation
+func (r1 *groupExpr) Op() OpKind {
+	return OpGroup
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *groupExpr) Children() []Relation {
+	return []Relation{r1.source1}
+}
+
+// Grouped is the relation-valued attribute which holds the groups
+func (r1 *groupExpr) Grouped() Attribute {
+	return r1.name
+}
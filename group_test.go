@@ -0,0 +1,294 @@
+package rel
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// supplierOrdersTup has the orders of each supplier
+type supplierOrdersTup struct {
+	SNO    int
+	Orders Relation
+}
+
+// tests for group op
+func TestGroup(t *testing.T) {
+	type ordersTup struct {
+		Orders Relation
+	}
+	type cityPartsTup struct {
+		City  string
+		Parts Relation
+	}
+	type joinTup struct {
+		SNO    int
+		Orders Relation
+		SName  string
+		Status int
+		City   string
+	}
+	type qtyTup struct {
+		PNO int
+		Qty int
+	}
+
+	// test the degrees, cardinality, and string representation
+	rel := orders().Group(supplierOrdersTup{}, "Orders")
+	var relTest = []struct {
+		rel          Relation
+		expectString string
+		expectDeg    int
+		expectCard   int
+	}{
+		{rel, "Relation(PNO, SNO, Qty).Group({PNO, Qty}->Orders)", 2, 6},
+		{rel.Restrict(Attribute("SNO").EQ(2)), "σ{SNO == 2}(Relation(PNO, SNO, Qty).Group({PNO, Qty}->Orders))", 2, 1},
+		{rel.Project(ordersTup{}), "π{Orders}(Relation(PNO, SNO, Qty).Group({PNO, Qty}->Orders))", 1, 6},
+		{rel.Join(suppliers(), joinTup{}), "", 5, 5},
+		{rel.SemiDiff(suppliers()), "", 2, 1},
+		{rel.Union(rel), "", 2, 6},
+		{rel.Diff(rel.Restrict(Attribute("SNO").GT(4))), "", 2, 4},
+		{parts().Group(cityPartsTup{}, "Parts"), "Relation(PNO, PName, Color, Weight, City).Group({PNO, PName, Color, Weight}->Parts)", 2, 3},
+		{rel.Ungroup("Orders"), "Relation(PNO, SNO, Qty).Group({PNO, Qty}->Orders).Ungroup(Orders->{PNO, Qty})", 3, 12},
+	}
+
+	for i, tt := range relTest {
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%d has Err() => %s", i, err.Error())
+			continue
+		}
+		if tt.expectString != "" {
+			if str := tt.rel.String(); str != tt.expectString {
+				t.Errorf("%d has String() => %v, want %v", i, str, tt.expectString)
+			}
+		}
+		if deg := Deg(tt.rel); deg != tt.expectDeg {
+			t.Errorf("%d %s has Deg() => %v, want %v", i, tt.rel, deg, tt.expectDeg)
+		}
+		if card := Card(tt.rel); card != tt.expectCard {
+			t.Errorf("%d %s has Card() => %v, want %v", i, tt.rel, card, tt.expectCard)
+		}
+	}
+
+	// the grouping attributes are the key of the result
+	if ck := rel.CKeys(); !reflect.DeepEqual(ck, CandKeys{{"SNO"}}) {
+		t.Errorf("%s has CKeys() => %v, want [[SNO]]", rel, ck)
+	}
+
+	// the groups hold the rest of the attributes of the source
+	var tups []supplierOrdersTup
+	if err := rel.Restrict(Attribute("SNO").EQ(4)).TupleSlice(&tups); err != nil {
+		t.Fatal(err)
+	}
+	want := New([]qtyTup{{1, 200}, {4, 300}}, [][]string{{"PNO"}})
+	if len(tups) != 1 || !Equal(tups[0].Orders, want) {
+		t.Errorf("%s has tuples %v, want SNO 4 to have orders %v", rel, tups, want)
+	}
+	if ck := tups[0].Orders.CKeys(); !reflect.DeepEqual(ck, CandKeys{{"PNO"}}) {
+		t.Errorf("group has CKeys() => %v, want [[PNO]]", ck)
+	}
+
+	// the zero tuple holds an empty relation with the heading of the groups
+	z := rel.Zero().(supplierOrdersTup)
+	if z.Orders == nil || Card(z.Orders) != 0 || !reflect.DeepEqual(Heading(z.Orders), []Attribute{"PNO", "Qty"}) {
+		t.Errorf("%s has Zero() => %v", rel, z)
+	}
+
+	// relation-valued attributes can be printed
+	if gs := rel.Restrict(Attribute("SNO").EQ(6)).GoString(); !strings.Contains(collapseSpace(gs), "{6, rel.New([]struct { PNO int Qty int }{ {1, 100, }, }), }") {
+		t.Errorf("GoString() => %v, want the orders in it", gs)
+	}
+
+	// test construction errors
+	type missingTup struct {
+		SNO int
+	}
+	type notRelTup struct {
+		SNO    int
+		Orders []orderTup
+	}
+	type badByTup struct {
+		SName  string
+		Orders Relation
+	}
+	type badTypeTup struct {
+		SNO    string
+		Orders Relation
+	}
+	constructTest := []struct {
+		rel Relation
+		err error
+	}{
+		{orders().Group(missingTup{}, "Orders"), &AttributeSubsetError{}},
+		{orders().Group(notRelTup{}, "Orders"), &AttributeTypeError{}},
+		{orders().Group(badByTup{}, "Orders"), &AttributeSubsetError{}},
+		{orders().Group(badTypeTup{}, "Orders"), &AttributeTypeError{}},
+	}
+	for i, tt := range constructTest {
+		err := tt.rel.Err()
+		if err == nil {
+			t.Errorf("%d did not produce a construction error", i)
+			continue
+		}
+		if reflect.TypeOf(err) != reflect.TypeOf(tt.err) {
+			t.Errorf("%d has Err() => %T, want %T", i, err, tt.err)
+		}
+	}
+
+	// test cancellation
+	res := make(chan supplierOrdersTup)
+	cancel := rel.TupleChan(res)
+	close(cancel)
+	select {
+	case <-res:
+		t.Errorf("cancel did not end tuple generation")
+	default:
+		// passed test
+	}
+
+	// test errors
+	err := fmt.Errorf("testing error")
+	r1 := orders().Group(supplierOrdersTup{}, "Orders").(*groupExpr)
+	r1.err = err
+	res = make(chan supplierOrdersTup)
+	_ = r1.TupleChan(res)
+	if _, ok := <-res; ok {
+		t.Errorf("group did not short circuit TupleChan")
+	}
+	errTest := []Relation{
+		r1.Project(ordersTup{}),
+		r1.Restrict(Attribute("SNO").EQ(1)),
+		r1.Union(rel),
+		rel.Union(r1),
+		r1.Diff(rel),
+		r1.Join(suppliers(), joinTup{}),
+		r1.SemiJoin(suppliers()),
+		r1.SemiDiff(suppliers()),
+		r1.Ungroup("Orders"),
+		(&errorRel{orderTup{}, 1, err}).Group(supplierOrdersTup{}, "Orders"),
+	}
+	for i, errRel := range errTest {
+		if errRel.Err() != err {
+			t.Errorf("%d did not short circuit error", i)
+		}
+	}
+
+	// errors in the source are reported after evaluation
+	r2 := (&errorRel{orderTup{}, 1, nil}).Group(supplierOrdersTup{}, "Orders")
+	res = make(chan supplierOrdersTup)
+	r2.TupleChan(res)
+	for _ = range res {
+	}
+	if r2.Err() == nil {
+		t.Errorf("group did not pass along the source error")
+	}
+}
+
+// tests for the equality of relations and relation-valued attributes
+func TestEqual(t *testing.T) {
+	type qtyTup struct {
+		PNO int
+		Qty int
+	}
+	type qtyPnoTup struct {
+		Qty int
+		PNO int
+	}
+	a := New([]qtyTup{{1, 200}, {4, 300}}, nil)
+	var equalTest = []struct {
+		r1, r2 Relation
+		expect bool
+	}{
+		{a, a, true},
+		{a, New([]qtyTup{{4, 300}, {1, 200}}, [][]string{{"PNO"}}), true},
+		{a, New([]qtyPnoTup{{200, 1}, {300, 4}}, nil), true},
+		{a, New([]qtyTup{{1, 200}}, nil), false},
+		{a, New([]qtyTup{{1, 200}, {4, 400}}, nil), false},
+		{a, New([]orderTup{{1, 4, 200}, {4, 4, 300}}, nil), false},
+		{orders(), orders().Group(supplierOrdersTup{}, "Orders").Ungroup("Orders"), true},
+		{orders().Group(supplierOrdersTup{}, "Orders"), orders().Order("Qty").Group(supplierOrdersTup{}, "Orders"), true},
+	}
+	for i, tt := range equalTest {
+		if eq := Equal(tt.r1, tt.r2); eq != tt.expect {
+			t.Errorf("%d Equal(%v, %v) => %v, want %v", i, tt.r1, tt.r2, eq, tt.expect)
+		}
+	}
+
+	// tuple-valued attributes are compared by their attributes, regardless
+	// of the names of their types and fields
+	type point struct {
+		X int
+		Y int
+	}
+	type coord struct {
+		Y    int
+		East int `rel:"X"`
+	}
+	type pointTup struct {
+		ID int
+		P  point
+	}
+	type coordTup struct {
+		P  coord
+		ID int
+	}
+	type pointsTup struct {
+		Points Relation
+	}
+	p := New([]pointTup{{1, point{2, 3}}}, nil)
+	c := New([]coordTup{{coord{3, 2}, 1}}, nil)
+	if !Equal(p, c) {
+		t.Errorf("Equal(%v, %v) => false, want true", p, c)
+	}
+	if Equal(p, New([]coordTup{{coord{2, 3}, 1}}, nil)) {
+		t.Errorf("Equal(%v, %v) => true, want false", p, c)
+	}
+	pp := New([]pointsTup{{p}, {c}}, nil)
+	if card := Card(pp); card != 1 {
+		t.Errorf("%v has Card() => %d, want 1", pp, card)
+	}
+
+	// tuples which hold equal relations are the same tuple
+	type groupTup struct {
+		A int
+		R Relation
+	}
+	r := New([]groupTup{
+		{1, New([]qtyTup{{1, 200}, {4, 300}}, nil)},
+		{1, New([]qtyPnoTup{{300, 4}, {200, 1}}, nil)},
+		{1, New([]qtyTup{{1, 200}}, nil)},
+		{2, New([]qtyTup{{1, 200}}, nil)},
+	}, nil)
+	var distinctTest = []struct {
+		rel        Relation
+		expectCard int
+	}{
+		{r, 3},
+		{r.Union(r), 3},
+		{r.Diff(r.Restrict(Attribute("A").EQ(2))), 2},
+		{r.SemiJoin(r.Restrict(Attribute("A").EQ(2)).Project(struct{ R Relation }{})), 2},
+		{orders().Group(supplierOrdersTup{}, "Orders").Project(struct{ Orders Relation }{}), 6},
+		{r.GroupBy(struct {
+			R Relation
+			A int
+		}{}, func(val <-chan struct{ A int }) struct{ A int } {
+			var res struct{ A int }
+			for v := range val {
+				res.A += v.A
+			}
+			return res
+		}), 2},
+	}
+	for i, tt := range distinctTest {
+		if card := Card(tt.rel); card != tt.expectCard {
+			t.Errorf("%d %s has Card() => %v, want %v", i, tt.rel, card, tt.expectCard)
+		}
+	}
+	// relations can't be spilled, so tuples which hold them are kept in
+	// memory regardless of the budget
+	defer SetMemoryBudget(SetMemoryBudget(1))
+	if card := Card(r.Union(r)); card != 3 {
+		t.Errorf("%s has Card() => %v with a memory budget, want 3", r.Union(r), card)
+	}
+}
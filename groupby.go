@@ -3,11 +3,45 @@
 package rel
 
 import (
+	"context"
 	"reflect"
+	"runtime"
 	"strings"
 	"sync"
 )
 
+// Aggregator is a grouping function which can be computed on parts of a
+// group, and then combined.  It can be provided to GroupBy in place of a
+// function which consumes a channel, which allows the groups to be computed
+// in parallel without a goroutine per group.
+//
+// The partial aggregates (acc) can have any type, and the aggregator is free
+// to modify and return the partial aggregates it is given.
+type Aggregator interface {
+	// ValZero returns a blank tuple of the values that are accumulated.  It
+	// has to be a subdomain of the source relation's tuples.
+	ValZero() interface{}
+
+	// ResZero returns a blank tuple of the result of the aggregation.  It has
+	// to be a subdomain of the GroupBy's result tuples.
+	ResZero() interface{}
+
+	// Init returns a partial aggregate for an empty group.
+	Init() interface{}
+
+	// Accumulate includes a value tuple in a partial aggregate, and returns
+	// the new partial aggregate.
+	Accumulate(acc, val interface{}) interface{}
+
+	// Merge combines two partial aggregates of the same group, and returns
+	// the combined partial aggregate.
+	Merge(acc1, acc2 interface{}) interface{}
+
+	// Result returns the result tuple of a partial aggregate which includes
+	// all of the values in the group.
+	Result(acc interface{}) interface{}
+}
+
 // groupByExpr represents a group by expression
 type groupByExpr struct {
 	source1 Relation
@@ -27,19 +61,23 @@ type groupByExpr struct {
 	// the value of the group after the input channel is closed.
 	gfcn reflect.Value
 
+	// agg is used instead of gfcn if it is not nil
+	agg Aggregator
+
 	// err has the first error encountered during construction or evaluation
 	err error
 }
 
-// the implementation for groupby creates a map from the groups to
-// a set of channels, and then creates those channels as new groups
-// are discovered.  Those channels each have a goroutine that concurrently
-// consumes the channel results (although that might simply be an
+// the implementation for groupby with a grouping function creates a map from
+// the groups to a set of channels, and then creates those channels as new
+// groups are discovered.  Those channels each have a goroutine that
+// concurrently consumes the channel results (although that might simply be an
 // accumulation if the aggregate can't be performed on partial results)
 // and then when all of the values in the intial relation are done, every
 // group chan is closed, which should allow the group go routines to
 // complete their work, and then send a done signal to a channel which
-// can then close the result channel.
+// can then close the result channel.  Groupby with an Aggregator is
+// implemented in aggTupleChan.
 
 // TupleChan sends each tuple in the relation to a channel
 func (r1 *groupByExpr) TupleChan(t interface{}) chan<- struct{} {
@@ -64,6 +102,11 @@ func (r1 *groupByExpr) TupleChan(t interface{}) chan<- struct{} {
 	body := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e1), 0)
 	bcancel := r1.source1.TupleChan(body.Interface())
 
+	if r1.agg != nil {
+		r1.aggTupleChan(body, chv, cancel, bcancel)
+		return cancel
+	}
+
 	// for each of the tuples, extract the group values out and set
 	// the ones that are not in vtup to the values in the tuple.
 	// then, if the tuple does not exist in the groupMap, create a
@@ -83,15 +126,15 @@ func (r1 *groupByExpr) TupleChan(t interface{}) chan<- struct{} {
 		// attributes are found
 		e2 := reflect.TypeOf(r1.zero) // type of the resulting relation's tuples
 		ev := r1.valType              // type of the tuples put into groupby values
-		e2fieldMap := FieldMap(e1, e2)
-		evfieldMap := FieldMap(e1, ev)
+		e2fieldMap := fieldMap(e1, e2)
+		evfieldMap := fieldMap(e1, ev)
 
 		// map from the values to the group (with zeros in the value fields)
 		// I couldn't figure out a way to assign the values into the group
 		// by modifying it using reflection though so we end up allocating a
 		// new element.
 		er := r1.resType
-		rgfieldMap := FieldMap(e2, er)
+		rgfieldMap := fieldMap(e2, er)
 
 		// create the select statement reflections
 		sourceSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: body}
@@ -112,8 +155,8 @@ func (r1 *groupByExpr) TupleChan(t interface{}) chan<- struct{} {
 
 			// this reflection may be a bottleneck, and we may be able to
 			// replace it with a parallel version.
-			gtup, vtup := PartialProject(tup, e2, ev, e2fieldMap, evfieldMap)
-			gtupi := gtup.Interface()
+			gtup, vtup := partialProject(tup, e2, ev, e2fieldMap, evfieldMap)
+			gtupi := tupleKey(gtup)
 			if _, exists := groupMap[gtupi]; !exists {
 				// a new group has been encountered
 				wg.Add(1)
@@ -135,7 +178,7 @@ func (r1 *groupByExpr) TupleChan(t interface{}) chan<- struct{} {
 					vals := r1.gfcn.Call([]reflect.Value{groupChan})
 					// combine the returned values with the group tuple
 					// to create the new complete tuple
-					resSel.Send = CombineTuples(gtup, vals[0], e2, rgfieldMap)
+					resSel.Send = combineTuples(gtup, vals[0], e2, rgfieldMap)
 
 					_, _, _ = reflect.Select([]reflect.SelectCase{canSel, resSel})
 					// we actually don't care about what's been chosen or what
@@ -166,6 +209,117 @@ func (r1 *groupByExpr) TupleChan(t interface{}) chan<- struct{} {
 	return cancel
 }
 
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *groupByExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *groupByExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *groupByExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
+// groupPartial is the partial aggregate of a single group
+type groupPartial struct {
+	// gtup is the group tuple, with zeros in the value fields
+	gtup reflect.Value
+
+	// acc is the partial aggregate from the Aggregator
+	acc interface{}
+}
+
+// aggTupleChan evaluates the groups with the Aggregator.  Each of a set of
+// worker goroutines consumes part of the source, and accumulates the values it
+// receives into its own set of partial groups.  When the source is exhausted,
+// the partial groups are merged together, and the results are sent.
+func (r1 *groupByExpr) aggTupleChan(body, res reflect.Value, cancel chan struct{}, bcancel chan<- struct{}) {
+	mc := runtime.GOMAXPROCS(-1)
+
+	// figure out where in each of the structs the group and value
+	// attributes are found
+	e2 := reflect.TypeOf(r1.zero)
+	ev := r1.valType
+	e2fieldMap := fieldMap(reflect.TypeOf(r1.source1.Zero()), e2)
+	evfieldMap := fieldMap(reflect.TypeOf(r1.source1.Zero()), ev)
+	rgfieldMap := fieldMap(e2, r1.resType)
+
+	canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+
+	shards := make([]map[interface{}]*groupPartial, mc)
+	var wg sync.WaitGroup
+	wg.Add(mc)
+	for i := 0; i < mc; i++ {
+		go func(i int) {
+			defer wg.Done()
+			groups := make(map[interface{}]*groupPartial)
+			shards[i] = groups
+			inCases := []reflect.SelectCase{canSel, {Dir: reflect.SelectRecv, Chan: body}}
+			for {
+				chosen, tup, ok := reflect.Select(inCases)
+				if chosen == 0 || !ok {
+					// cancel channel was closed, or the source completed
+					return
+				}
+				gtup, vtup := partialProject(tup, e2, ev, e2fieldMap, evfieldMap)
+				gtupi := tupleKey(gtup)
+				g, exists := groups[gtupi]
+				if !exists {
+					g = &groupPartial{gtup, r1.agg.Init()}
+					groups[gtupi] = g
+				}
+				g.acc = r1.agg.Accumulate(g.acc, vtup.Interface())
+			}
+		}(i)
+	}
+
+	go func() {
+		wg.Wait()
+		select {
+		case <-cancel:
+			// if we've been cancelled, send it up to the source
+			close(bcancel)
+			return
+		default:
+		}
+
+		// merge the partial groups from each of the workers
+		groups := shards[0]
+		for _, shard := range shards[1:] {
+			for gtupi, g := range shard {
+				if g0, exists := groups[gtupi]; exists {
+					g0.acc = r1.agg.Merge(g0.acc, g.acc)
+				} else {
+					groups[gtupi] = g
+				}
+			}
+		}
+
+		resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+		for _, g := range groups {
+			// the group tuple already has the type of the results, so the
+			// values only have to be filled in
+			combineTuples2(&g.gtup, reflect.ValueOf(r1.agg.Result(g.acc)), rgfieldMap)
+			resSel.Send = g.gtup
+			chosen, _, _ := reflect.Select([]reflect.SelectCase{canSel, resSel})
+			if chosen == 0 {
+				// the source has already completed, so there is nothing to
+				// relay the cancellation to.
+				return
+			}
+		}
+		if err := r1.source1.Err(); err != nil {
+			r1.err = err
+		}
+		res.Close()
+	}()
+}
+
 // Zero returns the zero value of the relation (a blank tuple)
 func (r1 *groupByExpr) Zero() interface{} {
 	return r1.zero
@@ -180,8 +334,8 @@ func (r1 *groupByExpr) CKeys() CandKeys {
 	// just a map) or the group itself.
 
 	e1 := reflect.TypeOf(r1.source1.Zero())
-	e2 := r1.resType // type of the resulting relation's tuples
-	ev := r1.valType // type of the tuples put into groupby values
+	e2 := reflect.TypeOf(r1.zero) // type of the resulting relation's tuples
+	ev := r1.valType              // type of the tuples put into groupby values
 
 	// note: if for some reason this is called on a grouping that includes
 	// a candidate key, then this function should instead act as a map, and
@@ -205,14 +359,19 @@ func (r1 *groupByExpr) CKeys() CandKeys {
 			groupFieldMap[name] = v
 		}
 	}
-	names := FieldNames(e2)
-
-	ck2 := SubsetCandidateKeys(r1.source1.CKeys(), names, groupFieldMap)
-
-	// determine the new names and types
-	cn := FieldNames(e2)
+	ck2 := SubsetCandidateKeys(r1.source1.CKeys(), Heading(r1.source1), groupFieldMap)
 
 	if len(ck2) == 0 {
+		// the group attributes are unique in the result
+		var cn []Attribute
+		for _, name := range FieldNames(e2) {
+			if _, isGroup := groupFieldMap[name]; isGroup {
+				cn = append(cn, name)
+			}
+		}
+		if len(cn) == 0 {
+			cn = FieldNames(e2)
+		}
 		ck2 = append(ck2, cn)
 	}
 
@@ -226,6 +385,20 @@ func (r1 *groupByExpr) GoString() string {
 
 // String returns a text representation of the Relation
 func (r1 *groupByExpr) String() string {
+	if sum, ok := r1.agg.(*summary); ok {
+		var groupAtt []Attribute
+		for _, att := range Heading(r1) {
+			isRes := false
+			for _, a := range sum.aggs {
+				isRes = isRes || a.res == att
+			}
+			if !isRes {
+				groupAtt = append(groupAtt, att)
+			}
+		}
+		return r1.source1.String() + ".Summarize({" + attributeString(groupAtt) + "}->{" + aggregateString(sum.aggs) + "})"
+	}
+
 	h := FieldNames(r1.resType)
 	s := make([]string, len(h))
 	for i, v := range h {
@@ -241,7 +414,7 @@ func (r1 *groupByExpr) String() string {
 // Project creates a new relation with less than or equal degree
 // t2 has to be a new type which is a subdomain of r1.
 func (r1 *groupByExpr) Project(z2 interface{}) Relation {
-	return NewProject(r1, z2)
+	return Rewrite(r1, NewProject(r1, z2))
 }
 
 // Restrict creates a new relation with less than or equal cardinality
@@ -249,43 +422,131 @@ func (r1 *groupByExpr) Project(z2 interface{}) Relation {
 func (r1 *groupByExpr) Restrict(p Predicate) Relation {
 	// TODO(jonlawlor): this can be passed through if the predicate only
 	// depends upon the attributes that are not in valZero
-	return NewRestrict(r1, p)
+	return Rewrite(r1, NewRestrict(r1, p))
 }
 
 // Rename creates a new relation with new column names
 // z2 has to be a struct with the same number of fields as the input relation
 func (r1 *groupByExpr) Rename(z2 interface{}) Relation {
-	return NewRename(r1, z2)
+	return Rewrite(r1, NewRename(r1, z2))
 }
 
 // Union creates a new relation by unioning the bodies of both inputs
 func (r1 *groupByExpr) Union(r2 Relation) Relation {
-	return NewUnion(r1, r2)
+	return Rewrite(r1, NewUnion(r1, r2))
 }
 
 // Diff creates a new relation by set minusing the two inputs
 func (r1 *groupByExpr) Diff(r2 Relation) Relation {
 	// TODO(jonlawlor): this can be rewritten if there are candidate keys
 	// in the groupby are a superset of some candidate keys in the union?
-	return NewDiff(r1, r2)
+	return Rewrite(r1, NewDiff(r1, r2))
 }
 
 // Join creates a new relation by performing a natural join on the inputs
 func (r1 *groupByExpr) Join(r2 Relation, zero interface{}) Relation {
-	return NewJoin(r1, r2, zero)
+	return Rewrite(r1, NewJoin(r1, r2, zero))
 }
 
 // GroupBy creates a new relation by grouping and applying a user defined func
 func (r1 *groupByExpr) GroupBy(t2, gfcn interface{}) Relation {
-	return NewGroupBy(r1, t2, gfcn)
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
 }
 
 // Map creates a new relation by applying a function to tuples in the source
 func (r1 *groupByExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
-	return NewMap(r1, mfcn, ckeystr)
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *groupByExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *groupByExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *groupByExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *groupByExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *groupByExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *groupByExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *groupByExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *groupByExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *groupByExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *groupByExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
 }
 
 // Err returns an error encountered during construction or computation
 func (r1 *groupByExpr) Err() error {
 	return r1.err
 }
+
+// Op is the operation that produces the relation
+func (r1 *groupByExpr) Op() OpKind {
+	return OpGroupBy
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *groupByExpr) Children() []Relation {
+	return []Relation{r1.source1}
+}
+
+// Func is the grouping function, or the Aggregator if one was given instead
+func (r1 *groupByExpr) Func() interface{} {
+	if r1.agg != nil {
+		return r1.agg
+	}
+	if !r1.gfcn.IsValid() {
+		return nil
+	}
+	return r1.gfcn.Interface()
+}
+
+// Aggregates are the aggregates given to Summarize, or nil if the group by
+// was not created by Summarize.
+func (r1 *groupByExpr) Aggregates() []Aggregate {
+	if s, ok := r1.agg.(*summary); ok {
+		return s.aggs
+	}
+	return nil
+}
@@ -168,6 +168,100 @@ func TestGroupBy(t *testing.T) {
 		}
 	}
 }
+
+// qtySum is an Aggregator which sums the Qty attribute
+type qtySum struct{}
+
+type qtyTup struct {
+	Qty int
+}
+
+func (qtySum) ValZero() interface{} { return qtyTup{} }
+func (qtySum) ResZero() interface{} { return qtyTup{} }
+func (qtySum) Init() interface{}    { return 0 }
+func (qtySum) Accumulate(acc, val interface{}) interface{} {
+	return acc.(int) + val.(qtyTup).Qty
+}
+func (qtySum) Merge(acc1, acc2 interface{}) interface{} {
+	return acc1.(int) + acc2.(int)
+}
+func (qtySum) Result(acc interface{}) interface{} {
+	return qtyTup{acc.(int)}
+}
+
+// tests for groupby with an Aggregator
+func TestGroupByAggregator(t *testing.T) {
+	type r1tup struct {
+		PNO int
+		Qty int
+	}
+	wantRes := New([]r1tup{
+		{4, 900},
+		{1, 1300},
+		{2, 700},
+		{3, 200},
+	}, [][]string{})
+	r1 := orders().GroupBy(r1tup{}, qtySum{})
+	if err := r1.Err(); err != nil {
+		t.Fatalf("orders.GroupBy has Err() => %s", err)
+	}
+	if Card(r1.Diff(wantRes)) != 0 || Card(wantRes.Diff(r1)) != 0 {
+		t.Errorf("orders.Groupby = \"%s\", want (ignore order) \"%s\"", r1.GoString(), wantRes.GoString())
+	}
+	if str, want := r1.String(), "Relation(PNO, SNO, Qty).GroupBy({PNO, Qty}->{Qty})"; str != want {
+		t.Errorf("orders.GroupBy has String() => %v, want %v", str, want)
+	}
+
+	// many groups, with partial groups spread across the workers
+	type r2tup struct {
+		Foo int
+		Qty int
+	}
+	tups := make([]r2tup, 10000)
+	for i := range tups {
+		tups[i] = r2tup{i % 1000, i}
+	}
+	type r3tup struct {
+		Foo int
+		N   int
+	}
+	r2 := New(tups, [][]string{}).GroupBy(r2tup{}, qtySum{})
+	if card := Card(r2); card != 1000 {
+		t.Errorf("%s has Card() => %d, want %d", r2, card, 1000)
+	}
+	r3 := Summarize(New(tups, [][]string{}), r3tup{}, Count("N"))
+	res := make(chan r3tup)
+	r3.TupleChan(res)
+	for tup := range res {
+		if tup.N != 10 {
+			t.Errorf("%s has tuple %v, want N = %d", r3, tup, 10)
+		}
+	}
+
+	// test errors
+	type badTup struct {
+		PNO int
+		Foo int
+	}
+	if r4 := orders().GroupBy(badTup{}, qtySum{}); r4.Err() == nil {
+		t.Errorf("orders.GroupBy did not produce an error with an invalid result")
+	}
+	if r4 := suppliers().GroupBy(r1tup{}, qtySum{}); r4.Err() == nil {
+		t.Errorf("suppliers.GroupBy did not produce an error with an invalid value")
+	}
+
+	// test cancellation
+	res2 := make(chan r1tup)
+	cancel := r1.TupleChan(res2)
+	close(cancel)
+	select {
+	case <-res2:
+		t.Errorf("cancel did not end tuple generation")
+	default:
+		// passed test
+	}
+}
+
 func BenchmarkGroupBy(b *testing.B) {
 	type r1tup struct {
 		PNO int
@@ -196,3 +290,55 @@ func BenchmarkGroupBy(b *testing.B) {
 		}
 	}
 }
+
+// manyGroups creates a relation where each tuple is in its own group
+func manyGroups(c int) Relation {
+	type r1tup struct {
+		PNO int
+		Qty int
+	}
+	tups := make([]r1tup, c)
+	for i := range tups {
+		tups[i] = r1tup{i, i}
+	}
+	return New(tups, [][]string{})
+}
+
+func BenchmarkGroupByManyGroups(b *testing.B) {
+	type r1tup struct {
+		PNO int
+		Qty int
+	}
+	groupFcn := func(val <-chan qtyTup) qtyTup {
+		res := qtyTup{}
+		for vi := range val {
+			res.Qty += vi.Qty
+		}
+		return res
+	}
+	r1 := manyGroups(10000).GroupBy(r1tup{}, groupFcn)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// each iteration produces 10000 tuples
+		t := make(chan r1tup)
+		r1.TupleChan(t)
+		for _ = range t {
+		}
+	}
+}
+
+func BenchmarkGroupByAggregator(b *testing.B) {
+	type r1tup struct {
+		PNO int
+		Qty int
+	}
+	r1 := manyGroups(10000).GroupBy(r1tup{}, qtySum{})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// each iteration produces 10000 tuples
+		t := make(chan r1tup)
+		r1.TupleChan(t)
+		for _ = range t {
+		}
+	}
+}
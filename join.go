@@ -3,6 +3,7 @@
 package rel
 
 import (
+	"context"
 	"reflect"
 	"runtime"
 	"sync"
@@ -20,9 +21,142 @@ type joinExpr struct {
 	err error
 }
 
-// This implementation of join uses a nested loop join, which is definitely
-// slower and in most cases less memory efficient than a merge join.  However,
-// I haven't implemented sorting yet so it was much easier to implement.
+// joinAlgorithm identifies the way that the tuples in a join are matched.
+type joinAlgorithm int
+
+const (
+	// symmetricHashJoin hashes the tuples from both sources as they arrive,
+	// and compares each new tuple against the tuples with the same join
+	// values that have already been received from the opposite source.
+	symmetricHashJoin joinAlgorithm = iota
+
+	// hashJoin1 consumes all of source1 into a hash table, and then probes
+	// that table with each of the tuples in source2.
+	hashJoin1
+
+	// hashJoin2 consumes all of source2 into a hash table, and then probes
+	// that table with each of the tuples in source1.
+	hashJoin2
+
+	// mergeJoin reads both sources in order of the join attributes, and
+	// only has to hold the tuples with the current join values in memory.
+	mergeJoin
+)
+
+// String returns the name of the join algorithm
+func (alg joinAlgorithm) String() string {
+	switch alg {
+	case hashJoin1, hashJoin2:
+		return "hash join"
+	case mergeJoin:
+		return "merge join"
+	default:
+		return "symmetric hash join"
+	}
+}
+
+// algorithm determines which join algorithm will be used to evaluate the
+// join.  If both of the sources are sorted on the join attributes, then they
+// are merged.  If the attributes that the sources have in common include one
+// of the candidate keys of a source, then that source can only have a single
+// tuple for each distinct set of join values, so it is the smaller side and it
+// is used to build the hash table.  If both sources have a candidate key in
+// the join attributes, the source with the lower degree is used, because it
+// uses less memory per tuple.  Otherwise both sides have to be hashed as they
+// arrive.  If a memory budget is set, then the hash joins are not used,
+// because they have to hold all of one source in memory, but the symmetric
+// hash join can spill its tuples to disk.
+func (r1 *joinExpr) algorithm() joinAlgorithm {
+	if r1.mergeOrder() != nil {
+		return mergeJoin
+	}
+	if MemoryBudget() > 0 {
+		return symmetricHashJoin
+	}
+	h1 := Heading(r1.source1)
+	h2 := Heading(r1.source2)
+	common := make([]Attribute, 0, len(h1))
+	for name := range AttributeMap(h1, h2) {
+		common = append(common, name)
+	}
+	cover1 := coversCandKey(common, r1.source1.CKeys())
+	cover2 := coversCandKey(common, r1.source2.CKeys())
+	switch {
+	case cover1 && cover2:
+		if len(h2) < len(h1) {
+			return hashJoin2
+		}
+		return hashJoin1
+	case cover1:
+		return hashJoin1
+	case cover2:
+		return hashJoin2
+	}
+	return symmetricHashJoin
+}
+
+// mergeOrder returns the join attributes in the order that both of the
+// sources are sorted on, or nil if the sources are not both sorted on all of
+// the join attributes.
+func (r1 *joinExpr) mergeOrder() []Attribute {
+	map12 := AttributeMap(Heading(r1.source1), Heading(r1.source2))
+	ord1 := SortOrder(r1.source1)
+	ord2 := SortOrder(r1.source2)
+	n := len(map12)
+	if n == 0 || len(ord1) < n || !isOrderPrefix(ord1[:n], ord2) {
+		return nil
+	}
+	seen := make(map[Attribute]struct{})
+	for _, att := range ord1[:n] {
+		if _, isJoin := map12[att]; !isJoin {
+			return nil
+		}
+		seen[att] = struct{}{}
+	}
+	if len(seen) != n {
+		return nil
+	}
+	return ord1[:n]
+}
+
+// coversCandKey returns true if any of the candidate keys are a subdomain of
+// the input attributes.
+func coversCandKey(att []Attribute, cKeys CandKeys) bool {
+	for _, ck := range cKeys {
+		if IsSubDomain(ck, att) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinIndex provides the field indexes of the join attributes in each of the
+// source tuples, in a consistent order, along with the type of the keys that
+// will be used in the hash tables.
+func joinIndex(map12 map[Attribute]fieldIndex) (idx1, idx2 [][]int, keyType reflect.Type) {
+	for _, fm := range map12 {
+		idx1 = append(idx1, fm.I)
+		idx2 = append(idx2, fm.J)
+	}
+	// the key is an array of interfaces so that it compares the same way as
+	// PartialEquals does
+	keyType = reflect.ArrayOf(len(idx1), reflect.TypeOf((*interface{})(nil)).Elem())
+	return
+}
+
+// joinKey extracts the values of the join attributes from a tuple into a
+// comparable value, which can be used as a map key.
+func joinKey(rtup reflect.Value, idx [][]int, keyType reflect.Type) interface{} {
+	key := reflect.Indirect(reflect.New(keyType))
+	for k, i := range idx {
+		if v := rtup.FieldByIndex(i); v.Type() == relationType || v.Kind() == reflect.Struct {
+			key.Index(k).Set(reflect.ValueOf(valueKey(v)))
+		} else {
+			key.Index(k).Set(v)
+		}
+	}
+	return key.Interface()
+}
 
 // TupleChan sends each tuple in the relation to a channel
 func (r1 *joinExpr) TupleChan(t interface{}) chan<- struct{} {
@@ -42,39 +176,33 @@ func (r1 *joinExpr) TupleChan(t interface{}) chan<- struct{} {
 	mc := runtime.GOMAXPROCS(-1)
 	e3 := reflect.TypeOf(r1.zero)
 
-	// create indexes between the three headings
-	h1 := Heading(r1.source1)
-	h2 := Heading(r1.source2)
-	h3 := Heading(r1)
-
-	map12 := AttributeMap(h1, h2) // used to determine equality
-	map31 := AttributeMap(h3, h1) // used to construct returned values
-	map32 := AttributeMap(h3, h2) // used to construct returned values
-
 	// the types of the source tuples
 	e1 := reflect.TypeOf(r1.source1.Zero())
 	e2 := reflect.TypeOf(r1.source2.Zero())
 
+	// create indexes between the three tuple types
+	map12 := fieldMap(e1, e2) // used to determine equality
+	map31 := fieldMap(e3, e1) // used to construct returned values
+	map32 := fieldMap(e3, e2) // used to construct returned values
+	idx1, idx2, keyType := joinIndex(map12)
+
 	// create channels over the body of the source relations
 	body1 := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e1), 0)
 	bcancel1 := r1.source1.TupleChan(body1.Interface())
 	body2 := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e2), 0)
 	bcancel2 := r1.source2.TupleChan(body2.Interface())
 
-	// Create the memory of previously sent tuples so that the joins can
-	// continue to compare against old values.
-	var (
-		mu   sync.Mutex
-		mem1 []reflect.Value
-		mem2 []reflect.Value
-	)
+	// combine creates a result tuple out of matching source tuples
+	combine := func(rtup1, rtup2 reflect.Value) reflect.Value {
+		tup3 := reflect.Indirect(reflect.New(e3))
+		combineTuples2(&tup3, rtup1, map31)
+		combineTuples2(&tup3, rtup2, map32)
+		return tup3
+	}
 
-	// wg is used to signal when each of the worker goroutines finishes
-	// processing the join operation
-	var wg sync.WaitGroup
-	wg.Add(mc)
-	go func(res reflect.Value) {
-		wg.Wait()
+	// finish either relays cancellation to the sources, or closes the
+	// results after all of the work is done
+	finish := func(res reflect.Value) {
 		// if we've been cancelled, send it up to the source
 		select {
 		case <-cancel:
@@ -88,11 +216,205 @@ func (r1 *joinExpr) TupleChan(t interface{}) chan<- struct{} {
 			}
 			res.Close()
 		}
-	}(chv)
+	}
+
+	switch r1.algorithm() {
+	case mergeJoin:
+		ord := r1.mergeOrder()
+		go r1.mergeJoin(body1, body2, chv, orderIndex(e1, ord), orderIndex(e2, ord), cancel, combine, finish)
+	case hashJoin1:
+		go r1.hashJoin(body1, body2, chv, idx1, idx2, keyType, cancel, mc, combine, finish)
+	case hashJoin2:
+		go r1.hashJoin(body2, body1, chv, idx2, idx1, keyType, cancel, mc,
+			func(rtup2, rtup1 reflect.Value) reflect.Value {
+				return combine(rtup1, rtup2)
+			}, finish)
+	default:
+		r1.symmetricHashJoin(body1, body2, chv, idx1, idx2, keyType, cancel, mc, combine, finish)
+	}
+	return cancel
+}
+
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *joinExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *joinExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *joinExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
+// hashJoin reads all of the tuples in the build body into a hash table, and
+// then concurrently probes the table with the tuples from the probe body.
+// This should be used when the build body can only have a single tuple for
+// each distinct set of join values.
+func (r1 *joinExpr) hashJoin(build, probe, res reflect.Value, buildIdx, probeIdx [][]int, keyType reflect.Type, cancel chan struct{}, mc int, combine func(btup, ptup reflect.Value) reflect.Value, finish func(res reflect.Value)) {
+	canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+
+	// build the hash table.  Nothing can be sent until it is complete.
+	table := make(map[interface{}]reflect.Value)
+	buildSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: build}
+	inCases := []reflect.SelectCase{canSel, buildSel}
+	for {
+		chosen, rtup, ok := reflect.Select(inCases)
+		if chosen == 0 || !ok {
+			// cancel channel was closed, or the build body completed
+			break
+		}
+		table[joinKey(rtup, buildIdx, keyType)] = rtup
+	}
+
+	// wg is used to signal when each of the worker goroutines finishes
+	// probing the hash table
+	var wg sync.WaitGroup
+	wg.Add(mc)
+	for i := 0; i < mc; i++ {
+		go func() {
+			defer wg.Done()
+			// input channels
+			probeSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: probe}
+			inCases := []reflect.SelectCase{canSel, probeSel}
+
+			// output channels
+			resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+			for {
+				chosen, rtup, ok := reflect.Select(inCases)
+				if chosen == 0 || !ok {
+					// cancel channel was closed, or the probe body completed
+					return
+				}
+				btup, match := table[joinKey(rtup, probeIdx, keyType)]
+				if !match {
+					continue
+				}
+				resSel.Send = combine(btup, rtup)
+				chosen, _, _ = reflect.Select([]reflect.SelectCase{canSel, resSel})
+				if chosen == 0 {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	finish(res)
+}
+
+// mergeJoin reads tuples from both bodies, which have to be sorted on the
+// join attributes, and combines the runs of tuples with equal join values.
+// The results are sent in order of the join attributes.
+func (r1 *joinExpr) mergeJoin(b1, b2, res reflect.Value, idx1, idx2 [][]int, cancel chan struct{}, combine func(rtup1, rtup2 reflect.Value) reflect.Value, finish func(res reflect.Value)) {
+	canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+	source1Sel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: b1}
+	source2Sel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: b2}
+	resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+
+	// recv retrieves the next tuple from a source.  The second result is
+	// false if the source has completed or if the join was cancelled.
+	cancelled := false
+	recv := func(sourceSel reflect.SelectCase) (reflect.Value, bool) {
+		chosen, rtup, ok := reflect.Select([]reflect.SelectCase{canSel, sourceSel})
+		if chosen == 0 {
+			cancelled = true
+			return rtup, false
+		}
+		return rtup, ok
+	}
+
+	// run reads all of the tuples in a source with the same join values as
+	// the first tuple, and then returns them along with the first tuple
+	// which has different join values.
+	run := func(rtup reflect.Value, sourceSel reflect.SelectCase, idx [][]int) ([]reflect.Value, reflect.Value, bool) {
+		tups := []reflect.Value{rtup}
+		for {
+			next, ok := recv(sourceSel)
+			if !ok || compareTuples(rtup, next, idx, idx) != 0 {
+				return tups, next, ok
+			}
+			tups = append(tups, next)
+		}
+	}
+
+	rtup1, ok1 := recv(source1Sel)
+	rtup2, ok2 := recv(source2Sel)
+	for ok1 && ok2 {
+		switch c := compareTuples(rtup1, rtup2, idx1, idx2); {
+		case c < 0:
+			rtup1, ok1 = recv(source1Sel)
+		case c > 0:
+			rtup2, ok2 = recv(source2Sel)
+		default:
+			var run1, run2 []reflect.Value
+			run1, rtup1, ok1 = run(rtup1, source1Sel, idx1)
+			run2, rtup2, ok2 = run(rtup2, source2Sel, idx2)
+			if cancelled {
+				break
+			}
+			for _, mtup1 := range run1 {
+				for _, mtup2 := range run2 {
+					resSel.Send = combine(mtup1, mtup2)
+					chosen, _, _ := reflect.Select([]reflect.SelectCase{canSel, resSel})
+					if chosen == 0 {
+						finish(res)
+						return
+					}
+				}
+			}
+		}
+	}
+	// consume the remainder of the other source so that it can complete
+	for ok1 {
+		_, ok1 = recv(source1Sel)
+	}
+	for ok2 {
+		_, ok2 = recv(source2Sel)
+	}
+	finish(res)
+}
+
+// symmetricHashJoin concurrently reads tuples from both bodies, and keeps a
+// hash table of the tuples received from each.  Each new tuple is compared
+// against the tuples with the same join values that were received from the
+// opposite body.  It is used when either body may contain several tuples with
+// the same join values.
+func (r1 *joinExpr) symmetricHashJoin(b1, b2, res reflect.Value, idx1, idx2 [][]int, keyType reflect.Type, cancel chan struct{}, mc int, combine func(rtup1, rtup2 reflect.Value) reflect.Value, finish func(res reflect.Value)) {
+	// Create the memory of previously sent tuples so that the joins can
+	// continue to compare against old values.
+	var mu sync.Mutex
+	mem := newJoinTable(b1.Type().Elem(), b2.Type().Elem(), idx1, idx2, keyType)
+
+	// wg is used to signal when each of the worker goroutines finishes
+	// processing the join operation
+	var wg sync.WaitGroup
+	wg.Add(mc)
+	go func() {
+		wg.Wait()
+		select {
+		case <-cancel:
+		default:
+			// join the tuples that were deferred if the memory budget was
+			// exceeded
+			mem.flush(func(rtup1, rtup2 reflect.Value) bool {
+				return sendTuple(res, cancel, combine(rtup1, rtup2))
+			})
+			if mem.err != nil {
+				r1.err = mem.err
+			}
+		}
+		mem.close()
+		finish(res)
+	}()
 
 	// create a go routine that generates the join for each of the input tuples
 	for i := 0; i < mc; i++ {
-		go func(b1, b2, res reflect.Value) {
+		go func() {
+			defer wg.Done()
 			// input channels
 			source1Sel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: b1}
 			source2Sel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: b2}
@@ -103,16 +425,14 @@ func (r1 *joinExpr) TupleChan(t interface{}) chan<- struct{} {
 			// output channels
 			resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
 
-			mtups := []reflect.Value{}
-
 			openSources := 2
 			for openSources > 0 {
 				chosen, rtup, ok := reflect.Select(inCases)
 				if chosen == 0 {
 					// cancel channel was closed
-					break
+					return
 				}
-				if chosen > 0 && !ok {
+				if !ok {
 					// one of the bodies completed
 					// TODO(jonlawlor): remove memory for the other body, because
 					// we won't have anything to compare it to from now on.
@@ -122,59 +442,30 @@ func (r1 *joinExpr) TupleChan(t interface{}) chan<- struct{} {
 				}
 
 				// If we've gotten this far, then one of the bodies has
-				// produced a new tuple.
-
-				// lock both memories
+				// produced a new tuple.  Add it to the memory of that body,
+				// and retrieve the tuples from the opposite body that have
+				// the same join values.
+				var mtups []reflect.Value
 				mu.Lock()
-				// depending on which body sent a value, append the tuple to
-				// that memory
-				if chosen == 1 {
-					mem1 = append(mem1, rtup)
-					mtups = mem2[:]
-				} else {
-					mem2 = append(mem2, rtup)
-					mtups = mem1[:]
-				}
+				mtups = mem.add(chosen-1, rtup)
 				mu.Unlock()
 
 				// Send tuples that match previously retrieved tuples in
 				// the opposite relation.
-				if chosen == 1 {
-					for _, rtup2 := range mtups {
-						if PartialEquals(rtup, rtup2, map12) {
-							tup3 := reflect.Indirect(reflect.New(e3))
-							CombineTuples2(&tup3, rtup, map31)
-							CombineTuples2(&tup3, rtup2, map32)
-
-							resSel.Send = tup3
-							chosen, _, ok = reflect.Select([]reflect.SelectCase{canSel, resSel})
-							if chosen == 0 {
-								openSources = 0
-								break
-							}
-						}
+				for _, mtup := range mtups {
+					if chosen == 1 {
+						resSel.Send = combine(rtup, mtup)
+					} else {
+						resSel.Send = combine(mtup, rtup)
 					}
-				} else {
-					for _, rtup1 := range mtups {
-						if PartialEquals(rtup1, rtup, map12) {
-							tup3 := reflect.Indirect(reflect.New(e3))
-							CombineTuples2(&tup3, rtup1, map31)
-							CombineTuples2(&tup3, rtup, map32)
-							resSel.Send = tup3
-							chosen, _, ok = reflect.Select([]reflect.SelectCase{canSel, resSel})
-							if chosen == 0 {
-								openSources = 0
-								break
-							}
-						}
+					sent, _, _ := reflect.Select([]reflect.SelectCase{canSel, resSel})
+					if sent == 0 {
+						return
 					}
 				}
 			}
-			wg.Done()
-		}(body1, body2, chv)
+		}()
 	}
-
-	return cancel
 }
 
 // Zero returns the zero value of the relation (a blank tuple)
@@ -211,6 +502,15 @@ func (r1 *joinExpr) CKeys() CandKeys {
 	return cKeysRes
 }
 
+// SortOrder is the set of attributes that the relation is sorted on.  Only
+// merge joins produce sorted results.
+func (r1 *joinExpr) SortOrder() []Attribute {
+	if r1.algorithm() != mergeJoin {
+		return nil
+	}
+	return orderPrefix(r1.mergeOrder(), Heading(r1))
+}
+
 // GoString returns a text representation of the Relation
 func (r1 *joinExpr) GoString() string {
 	return r1.source1.GoString() + ".Join(" + r1.source2.GoString() + ")"
@@ -227,7 +527,7 @@ func (r1 *joinExpr) Project(z2 interface{}) Relation {
 	// TODO(jonlawlor): this can be sped up if we compare the candidate keys
 	// used in the relation to the new domain, along with the source relations
 	// domains.
-	return NewProject(r1, z2)
+	return Rewrite(r1, NewProject(r1, z2))
 }
 
 // Restrict creates a new relation with less than or equal cardinality
@@ -235,59 +535,109 @@ func (r1 *joinExpr) Project(z2 interface{}) Relation {
 // This can be rewritten if the predicate is a subdomain of either source
 // relation.
 func (r1 *joinExpr) Restrict(p Predicate) Relation {
-	// decompose compound predicates
-	if andPred, ok := p.(AndPred); ok {
-		// this covers some theta joins
-		return r1.Restrict(andPred.P1).Restrict(andPred.P2)
-	}
-
-	dom := p.Domain()
-	h1 := Heading(r1.source1)
-	h2 := Heading(r1.source2)
-	if IsSubDomain(dom, h1) {
-		if IsSubDomain(dom, h2) {
-			return r1.source1.Restrict(p).Join(r1.source2.Restrict(p), r1.zero)
-		}
-		return r1.source1.Restrict(p).Join(r1.source2, r1.zero)
-	}
-	if IsSubDomain(dom, h2) {
-		return r1.source1.Join(r1.source2.Restrict(p), r1.zero)
-	}
-	return NewRestrict(r1, p)
+	return Rewrite(r1, NewRestrict(r1, p))
 }
 
 // Rename creates a new relation with new column names
 // z2 has to be a struct with the same number of fields as the input relation
 func (r1 *joinExpr) Rename(z2 interface{}) Relation {
-	return NewRename(r1, z2)
+	return Rewrite(r1, NewRename(r1, z2))
 }
 
 // Union creates a new relation by unioning the bodies of both inputs
 func (r1 *joinExpr) Union(r2 Relation) Relation {
-	return NewUnion(r1, r2)
+	return Rewrite(r1, NewUnion(r1, r2))
 }
 
 // Diff creates a new relation by set minusing the two inputs
 func (r1 *joinExpr) Diff(r2 Relation) Relation {
-	return NewDiff(r1, r2)
+	return Rewrite(r1, NewDiff(r1, r2))
 }
 
 // Join creates a new relation by performing a natural join on the inputs
 func (r1 *joinExpr) Join(r2 Relation, zero interface{}) Relation {
-	return NewJoin(r1, r2, zero)
+	return Rewrite(r1, NewJoin(r1, r2, zero))
 }
 
 // GroupBy creates a new relation by grouping and applying a user defined func
 func (r1 *joinExpr) GroupBy(t2, gfcn interface{}) Relation {
-	return NewGroupBy(r1, t2, gfcn)
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
 }
 
 // Map creates a new relation by applying a function to tuples in the source
 func (r1 *joinExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
-	return NewMap(r1, mfcn, ckeystr)
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *joinExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *joinExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *joinExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *joinExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *joinExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *joinExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *joinExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *joinExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *joinExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *joinExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
 }
 
 // Err returns an error encountered during construction or computation
 func (r1 *joinExpr) Err() error {
 	return r1.err
 }
+
+// Op is the operation that produces the relation
+func (r1 *joinExpr) Op() OpKind {
+	return OpJoin
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *joinExpr) Children() []Relation {
+	return []Relation{r1.source1, r1.source2}
+}
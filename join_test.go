@@ -2,6 +2,7 @@ package rel
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -186,6 +187,141 @@ func TestJoin(t *testing.T) {
 	}
 }
 
+// tests for the choice of join algorithm
+func TestJoinAlgorithm(t *testing.T) {
+	type partOrderTup struct {
+		PNO    int
+		PName  string
+		Color  string
+		Weight float64
+		City   string
+		SNO    int
+		Qty    int
+	}
+	type supplierPartTup struct {
+		SNO    int
+		SName  string
+		Status int
+		City   string
+		PNO    int
+		PName  string
+		Color  string
+		Weight float64
+	}
+	type cityTup struct {
+		SNO  int
+		City string
+	}
+	type pnoTup struct {
+		PNO int
+	}
+	type snoTup struct {
+		SNO int
+	}
+	type crossTup struct {
+		PNO int
+		SNO int
+	}
+
+	var algTest = []struct {
+		rel         Relation
+		expectAlg   joinAlgorithm
+		expectCard  int
+		expectError bool
+	}{
+		{parts().Join(orders(), partOrderTup{}), hashJoin1, 12, false},
+		{orders().Join(parts(), partOrderTup{}), hashJoin2, 12, false},
+		{suppliers().Join(parts(), supplierPartTup{}), symmetricHashJoin, 10, false},
+		{suppliers().Join(suppliers().Project(cityTup{}), supplierTup{}), hashJoin2, 5, false},
+		{suppliers().Project(cityTup{}).Join(suppliers(), supplierTup{}), hashJoin1, 5, false},
+		{parts().Project(pnoTup{}).Join(suppliers().Project(snoTup{}), crossTup{}), symmetricHashJoin, 30, false},
+		{orders().Restrict(Attribute("Qty").GT(300)).Join(parts(), partOrderTup{}), hashJoin2, 3, false},
+		{parts().Order("PNO").Join(orders().Order("PNO", "SNO"), partOrderTup{}), mergeJoin, 12, false},
+		{suppliers().Order("City").Join(parts().Order("City", "PNO"), supplierPartTup{}), mergeJoin, 10, false},
+		{orders().Order("SNO").Join(parts().Order("PNO"), partOrderTup{}), hashJoin2, 12, false},
+		{parts().Order("PNO").Restrict(Attribute("PNO").LT(4)).Join(orders().Order("PNO"), partOrderTup{}), mergeJoin, 9, false},
+	}
+	for i, tt := range algTest {
+		r := tt.rel.(*joinExpr)
+		if alg := r.algorithm(); alg != tt.expectAlg {
+			t.Errorf("%d %s has algorithm() => %v, want %v", i, r, alg, tt.expectAlg)
+		}
+		if card := Card(r); card != tt.expectCard {
+			t.Errorf("%d %s has Card() => %v, want %v", i, r, card, tt.expectCard)
+		}
+	}
+
+	// merge joins produce results in order of the join attributes
+	r1 := suppliers().Order("City").Join(parts().Order("City"), supplierPartTup{})
+	if ord := SortOrder(r1); len(ord) != 1 || ord[0] != "City" {
+		t.Errorf("%s has SortOrder() => %v, want [City]", r1, ord)
+	}
+	res := make(chan supplierPartTup)
+	r1.TupleChan(res)
+	prev := ""
+	for tup := range res {
+		if tup.City < prev {
+			t.Errorf("%s sent %v after %v", r1, tup.City, prev)
+		}
+		prev = tup.City
+	}
+	if ord := SortOrder(parts().Join(orders(), partOrderTup{})); ord != nil {
+		t.Errorf("hash join has SortOrder() => %v, want nil", ord)
+	}
+
+	// test cancellation while the hash table is being built, and while it
+	// is being probed, and during a merge
+	for i, r := range []Relation{
+		parts().Join(orders(), partOrderTup{}),
+		suppliers().Join(parts(), supplierPartTup{}),
+		suppliers().Order("City").Join(parts().Order("City"), supplierPartTup{}),
+	} {
+		e := reflect.TypeOf(r.Zero())
+		body := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e), 0)
+		cancel := r.TupleChan(body.Interface())
+		if _, ok := body.Recv(); !ok {
+			t.Errorf("%d %s closed before sending a tuple", i, r)
+		}
+		close(cancel)
+		if _, ok := body.TryRecv(); ok {
+			t.Errorf("%d %s cancel did not end tuple generation", i, r)
+		}
+	}
+}
+
+func BenchmarkHashJoin(b *testing.B) {
+	type leftTup struct {
+		Foo int
+		Bar string
+	}
+	type rightTup struct {
+		Foo int
+		Baz int
+	}
+	type resTup struct {
+		Foo int
+		Bar string
+		Baz int
+	}
+	left := make([]leftTup, 1000)
+	right := make([]rightTup, 10000)
+	for i := range left {
+		left[i] = leftTup{i, "test"}
+	}
+	for i := range right {
+		right[i] = rightTup{i % 1000, i}
+	}
+	r1 := New(left, [][]string{[]string{"Foo"}}).Join(New(right, [][]string{[]string{"Baz"}}), resTup{})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// each iteration produces 10000 tuples
+		t := make(chan resTup)
+		r1.TupleChan(t)
+		for _ = range t {
+		}
+	}
+}
+
 func BenchmarkJoin(b *testing.B) {
 	type restup struct {
 		PNO    int     // from the parts & orders tables
@@ -0,0 +1,131 @@
+package json
+
+import (
+	"bufio"
+	stdjson "encoding/json"
+	"io"
+	"reflect"
+
+	"github.com/jonlawlor/rel"
+)
+
+// Document is the JSON representation of a relation, which has the heading,
+// the types of the attributes, and the candidate keys of the relation along
+// with its body.  The types are the names of the go types of the attributes,
+// as given by reflect.Type's String method.
+type Document struct {
+	Heading []rel.Attribute    `json:"heading"`
+	Types   []string           `json:"types"`
+	CKeys   rel.CandKeys       `json:"ckeys"`
+	Body    stdjson.RawMessage `json:"body"`
+}
+
+// TypeError represents an error that occurs when the type of an attribute in
+// a relation document does not match the type of the tuples it is read into.
+type TypeError struct {
+	Attribute rel.Attribute
+	Expected  string
+	Found     string
+}
+
+func (e *TypeError) Error() string {
+	return "rel/json: expected type '" + e.Expected + "' for attribute '" + string(e.Attribute) + "', found '" + e.Found + "'"
+}
+
+// typeNames returns the names of the types of the fields of e
+func typeNames(e reflect.Type) []string {
+	types := rel.FieldTypes(e)
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.String()
+	}
+	return names
+}
+
+// WriteDocument writes the relation to w as a relation document.  The body
+// is written as the relation is evaluated.  It returns the first error
+// encountered while evaluating the relation or writing its tuples.
+func WriteDocument(w io.Writer, r rel.Relation) error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+	head, err := stdjson.Marshal(Document{
+		Heading: rel.Heading(r),
+		Types:   typeNames(reflect.TypeOf(r.Zero())),
+		CKeys:   r.CKeys(),
+		Body:    stdjson.RawMessage("[]"),
+	})
+	if err != nil {
+		return err
+	}
+
+	// the body is always last, so everything up to its opening bracket can
+	// be written at once
+	bw := bufio.NewWriter(w)
+	bw.Write(head[:len(head)-len("]}")])
+	tups, errf := rel.Tuples(r)
+	first := true
+	for tup := range tups {
+		b, err := stdjson.Marshal(tup)
+		if err != nil {
+			return err
+		}
+		if !first {
+			bw.WriteByte(',')
+		}
+		first = false
+		bw.Write(b)
+	}
+	if err := errf(); err != nil {
+		return err
+	}
+	bw.WriteString("]}\n")
+	return bw.Flush()
+}
+
+// ReadDocument reads a relation document from r, and returns a relation with
+// tuples of the same type as zero, as though it were created with rel.New.
+// It returns an error if the document can't be decoded, or if its heading or
+// attribute types do not match zero.
+func ReadDocument(r io.Reader, zero interface{}) (rel.Relation, error) {
+	var doc Document
+	if err := stdjson.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	e := reflect.TypeOf(zero)
+	names := rel.FieldNames(e)
+	if err := rel.EnsureSameDomain(doc.Heading, names); err != nil {
+		return nil, err
+	}
+	if len(doc.Types) != len(doc.Heading) {
+		return nil, &rel.DegreeError{Expected: len(doc.Heading), Found: len(doc.Types)}
+	}
+	types := make(map[rel.Attribute]string, len(doc.Heading))
+	for i, att := range doc.Heading {
+		types[att] = doc.Types[i]
+	}
+	for i, name := range typeNames(e) {
+		if found := types[names[i]]; found != name {
+			return nil, &TypeError{names[i], name, found}
+		}
+	}
+
+	ckeystr := make([][]string, len(doc.CKeys))
+	for i, ck := range doc.CKeys {
+		if err := rel.EnsureSubDomain(ck, names); err != nil {
+			return nil, err
+		}
+		ckeystr[i] = make([]string, len(ck))
+		for j, att := range ck {
+			ckeystr[i][j] = string(att)
+		}
+	}
+
+	body := reflect.New(reflect.SliceOf(e))
+	if len(doc.Body) > 0 {
+		if err := stdjson.Unmarshal(doc.Body, body.Interface()); err != nil {
+			return nil, err
+		}
+	}
+	return rel.New(body.Elem().Interface(), ckeystr), nil
+}
@@ -0,0 +1,99 @@
+package json
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jonlawlor/rel"
+)
+
+func TestDocument(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDocument(&buf, parts()); err != nil {
+		t.Fatal(err)
+	}
+	var doc Document
+	if err := stdjson.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("WriteDocument() wrote invalid JSON %q: %v", buf.String(), err)
+	}
+	if want := rel.Heading(parts()); !reflect.DeepEqual(doc.Heading, want) {
+		t.Errorf("Heading => %v, want %v", doc.Heading, want)
+	}
+	if want := []string{"int", "string", "string", "float64", "string"}; !reflect.DeepEqual(doc.Types, want) {
+		t.Errorf("Types => %v, want %v", doc.Types, want)
+	}
+	if want := (rel.CandKeys{{"PNO"}}); !reflect.DeepEqual(doc.CKeys, want) {
+		t.Errorf("CKeys => %v, want %v", doc.CKeys, want)
+	}
+
+	// round trip
+	r, err := ReadDocument(&buf, partTup{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.CKeys(), parts().CKeys()) {
+		t.Errorf("ReadDocument() has CKeys %v, want %v", r.CKeys(), parts().CKeys())
+	}
+	var got map[partTup]struct{}
+	if err := r.TupleMap(&got); err != nil {
+		t.Fatal(err)
+	}
+	for _, tup := range partTups {
+		if _, ok := got[tup]; !ok || len(got) != len(partTups) {
+			t.Errorf("round trip => %v, want %v", got, partTups)
+			break
+		}
+	}
+
+	// empty relations
+	buf.Reset()
+	if err := WriteDocument(&buf, parts().Restrict(rel.Attribute("Color").EQ("Purple"))); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := ReadDocument(&buf, partTup{}); err != nil || rel.Card(r) != 0 {
+		t.Errorf("ReadDocument() of an empty relation => %v, %v", r, err)
+	}
+
+	// errors in the relation are returned
+	type orderTup struct {
+		PNO int
+		SNO int
+		Qty int
+	}
+	if err := WriteDocument(&buf, parts().Project(orderTup{})); err == nil {
+		t.Errorf("WriteDocument() of an invalid relation did not return an error")
+	}
+}
+
+func TestReadDocumentErrors(t *testing.T) {
+	type pnoTup struct {
+		PNO int
+	}
+	var errTests = []struct {
+		in  string
+		err interface{}
+	}{
+		{`{"heading":["PNO"],"types":["int"],"ckeys":[["PNO"]],"body":[{"PNO":1}]}`, nil},
+		{`{"heading":["PName"],"types":["string"],"ckeys":[],"body":[]}`, &rel.DomainMismatchError{}},
+		{`{"heading":["PNO"],"types":["string"],"ckeys":[],"body":[]}`, &TypeError{}},
+		{`{"heading":["PNO"],"types":[],"ckeys":[],"body":[]}`, &rel.DegreeError{}},
+		{`{"heading":["PNO"],"types":["int"],"ckeys":[["SNO"]],"body":[]}`, &rel.AttributeSubsetError{}},
+		{`{"heading":["PNO"],"types":["int"],"ckeys":[],"body":[{"PNO":"x"}]}`, &stdjson.UnmarshalTypeError{}},
+		{`{"heading":}`, &stdjson.SyntaxError{}},
+	}
+	for i, tt := range errTests {
+		_, err := ReadDocument(strings.NewReader(tt.in), pnoTup{})
+		if tt.err == nil {
+			if err != nil {
+				t.Errorf("%d ReadDocument() => %v, want nil", i, err)
+			}
+			continue
+		}
+		if reflect.TypeOf(err) != reflect.TypeOf(tt.err) {
+			t.Errorf("%d ReadDocument() => %T %v, want %T", i, err, err, tt.err)
+		}
+	}
+}
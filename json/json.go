@@ -0,0 +1,394 @@
+// Package json implements encoding and decoding of relations as JSON.
+//
+// There are two formats.  Newline delimited JSON (NDJSON) has one JSON
+// object per line for each of the tuples in the relation, which can be
+// streamed as the relation is evaluated, for example in an HTTP response.
+// Relation documents are JSON objects which also carry the heading, the
+// attribute types, and the candidate keys of the relation along with its
+// body, so that it can be reconstructed on the other side.
+//
+// Tuples are encoded and decoded with the encoding/json package, so the
+// names of the JSON fields can be changed with struct tags.
+package json
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sync"
+
+	"github.com/jonlawlor/rel"
+)
+
+// ErrConsumed is the error that results from evaluating a relation a second
+// time when its input can't be read again.  The input can be read by any
+// number of evaluations at once if it is an io.ReaderAt, such as a
+// strings.Reader, a bytes.Reader, or an os.File.  If it is only an
+// io.Seeker, then it can be read more than once, but only by one evaluation
+// at a time, so self joins and other expressions which evaluate it
+// concurrently result in this error.  Otherwise, rel.Replay can be used to
+// remember the tuples.
+var ErrConsumed = errors.New("rel/json: input has already been read")
+
+// LineError represents an error that occurs when a line of NDJSON input
+// can't be decoded as a tuple.  Lines are numbered from 1.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("rel/json: line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the underlying decoding error
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// WriteNDJSON writes the tuples of the relation to w as newline delimited
+// JSON, with one object per tuple.  Tuples are written as the relation is
+// evaluated.  It returns the first error encountered while evaluating the
+// relation or writing its tuples.
+func WriteNDJSON(w io.Writer, r rel.Relation) error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+	enc := stdjson.NewEncoder(w)
+	tups, errf := rel.Tuples(r)
+	for tup := range tups {
+		if err := enc.Encode(tup); err != nil {
+			return err
+		}
+	}
+	return errf()
+}
+
+// ndjsonRel is a relation with tuples read from newline delimited JSON
+type ndjsonRel struct {
+	// src is the input
+	src io.Reader
+
+	// set of candidate keys
+	cKeys rel.CandKeys
+
+	// the type of the tuples contained within the relation
+	zero interface{}
+
+	// sourceDistinct indicates if the input was already distinct or if a
+	// distinct has to be performed when sending tuples
+	sourceDistinct bool
+
+	// mu protects read, reading, and err, which are used by concurrent
+	// evaluations
+	mu sync.Mutex
+
+	// read is true once src has been read
+	read bool
+
+	// reading is true while an evaluation is reading a src which can't be
+	// shared
+	reading bool
+
+	// err holds the first error encountered during construction or
+	// evaluation.
+	err error
+}
+
+// NewNDJSON creates a new Relation with tuples of the same type as zero,
+// which are read from newline delimited JSON.  Blank lines are ignored.  See
+// rel.New for a description of the candidate keys.  If no candidate keys are
+// provided, then duplicate tuples are removed.
+//
+// Errors in the input are reported by Err() after the relation has been
+// evaluated.
+func NewNDJSON(r io.Reader, zero interface{}, ckeys [][]string) rel.Relation {
+	r1 := &ndjsonRel{src: r, zero: zero}
+	if len(ckeys) == 0 {
+		r1.cKeys = rel.DefaultKeys(zero)
+	} else {
+		r1.cKeys = rel.String2CandKeys(ckeys)
+		r1.sourceDistinct = true
+	}
+	rel.OrderCandidateKeys(r1.cKeys)
+	return r1
+}
+
+// open returns a reader over the input from the beginning, and a func which
+// has to be called once it is no longer needed.  Each evaluation of an
+// io.ReaderAt input gets its own section reader, so they can read it at the
+// same time.  An io.Seeker input shares a single offset, so it is rewound,
+// and only one evaluation can read it at a time.  Other input can only be
+// read once.
+func (r1 *ndjsonRel) open() (io.Reader, func(), error) {
+	if ra, ok := r1.src.(io.ReaderAt); ok {
+		return io.NewSectionReader(ra, 0, math.MaxInt64), func() {}, nil
+	}
+	r1.mu.Lock()
+	defer r1.mu.Unlock()
+	if r1.reading {
+		return nil, nil, ErrConsumed
+	}
+	if s, ok := r1.src.(io.Seeker); ok {
+		if _, err := s.Seek(0, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+	} else if r1.read {
+		return nil, nil, ErrConsumed
+	}
+	r1.read = true
+	r1.reading = true
+	return r1.src, func() {
+		r1.mu.Lock()
+		r1.reading = false
+		r1.mu.Unlock()
+	}, nil
+}
+
+// setErr records the first error encountered during evaluation
+func (r1 *ndjsonRel) setErr(err error) {
+	r1.mu.Lock()
+	if r1.err == nil {
+		r1.err = err
+	}
+	r1.mu.Unlock()
+}
+
+// TupleChan sends each tuple in the relation to a channel
+func (r1 *ndjsonRel) TupleChan(t interface{}) chan<- struct{} {
+	cancel := make(chan struct{})
+	// reflect on the channel
+	chv := reflect.ValueOf(t)
+	err := rel.EnsureChan(chv.Type(), r1.zero)
+	if err != nil {
+		r1.setErr(err)
+		return cancel
+	}
+	if r1.Err() != nil {
+		chv.Close()
+		return cancel
+	}
+	src, done, err := r1.open()
+	if err != nil {
+		r1.setErr(err)
+		chv.Close()
+		return cancel
+	}
+
+	go func(res reflect.Value) {
+		e := reflect.TypeOf(r1.zero)
+		br := bufio.NewReader(src)
+
+		var mem map[interface{}]struct{}
+		if !r1.sourceDistinct {
+			mem = make(map[interface{}]struct{})
+		}
+
+		// output channels
+		canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+		resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+		var rerr error
+		for line := 1; ; line++ {
+			b, err := br.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				rerr = err
+				break
+			}
+			if b = bytes.TrimSpace(b); len(b) > 0 {
+				tup := reflect.New(e)
+				if derr := stdjson.Unmarshal(b, tup.Interface()); derr != nil {
+					rerr = &LineError{line, derr}
+					break
+				}
+				// fields which are not attributes may have been decoded
+				rtup := rel.ClearExcluded(tup.Elem())
+				if mem != nil {
+					key := rel.TupleKey(rtup)
+					if _, dup := mem[key]; dup {
+						continue
+					}
+					mem[key] = struct{}{}
+				}
+				resSel.Send = rtup
+				chosen, _, _ := reflect.Select([]reflect.SelectCase{canSel, resSel})
+				if chosen == 0 {
+					// cancel has been closed, so stop reading
+					done()
+					return
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+		}
+		// the input is released before the channel is closed, so that it
+		// can be evaluated again as soon as this evaluation is complete
+		done()
+		if rerr != nil {
+			r1.setErr(rerr)
+		}
+		res.Close()
+	}(chv)
+	return cancel
+}
+
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *ndjsonRel) TupleChanContext(ctx context.Context, t interface{}) error {
+	return rel.TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *ndjsonRel) TupleSlice(t interface{}) error {
+	return rel.TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *ndjsonRel) TupleMap(t interface{}) error {
+	return rel.TupleMap(r1, t)
+}
+
+// Zero returns the zero value of the relation (a blank tuple)
+func (r1 *ndjsonRel) Zero() interface{} {
+	return r1.zero
+}
+
+// CKeys is the set of candidate keys in the relation
+func (r1 *ndjsonRel) CKeys() rel.CandKeys {
+	return r1.cKeys
+}
+
+// GoString returns a text representation of the Relation
+func (r1 *ndjsonRel) GoString() string {
+	return "json.NewNDJSON(" + rel.HeadingString(r1) + ")"
+}
+
+// String returns a text representation of the Relation
+func (r1 *ndjsonRel) String() string {
+	return "Relation(" + rel.HeadingString(r1) + ")"
+}
+
+// Project creates a new relation with less than or equal degree
+// t2 has to be a new type which is a subdomain of r.
+func (r1 *ndjsonRel) Project(z2 interface{}) rel.Relation {
+	return rel.Rewrite(r1, rel.NewProject(r1, z2))
+}
+
+// Restrict creates a new relation with less than or equal cardinality
+// p has to be a func(tup T) bool where tup is a subdomain of the input r.
+func (r1 *ndjsonRel) Restrict(p rel.Predicate) rel.Relation {
+	return rel.Rewrite(r1, rel.NewRestrict(r1, p))
+}
+
+// Rename creates a new relation with new column names
+// z2 has to be a struct with the same number of fields as the input relation
+func (r1 *ndjsonRel) Rename(z2 interface{}) rel.Relation {
+	return rel.Rewrite(r1, rel.NewRename(r1, z2))
+}
+
+// Union creates a new relation by unioning the bodies of both inputs
+func (r1 *ndjsonRel) Union(r2 rel.Relation) rel.Relation {
+	return rel.Rewrite(r1, rel.NewUnion(r1, r2))
+}
+
+// Diff creates a new relation by set minusing the two inputs
+func (r1 *ndjsonRel) Diff(r2 rel.Relation) rel.Relation {
+	return rel.Rewrite(r1, rel.NewDiff(r1, r2))
+}
+
+// Join creates a new relation by performing a natural join on the inputs
+func (r1 *ndjsonRel) Join(r2 rel.Relation, zero interface{}) rel.Relation {
+	return rel.Rewrite(r1, rel.NewJoin(r1, r2, zero))
+}
+
+// GroupBy creates a new relation by grouping and applying a user defined func
+func (r1 *ndjsonRel) GroupBy(t2, gfcn interface{}) rel.Relation {
+	return rel.Rewrite(r1, rel.NewGroupBy(r1, t2, gfcn))
+}
+
+// Map creates a new relation by applying a function to tuples in the source
+func (r1 *ndjsonRel) Map(mfcn interface{}, ckeystr [][]string) rel.Relation {
+	return rel.Rewrite(r1, rel.NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *ndjsonRel) Extend(z2, efcn interface{}) rel.Relation {
+	return rel.Rewrite(r1, rel.NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *ndjsonRel) Order(att ...rel.Attribute) rel.Relation {
+	return rel.Rewrite(r1, rel.NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *ndjsonRel) SemiJoin(r2 rel.Relation) rel.Relation {
+	return rel.Rewrite(r1, rel.NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *ndjsonRel) SemiDiff(r2 rel.Relation) rel.Relation {
+	return rel.Rewrite(r1, rel.NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *ndjsonRel) Wrap(z2 interface{}, name rel.Attribute) rel.Relation {
+	return rel.Rewrite(r1, rel.NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *ndjsonRel) Unwrap(name rel.Attribute) rel.Relation {
+	return rel.Rewrite(r1, rel.NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *ndjsonRel) Group(z2 interface{}, name rel.Attribute) rel.Relation {
+	return rel.Rewrite(r1, rel.NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *ndjsonRel) Ungroup(name rel.Attribute) rel.Relation {
+	return rel.Rewrite(r1, rel.NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *ndjsonRel) Divide(r2, per rel.Relation) rel.Relation {
+	return rel.Rewrite(r1, rel.NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *ndjsonRel) TClose() rel.Relation {
+	return rel.Rewrite(r1, rel.NewTClose(r1))
+}
+
+// Err returns an error encountered during construction or computation
+func (r1 *ndjsonRel) Err() error {
+	r1.mu.Lock()
+	defer r1.mu.Unlock()
+	return r1.err
+}
+
+// Op is the operation that produces the relation, which is a literal
+func (r1 *ndjsonRel) Op() rel.OpKind {
+	return rel.OpLiteral
+}
+
+// Children are the relations that the operation is performed on, which is
+// empty for literals
+func (r1 *ndjsonRel) Children() []rel.Relation {
+	return nil
+}
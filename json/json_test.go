@@ -0,0 +1,211 @@
+package json
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jonlawlor/rel"
+)
+
+type partTup struct {
+	PNO    int
+	PName  string
+	Color  string
+	Weight float64
+	City   string
+}
+
+var partTups = []partTup{
+	{1, "Nut", "Red", 12.0, "London"},
+	{2, "Bolt", "Green", 17.0, "Paris"},
+	{3, "Screw", "Blue", 17.0, "Oslo"},
+	{4, "Screw", "Red", 14.0, "London"},
+	{5, "Cam", "Blue", 12.0, "Paris"},
+	{6, "Cog", "Red", 19.0, "London"},
+}
+
+func parts() rel.Relation {
+	return rel.New(partTups, [][]string{{"PNO"}})
+}
+
+const partsNDJSON = `{"PNO":1,"PName":"Nut","Color":"Red","Weight":12,"City":"London"}
+{"PNO":2,"PName":"Bolt","Color":"Green","Weight":17,"City":"Paris"}
+{"PNO":3,"PName":"Screw","Color":"Blue","Weight":17,"City":"Oslo"}
+{"PNO":4,"PName":"Screw","Color":"Red","Weight":14,"City":"London"}
+{"PNO":5,"PName":"Cam","Color":"Blue","Weight":12,"City":"Paris"}
+{"PNO":6,"PName":"Cog","Color":"Red","Weight":19,"City":"London"}
+`
+
+// onlyReader hides any methods of the underlying reader other than Read
+type onlyReader struct {
+	io.Reader
+}
+
+// seekReader hides any methods of the underlying reader other than Read and
+// Seek
+type seekReader struct {
+	io.ReadSeeker
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteNDJSON(&buf, parts().Order("PNO")); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != partsNDJSON {
+		t.Errorf("WriteNDJSON() => %q, want %q", s, partsNDJSON)
+	}
+
+	// struct tags change the names of the fields
+	type taggedTup struct {
+		PNO   int    `json:"pno"`
+		PName string `json:"name,omitempty"`
+	}
+	buf.Reset()
+	if err := WriteNDJSON(&buf, rel.New([]taggedTup{{1, ""}}, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != "{\"pno\":1}\n" {
+		t.Errorf("WriteNDJSON() => %q, want %q", s, "{\"pno\":1}\n")
+	}
+
+	// errors in the relation are returned
+	type orderTup struct {
+		PNO int
+		SNO int
+		Qty int
+	}
+	if err := WriteNDJSON(&buf, parts().Project(orderTup{})); err == nil {
+		t.Errorf("WriteNDJSON() of an invalid relation did not return an error")
+	}
+}
+
+func TestNewNDJSON(t *testing.T) {
+	type colorTup struct {
+		Color string
+	}
+	var newTests = []struct {
+		in   string
+		zero interface{}
+		card int
+	}{
+		{partsNDJSON, partTup{}, 6},
+		// extra fields are ignored
+		{partsNDJSON, colorTup{}, 3},
+		// as are blank lines, and the last line does not need a newline
+		{"{\"Color\":\"Red\"}\n\n  \n{\"Color\":\"Blue\"}", colorTup{}, 2},
+		{"", colorTup{}, 0},
+	}
+	for i, tt := range newTests {
+		r := NewNDJSON(strings.NewReader(tt.in), tt.zero, nil)
+		if c := rel.Card(r); c != tt.card {
+			t.Errorf("%d Card() => %d, want %d", i, c, tt.card)
+		}
+		if err := r.Err(); err != nil {
+			t.Errorf("%d has error %v", i, err)
+		}
+	}
+
+	// round trip
+	var buf bytes.Buffer
+	if err := WriteNDJSON(&buf, parts()); err != nil {
+		t.Fatal(err)
+	}
+	r := NewNDJSON(bytes.NewReader(buf.Bytes()), partTup{}, [][]string{{"PNO"}})
+	var got map[partTup]struct{}
+	if err := r.TupleMap(&got); err != nil {
+		t.Fatal(err)
+	}
+	for _, tup := range partTups {
+		if _, ok := got[tup]; !ok || len(got) != len(partTups) {
+			t.Errorf("round trip => %v, want %v", got, partTups)
+			break
+		}
+	}
+
+	// seekable input can be evaluated any number of times
+	if c := rel.Card(r.Restrict(rel.Attribute("Color").EQ("Red"))); c != 3 || r.Err() != nil {
+		t.Errorf("re-evaluation => %d and %v, want %d and nil", c, r.Err(), 3)
+	}
+
+	// other input can only be evaluated once
+	r = NewNDJSON(onlyReader{strings.NewReader(partsNDJSON)}, partTup{}, nil)
+	rel.Card(r)
+	rel.Card(r)
+	if err := r.Err(); err != ErrConsumed {
+		t.Errorf("second evaluation => %v, want %v", err, ErrConsumed)
+	}
+
+	// input which is only an io.Seeker can be evaluated again, but not
+	// while another evaluation is reading it
+	r = NewNDJSON(seekReader{strings.NewReader(partsNDJSON)}, partTup{}, nil)
+	rel.Card(r)
+	if c := rel.Card(r); c != 6 || r.Err() != nil {
+		t.Errorf("seeker Card() => %d and %v, want %d and nil", c, r.Err(), 6)
+	}
+	rel.Card(r.Union(r.Restrict(rel.Attribute("Color").EQ("Red"))))
+	if err := r.Err(); err != ErrConsumed {
+		t.Errorf("concurrent evaluation => %v, want %v", err, ErrConsumed)
+	}
+
+	// fields which are not attributes are cleared, so tuples which only
+	// differ in them are the same tuple
+	type noteTup struct {
+		A    int
+		Note string `rel:"-"`
+	}
+	r = NewNDJSON(strings.NewReader("{\"A\":1,\"Note\":\"x\"}\n{\"A\":1,\"Note\":\"y\"}\n"), noteTup{}, nil)
+	var notes []noteTup
+	if err := r.TupleSlice(&notes); err != nil {
+		t.Fatal(err)
+	}
+	if want := []noteTup{{1, ""}}; !reflect.DeepEqual(notes, want) {
+		t.Errorf("excluded fields => %v, want %v", notes, want)
+	}
+
+	// decoding errors have line numbers
+	r = NewNDJSON(strings.NewReader("{\"PNO\":1}\n\n{\"PNO\":\"x\"}\n"), partTup{}, nil)
+	rel.Card(r)
+	var err *LineError
+	if !errors.As(r.Err(), &err) || err.Line != 3 {
+		t.Errorf("Err() => %v, want an error on line 3", r.Err())
+	}
+}
+
+// tests for expressions which evaluate the same input more than once at the
+// same time
+func TestSelfCombination(t *testing.T) {
+	type abTup struct {
+		A int
+		B int
+	}
+	var sb strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&sb, "{\"A\":%d,\"B\":%d}\n", i, 2*i)
+	}
+	r := NewNDJSON(strings.NewReader(sb.String()), abTup{}, [][]string{{"A"}})
+	var selfTest = []struct {
+		rel  rel.Relation
+		card int
+	}{
+		{r.Union(r.Restrict(rel.Attribute("A").LT(1000))), 2000},
+		{r.Join(r.Restrict(rel.Attribute("B").LT(1000)), abTup{}), 500},
+		{r.Diff(r.Restrict(rel.Attribute("A").LT(1000))), 1000},
+	}
+	for i, tt := range selfTest {
+		if c := rel.Card(tt.rel); c != tt.card {
+			t.Errorf("%d %s has Card() => %d, want %d", i, tt.rel, c, tt.card)
+		}
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%d %s has Err() => %v", i, tt.rel, err)
+		}
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("self combination has Err() => %v", err)
+	}
+}
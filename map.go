@@ -4,6 +4,7 @@
 package rel
 
 import (
+	"context"
 	"reflect"
 )
 
@@ -59,7 +60,10 @@ func (r1 *mapExpr) TupleChan(t interface{}) chan<- struct{} {
 	// figure out which fields stay, and where they are in each of the tuple
 	// types.
 	// TODO(jonlawlor): error if fields in e2 are not in r1's tuples.
-	fMap := FieldMap(e1, r1.valType)
+	fMap := fieldMap(e1, r1.valType)
+
+	// the function may set fields which are excluded from the results
+	excluded := hasExcludedFields(r1.resType)
 
 	body1 := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e1), 0)
 	bcancel := r1.source1.TupleChan(body1.Interface())
@@ -92,12 +96,15 @@ func (r1 *mapExpr) TupleChan(t interface{}) chan<- struct{} {
 				// construct the function input
 				fcnin := reflect.Indirect(reflect.New(r1.valType))
 				for _, fm := range fMap {
-					fcninf := fcnin.Field(fm.J)
-					fcninf.Set(tup.Field(fm.I))
+					fcninf := fcnin.FieldByIndex(fm.J)
+					fcninf.Set(tup.FieldByIndex(fm.I))
 				}
 				// set the field in the new tuple to the value from the old one
 
 				fcnout := r1.rmfcn.Call([]reflect.Value{fcnin})[0]
+				if excluded {
+					fcnout = clearExcluded(fcnout)
+				}
 				resSel.Send = fcnout
 				chosen, _, ok = reflect.Select([]reflect.SelectCase{canSel, resSel})
 				if chosen == 0 {
@@ -114,7 +121,8 @@ func (r1 *mapExpr) TupleChan(t interface{}) chan<- struct{} {
 		return cancel
 	}
 	go func(body, res reflect.Value) {
-		m := map[interface{}]struct{}{}
+		m := newDistinctSet(r1.resType)
+		defer m.close()
 
 		// input channels
 		sourceSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: body}
@@ -140,17 +148,19 @@ func (r1 *mapExpr) TupleChan(t interface{}) chan<- struct{} {
 			// construct the function input
 			fcnin := reflect.Indirect(reflect.New(r1.valType))
 			for _, fm := range fMap {
-				fcninf := fcnin.Field(fm.J)
-				fcninf.Set(tup.Field(fm.I))
+				fcninf := fcnin.FieldByIndex(fm.J)
+				fcninf.Set(tup.FieldByIndex(fm.I))
 			}
 			// set the field in the new tuple to the value from the old one
 
 			fcnout := r1.rmfcn.Call([]reflect.Value{fcnin})[0]
+			if excluded {
+				fcnout = clearExcluded(fcnout)
+			}
 
 			// check that the output from the function is not a duplicate
-			if _, isdup := m[fcnout.Interface()]; !isdup {
+			if m.add(fcnout) {
 				// it isn't a dupe, so send it on the results
-				m[fcnout.Interface()] = struct{}{}
 				resSel.Send = fcnout
 				chosen, _, ok = reflect.Select([]reflect.SelectCase{canSel, resSel})
 				if chosen == 0 {
@@ -160,8 +170,15 @@ func (r1 *mapExpr) TupleChan(t interface{}) chan<- struct{} {
 			}
 		}
 
+		// send the tuples that were deferred if the memory budget was
+		// exceeded
+		if !m.flush(func(tup reflect.Value) bool { return sendTuple(res, cancel, tup) }) && m.err == nil {
+			return
+		}
 		if err := r1.source1.Err(); err != nil {
 			r1.err = err
+		} else if m.err != nil {
+			r1.err = m.err
 		}
 		chv.Close()
 	}(body1, chv)
@@ -170,6 +187,22 @@ func (r1 *mapExpr) TupleChan(t interface{}) chan<- struct{} {
 
 }
 
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *mapExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *mapExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *mapExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
 // Zero returns the zero value of the relation (a blank tuple)
 func (r1 *mapExpr) Zero() interface{} {
 	return r1.zero
@@ -193,47 +226,123 @@ func (r1 *mapExpr) String() string {
 // Project creates a new relation with less than or equal degree
 // t2 has to be a new type which is a subdomain of r.
 func (r1 *mapExpr) Project(z2 interface{}) Relation {
-	return NewProject(r1, z2)
+	return Rewrite(r1, NewProject(r1, z2))
 }
 
 // Restrict creates a new relation with less than or equal cardinality
 // p has to be a func(tup T) bool where tup is a subdomain of the input r.
 func (r1 *mapExpr) Restrict(p Predicate) Relation {
-	return NewRestrict(r1, p)
+	return Rewrite(r1, NewRestrict(r1, p))
 }
 
 // Rename creates a new relation with new column names
 // z2 has to be a struct with the same number of fields as the input relation
 func (r1 *mapExpr) Rename(z2 interface{}) Relation {
-	return NewRename(r1, z2)
+	return Rewrite(r1, NewRename(r1, z2))
 }
 
 // Union creates a new relation by unioning the bodies of both inputs
 func (r1 *mapExpr) Union(r2 Relation) Relation {
-	return NewUnion(r1, r2)
+	return Rewrite(r1, NewUnion(r1, r2))
 }
 
 // Diff creates a new relation by set minusing the two inputs
 func (r1 *mapExpr) Diff(r2 Relation) Relation {
-	return NewDiff(r1, r2)
+	return Rewrite(r1, NewDiff(r1, r2))
 }
 
 // Join creates a new relation by performing a natural join on the inputs
 func (r1 *mapExpr) Join(r2 Relation, zero interface{}) Relation {
-	return NewJoin(r1, r2, zero)
+	return Rewrite(r1, NewJoin(r1, r2, zero))
 }
 
 // GroupBy creates a new relation by grouping and applying a user defined func
 func (r1 *mapExpr) GroupBy(t2, gfcn interface{}) Relation {
-	return NewGroupBy(r1, t2, gfcn)
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
 }
 
 // Map creates a new relation by applying a function to tuples in the source
 func (r1 *mapExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
-	return NewMap(r1, mfcn, ckeystr)
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *mapExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *mapExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *mapExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *mapExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *mapExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *mapExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *mapExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *mapExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *mapExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *mapExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
 }
 
 // Err returns an error encountered during construction or computation
 func (r1 *mapExpr) Err() error {
 	return r1.err
 }
+
+// Op is the operation that produces the relation
+func (r1 *mapExpr) Op() OpKind {
+	return OpMap
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *mapExpr) Children() []Relation {
+	return []Relation{r1.source1}
+}
+
+// Func is the function which is applied to each tuple
+func (r1 *mapExpr) Func() interface{} {
+	if !r1.rmfcn.IsValid() {
+		return nil
+	}
+	return r1.rmfcn.Interface()
+}
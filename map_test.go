@@ -1,8 +1,11 @@
 package rel
 
 import (
+	"context"
 	"fmt"
+	"reflect"
 	"testing"
+	"time"
 )
 
 // tests for map op
@@ -106,6 +109,36 @@ func TestMap(t *testing.T) {
 	default:
 		// passed test
 	}
+
+	// cancelling the context of an evaluation also cancels the source, with
+	// and without the removal of duplicates
+	asNonDistinct := func(tup orderTup) nonDistinctTup {
+		return nonDistinctTup{tup.PNO, tup.Qty}
+	}
+	for i, r2 := range []Relation{rel, orders().Map(asNonDistinct, nil)} {
+		c := &Collector{}
+		ctx, stop := context.WithCancel(context.Background())
+		ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, reflect.TypeOf(r2.Zero())), 0)
+		errc := make(chan error, 1)
+		go func() {
+			errc <- WithObserver(r2, c).TupleChanContext(ctx, ch.Interface())
+		}()
+		ch.Recv()
+		stop()
+		if err := <-errc; err != context.Canceled {
+			t.Errorf("%d has TupleChanContext() => %v, want %v", i, err, context.Canceled)
+		}
+		src := r2.(Node).Children()[0]
+		deadline := time.Now().Add(time.Second)
+		for s := c.Stats(src); s == nil || s.Cancels != 1; s = c.Stats(src) {
+			if time.Now().After(deadline) {
+				t.Errorf("%d cancellation of the source was not observed, got %+v", i, s)
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
 	// test errors
 	err := fmt.Errorf("testing error")
 	r1 := orders().Map(doubleQty, mapKeys).(*mapExpr)
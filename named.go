@@ -0,0 +1,202 @@
+// named implements a relation which gives a name to another relation, so that
+// it can be referred to as a table in SQL generated by ToSQL.
+
+package rel
+
+import (
+	"context"
+	"strconv"
+)
+
+// namedExpr is a relation with a name, which has the same tuples as its
+// source relation.
+type namedExpr struct {
+	// the input relation
+	source1 Relation
+
+	// name is the name of the relation, such as a table or view
+	name string
+}
+
+// Named creates a new relation with a name, which has the same tuples as the
+// input.  Named relations are the base relations of the SQL produced by
+// ToSQL, where they are referred to by name.  The name also replaces the
+// input in the String representation of expressions which use it.
+//
+// Queries on the result are not rewritten into queries on the input, so
+// that the name is kept.
+func Named(name string, r1 Relation) Relation {
+	if r1.Err() != nil {
+		// don't bother building the relation and just return the original
+		return r1
+	}
+	return &namedExpr{r1, name}
+}
+
+// TupleChan sends each tuple in the relation to a channel
+func (r1 *namedExpr) TupleChan(t interface{}) chan<- struct{} {
+	return r1.source1.TupleChan(t)
+}
+
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *namedExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *namedExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *namedExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
+// Zero returns the zero value of the relation (a blank tuple)
+func (r1 *namedExpr) Zero() interface{} {
+	return r1.source1.Zero()
+}
+
+// CKeys is the set of candidate keys in the relation
+func (r1 *namedExpr) CKeys() CandKeys {
+	return r1.source1.CKeys()
+}
+
+// SortOrder is the set of attributes that the relation is sorted on, which
+// is the same as the source relation.
+func (r1 *namedExpr) SortOrder() []Attribute {
+	return SortOrder(r1.source1)
+}
+
+// GoString returns a text representation of the Relation
+func (r1 *namedExpr) GoString() string {
+	return "rel.Named(" + strconv.Quote(r1.name) + ", " + r1.source1.GoString() + ")"
+}
+
+// String returns a text representation of the Relation
+func (r1 *namedExpr) String() string {
+	return r1.name
+}
+
+// Project creates a new relation with less than or equal degree
+// t2 has to be a new type which is a subdomain of r.
+func (r1 *namedExpr) Project(z2 interface{}) Relation {
+	return Rewrite(r1, NewProject(r1, z2))
+}
+
+// Restrict creates a new relation with less than or equal cardinality
+// p has to be a func(tup T) bool where tup is a subdomain of the input r.
+func (r1 *namedExpr) Restrict(p Predicate) Relation {
+	return Rewrite(r1, NewRestrict(r1, p))
+}
+
+// Rename creates a new relation with new column names
+// z2 has to be a struct with the same number of fields as the input relation
+func (r1 *namedExpr) Rename(z2 interface{}) Relation {
+	return Rewrite(r1, NewRename(r1, z2))
+}
+
+// Union creates a new relation by unioning the bodies of both inputs
+func (r1 *namedExpr) Union(r2 Relation) Relation {
+	return Rewrite(r1, NewUnion(r1, r2))
+}
+
+// Diff creates a new relation by set minusing the two inputs
+func (r1 *namedExpr) Diff(r2 Relation) Relation {
+	return Rewrite(r1, NewDiff(r1, r2))
+}
+
+// Join creates a new relation by performing a natural join on the inputs
+func (r1 *namedExpr) Join(r2 Relation, zero interface{}) Relation {
+	return Rewrite(r1, NewJoin(r1, r2, zero))
+}
+
+// GroupBy creates a new relation by grouping and applying a user defined func
+func (r1 *namedExpr) GroupBy(t2, gfcn interface{}) Relation {
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
+}
+
+// Map creates a new relation by applying a function to tuples in the source
+func (r1 *namedExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *namedExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *namedExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *namedExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *namedExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *namedExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *namedExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *namedExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *namedExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *namedExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *namedExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
+}
+
+// Err returns an error encountered during construction or computation
+func (r1 *namedExpr) Err() error {
+	return r1.source1.Err()
+}
+
+// Op is the operation that produces the relation
+func (r1 *namedExpr) Op() OpKind {
+	return OpNamed
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *namedExpr) Children() []Relation {
+	return []Relation{r1.source1}
+}
+
+// Name is the name of the relation
+func (r1 *namedExpr) Name() string {
+	return r1.name
+}
@@ -0,0 +1,160 @@
+// node defines the interfaces which expose the structure of relational
+// expressions, so that they can be inspected outside of the rel package.
+
+package rel
+
+// OpKind identifies the operation that produces a relation
+type OpKind int
+
+const (
+	// OpLiteral is an essential relation, which is a source of tuples
+	// rather than an operation on other relations, like those created by New.
+	OpLiteral OpKind = iota
+	OpProject
+	OpRestrict
+	OpRename
+	OpUnion
+	OpDiff
+	OpJoin
+	OpGroupBy
+	OpMap
+	OpExtend
+	OpOrder
+	OpSemiJoin
+	OpSemiDiff
+	OpReplay
+	OpNamed
+	OpWrap
+	OpUnwrap
+	OpGroup
+	OpUngroup
+	OpDivide
+	OpTClose
+)
+
+// String returns the name of the operation
+func (k OpKind) String() string {
+	switch k {
+	case OpLiteral:
+		return "Literal"
+	case OpProject:
+		return "Project"
+	case OpRestrict:
+		return "Restrict"
+	case OpRename:
+		return "Rename"
+	case OpUnion:
+		return "Union"
+	case OpDiff:
+		return "Diff"
+	case OpJoin:
+		return "Join"
+	case OpGroupBy:
+		return "GroupBy"
+	case OpMap:
+		return "Map"
+	case OpExtend:
+		return "Extend"
+	case OpOrder:
+		return "Order"
+	case OpSemiJoin:
+		return "SemiJoin"
+	case OpSemiDiff:
+		return "SemiDiff"
+	case OpReplay:
+		return "Replay"
+	case OpNamed:
+		return "Named"
+	case OpWrap:
+		return "Wrap"
+	case OpUnwrap:
+		return "Unwrap"
+	case OpGroup:
+		return "Group"
+	case OpUngroup:
+		return "Ungroup"
+	case OpDivide:
+		return "Divide"
+	case OpTClose:
+		return "TClose"
+	}
+	return "Unknown"
+}
+
+// Node is a relation which exposes its place in a tree of relational
+// expressions.  All of the relations in the rel package implement it.  The
+// result type of an operation like Project or Join is given by its Zero
+// method, the attributes of Order nodes are given by their SortOrder method,
+// and the rest of the parameters are provided by the other interfaces in
+// this file, depending on the operation.
+type Node interface {
+	Relation
+
+	// Op is the operation that produces the relation
+	Op() OpKind
+
+	// Children are the relations that the operation is performed on, in the
+	// same order as they were given to it.  Literals do not have children.
+	Children() []Relation
+}
+
+// PredicateNode is implemented by Restrict nodes.
+type PredicateNode interface {
+	Node
+
+	// Predicate is the predicate that tuples have to satisfy
+	Predicate() Predicate
+}
+
+// RenameNode is implemented by Rename nodes.
+type RenameNode interface {
+	Node
+
+	// Renames is a map from the attributes of the child to their new names.
+	// Attributes which keep the same name are included.
+	Renames() map[Attribute]Attribute
+}
+
+// FuncNode is implemented by GroupBy, Map, and Extend nodes.
+type FuncNode interface {
+	Node
+
+	// Func is the function which was given to the operation, or the
+	// Aggregator for GroupBy nodes that use one.
+	Func() interface{}
+}
+
+// AggregateNode is implemented by GroupBy nodes.
+type AggregateNode interface {
+	Node
+
+	// Aggregates are the aggregates given to Summarize, or nil if the node
+	// was not created by Summarize.
+	Aggregates() []Aggregate
+}
+
+// NamedNode is implemented by the relations created by Named.
+type NamedNode interface {
+	Node
+
+	// Name is the name of the relation
+	Name() string
+}
+
+// WrapNode is implemented by Wrap and Unwrap nodes.
+type WrapNode interface {
+	Node
+
+	// Wrapped is the tuple-valued attribute which the attributes are wrapped
+	// into, or unwrapped from.
+	Wrapped() Attribute
+}
+
+// GroupNode is implemented by Group and Ungroup nodes.
+type GroupNode interface {
+	Node
+
+	// Grouped is the relation-valued attribute which the attributes are
+	// grouped into, or ungrouped from.
+	Grouped() Attribute
+}
@@ -0,0 +1,102 @@
+package rel
+
+import (
+	"reflect"
+	"testing"
+)
+
+// tests for the structure of relational expressions
+func TestNode(t *testing.T) {
+	ops := []OpKind{
+		OpLiteral, OpLiteral, OpLiteral, OpProject, OpRestrict, OpRename,
+		OpUnion, OpDiff, OpJoin, OpGroupBy, OpMap, OpExtend, OpOrder,
+		OpSemiJoin, OpSemiDiff, OpGroupBy, OpReplay, OpNamed, OpWrap, OpUnwrap,
+		OpGroup, OpUngroup, OpDivide, OpTClose,
+	}
+	for i, tt := range relationTests() {
+		r := tt.rel()
+		n, ok := r.(Node)
+		if !ok {
+			t.Errorf("%d %v is not a Node", i, r)
+			continue
+		}
+		if n.Op() != ops[i] {
+			t.Errorf("%d %v has Op() => %v, want %v", i, r, n.Op(), ops[i])
+		}
+		var want int
+		switch n.Op() {
+		case OpLiteral:
+			want = 0
+		case OpUnion, OpDiff, OpJoin, OpSemiJoin, OpSemiDiff:
+			want = 2
+		case OpDivide:
+			want = 3
+		default:
+			want = 1
+		}
+		if c := n.Children(); len(c) != want {
+			t.Errorf("%d %v has %d children, want %d", i, r, len(c), want)
+		}
+	}
+
+	// the children are in the same order as they were given
+	r := parts().SemiDiff(orders()).(Node)
+	if c := r.Children(); c[0].String() != parts().String() || c[1].String() != orders().String() {
+		t.Errorf("%v has Children() => %v", r, c)
+	}
+	if s := OpSemiDiff.String(); s != "SemiDiff" {
+		t.Errorf("OpSemiDiff.String() => %s, want SemiDiff", s)
+	}
+}
+
+// tests for the parameters of relational expressions
+func TestNodeParams(t *testing.T) {
+	p := Attribute("Color").EQ("Red")
+	if n, ok := parts().Restrict(p).(PredicateNode); !ok || n.Predicate().String() != p.String() {
+		t.Errorf("Restrict() does not provide its predicate")
+	}
+
+	type renameTup struct {
+		No     int
+		PName  string
+		Color  string
+		Weight float64
+		City   string
+	}
+	want := map[Attribute]Attribute{"PNO": "No", "PName": "PName", "Color": "Color", "Weight": "Weight", "City": "City"}
+	if n, ok := parts().Rename(renameTup{}).(RenameNode); !ok || !reflect.DeepEqual(n.Renames(), want) {
+		t.Errorf("Rename() does not provide its renames")
+	}
+
+	type weightTup struct {
+		PNO    int
+		Weight float64
+	}
+	halfWeight := func(tup1 weightTup) weightTup {
+		return weightTup{tup1.PNO, tup1.Weight / 2}
+	}
+	n, ok := parts().Map(halfWeight, [][]string{{"PNO"}}).(FuncNode)
+	if !ok || reflect.ValueOf(n.Func()).Pointer() != reflect.ValueOf(halfWeight).Pointer() {
+		t.Errorf("Map() does not provide its function")
+	}
+
+	type countTup struct {
+		City string
+		N    int
+	}
+	aggs := []Aggregate{Count("N")}
+	g, ok := Summarize(parts(), countTup{}, aggs...).(AggregateNode)
+	if !ok || !reflect.DeepEqual(g.Aggregates(), aggs) {
+		t.Errorf("Summarize() does not provide its aggregates")
+	}
+	if a := aggs[0]; a.Func() != "Count" || a.Attribute() != "" || a.Result() != "N" {
+		t.Errorf("Count(N) => %s, %s, %s", a.Func(), a.Attribute(), a.Result())
+	}
+	if a := Sum("Qty", "Total"); a.Func() != "Sum" || a.Attribute() != "Qty" || a.Result() != "Total" {
+		t.Errorf("Sum(Qty, Total) => %s, %s, %s", a.Func(), a.Attribute(), a.Result())
+	}
+
+	if n, ok := Named("parts", parts()).(NamedNode); !ok || n.Name() != "parts" {
+		t.Errorf("Named() does not provide its name")
+	}
+}
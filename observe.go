@@ -0,0 +1,553 @@
+// observe implements the reporting of events during the evaluation of each
+// of the operations in a relational expression, so that the number of tuples
+// that flow through them and the time that they take can be measured.
+
+package rel
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// EventKind identifies the kind of an observed event
+type EventKind int
+
+const (
+	// EventStart is reported when the evaluation of an operation starts
+	EventStart EventKind = iota
+
+	// EventTuple is reported after an operation has sent a tuple
+	EventTuple
+
+	// EventFinish is reported after an operation has sent all of its tuples
+	EventFinish
+
+	// EventError is reported when an operation finishes with an error,
+	// before EventFinish.
+	EventError
+
+	// EventCancel is reported when the evaluation of an operation is
+	// cancelled by its consumer, instead of EventFinish.
+	EventCancel
+)
+
+// String returns the name of the event kind
+func (k EventKind) String() string {
+	switch k {
+	case EventStart:
+		return "Start"
+	case EventTuple:
+		return "Tuple"
+	case EventFinish:
+		return "Finish"
+	case EventError:
+		return "Error"
+	case EventCancel:
+		return "Cancel"
+	}
+	return "Unknown"
+}
+
+// Event is something that happened during the evaluation of an operation
+type Event struct {
+	Kind EventKind
+
+	// Node is the relation of the operation, as it is in the expression
+	// given to WithObserver.
+	Node Relation
+
+	// ID identifies the operation in the expression given to WithObserver,
+	// which are numbered in depth first order from 0.
+	ID int
+
+	// Time is when the event happened
+	Time time.Time
+
+	// Blocked is the time that the operation spent waiting for its consumer
+	// to receive the tuple, for EventTuple.
+	Blocked time.Duration
+
+	// Err is the error, for EventError
+	Err error
+}
+
+// Observer receives the events of the operations in an expression.  The
+// operations are evaluated concurrently, so Observe has to be safe for
+// concurrent use.
+type Observer interface {
+	Observe(e Event)
+}
+
+// observedExpr is a relation which reports events to an observer while its
+// source relation is evaluated.
+type observedExpr struct {
+	// the input relation, which has observed children
+	source1 Relation
+
+	// node is the relation that the events are reported for
+	node Relation
+
+	// id identifies the node in the observed expression
+	id int
+
+	// obs receives the events
+	obs Observer
+
+	// err is the first error encountered during construction or evaluation
+	err error
+}
+
+// WithObserver returns a relation with the same tuples as r, which reports
+// events for each of the operations in r to obs when it is evaluated.  The
+// source of a Replay is only evaluated once, so it is not observed
+// separately.
+//
+// Queries on the result are not rewritten into queries on r.
+func WithObserver(r Relation, obs Observer) Relation {
+	if r.Err() != nil {
+		// don't bother building the relation and just return the original
+		return r
+	}
+	id := 0
+	return observe(r, obs, &id)
+}
+
+// observe builds an observed relation for r and its children, with IDs in
+// depth first order starting at id
+func observe(r Relation, obs Observer, id *int) Relation {
+	r1 := &observedExpr{source1: r, node: r, id: *id, obs: obs}
+	*id++
+	n, ok := r.(Node)
+	if !ok {
+		return r1
+	}
+	c := n.Children()
+	if len(c) == 0 {
+		return r1
+	}
+	if _, ok := r.(*replayExpr); ok {
+		return r1
+	}
+	c2 := make([]Relation, len(c))
+	for i := range c {
+		c2[i] = observe(c[i], obs, id)
+	}
+	if s, ok := withChildren(r, c2); ok {
+		r1.source1 = s
+	}
+	return r1
+}
+
+// withChildren returns a copy of the relation r with its children replaced,
+// or false if r can't be copied.
+func withChildren(r Relation, c []Relation) (Relation, bool) {
+	switch r1 := r.(type) {
+	case *projectExpr:
+		r2 := *r1
+		r2.source1 = c[0]
+		return &r2, true
+	case *restrictExpr:
+		r2 := *r1
+		r2.source1 = c[0]
+		return &r2, true
+	case *renameExpr:
+		r2 := *r1
+		r2.source1 = c[0]
+		return &r2, true
+	case *groupByExpr:
+		r2 := *r1
+		r2.source1 = c[0]
+		return &r2, true
+	case *mapExpr:
+		r2 := *r1
+		r2.source1 = c[0]
+		return &r2, true
+	case *extendExpr:
+		r2 := *r1
+		r2.source1 = c[0]
+		return &r2, true
+	case *orderExpr:
+		r2 := *r1
+		r2.source1 = c[0]
+		return &r2, true
+	case *namedExpr:
+		r2 := *r1
+		r2.source1 = c[0]
+		return &r2, true
+	case *wrapExpr:
+		r2 := *r1
+		r2.source1 = c[0]
+		return &r2, true
+	case *unwrapExpr:
+		r2 := *r1
+		r2.source1 = c[0]
+		return &r2, true
+	case *groupExpr:
+		r2 := *r1
+		r2.source1 = c[0]
+		return &r2, true
+	case *ungroupExpr:
+		r2 := *r1
+		r2.source1 = c[0]
+		return &r2, true
+	case *tcloseExpr:
+		r2 := *r1
+		r2.source1 = c[0]
+		return &r2, true
+	case *unionExpr:
+		r2 := *r1
+		r2.source1, r2.source2 = c[0], c[1]
+		return &r2, true
+	case *diffExpr:
+		r2 := *r1
+		r2.source1, r2.source2 = c[0], c[1]
+		return &r2, true
+	case *joinExpr:
+		r2 := *r1
+		r2.source1, r2.source2 = c[0], c[1]
+		return &r2, true
+	case *semiJoinExpr:
+		r2 := *r1
+		r2.source1, r2.source2 = c[0], c[1]
+		return &r2, true
+	case *semiDiffExpr:
+		r2 := *r1
+		r2.source1, r2.source2 = c[0], c[1]
+		return &r2, true
+	case *divideExpr:
+		r2 := *r1
+		r2.source1, r2.source2, r2.per = c[0], c[1], c[2]
+		return &r2, true
+	}
+	return r, false
+}
+
+// event reports an event to the observer
+func (r1 *observedExpr) event(kind EventKind, blocked time.Duration, err error) {
+	r1.obs.Observe(Event{Kind: kind, Node: r1.node, ID: r1.id, Time: time.Now(), Blocked: blocked, Err: err})
+}
+
+// TupleChan sends each tuple in the relation to a channel
+func (r1 *observedExpr) TupleChan(t interface{}) chan<- struct{} {
+	cancel := make(chan struct{})
+	// reflect on the channel
+	chv := reflect.ValueOf(t)
+	err := EnsureChan(chv.Type(), r1.Zero())
+	if err != nil {
+		r1.err = err
+		return cancel
+	}
+	if r1.err != nil {
+		chv.Close()
+		return cancel
+	}
+
+	r1.event(EventStart, 0, nil)
+	body := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, reflect.TypeOf(r1.Zero())), 0)
+	bcancel := r1.source1.TupleChan(body.Interface())
+
+	go func(res reflect.Value) {
+		// input channels
+		sourceSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: body}
+		canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+		inCases := []reflect.SelectCase{canSel, sourceSel}
+
+		// output channels
+		resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+		for {
+			chosen, tup, ok := reflect.Select(inCases)
+			if chosen == 0 {
+				close(bcancel)
+				r1.event(EventCancel, 0, nil)
+				return
+			}
+			if !ok {
+				break
+			}
+			start := time.Now()
+			resSel.Send = tup
+			chosen, _, _ = reflect.Select([]reflect.SelectCase{canSel, resSel})
+			if chosen == 0 {
+				close(bcancel)
+				r1.event(EventCancel, 0, nil)
+				return
+			}
+			r1.event(EventTuple, time.Since(start), nil)
+		}
+		if err := r1.source1.Err(); err != nil {
+			r1.err = err
+			r1.event(EventError, 0, err)
+		}
+		r1.event(EventFinish, 0, nil)
+		res.Close()
+	}(chv)
+	return cancel
+}
+
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *observedExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *observedExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *observedExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
+// Zero returns the zero value of the relation (a blank tuple)
+func (r1 *observedExpr) Zero() interface{} {
+	return r1.source1.Zero()
+}
+
+// CKeys is the set of candidate keys in the relation
+func (r1 *observedExpr) CKeys() CandKeys {
+	return r1.source1.CKeys()
+}
+
+// SortOrder is the set of attributes that the relation is sorted on, which
+// is the same as the source relation.
+func (r1 *observedExpr) SortOrder() []Attribute {
+	return SortOrder(r1.source1)
+}
+
+// GoString returns a text representation of the Relation
+func (r1 *observedExpr) GoString() string {
+	return r1.node.GoString()
+}
+
+// String returns a text representation of the Relation
+func (r1 *observedExpr) String() string {
+	return r1.node.String()
+}
+
+// Project creates a new relation with less than or equal degree
+// t2 has to be a new type which is a subdomain of r.
+func (r1 *observedExpr) Project(z2 interface{}) Relation {
+	return Rewrite(r1, NewProject(r1, z2))
+}
+
+// Restrict creates a new relation with less than or equal cardinality
+// p has to be a func(tup T) bool where tup is a subdomain of the input r.
+func (r1 *observedExpr) Restrict(p Predicate) Relation {
+	return Rewrite(r1, NewRestrict(r1, p))
+}
+
+// Rename creates a new relation with new column names
+// z2 has to be a struct with the same number of fields as the input relation
+func (r1 *observedExpr) Rename(z2 interface{}) Relation {
+	return Rewrite(r1, NewRename(r1, z2))
+}
+
+// Union creates a new relation by unioning the bodies of both inputs
+func (r1 *observedExpr) Union(r2 Relation) Relation {
+	return Rewrite(r1, NewUnion(r1, r2))
+}
+
+// Diff creates a new relation by set minusing the two inputs
+func (r1 *observedExpr) Diff(r2 Relation) Relation {
+	return Rewrite(r1, NewDiff(r1, r2))
+}
+
+// Join creates a new relation by performing a natural join on the inputs
+func (r1 *observedExpr) Join(r2 Relation, zero interface{}) Relation {
+	return Rewrite(r1, NewJoin(r1, r2, zero))
+}
+
+// GroupBy creates a new relation by grouping and applying a user defined func
+func (r1 *observedExpr) GroupBy(t2, gfcn interface{}) Relation {
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
+}
+
+// Map creates a new relation by applying a function to tuples in the source
+func (r1 *observedExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *observedExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *observedExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *observedExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *observedExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *observedExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *observedExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *observedExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *observedExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *observedExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *observedExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
+}
+
+// Err returns an error encountered during construction or computation
+func (r1 *observedExpr) Err() error {
+	if r1.err != nil {
+		return r1.err
+	}
+	return r1.source1.Err()
+}
+
+// Op is the operation that produces the relation, which is the same as the
+// observed relation
+func (r1 *observedExpr) Op() OpKind {
+	if n, ok := r1.source1.(Node); ok {
+		return n.Op()
+	}
+	return OpLiteral
+}
+
+// Children are the relations that the operation is performed on, which are
+// also observed
+func (r1 *observedExpr) Children() []Relation {
+	if n, ok := r1.source1.(Node); ok {
+		return n.Children()
+	}
+	return nil
+}
+
+// Stats are the counts and timings of an operation, accumulated over all of
+// its evaluations.
+type Stats struct {
+	// Starts is the number of times the operation was evaluated
+	Starts int
+
+	// Tuples is the number of tuples that the operation sent
+	Tuples int
+
+	// Blocked is the total time that the operation spent waiting for its
+	// consumer to receive tuples
+	Blocked time.Duration
+
+	// Elapsed is the total time between the start of each evaluation and
+	// when it finished or was cancelled
+	Elapsed time.Duration
+
+	// Cancels is the number of evaluations which were cancelled
+	Cancels int
+
+	// Err is the last error that the operation finished with
+	Err error
+
+	// start is the time of the evaluation which started most recently
+	start time.Time
+}
+
+// Collector is an Observer which accumulates the Stats of each operation,
+// which can be in any number of observed expressions.  It is safe for
+// concurrent use.
+type Collector struct {
+	mu    sync.Mutex
+	nodes []Relation
+	stats []*Stats
+}
+
+// index returns the position of the stats of an operation, or -1 if it has
+// not been observed
+func (c *Collector) index(r Relation) int {
+	for i, n := range c.nodes {
+		if sameRelation(n, r) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Observe includes an event in the stats of its operation
+func (c *Collector) Observe(e Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.index(e.Node)
+	if i < 0 {
+		i = len(c.nodes)
+		c.nodes = append(c.nodes, e.Node)
+		c.stats = append(c.stats, &Stats{})
+	}
+	s := c.stats[i]
+	switch e.Kind {
+	case EventStart:
+		s.Starts++
+		s.start = e.Time
+	case EventTuple:
+		s.Tuples++
+		s.Blocked += e.Blocked
+	case EventError:
+		s.Err = e.Err
+	case EventCancel:
+		s.Cancels++
+		s.Elapsed += e.Time.Sub(s.start)
+	case EventFinish:
+		s.Elapsed += e.Time.Sub(s.start)
+	}
+}
+
+// Stats returns the stats of an operation in an observed expression, or nil
+// if it has not been evaluated.
+func (c *Collector) Stats(r Relation) *Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i := c.index(r); i >= 0 {
+		s := *c.stats[i]
+		return &s
+	}
+	return nil
+}
+
+// Annotate sets the Stats of each node of a plan which has been evaluated.
+// The plan should be explained from the same relation that was given to
+// WithObserver.
+func (c *Collector) Annotate(p *Plan) {
+	p.Stats = c.Stats(p.rel)
+	for _, child := range p.Children {
+		c.Annotate(child)
+	}
+}
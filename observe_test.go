@@ -0,0 +1,91 @@
+package rel
+
+import (
+	"testing"
+	"time"
+)
+
+// tests for observing the evaluation of relations
+func TestWithObserver(t *testing.T) {
+	for i, tt := range relationTests() {
+		r := tt.rel()
+		c := &Collector{}
+		o := WithObserver(r, c)
+		if str := o.String(); str != r.String() {
+			t.Errorf("%d has String() => %v, want %v", i, str, r.String())
+		}
+		if card := Card(o); card != tt.expectCard {
+			t.Errorf("%d %v has Card() => %v, want %v", i, r, card, tt.expectCard)
+		}
+		if s := c.Stats(r); s == nil || s.Starts != 1 || s.Tuples != tt.expectCard {
+			t.Errorf("%d %v has Stats() => %+v, want %d tuples", i, r, s, tt.expectCard)
+		}
+	}
+}
+
+// tests for the stats attached to plans
+func TestCollector(t *testing.T) {
+	type joinTup struct {
+		PNO    int
+		SNO    int
+		Qty    int
+		SName  string
+		Status int
+		City   string
+	}
+	r := orders().Restrict(Attribute("Qty").GT(100)).Join(suppliers(), joinTup{})
+	c := &Collector{}
+	if card := Card(WithObserver(r, c)); card != 10 {
+		t.Errorf("observed relation has Card() => %v, want 10", card)
+	}
+	p := Explain(r)
+	c.Annotate(p)
+	var planTest = []struct {
+		p            *Plan
+		expectTuples int
+	}{
+		{p, 10},
+		{p.Children[0], 10},
+		{p.Children[0].Children[0], 12},
+		{p.Children[1], 5},
+	}
+	for i, tt := range planTest {
+		s := tt.p.Stats
+		if s == nil {
+			t.Errorf("%d has no Stats", i)
+			continue
+		}
+		if s.Starts != 1 || s.Tuples != tt.expectTuples || s.Cancels != 0 || s.Err != nil {
+			t.Errorf("%d has Stats => %+v, want %d tuples", i, s, tt.expectTuples)
+		}
+		if s.Elapsed < s.Blocked {
+			t.Errorf("%d has Elapsed %v less than Blocked %v", i, s.Elapsed, s.Blocked)
+		}
+	}
+}
+
+// tests for the events of cancelled and failed evaluations
+func TestObserverEvents(t *testing.T) {
+	c := &Collector{}
+	r := orders()
+	res := make(chan orderTup)
+	cancel := WithObserver(r, c).TupleChan(res)
+	<-res
+	close(cancel)
+	deadline := time.Now().Add(time.Second)
+	for s := c.Stats(r); s == nil || s.Cancels != 1; s = c.Stats(r) {
+		if time.Now().After(deadline) {
+			t.Fatalf("cancellation was not observed, got %+v", s)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	r = &errorRel{orderTup{}, 1, nil}
+	o := WithObserver(r, c)
+	if err := o.TupleSlice(&[]orderTup{}); err == nil {
+		t.Errorf("observed error relation has no error")
+	}
+	if s := c.Stats(r); s == nil || s.Err == nil || s.Tuples != 1 {
+		t.Errorf("error relation has Stats() => %+v", s)
+	}
+}
@@ -0,0 +1,408 @@
+// order implements an ordering of the tuples in a relation.  Ordering is not
+// a part of relational algebra, because relations are sets, but it is useful
+// for presenting results, and some operations (like merge joins) can take
+// advantage of sorted input.
+
+package rel
+
+import (
+	"context"
+	"reflect"
+	"sort"
+)
+
+// Sorted is implemented by relations which send their tuples in a known
+// order.  SortOrder returns the attributes that the tuples are sorted on, in
+// ascending order of significance from first to last.  A nil result indicates
+// that the order of the tuples is unknown.
+type Sorted interface {
+	SortOrder() []Attribute
+}
+
+// SortOrder returns the attributes that the tuples of the input relation are
+// sorted on, or nil if the relation is not known to be sorted.
+func SortOrder(r Relation) []Attribute {
+	if s, ok := r.(Sorted); ok {
+		return s.SortOrder()
+	}
+	return nil
+}
+
+// isOrderPrefix returns true if the tuples sorted in order ord are also
+// sorted in order prefix.
+func isOrderPrefix(prefix, ord []Attribute) bool {
+	if len(prefix) > len(ord) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != ord[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// orderPrefix returns the longest prefix of the sort order which only
+// contains attributes in the domain.  If a relation is sorted by ord, and then
+// some of its attributes are removed, it will still be sorted by the result.
+func orderPrefix(ord, dom []Attribute) []Attribute {
+	for i, att := range ord {
+		if !IsSubDomain([]Attribute{att}, dom) {
+			ord = ord[:i]
+			break
+		}
+	}
+	if len(ord) == 0 {
+		return nil
+	}
+	return ord
+}
+
+// isOrderable returns true if values of the kind can be compared with
+// compareValues.
+func isOrderable(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.String, reflect.Bool:
+		return true
+	}
+	return false
+}
+
+// EnsureOrderable returns an error if any of the attributes in the tuple type
+// e can't be used to order tuples.
+func EnsureOrderable(e reflect.Type, att []Attribute) error {
+	for _, a := range att {
+		if k := e.FieldByIndex(attributeField(e, a)).Type.Kind(); !isOrderable(k) {
+			return &KindError{a, k}
+		}
+	}
+	return nil
+}
+
+// compareValues returns -1 if v1 < v2, 0 if v1 == v2, and 1 if v1 > v2.  The
+// values have to be of the same orderable kind.
+func compareValues(v1, v2 reflect.Value) int {
+	switch v1.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i1, i2 := v1.Int(), v2.Int()
+		if i1 < i2 {
+			return -1
+		} else if i1 > i2 {
+			return 1
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u1, u2 := v1.Uint(), v2.Uint()
+		if u1 < u2 {
+			return -1
+		} else if u1 > u2 {
+			return 1
+		}
+	case reflect.Float32, reflect.Float64:
+		f1, f2 := v1.Float(), v2.Float()
+		if f1 < f2 {
+			return -1
+		} else if f1 > f2 {
+			return 1
+		}
+	case reflect.String:
+		s1, s2 := v1.String(), v2.String()
+		if s1 < s2 {
+			return -1
+		} else if s1 > s2 {
+			return 1
+		}
+	case reflect.Bool:
+		b1, b2 := v1.Bool(), v2.Bool()
+		if !b1 && b2 {
+			return -1
+		} else if b1 && !b2 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// compareTuples compares the fields at positions idx1 in rtup1 with the
+// fields at positions idx2 in rtup2, in order of significance.
+func compareTuples(rtup1, rtup2 reflect.Value, idx1, idx2 [][]int) int {
+	for k := range idx1 {
+		if c := compareValues(rtup1.FieldByIndex(idx1[k]), rtup2.FieldByIndex(idx2[k])); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// orderIndex returns the indexes of the fields which hold the attributes in
+// the tuple type.
+func orderIndex(e reflect.Type, att []Attribute) [][]int {
+	idx := make([][]int, len(att))
+	for i, a := range att {
+		idx[i] = attributeField(e, a)
+	}
+	return idx
+}
+
+// tupleSorter sorts a slice of tuples on a set of fields
+type tupleSorter struct {
+	tups []reflect.Value
+	idx  [][]int
+}
+
+func (ts tupleSorter) Len() int {
+	return len(ts.tups)
+}
+func (ts tupleSorter) Swap(i, j int) {
+	ts.tups[i], ts.tups[j] = ts.tups[j], ts.tups[i]
+}
+func (ts tupleSorter) Less(i, j int) bool {
+	return compareTuples(ts.tups[i], ts.tups[j], ts.idx, ts.idx) < 0
+}
+
+// orderExpr sorts the tuples of a relation.
+// This is one of the operations which consumes memory.  In addition, no values
+// can be sent before all values from the source are consumed.
+type orderExpr struct {
+	// the input relation
+	source1 Relation
+
+	// attributes to sort on, from most significant to least
+	att []Attribute
+
+	// err is the first error encountered during construction or evaluation
+	err error
+}
+
+// TupleChan sends each tuple in the relation to a channel
+func (r1 *orderExpr) TupleChan(t interface{}) chan<- struct{} {
+	cancel := make(chan struct{})
+	// reflect on the channel
+	chv := reflect.ValueOf(t)
+	err := EnsureChan(chv.Type(), r1.source1.Zero())
+	if err != nil {
+		r1.err = err
+		return cancel
+	}
+	if r1.err != nil {
+		chv.Close()
+		return cancel
+	}
+
+	e1 := reflect.TypeOf(r1.source1.Zero())
+
+	// create the channel of tuples from source
+	body := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e1), 0)
+	bcancel := r1.source1.TupleChan(body.Interface())
+
+	go func(body, res reflect.Value) {
+		// input channels
+		sourceSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: body}
+		canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+		inCases := []reflect.SelectCase{canSel, sourceSel}
+
+		// output channels
+		resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+
+		// all of the tuples have to be received before the first can be sent
+		ts := tupleSorter{idx: orderIndex(e1, r1.att)}
+		for {
+			chosen, tup, ok := reflect.Select(inCases)
+			if chosen == 0 {
+				// cancel has been closed, so close the source as well
+				close(bcancel)
+				return
+			}
+			if !ok {
+				// source channel was closed
+				break
+			}
+			ts.tups = append(ts.tups, tup)
+		}
+		if err := r1.source1.Err(); err != nil {
+			r1.err = err
+			res.Close()
+			return
+		}
+
+		// a stable sort retains any ordering from the source that does not
+		// conflict with the new order.
+		sort.Stable(ts)
+		for _, tup := range ts.tups {
+			resSel.Send = tup
+			chosen, _, _ := reflect.Select([]reflect.SelectCase{canSel, resSel})
+			if chosen == 0 {
+				// the source has already completed, so there is nothing to
+				// relay the cancellation to.
+				return
+			}
+		}
+		res.Close()
+	}(body, chv)
+	return cancel
+}
+
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *orderExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *orderExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *orderExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
+// Zero returns the zero value of the relation (a blank tuple)
+func (r1 *orderExpr) Zero() interface{} {
+	return r1.source1.Zero()
+}
+
+// CKeys is the set of candidate keys in the relation
+func (r1 *orderExpr) CKeys() CandKeys {
+	return r1.source1.CKeys()
+}
+
+// SortOrder is the set of attributes that the relation is sorted on
+func (r1 *orderExpr) SortOrder() []Attribute {
+	return r1.att
+}
+
+// GoString returns a text representation of the Relation
+func (r1 *orderExpr) GoString() string {
+	return r1.source1.GoString() + ".Order(" + attributeString(r1.att) + ")"
+}
+
+// String returns a text representation of the Relation
+func (r1 *orderExpr) String() string {
+	return "τ{" + attributeString(r1.att) + "}(" + r1.source1.String() + ")"
+}
+
+// Project creates a new relation with less than or equal degree
+// t2 has to be a new type which is a subdomain of r.
+// If the sort attributes are retained then the project can be performed
+// before the sort, which reduces the amount of memory needed.
+func (r1 *orderExpr) Project(z2 interface{}) Relation {
+	return Rewrite(r1, NewProject(r1, z2))
+}
+
+// Restrict creates a new relation with less than or equal cardinality
+// p has to be a func(tup T) bool where tup is a subdomain of the input r.
+// Restrict can always be performed before the sort.
+func (r1 *orderExpr) Restrict(p Predicate) Relation {
+	return Rewrite(r1, NewRestrict(r1, p))
+}
+
+// Rename creates a new relation with new column names
+// z2 has to be a struct with the same number of fields as the input relation
+// Rename can be performed before the sort if the sort attributes are renamed
+// as well.
+func (r1 *orderExpr) Rename(z2 interface{}) Relation {
+	return Rewrite(r1, NewRename(r1, z2))
+}
+
+// Union creates a new relation by unioning the bodies of both inputs
+func (r1 *orderExpr) Union(r2 Relation) Relation {
+	return Rewrite(r1, NewUnion(r1, r2))
+}
+
+// Diff creates a new relation by set minusing the two inputs
+func (r1 *orderExpr) Diff(r2 Relation) Relation {
+	return Rewrite(r1, NewDiff(r1, r2))
+}
+
+// Join creates a new relation by performing a natural join on the inputs
+func (r1 *orderExpr) Join(r2 Relation, zero interface{}) Relation {
+	return Rewrite(r1, NewJoin(r1, r2, zero))
+}
+
+// GroupBy creates a new relation by grouping and applying a user defined func
+func (r1 *orderExpr) GroupBy(t2, gfcn interface{}) Relation {
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
+}
+
+// Map creates a new relation by applying a function to tuples in the source
+func (r1 *orderExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *orderExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes.
+// The previous ordering is discarded, unless it already satisfies the new one.
+func (r1 *orderExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *orderExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *orderExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *orderExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *orderExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *orderExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *orderExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *orderExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *orderExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
+}
+
+// Err returns an error encountered during construction or computation
+func (r1 *orderExpr) Err() error {
+	return r1.err
+}
+
+// Op is the operation that produces the relation
+func (r1 *orderExpr) Op() OpKind {
+	return OpOrder
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *orderExpr) Children() []Relation {
+	return []Relation{r1.source1}
+}
@@ -0,0 +1,189 @@
+package rel
+
+import (
+	"fmt"
+	"testing"
+)
+
+// tests for order
+func TestOrder(t *testing.T) {
+
+	// the tuples should be sent in order
+	r1 := orders().Order("SNO", "PNO")
+	wantString := `rel.New([]struct {
+ PNO int 
+ SNO int 
+ Qty int 
+}{
+ {1, 1, 300, },
+ {2, 1, 300, },
+ {1, 2, 200, },
+ {2, 2, 400, },
+ {3, 2, 200, },
+ {4, 2, 200, },
+ {1, 3, 400, },
+ {1, 4, 200, },
+ {4, 4, 300, },
+ {1, 5, 100, },
+ {4, 5, 400, },
+ {1, 6, 100, },
+})`
+	if GoString(r1) != wantString {
+		t.Errorf("orders.Order(SNO, PNO).GoString() = \"%s\", want \"%s\"", GoString(r1), wantString)
+	}
+
+	// test the degrees, cardinality, and string representation
+	rel := orders().Order("SNO", "PNO")
+	type distinctTup struct {
+		PNO int
+		SNO int
+	}
+	type nonDistinctTup struct {
+		PNO int
+		Qty int
+	}
+	type titleCaseTup struct {
+		Pno int
+		Sno int
+		Qty int
+	}
+	type joinTup struct {
+		PNO    int
+		SNO    int
+		Qty    int
+		SName  string
+		Status int
+		City   string
+	}
+	type groupByTup struct {
+		PNO int
+		Qty int
+	}
+	type valTup struct {
+		Qty int
+	}
+	groupFcn := func(val <-chan valTup) valTup {
+		res := valTup{}
+		for vi := range val {
+			res.Qty += vi.Qty
+		}
+		return res
+	}
+	type mapRes struct {
+		PNO  int
+		SNO  int
+		Qty1 int
+		Qty2 int
+	}
+	mapFcn := func(tup1 orderTup) mapRes {
+		return mapRes{tup1.PNO, tup1.SNO, tup1.Qty, tup1.Qty * 2}
+	}
+	mapKeys := [][]string{
+		[]string{"PNO", "SNO"},
+	}
+
+	var relTest = []struct {
+		rel          Relation
+		expectString string
+		expectDeg    int
+		expectCard   int
+		expectOrder  []Attribute
+	}{
+		{rel, "τ{SNO, PNO}(Relation(PNO, SNO, Qty))", 3, 12, []Attribute{"SNO", "PNO"}},
+		{rel.Restrict(Attribute("PNO").EQ(1)), "τ{SNO, PNO}(σ{PNO == 1}(Relation(PNO, SNO, Qty)))", 3, 6, []Attribute{"SNO", "PNO"}},
+		{rel.Project(distinctTup{}), "τ{SNO, PNO}(π{PNO, SNO}(Relation(PNO, SNO, Qty)))", 2, 12, []Attribute{"SNO", "PNO"}},
+		{rel.Project(nonDistinctTup{}), "π{PNO, Qty}(τ{SNO, PNO}(Relation(PNO, SNO, Qty)))", 2, 10, nil},
+		{rel.Rename(titleCaseTup{}), "τ{Sno, Pno}(ρ{Pno, Sno, Qty}/{PNO, SNO, Qty}(Relation(PNO, SNO, Qty)))", 3, 12, []Attribute{"Sno", "Pno"}},
+		{rel.Diff(orders()), "τ{SNO, PNO}(Relation(PNO, SNO, Qty)) − Relation(PNO, SNO, Qty)", 3, 0, []Attribute{"SNO", "PNO"}},
+		{rel.Union(orders()), "τ{SNO, PNO}(Relation(PNO, SNO, Qty)) ∪ Relation(PNO, SNO, Qty)", 3, 12, nil},
+		{rel.Join(suppliers(), joinTup{}), "τ{SNO, PNO}(Relation(PNO, SNO, Qty)) ⋈ Relation(SNO, SName, Status, City)", 6, 11, nil},
+		{rel.GroupBy(groupByTup{}, groupFcn), "τ{SNO, PNO}(Relation(PNO, SNO, Qty)).GroupBy({PNO, Qty}->{Qty})", 2, 4, nil},
+		{rel.Map(mapFcn, mapKeys), "τ{SNO, PNO}(Relation(PNO, SNO, Qty)).Map({PNO, SNO, Qty}->{PNO, SNO, Qty1, Qty2})", 4, 12, nil},
+		{rel.Order("Qty"), "τ{Qty}(Relation(PNO, SNO, Qty))", 3, 12, []Attribute{"Qty"}},
+		{rel.Restrict(Attribute("Qty").GT(100)).Order("SNO"), "τ{SNO, PNO}(σ{Qty > 100}(Relation(PNO, SNO, Qty)))", 3, 10, []Attribute{"SNO", "PNO"}},
+	}
+
+	for i, tt := range relTest {
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%d has Err() => %s", i, err.Error())
+			continue
+		}
+		if str := tt.rel.String(); str != tt.expectString {
+			t.Errorf("%d has String() => %v, want %v", i, str, tt.expectString)
+		}
+		if deg := Deg(tt.rel); deg != tt.expectDeg {
+			t.Errorf("%d %s has Deg() => %v, want %v", i, tt.expectString, deg, tt.expectDeg)
+		}
+		if card := Card(tt.rel); card != tt.expectCard {
+			t.Errorf("%d %s has Card() => %v, want %v", i, tt.expectString, card, tt.expectCard)
+		}
+		if ord := SortOrder(tt.rel); fmt.Sprint(ord) != fmt.Sprint(tt.expectOrder) {
+			t.Errorf("%d %s has SortOrder() => %v, want %v", i, tt.expectString, ord, tt.expectOrder)
+		}
+	}
+
+	// test cancellation
+	res := make(chan orderTup)
+	cancel := rel.TupleChan(res)
+	close(cancel)
+	select {
+	case <-res:
+		t.Errorf("cancel did not end tuple generation")
+	default:
+		// passed test
+	}
+
+	// test construction errors
+	type sliceTup struct {
+		Foo []int
+	}
+	if err := orders().Order("Foo").Err(); err == nil {
+		t.Errorf("orders.Order(Foo) did not produce an error")
+	}
+	if err := New([]sliceTup{}, [][]string{}).Order("Foo").Err(); err == nil {
+		t.Errorf("Order(Foo []int) did not produce an error")
+	}
+
+	// test errors
+	err := fmt.Errorf("testing error")
+	rel1 := orders().Order("SNO", "PNO").(*orderExpr)
+	rel1.err = err
+	rel2 := orders().Order("SNO", "PNO").(*orderExpr)
+	rel2.err = err
+	res = make(chan orderTup)
+	_ = rel1.TupleChan(res)
+	if _, ok := <-res; ok {
+		t.Errorf("order did not short circuit TupleChan")
+	}
+	errTest := []Relation{
+		rel1.Project(distinctTup{}),
+		rel1.Restrict(Attribute("PNO").EQ(1)),
+		rel1.Rename(titleCaseTup{}),
+		rel1.Union(rel2),
+		rel.Union(rel2),
+		rel1.Diff(rel2),
+		rel.Diff(rel2),
+		rel1.Join(rel2, orderTup{}),
+		rel.Join(rel2, orderTup{}),
+		rel1.GroupBy(groupByTup{}, groupFcn),
+		rel1.Map(mapFcn, mapKeys),
+		rel1.Order("Qty"),
+	}
+	for i, errRel := range errTest {
+		if errRel.Err() != err {
+			t.Errorf("%d did not short circuit error", i)
+		}
+	}
+}
+
+func BenchmarkOrder(b *testing.B) {
+	exRel := New(exampleRelSlice2(1000), [][]string{[]string{"Foo"}}).Order("Bar", "Foo")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// each iteration produces 1000 tuples
+		t := make(chan exTup2)
+		exRel.TupleChan(t)
+		for _ = range t {
+		}
+	}
+}
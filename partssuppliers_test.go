@@ -74,3 +74,24 @@ func orders() Relation {
 		[]string{"PNO", "SNO"},
 	})
 }
+
+// bill of materials relation, with candidate keys {Major, Minor}.  Each
+// tuple means that the Major part contains the Minor part as an immediate
+// component, as in Date's example of the TCLOSE operator.
+type bomTup struct {
+	Major int
+	Minor int
+}
+
+func bom() Relation {
+	return New([]bomTup{
+		{1, 2},
+		{1, 3},
+		{2, 3},
+		{2, 4},
+		{3, 5},
+		{4, 6},
+	}, [][]string{
+		[]string{"Major", "Minor"},
+	})
+}
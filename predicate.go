@@ -253,15 +253,15 @@ func (p1 AdHoc) EvalFunc(e1 reflect.Type) func(t interface{}) bool {
 	// figure out which fields stay, and where they are in each of the tuple
 	// types.
 	// TODO(jonlawlor): error if fields in e2 are not in r1's tuples.
-	fMap := FieldMap(e1, e2)
+	fMap := fieldMap(e1, e2)
 	pf := reflect.ValueOf(p1.F)
 
 	return func(tup1 interface{}) bool {
 		tup2 := reflect.Indirect(reflect.New(e2))
 		rtup1 := reflect.ValueOf(tup1)
 		for _, fm := range fMap {
-			tupf2 := tup2.Field(fm.J)
-			tupf2.Set(rtup1.Field(fm.I))
+			tupf2 := tup2.FieldByIndex(fm.J)
+			tupf2.Set(rtup1.FieldByIndex(fm.I))
 		}
 
 		parm := make([]reflect.Value, 1)
@@ -336,18 +336,18 @@ func (p1 EQPred) Domain() []Attribute {
 func (p1 EQPred) EvalFunc(e1 reflect.Type) func(t interface{}) bool {
 	// The only method defined on all interfaces is equal & not equal.
 	if len(p1.att) == 2 {
-		att1 := string(p1.att[0])
-		att2 := string(p1.att[1])
+		att1 := attributeField(e1, p1.att[0])
+		att2 := attributeField(e1, p1.att[1])
 		return func(tup1 interface{}) bool {
 			rtup1 := reflect.ValueOf(tup1)
-			return rtup1.FieldByName(att1).Interface() == rtup1.FieldByName(att2).Interface()
+			return rtup1.FieldByIndex(att1).Interface() == rtup1.FieldByIndex(att2).Interface()
 		}
 	}
 	// the second element is a literal
-	att1 := string(p1.att[0])
+	att1 := attributeField(e1, p1.att[0])
 	return func(tup1 interface{}) bool {
 		rtup1 := reflect.ValueOf(tup1)
-		return rtup1.FieldByName(att1).Interface() == p1.lit
+		return rtup1.FieldByIndex(att1).Interface() == p1.lit
 	}
 }
 
@@ -401,17 +401,15 @@ func (p1 LTPred) Domain() []Attribute {
 
 // EvalFunc returns a function which evalutes a predicate on an input tuple
 func (p1 LTPred) EvalFunc(e1 reflect.Type) func(t interface{}) bool {
-	// e1 is currently unused
-
 	// Less than is only defined on numeric and string types
 	// TODO(jonlawlor): this is hideous!
 	if len(p1.att) == 2 {
-		att1 := string(p1.att[0])
-		att2 := string(p1.att[1])
+		att1 := attributeField(e1, p1.att[0])
+		att2 := attributeField(e1, p1.att[1])
 		return func(tup1 interface{}) bool {
 			rtup1 := reflect.ValueOf(tup1)
-			f1 := rtup1.FieldByName(att1).Interface()
-			f2 := rtup1.FieldByName(att2).Interface()
+			f1 := rtup1.FieldByIndex(att1).Interface()
+			f2 := rtup1.FieldByIndex(att2).Interface()
 			switch f1.(type) {
 			default:
 				// I am _REALLY_ unsure this is the desired behavior
@@ -444,10 +442,10 @@ func (p1 LTPred) EvalFunc(e1 reflect.Type) func(t interface{}) bool {
 		}
 	}
 	// the second element is a literal
-	att1 := string(p1.att[0])
+	att1 := attributeField(e1, p1.att[0])
 	return func(tup1 interface{}) bool {
 		rtup1 := reflect.ValueOf(tup1)
-		f1 := rtup1.FieldByName(att1).Interface()
+		f1 := rtup1.FieldByIndex(att1).Interface()
 		switch f1.(type) {
 		default:
 			// I am _REALLY_ unsure this is the desired behavior
@@ -530,17 +528,15 @@ func (p1 LEPred) Domain() []Attribute {
 
 // EvalFunc returns a function which evalutes a predicate on an input tuple
 func (p1 LEPred) EvalFunc(e1 reflect.Type) func(t interface{}) bool {
-	// e1 is currently unused
-
 	// Less than is only defined on numeric and string types
 	// TODO(jonlawlor): this is hideous!
 	if len(p1.att) == 2 {
-		att1 := string(p1.att[0])
-		att2 := string(p1.att[1])
+		att1 := attributeField(e1, p1.att[0])
+		att2 := attributeField(e1, p1.att[1])
 		return func(tup1 interface{}) bool {
 			rtup1 := reflect.ValueOf(tup1)
-			f1 := rtup1.FieldByName(att1).Interface()
-			f2 := rtup1.FieldByName(att2).Interface()
+			f1 := rtup1.FieldByIndex(att1).Interface()
+			f2 := rtup1.FieldByIndex(att2).Interface()
 			switch f1.(type) {
 			default:
 				// I am _REALLY_ unsure this is the desired behavior
@@ -573,10 +569,10 @@ func (p1 LEPred) EvalFunc(e1 reflect.Type) func(t interface{}) bool {
 		}
 	}
 	// the second element is a literal
-	att1 := string(p1.att[0])
+	att1 := attributeField(e1, p1.att[0])
 	return func(tup1 interface{}) bool {
 		rtup1 := reflect.ValueOf(tup1)
-		f1 := rtup1.FieldByName(att1).Interface()
+		f1 := rtup1.FieldByIndex(att1).Interface()
 		switch f1.(type) {
 		default:
 			// I am _REALLY_ unsure this is the desired behavior
@@ -659,17 +655,15 @@ func (p1 GTPred) Domain() []Attribute {
 
 // EvalFunc returns a function which evalutes a predicate on an input tuple
 func (p1 GTPred) EvalFunc(e1 reflect.Type) func(t interface{}) bool {
-	// e1 is currently unused
-
 	// Less than is only defined on numeric and string types
 	// TODO(jonlawlor): this is hideous!
 	if len(p1.att) == 2 {
-		att1 := string(p1.att[0])
-		att2 := string(p1.att[1])
+		att1 := attributeField(e1, p1.att[0])
+		att2 := attributeField(e1, p1.att[1])
 		return func(tup1 interface{}) bool {
 			rtup1 := reflect.ValueOf(tup1)
-			f1 := rtup1.FieldByName(att1).Interface()
-			f2 := rtup1.FieldByName(att2).Interface()
+			f1 := rtup1.FieldByIndex(att1).Interface()
+			f2 := rtup1.FieldByIndex(att2).Interface()
 			switch f1.(type) {
 			default:
 				// I am _REALLY_ unsure this is the desired behavior
@@ -702,10 +696,10 @@ func (p1 GTPred) EvalFunc(e1 reflect.Type) func(t interface{}) bool {
 		}
 	}
 	// the second element is a literal
-	att1 := string(p1.att[0])
+	att1 := attributeField(e1, p1.att[0])
 	return func(tup1 interface{}) bool {
 		rtup1 := reflect.ValueOf(tup1)
-		f1 := rtup1.FieldByName(att1).Interface()
+		f1 := rtup1.FieldByIndex(att1).Interface()
 		switch f1.(type) {
 		default:
 			// I am _REALLY_ unsure this is the desired behavior
@@ -788,17 +782,15 @@ func (p1 GEPred) Domain() []Attribute {
 
 // EvalFunc returns a function which evalutes a predicate on an input tuple
 func (p1 GEPred) EvalFunc(e1 reflect.Type) func(t interface{}) bool {
-	// e1 is currently unused
-
 	// Less than is only defined on numeric and string types
 	// TODO(jonlawlor): this is hideous!
 	if len(p1.att) == 2 {
-		att1 := string(p1.att[0])
-		att2 := string(p1.att[1])
+		att1 := attributeField(e1, p1.att[0])
+		att2 := attributeField(e1, p1.att[1])
 		return func(tup1 interface{}) bool {
 			rtup1 := reflect.ValueOf(tup1)
-			f1 := rtup1.FieldByName(att1).Interface()
-			f2 := rtup1.FieldByName(att2).Interface()
+			f1 := rtup1.FieldByIndex(att1).Interface()
+			f2 := rtup1.FieldByIndex(att2).Interface()
 			switch f1.(type) {
 			default:
 				// I am _REALLY_ unsure this is the desired behavior
@@ -831,10 +823,10 @@ func (p1 GEPred) EvalFunc(e1 reflect.Type) func(t interface{}) bool {
 		}
 	}
 	// the second element is a literal
-	att1 := string(p1.att[0])
+	att1 := attributeField(e1, p1.att[0])
 	return func(tup1 interface{}) bool {
 		rtup1 := reflect.ValueOf(tup1)
-		f1 := rtup1.FieldByName(att1).Interface()
+		f1 := rtup1.FieldByIndex(att1).Interface()
 		switch f1.(type) {
 		default:
 			// I am _REALLY_ unsure this is the desired behavior
@@ -921,18 +913,18 @@ func (p1 NEPred) EvalFunc(e1 reflect.Type) func(t interface{}) bool {
 	// The only method defined on all interfaces is equal & not equal.
 
 	if len(p1.att) == 2 {
-		att1 := string(p1.att[0])
-		att2 := string(p1.att[1])
+		att1 := attributeField(e1, p1.att[0])
+		att2 := attributeField(e1, p1.att[1])
 		return func(tup1 interface{}) bool {
 			rtup1 := reflect.ValueOf(tup1)
-			return rtup1.FieldByName(att1).Interface() != rtup1.FieldByName(att2).Interface()
+			return rtup1.FieldByIndex(att1).Interface() != rtup1.FieldByIndex(att2).Interface()
 		}
 	}
 	// the second element is a literal
-	att1 := string(p1.att[0])
+	att1 := attributeField(e1, p1.att[0])
 	return func(tup1 interface{}) bool {
 		rtup1 := reflect.ValueOf(tup1)
-		return rtup1.FieldByName(att1).Interface() != p1.lit
+		return rtup1.FieldByIndex(att1).Interface() != p1.lit
 	}
 }
 
@@ -3,6 +3,7 @@
 package rel
 
 import (
+	"context"
 	"reflect"
 )
 
@@ -48,16 +49,17 @@ func (r1 *projectExpr) TupleChan(t interface{}) chan<- struct{} {
 
 	// figure out which fields stay, and where they are in each of the tuple
 	// types.
-	fMap := FieldMap(e1, e2)
+	fMap := fieldMap(e1, e2)
 
 	// figure out if we need to distinct the results because there are no
 	// candidate keys left
 	// TODO(jonlawlor): refactor with the code in the CKeys() method, or
 	// include in an isDistinct field?
-	cKeys := SubsetCandidateKeys(r1.source1.CKeys(), Heading(r1.source1), fMap)
+	cKeys := SubsetCandidateKeys(r1.source1.CKeys(), Heading(r1.source1), FieldMap(e1, e2))
 	if len(cKeys) == 0 {
 		go func(body, res reflect.Value) {
-			m := map[interface{}]struct{}{}
+			m := newDistinctSet(e2)
+			defer m.close()
 
 			// input channels
 			sourceSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: body}
@@ -80,12 +82,11 @@ func (r1 *projectExpr) TupleChan(t interface{}) chan<- struct{} {
 				}
 				tup2 := reflect.Indirect(reflect.New(e2))
 				for _, fm := range fMap {
-					tupf2 := tup2.Field(fm.J)
-					tupf2.Set(tup.Field(fm.I))
+					tupf2 := tup2.FieldByIndex(fm.J)
+					tupf2.Set(tup.FieldByIndex(fm.I))
 				}
 				// set the field in the new tuple to the value from the old one
-				if _, isdup := m[tup2.Interface()]; !isdup {
-					m[tup2.Interface()] = struct{}{}
+				if m.add(tup2) {
 					resSel.Send = tup2
 					chosen, _, ok = reflect.Select([]reflect.SelectCase{canSel, resSel})
 					if chosen == 0 {
@@ -94,8 +95,15 @@ func (r1 *projectExpr) TupleChan(t interface{}) chan<- struct{} {
 					}
 				}
 			}
+			// send the tuples that were deferred if the memory budget was
+			// exceeded
+			if !m.flush(func(tup reflect.Value) bool { return sendTuple(res, cancel, tup) }) && m.err == nil {
+				return
+			}
 			if err := r1.source1.Err(); err != nil {
 				r1.err = err
+			} else if m.err != nil {
+				r1.err = m.err
 			}
 			res.Close()
 		}(body, chv)
@@ -127,8 +135,8 @@ func (r1 *projectExpr) TupleChan(t interface{}) chan<- struct{} {
 			}
 			tup2 := reflect.Indirect(reflect.New(e2))
 			for _, fm := range fMap {
-				tupf2 := tup2.Field(fm.J)
-				tupf2.Set(tup.Field(fm.I))
+				tupf2 := tup2.FieldByIndex(fm.J)
+				tupf2.Set(tup.FieldByIndex(fm.I))
 			}
 
 			resSel.Send = tup2
@@ -147,6 +155,22 @@ func (r1 *projectExpr) TupleChan(t interface{}) chan<- struct{} {
 	return cancel
 }
 
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *projectExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *projectExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *projectExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
 // Zero returns the zero value of the relation (a blank tuple)
 func (r1 *projectExpr) Zero() interface{} {
 	return r1.zero
@@ -183,6 +207,20 @@ func (r1 *projectExpr) CKeys() CandKeys {
 
 // text representation
 
+// SortOrder is the set of attributes that the relation is sorted on, which
+// are the sort attributes of the source that have not been projected away.
+// If duplicates have to be removed while a memory budget is in effect, then
+// the tuples that were spilled to disk are sent after the rest, so the
+// results are not sorted.
+func (r1 *projectExpr) SortOrder() []Attribute {
+	e1 := reflect.TypeOf(r1.source1.Zero())
+	e2 := reflect.TypeOf(r1.zero)
+	if tupleBudget(e2) > 0 && len(SubsetCandidateKeys(r1.source1.CKeys(), Heading(r1.source1), FieldMap(e1, e2))) == 0 {
+		return nil
+	}
+	return orderPrefix(SortOrder(r1.source1), Heading(r1))
+}
+
 // GoString returns a text representation of the Relation
 func (r1 *projectExpr) GoString() string {
 	return r1.source1.GoString() + ".Project(" + HeadingString(r1) + ")"
@@ -198,7 +236,7 @@ func (r1 *projectExpr) String() string {
 // This can always be rewritten as a project of the source, and skip the
 // intermediate project.
 func (r1 *projectExpr) Project(z2 interface{}) Relation {
-	return NewProject(r1.source1, z2)
+	return Rewrite(r1, NewProject(r1, z2))
 }
 
 // Restrict creates a new relation with less than or equal cardinality
@@ -206,41 +244,109 @@ func (r1 *projectExpr) Project(z2 interface{}) Relation {
 // This can always be rewritten to pass the restrict up the relational
 // expression.
 func (r1 *projectExpr) Restrict(p Predicate) Relation {
-	return NewProject(r1.source1.Restrict(p), r1.zero)
+	return Rewrite(r1, NewRestrict(r1, p))
 }
 
 // Rename creates a new relation with new column names
 // z2 has to be a struct with the same number of fields as the input relation
 func (r1 *projectExpr) Rename(z2 interface{}) Relation {
-	return NewRename(r1, z2)
+	return Rewrite(r1, NewRename(r1, z2))
 }
 
 // Union creates a new relation by unioning the bodies of both inputs
 func (r1 *projectExpr) Union(r2 Relation) Relation {
-	return NewUnion(r1, r2)
+	return Rewrite(r1, NewUnion(r1, r2))
 }
 
 // Diff creates a new relation by set minusing the two inputs
 func (r1 *projectExpr) Diff(r2 Relation) Relation {
-	return NewDiff(r1, r2)
+	return Rewrite(r1, NewDiff(r1, r2))
 }
 
 // Join creates a new relation by performing a natural join on the inputs
 func (r1 *projectExpr) Join(r2 Relation, zero interface{}) Relation {
-	return NewJoin(r1, r2, zero)
+	return Rewrite(r1, NewJoin(r1, r2, zero))
 }
 
 // GroupBy creates a new relation by grouping and applying a user defined func
 func (r1 *projectExpr) GroupBy(t2, gfcn interface{}) Relation {
-	return NewGroupBy(r1, t2, gfcn)
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
 }
 
 // Map creates a new relation by applying a function to tuples in the source
 func (r1 *projectExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
-	return NewMap(r1, mfcn, ckeystr)
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *projectExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *projectExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *projectExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *projectExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *projectExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *projectExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *projectExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *projectExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *projectExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *projectExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
 }
 
 // Err returns an error encountered during construction or computation
 func (r1 *projectExpr) Err() error {
 	return r1.err
 }
+
+// Op is the operation that produces the relation
+func (r1 *projectExpr) Op() OpKind {
+	return OpProject
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *projectExpr) Children() []Relation {
+	return []Relation{r1.source1}
+}
@@ -3,6 +3,7 @@
 package rel
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"strings"
@@ -38,9 +39,41 @@ type Relation interface {
 	// Err() return.
 	TupleChan(interface{}) (cancel chan<- struct{})
 
-	// TODO(jonlawlor): not sure if these would be useful...
-	//TupleSlice(interface{}) ???
-	//TupleMap(interface{}) ???
+	// TupleChanContext takes a channel with the same element type as Zero,
+	// and sends the results of the relational operation over it.  Unlike
+	// TupleChan, it blocks until all of the results have been sent, so the
+	// channel has to be consumed concurrently, and it closes the channel when
+	// it returns.  If ctx is done before all of the results have been sent,
+	// then the calculations are cancelled and ctx.Err() is returned.
+	// Otherwise, the result is the first error encountered while evaluating
+	// the relation, which is the same as the Err() result.
+	//
+	// If you provide a non channel input or a channel with an element type
+	// that does not match the Zero type, this method will return an error
+	// without sending any results.
+	TupleChanContext(ctx context.Context, t interface{}) error
+
+	// TupleSlice takes a pointer to a slice with the same element type as
+	// Zero, and sets it to a new slice containing all of the tuples in the
+	// relation.  It blocks until the relation has been evaluated, and it
+	// returns the first error encountered while evaluating the relation, in
+	// which case the slice is not modified.
+	//
+	// If you provide something other than a pointer to a slice with an
+	// element type that matches the Zero type, this method will return an
+	// error.
+	TupleSlice(t interface{}) error
+
+	// TupleMap takes a pointer to a map with the same key type as Zero and
+	// a value type of struct{}, and sets it to a new map containing all of
+	// the tuples in the relation.  It blocks until the relation has been
+	// evaluated, and it returns the first error encountered while evaluating
+	// the relation, in which case the map is not modified.
+	//
+	// If you provide something other than a pointer to a map with a key
+	// type that matches the Zero type and empty struct values, this method
+	// will return an error.
+	TupleMap(t interface{}) error
 
 	// primatives of relational algebra
 
@@ -80,6 +113,90 @@ type Relation interface {
 	// have non-nil Err().
 	Map(mfcn interface{}, ckeystr [][]string) Relation
 
+	// Extend adds new attributes to each of the tuples in the source
+	// relation, which are computed from the existing attributes.  z2 is the
+	// resulting tuple type, which has to contain all of the attributes of the
+	// source relation, and efcn is a function which takes as input a
+	// subdomain of the source relation's tuples, and which returns a tuple
+	// with exactly the attributes that z2 adds.  Because the new attributes
+	// are functionally dependent on the existing ones, the resulting relation
+	// has the same candidate keys as the source.  Date calls this operation
+	// EXTEND.
+	//
+	// If z2 does not contain all of the attributes of the source relation,
+	// or if efcn is not a function, or if it does not take tuples that are a
+	// subdomain of the source relation's, or if it does not result in tuples
+	// with the new attributes of z2, then the resulting Relation will have
+	// non-nil Err().
+	Extend(z2, efcn interface{}) Relation
+
+	// Wrap replaces some of the attributes of the relation with a single
+	// tuple-valued attribute, which holds their values.  z2 is the resulting
+	// tuple type, and name is the attribute of z2 that holds the wrapped
+	// attributes, which has to be a struct with the attributes to wrap.  The
+	// rest of the attributes of z2 are the remaining attributes of the
+	// source.  Date calls this operation WRAP.
+	//
+	// If name is not an attribute of z2, or if it is not a struct, or if the
+	// attributes of z2 and name together are not the same as the attributes
+	// of the source relation, or if they have different types, then the
+	// resulting Relation will have non-nil Err().
+	Wrap(z2 interface{}, name Attribute) Relation
+
+	// Unwrap replaces a tuple-valued attribute of the relation with the
+	// attributes that it holds.  The resulting tuple type is constructed
+	// from the fields of the source, so it is an unnamed struct type, which
+	// can be changed with Map.  Date calls this operation UNWRAP.
+	//
+	// If name is not an attribute of the source relation, or if it is not a
+	// struct, or if it holds attributes with the same names as the other
+	// attributes of the source, then the resulting Relation will have
+	// non-nil Err().
+	Unwrap(name Attribute) Relation
+
+	// Group replaces some of the attributes of the relation with a single
+	// relation-valued attribute.  z2 is the resulting tuple type, and name
+	// is the attribute of z2 that holds the groups, which has to have the
+	// type Relation.  The tuples of the source are grouped by the rest of
+	// the attributes of z2, and the source attributes that are not in z2 are
+	// gathered into a relation for each group.  Date calls this operation
+	// GROUP.  The zero tuple of the result holds an empty relation with the
+	// heading of the groups.
+	//
+	// If name is not an attribute of z2, or if it does not have the type
+	// Relation, or if the rest of the attributes of z2 are not attributes of
+	// the source relation with the same types, then the resulting Relation
+	// will have non-nil Err().
+	Group(z2 interface{}, name Attribute) Relation
+
+	// Ungroup replaces a relation-valued attribute of the relation with the
+	// attributes of the relations that it holds, resulting in a tuple for
+	// each of their tuples.  The heading of the relations is determined by
+	// the relation held in the zero tuple of the source, like the results of
+	// Group, and UngroupOf can be used when it does not hold one.  The
+	// resulting tuple type is constructed from the fields of the source and
+	// of that relation, so it is an unnamed struct type, which can be
+	// changed with Map.  Date calls this operation UNGROUP.
+	//
+	// If name is not an attribute of the source relation, or if it does not
+	// have the type Relation, or if the heading of its relations is not
+	// known, or if they have attributes with the same names as the other
+	// attributes of the source, then the resulting Relation will have
+	// non-nil Err().
+	Ungroup(name Attribute) Relation
+
+	// TClose computes the transitive closure of a relation with two
+	// attributes of the same type.  The result has a tuple {x, y} for each
+	// path from x to y through the tuples of the source, so if it has the
+	// tuples {x, y} and {y, z}, then it also has {x, z}.  The resulting
+	// relation has the same tuple type as the source, and its only candidate
+	// key is both attributes.  Date calls this operation TCLOSE.
+	//
+	// If the source relation does not have exactly two attributes, or if
+	// they have different types, then the resulting Relation will have
+	// non-nil Err().
+	TClose() Relation
+
 	// binary primatives
 
 	// Union combines two relations into one relation, using a set union
@@ -92,7 +209,34 @@ type Relation interface {
 	// Relation will have a non nil Err().
 	Diff(r2 Relation) Relation
 
-	// TODO(jonlawlor): implement SemiDiff
+	// SemiJoin reduces the tuples in the relation to the ones that have a
+	// matching tuple in r2, where tuples match if they have identical values
+	// in the attributes that share the same names.  The result has the same
+	// attributes and candidate keys as the source.  Date calls this operation
+	// MATCHING.
+	SemiJoin(r2 Relation) Relation
+
+	// SemiDiff reduces the tuples in the relation to the ones that do not
+	// have a matching tuple in r2, where tuples match if they have identical
+	// values in the attributes that share the same names.  The result has the
+	// same attributes and candidate keys as the source.  Date calls this
+	// operation NOT MATCHING, and it is also known as an antijoin.
+	SemiDiff(r2 Relation) Relation
+
+	// Divide reduces the tuples in the relation to the ones that are related
+	// by per to every tuple in r2, where a tuple is related to a tuple of r2
+	// if per has a tuple with the values of both.  The attributes of the
+	// relation and r2 have to be disjoint, and per has to have all of them,
+	// although it can have others as well.  This answers questions like
+	// "which suppliers supply all of the parts?"  If r2 is empty, then the
+	// result has all of the tuples of the relation.  The result has the same
+	// attributes and candidate keys as the source.  Date calls this
+	// operation DIVIDEBY, and this form of it is known as the Small Divide.
+	//
+	// If the relation and r2 have attributes in common, or if their
+	// attributes are not attributes of per with the same types, then the
+	// resulting Relation will have non-nil Err().
+	Divide(r2, per Relation) Relation
 
 	// Join combines two relations by combining tuples between the two if the
 	// tuples have identical values in the attributes that share the same
@@ -115,12 +259,25 @@ type Relation interface {
 	// also exist in the source relation's tuples, and they are used to
 	// determine unique groups.
 	//
+	// gfcn can also be an Aggregator, which computes the groups from partial
+	// aggregates in parallel.  This is much more efficient than a function
+	// when there are many groups.
+	//
 	// If t2 is not a blank example tuple struct, or if gfcn is not a function
 	// which takes as input a channel with element type a subdomain of the
 	// source relation, or if the result of the function is not a tuple
 	// subdomain of t2, then the Err() result will be set.
 	GroupBy(t2, gfcn interface{}) Relation
 
+	// Order sorts the tuples in the relation on the input attributes, from
+	// most significant to least, in ascending order.  Relations are sets, so
+	// this is not a part of relational algebra, but the resulting relation
+	// will send its tuples in order, and its SortOrder will reflect it.
+	//
+	// If the attributes do not exist in the relation, or if they are not
+	// numeric, string, or bool attributes, then the Err() result will be set.
+	Order(att ...Attribute) Relation
+
 	// String provides a short relational algebra representation of the
 	// relation.  It is particularly useful to determine which rewrite
 	// rules have been applied.
@@ -156,6 +313,16 @@ func New(v interface{}, ckeystr [][]string) Relation {
 	switch rbody.Kind() {
 	case reflect.Map:
 		e := rbody.Type().Key()
+		if hasExcludedFields(e) {
+			// tuples which only differ in their excluded fields are the same
+			// tuple, so a copy is made with those fields cleared.
+			m := reflect.MakeMapWithSize(rbody.Type(), rbody.Len())
+			iter := rbody.MapRange()
+			for iter.Next() {
+				m.SetMapIndex(clearExcluded(iter.Key()), iter.Value())
+			}
+			rbody = m
+		}
 		z := reflect.Indirect(reflect.New(e)).Interface()
 
 		r := new(mapLiteral)
@@ -198,6 +365,13 @@ func New(v interface{}, ckeystr [][]string) Relation {
 
 	case reflect.Slice:
 		e := rbody.Type().Elem()
+		if hasExcludedFields(e) {
+			sl := reflect.MakeSlice(rbody.Type(), rbody.Len(), rbody.Len())
+			for i := 0; i < rbody.Len(); i++ {
+				sl.Index(i).Set(clearExcluded(rbody.Index(i)))
+			}
+			rbody = sl
+		}
 		z := reflect.Indirect(reflect.New(e)).Interface()
 
 		r := new(sliceLiteral)
@@ -228,9 +402,14 @@ func Heading(r Relation) []Attribute {
 // HeadingString is a string representation of the attributes of a relation
 // formatted like "{foo, bar}"
 func HeadingString(r Relation) string {
-	h := Heading(r)
-	s := make([]string, len(h))
-	for i, v := range h {
+	return attributeString(Heading(r))
+}
+
+// attributeString is a string representation of a set of attributes
+// formatted like "foo, bar"
+func attributeString(att []Attribute) string {
+	s := make([]string, len(att))
+	for i, v := range att {
 		s[i] = string(v)
 	}
 	return strings.Join(s, ", ")
@@ -251,19 +430,137 @@ func Deg(r Relation) int {
 // note: this consumes the values of the relation's tuples and can be an
 // expensive operation.
 func Card(r Relation) (i int) {
-	z := r.Zero()
-	e := reflect.TypeOf(z)
+	tups, _ := Tuples(r)
+	for _ = range tups {
+		i++
+	}
+	return
+}
 
-	body := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e), 0)
-	_ = r.TupleChan(body.Interface())
+// TupleChanContext implements the TupleChanContext method of a relation using
+// its TupleChan method.  The results are relayed through an intermediate
+// channel, so that cancellation of the context can be sent to the relation's
+// cancel channel, and so that the relation's error can be returned after the
+// results are complete.  It should be used to implement new Relations.
+func TupleChanContext(ctx context.Context, r Relation, t interface{}) error {
+	chv := reflect.ValueOf(t)
+	if err := EnsureChan(chv.Type(), r.Zero()); err != nil {
+		return err
+	}
+	if err := r.Err(); err != nil {
+		chv.Close()
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		chv.Close()
+		return err
+	}
 
+	body := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, chv.Type().Elem()), 0)
+	bcancel := r.TupleChan(body.Interface())
+
+	// input channels
+	doneSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}
+	sourceSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: body}
+	inCases := []reflect.SelectCase{doneSel, sourceSel}
+
+	// output channels
+	resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: chv}
 	for {
-		if _, ok := body.Recv(); !ok {
+		chosen, tup, ok := reflect.Select(inCases)
+		if chosen == 0 {
+			// the context is done, so cancel the source
+			close(bcancel)
+			chv.Close()
+			return ctx.Err()
+		}
+		if !ok {
+			// source channel was closed
 			break
 		}
-		i++
+		resSel.Send = tup
+		chosen, _, _ = reflect.Select([]reflect.SelectCase{doneSel, resSel})
+		if chosen == 0 {
+			close(bcancel)
+			chv.Close()
+			return ctx.Err()
+		}
 	}
-	return
+	chv.Close()
+	return r.Err()
+}
+
+// ensurePtr returns the value pointed to by t, or an error if t is not a
+// non-nil pointer.
+func ensurePtr(t interface{}) (reflect.Value, error) {
+	ptr := reflect.ValueOf(t)
+	if k := ptr.Kind(); k != reflect.Ptr || ptr.IsNil() {
+		return ptr, &ContainerError{Expected: reflect.Ptr, Found: k}
+	}
+	return ptr.Elem(), nil
+}
+
+// TupleSlice implements the TupleSlice method of a relation by draining its
+// TupleChan into a new slice.  It should be used to implement new Relations.
+func TupleSlice(r Relation, t interface{}) error {
+	slv, err := ensurePtr(t)
+	if err != nil {
+		return err
+	}
+	if err := EnsureSlice(slv.Type(), r.Zero()); err != nil {
+		return err
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+
+	body := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, slv.Type().Elem()), 0)
+	r.TupleChan(body.Interface())
+	res := reflect.MakeSlice(slv.Type(), 0, 0)
+	for {
+		tup, ok := body.Recv()
+		if !ok {
+			break
+		}
+		res = reflect.Append(res, tup)
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	slv.Set(res)
+	return nil
+}
+
+// TupleMap implements the TupleMap method of a relation by draining its
+// TupleChan into a new map.  It should be used to implement new Relations.
+func TupleMap(r Relation, t interface{}) error {
+	mv, err := ensurePtr(t)
+	if err != nil {
+		return err
+	}
+	if err := EnsureMap(mv.Type(), r.Zero()); err != nil {
+		return err
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+
+	body := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, mv.Type().Key()), 0)
+	r.TupleChan(body.Interface())
+	res := reflect.MakeMap(mv.Type())
+	empty := reflect.ValueOf(struct{}{})
+	for {
+		tup, ok := body.Recv()
+		if !ok {
+			break
+		}
+		res.SetMapIndex(tup, empty)
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	mv.Set(res)
+	return nil
 }
 
 // TODO(jonlawlor): avoid error rechecking during query rewrite?
@@ -282,7 +579,7 @@ func NewProject(r1 Relation, z2 interface{}) Relation {
 		// projection is a no op
 		return r1
 	}
-	return &projectExpr{r1, z2, err}
+	return &projectExpr{r1, zeroRelations(z2, r1), err}
 }
 
 // NewRestrict creates a new relation expression with less than or equal cardinality.
@@ -356,7 +653,7 @@ func NewJoin(r1, r2 Relation, zero interface{}) Relation {
 		return r2
 	}
 	err := EnsureSubDomain(FieldNames(reflect.TypeOf(zero)), append(Heading(r1), Heading(r2)...))
-	return &joinExpr{r1, r2, zero, err}
+	return &joinExpr{r1, r2, zeroRelations(zero, r1, r2), err}
 }
 
 // NewGroupBy creates a new relation by grouping and applying a user defined
@@ -368,11 +665,15 @@ func NewGroupBy(r1 Relation, t2, gfcn interface{}) Relation {
 		// don't bother building the relation and just return the original
 		return r1
 	}
+	if agg, ok := gfcn.(Aggregator); ok {
+		intup, outtup, err := EnsureAggregator(agg, r1.Zero(), t2)
+		return &groupByExpr{r1, t2, intup, outtup, reflect.Value{}, agg, err}
+	}
 	// gfcn has to be a function with one input, and one output, where the
 	// input is a subdomain of r1, and where the output is a subdomain of t2.
 	rgfcn := reflect.ValueOf(gfcn)
 	intup, outtup, err := EnsureGroupFunc(rgfcn.Type(), r1.Zero(), t2)
-	return &groupByExpr{r1, t2, intup, outtup, rgfcn, err}
+	return &groupByExpr{r1, t2, intup, outtup, rgfcn, nil, err}
 }
 
 // NewMap creates a new relation by applying a function to tuples in the
@@ -394,3 +695,318 @@ func NewMap(r1 Relation, mfcn interface{}, ckeystr [][]string) Relation {
 	}
 	return &mapExpr{r1, z2, intup, outtup, rmfcn, String2CandKeys(ckeystr), true, err}
 }
+
+// NewExtend creates a new relation by adding attributes computed from the
+// existing attributes of the source.  It should be used to implement new
+// Relations.
+func NewExtend(r1 Relation, z2, efcn interface{}) Relation {
+	if r1.Err() != nil {
+		// don't bother building the relation and just return the original
+		return r1
+	}
+	// efcn has to be a function with one input and one output, where the
+	// input is a subdomain of r1, and where the output has exactly the
+	// attributes in z2 which are not in r1.
+	refcn := reflect.ValueOf(efcn)
+	intup, outtup, err := EnsureMapFunc(refcn.Type(), r1.Zero())
+	if err == nil {
+		att1 := Heading(r1)
+		att2 := FieldNames(reflect.TypeOf(z2))
+		err = EnsureSubDomain(att1, att2)
+		if err == nil {
+			var newAtt []Attribute
+			for _, att := range att2 {
+				if !IsSubDomain([]Attribute{att}, att1) {
+					newAtt = append(newAtt, att)
+				}
+			}
+			err = EnsureSameDomain(FieldNames(outtup), newAtt)
+		}
+	}
+	if err == nil {
+		// the values are copied from the source to the function input and
+		// the result, and from the function output to the result, so the
+		// attributes have to have the same types in each of them
+		e1 := reflect.TypeOf(r1.Zero())
+		e2 := reflect.TypeOf(z2)
+		for _, ts := range [][2]reflect.Type{{e1, intup}, {e1, e2}, {outtup, e2}} {
+			for a, fm := range fieldMap(ts[0], ts[1]) {
+				if t1, t2 := ts[0].FieldByIndex(fm.I).Type, ts[1].FieldByIndex(fm.J).Type; t1 != t2 {
+					return &extendExpr{r1, z2, intup, outtup, refcn, &AttributeTypeError{a, t2, t1}}
+				}
+			}
+		}
+	}
+	return &extendExpr{r1, zeroRelations(z2, r1), intup, outtup, refcn, err}
+}
+
+// NewOrder creates a new relation with its tuples sorted on the input
+// attributes.  It should be used to implement new Relations.
+func NewOrder(r1 Relation, att ...Attribute) Relation {
+	if r1.Err() != nil {
+		// don't bother building the relation and just return the original
+		return r1
+	}
+	err := EnsureSubDomain(att, Heading(r1))
+	if err == nil {
+		err = EnsureOrderable(reflect.TypeOf(r1.Zero()), att)
+	}
+	if err == nil && isOrderPrefix(att, SortOrder(r1)) {
+		// the relation is already sorted
+		return r1
+	}
+	return &orderExpr{r1, att, err}
+}
+
+// NewSemiJoin creates a new relation with the tuples in r1 that match tuples
+// in r2.  It should be used to implement new Relations.
+func NewSemiJoin(r1, r2 Relation) Relation {
+	if r1.Err() != nil {
+		// don't bother building the relation and just return the original
+		return r1
+	}
+	if r2.Err() != nil {
+		// don't bother building the relation and just return the original
+		return r2
+	}
+	return &semiJoinExpr{r1, r2, nil}
+}
+
+// NewSemiDiff creates a new relation with the tuples in r1 that do not match
+// tuples in r2.  It should be used to implement new Relations.
+func NewSemiDiff(r1, r2 Relation) Relation {
+	if r1.Err() != nil {
+		// don't bother building the relation and just return the original
+		return r1
+	}
+	if r2.Err() != nil {
+		// don't bother building the relation and just return the original
+		return r2
+	}
+	return &semiDiffExpr{r1, r2, nil}
+}
+
+// NewTClose creates a new relation with the transitive closure of r1.  It
+// should be used to implement new Relations.
+func NewTClose(r1 Relation) Relation {
+	if r1.Err() != nil {
+		// don't bother building the relation and just return the original
+		return r1
+	}
+	if d := Deg(r1); d != 2 {
+		return &tcloseExpr{r1, &DegreeError{2, d}}
+	}
+	att1 := Heading(r1)
+	types := FieldTypes(reflect.TypeOf(r1.Zero()))
+	if types[0] != types[1] {
+		return &tcloseExpr{r1, &AttributeTypeError{att1[1], types[0], types[1]}}
+	}
+	return &tcloseExpr{r1, nil}
+}
+
+// NewDivide creates a new relation with the tuples in r1 that are related by
+// per to every tuple in r2.  It should be used to implement new Relations.
+func NewDivide(r1, r2, per Relation) Relation {
+	for _, r := range []Relation{r1, r2, per} {
+		if r.Err() != nil {
+			// don't bother building the relation and just return the original
+			return r
+		}
+	}
+	att1 := Heading(r1)
+	att2 := Heading(r2)
+	for _, a := range att1 {
+		if IsSubDomain([]Attribute{a}, att2) {
+			return &divideExpr{r1, r2, per, &AttributeConflictError{a}}
+		}
+	}
+	if err := EnsureSubDomain(append(append([]Attribute(nil), att1...), att2...), Heading(per)); err != nil {
+		return &divideExpr{r1, r2, per, err}
+	}
+	e3 := reflect.TypeOf(per.Zero())
+	for _, r := range []Relation{r1, r2} {
+		e := reflect.TypeOf(r.Zero())
+		for a, fm := range fieldMap(e, e3) {
+			if t, t3 := e.FieldByIndex(fm.I).Type, e3.FieldByIndex(fm.J).Type; t != t3 {
+				return &divideExpr{r1, r2, per, &AttributeTypeError{a, t, t3}}
+			}
+		}
+	}
+	return &divideExpr{r1, r2, per, nil}
+}
+
+// NewWrap creates a new relation with some of the attributes of r1 gathered
+// into the tuple-valued attribute name of z2.  It should be used to
+// implement new Relations.
+func NewWrap(r1 Relation, z2 interface{}, name Attribute) Relation {
+	if r1.Err() != nil {
+		// don't bother building the relation and just return the original
+		return r1
+	}
+	e1 := reflect.TypeOf(r1.Zero())
+	e2 := reflect.TypeOf(z2)
+	att2 := FieldNames(e2)
+	if err := EnsureSubDomain([]Attribute{name}, att2); err != nil {
+		return &wrapExpr{r1, z2, name, err}
+	}
+	ew := e2.FieldByIndex(attributeField(e2, name)).Type
+	if ew.Kind() != reflect.Struct {
+		return &wrapExpr{r1, z2, name, &KindError{name, ew.Kind()}}
+	}
+
+	// the attributes of the result other than name are not wrapped
+	var att []Attribute
+	for _, a := range att2 {
+		if a != name {
+			att = append(att, a)
+		}
+	}
+	for _, a := range FieldNames(ew) {
+		if IsSubDomain([]Attribute{a}, att) {
+			return &wrapExpr{r1, z2, name, &AttributeConflictError{a}}
+		}
+		att = append(att, a)
+	}
+	if err := EnsureSameDomain(att, Heading(r1)); err != nil {
+		return &wrapExpr{r1, z2, name, err}
+	}
+	for a, fm := range wrapMap(e1, e2, name) {
+		if t1, t2 := e1.FieldByIndex(fm.I).Type, e2.FieldByIndex(fm.J).Type; t1 != t2 {
+			return &wrapExpr{r1, z2, name, &AttributeTypeError{a, t2, t1}}
+		}
+	}
+	return &wrapExpr{r1, z2, name, nil}
+}
+
+// NewUnwrap creates a new relation with the tuple-valued attribute name of
+// r1 replaced by the attributes it holds.  It should be used to implement
+// new Relations.
+func NewUnwrap(r1 Relation, name Attribute) Relation {
+	if r1.Err() != nil {
+		// don't bother building the relation and just return the original
+		return r1
+	}
+	e1 := reflect.TypeOf(r1.Zero())
+	att1 := Heading(r1)
+	if err := EnsureSubDomain([]Attribute{name}, att1); err != nil {
+		return &unwrapExpr{r1, r1.Zero(), name, err}
+	}
+	ew := e1.FieldByIndex(attributeField(e1, name)).Type
+	if ew.Kind() != reflect.Struct {
+		return &unwrapExpr{r1, r1.Zero(), name, &KindError{name, ew.Kind()}}
+	}
+	for _, a := range FieldNames(ew) {
+		if a != name && IsSubDomain([]Attribute{a}, att1) {
+			return &unwrapExpr{r1, r1.Zero(), name, &AttributeConflictError{a}}
+		}
+	}
+	z2 := reflect.New(unwrapType(e1, name)).Elem().Interface()
+	return &unwrapExpr{r1, z2, name, nil}
+}
+
+// NewGroup creates a new relation with the tuples of r1 grouped by the
+// attributes of z2 other than name, where the rest of the attributes are
+// gathered into the relation-valued attribute name.  It should be used to
+// implement new Relations.
+func NewGroup(r1 Relation, z2 interface{}, name Attribute) Relation {
+	if r1.Err() != nil {
+		// don't bother building the relation and just return the original
+		return r1
+	}
+	e1 := reflect.TypeOf(r1.Zero())
+	e2 := reflect.TypeOf(z2)
+	att2 := FieldNames(e2)
+	if err := EnsureSubDomain([]Attribute{name}, att2); err != nil {
+		return &groupExpr{r1, z2, name, err}
+	}
+	if t2 := e2.FieldByIndex(attributeField(e2, name)).Type; t2 != relationType {
+		return &groupExpr{r1, z2, name, &AttributeTypeError{name, relationType, t2}}
+	}
+
+	// the attributes of the result other than name are the ones that the
+	// tuples are grouped by
+	var by []Attribute
+	for _, a := range att2 {
+		if a != name {
+			by = append(by, a)
+		}
+	}
+	if err := EnsureSubDomain(by, Heading(r1)); err != nil {
+		return &groupExpr{r1, z2, name, err}
+	}
+	for _, a := range by {
+		t1 := e1.FieldByIndex(attributeField(e1, a)).Type
+		if t2 := e2.FieldByIndex(attributeField(e2, a)).Type; t1 != t2 {
+			return &groupExpr{r1, z2, name, &AttributeTypeError{a, t2, t1}}
+		}
+	}
+
+	// the zero tuple holds an empty relation of the grouped attributes
+	var fields []reflect.StructField
+	var grouped []Attribute
+	for _, f := range attributeFields(e1) {
+		if !IsSubDomain([]Attribute{f.name}, by) {
+			fields = append(fields, e1.FieldByIndex(f.index))
+			grouped = append(grouped, f.name)
+		}
+	}
+	ei := tupleType(fields)
+	inner := New(reflect.MakeSlice(reflect.SliceOf(ei), 0, 0).Interface(), groupKeys(r1.CKeys(), grouped))
+	zero := reflect.New(e2).Elem()
+	zero.FieldByIndex(attributeField(e2, name)).Set(reflect.ValueOf(inner))
+	return &groupExpr{r1, zero.Interface(), name, nil}
+}
+
+// NewUngroup creates a new relation with the relation-valued attribute name
+// of r1 replaced by the tuples of the relations it holds.  It should be used
+// to implement new Relations.
+func NewUngroup(r1 Relation, name Attribute) Relation {
+	return newUngroup(r1, name, nil)
+}
+
+// UngroupOf creates a new relation with the relation-valued attribute name
+// of r1 replaced by the tuples of the relations it holds, which have the
+// tuple type of zi.  Unlike Ungroup, the heading of the relations does not
+// have to be held in the zero tuple of r1, so it can be used on relations
+// like literals created with New, whose zero tuple holds a nil Relation.
+func UngroupOf(r1 Relation, name Attribute, zi interface{}) Relation {
+	if r1.Err() != nil {
+		return r1
+	}
+	if zi == nil || reflect.TypeOf(zi).Kind() != reflect.Struct {
+		return &ungroupExpr{r1, r1.Zero(), name, nil, &HeadingError{name}}
+	}
+	inner := New(reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(zi)), 0, 0).Interface(), nil)
+	return newUngroup(r1, name, inner)
+}
+
+// newUngroup creates an ungroup expression where the heading of the
+// relations held in name is given by inner, or by the relation held in the
+// zero tuple of r1 if inner is nil.
+func newUngroup(r1 Relation, name Attribute, inner Relation) Relation {
+	if r1.Err() != nil {
+		// don't bother building the relation and just return the original
+		return r1
+	}
+	e1 := reflect.TypeOf(r1.Zero())
+	att1 := Heading(r1)
+	if err := EnsureSubDomain([]Attribute{name}, att1); err != nil {
+		return &ungroupExpr{r1, r1.Zero(), name, nil, err}
+	}
+	if t1 := e1.FieldByIndex(attributeField(e1, name)).Type; t1 != relationType {
+		return &ungroupExpr{r1, r1.Zero(), name, nil, &AttributeTypeError{name, relationType, t1}}
+	}
+	if inner == nil {
+		inner = groupedRelation(r1.Zero(), name)
+	}
+	if inner == nil {
+		return &ungroupExpr{r1, r1.Zero(), name, nil, &HeadingError{name}}
+	}
+	for _, a := range Heading(inner) {
+		if a != name && IsSubDomain([]Attribute{a}, att1) {
+			return &ungroupExpr{r1, r1.Zero(), name, inner, &AttributeConflictError{a}}
+		}
+	}
+	z2 := reflect.New(ungroupType(e1, reflect.TypeOf(inner.Zero()), name)).Elem().Interface()
+	return &ungroupExpr{r1, z2, name, inner, nil}
+}
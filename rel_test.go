@@ -1,7 +1,10 @@
 package rel
 
 import (
+	"context"
+	"reflect"
 	"testing"
+	"time"
 )
 
 // TestMatrixExample is an example of sparse matrix algebra implemented in
@@ -171,3 +174,248 @@ func TestNewNonDistinctChan(t *testing.T) {
 		t.Errorf("rel.New has Card() => %v, want %v", c, 1)
 	}
 }
+
+// relationTest is a relation of each of the built in types, along with its
+// expected cardinality
+type relationTest struct {
+	rel        func() Relation
+	expectCard int
+}
+
+// relationTests returns a relation of each of the built in types.  Each of
+// the relations is constructed as needed, because chan literals can only be
+// consumed once.
+func relationTests() []relationTest {
+	type pnoTup struct {
+		PNO int
+	}
+	type titleCaseTup struct {
+		Pno    int
+		PName  string
+		Color  string
+		Weight float64
+		City   string
+	}
+	type cityTup struct {
+		City   string
+		Weight float64
+	}
+	type weightTup struct {
+		PNO    int
+		Weight float64
+	}
+	halfWeight := func(tup1 weightTup) weightTup {
+		return weightTup{tup1.PNO, tup1.Weight / 2}
+	}
+	type heavyTup struct {
+		Heavy bool
+	}
+	type extTup struct {
+		PNO    int
+		PName  string
+		Color  string
+		Weight float64
+		City   string
+		Heavy  bool
+	}
+	isHeavy := func(tup1 weightTup) heavyTup {
+		return heavyTup{tup1.Weight > 15}
+	}
+	type countTup struct {
+		City string
+		N    int
+	}
+	type valTup struct {
+		Weight float64
+	}
+	weightSum := func(val <-chan valTup) valTup {
+		res := valTup{}
+		for vi := range val {
+			res.Weight += vi.Weight
+		}
+		return res
+	}
+	type joinTup struct {
+		PNO    int
+		PName  string
+		Color  string
+		Weight float64
+		City   string
+		SNO    int
+		Qty    int
+	}
+	type placeTup struct {
+		Color string
+		City  string
+	}
+	type cityPartsTup struct {
+		City  string
+		Parts Relation
+	}
+	type snoTup struct {
+		SNO int
+	}
+	type wrapTup struct {
+		PNO    int
+		PName  string
+		Weight float64
+		Place  placeTup
+	}
+
+	return []relationTest{
+		{func() Relation { return parts() }, 6},
+		{func() Relation { return New(exampleRelMap2(10), [][]string{}) }, 10},
+		{func() Relation { return New(exampleRelChan2(10), [][]string{}) }, 10},
+		{func() Relation { return parts().Project(pnoTup{}) }, 6},
+		{func() Relation { return parts().Restrict(Attribute("Color").EQ("Red")) }, 3},
+		{func() Relation { return parts().Rename(titleCaseTup{}) }, 6},
+		{func() Relation { return parts().Union(parts()) }, 6},
+		{func() Relation { return parts().Diff(parts().Restrict(Attribute("Color").EQ("Red"))) }, 3},
+		{func() Relation { return parts().Join(orders(), joinTup{}) }, 12},
+		{func() Relation { return parts().GroupBy(cityTup{}, weightSum) }, 3},
+		{func() Relation { return parts().Map(halfWeight, [][]string{[]string{"PNO"}}) }, 6},
+		{func() Relation { return parts().Extend(extTup{}, isHeavy) }, 6},
+		{func() Relation { return parts().Order("Weight") }, 6},
+		{func() Relation { return parts().SemiJoin(orders()) }, 4},
+		{func() Relation { return parts().SemiDiff(orders()) }, 2},
+		{func() Relation { return Summarize(parts(), countTup{}, Count("N")) }, 3},
+		{func() Relation { return Replay(New(exampleRelChan2(10), [][]string{})) }, 10},
+		{func() Relation { return Named("parts", parts()) }, 6},
+		{func() Relation { return parts().Wrap(wrapTup{}, "Place") }, 6},
+		{func() Relation { return parts().Wrap(wrapTup{}, "Place").Unwrap("Place") }, 6},
+		{func() Relation { return parts().Group(cityPartsTup{}, "Parts") }, 3},
+		{func() Relation { return parts().Group(cityPartsTup{}, "Parts").Ungroup("Parts") }, 6},
+		{func() Relation { return parts().Project(pnoTup{}).Divide(suppliers().Project(snoTup{}), orders()) }, 1},
+		{func() Relation { return bom().TClose() }, 11},
+	}
+}
+
+// test of TupleChanContext on each of the built in relations
+func TestTupleChanContext(t *testing.T) {
+	for i, tt := range relationTests() {
+		// all of the tuples are sent, and the channel is closed
+		r := tt.rel()
+		ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, reflect.TypeOf(r.Zero())), 0)
+		errc := make(chan error, 1)
+		go func() {
+			errc <- r.TupleChanContext(context.Background(), ch.Interface())
+		}()
+		card := 0
+		for {
+			if _, ok := ch.Recv(); !ok {
+				break
+			}
+			card++
+		}
+		if err := <-errc; err != nil {
+			t.Errorf("%d %s has TupleChanContext() => %s", i, r, err)
+		}
+		if card != tt.expectCard {
+			t.Errorf("%d %s has TupleChanContext() with %d tuples, want %d", i, r, card, tt.expectCard)
+		}
+
+		// cancellation stops the results and closes the channel
+		r = tt.rel()
+		ch = reflect.MakeChan(reflect.ChanOf(reflect.BothDir, reflect.TypeOf(r.Zero())), 0)
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			errc <- r.TupleChanContext(ctx, ch.Interface())
+		}()
+		ch.Recv()
+		cancel()
+		if err := <-errc; err != context.Canceled {
+			t.Errorf("%d %s has TupleChanContext() => %v after cancel, want %v", i, r, err, context.Canceled)
+		}
+		if _, ok := ch.Recv(); ok {
+			t.Errorf("%d %s did not close the channel after cancel", i, r)
+		}
+
+		// deadlines stop the results
+		r = tt.rel()
+		ch = reflect.MakeChan(reflect.ChanOf(reflect.BothDir, reflect.TypeOf(r.Zero())), 0)
+		ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+		if err := r.TupleChanContext(ctx, ch.Interface()); err != context.DeadlineExceeded {
+			t.Errorf("%d %s has TupleChanContext() => %v after deadline, want %v", i, r, err, context.DeadlineExceeded)
+		}
+		cancel()
+
+		// the wrong type of channel is an error
+		if err := tt.rel().TupleChanContext(context.Background(), make(chan struct{})); err == nil {
+			t.Errorf("%d %s did not return an error for an invalid channel", i, r)
+		}
+	}
+
+	// errors during evaluation are returned
+	r := &errorRel{partTup{}, 2, nil}
+	ch := make(chan partTup)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- r.Restrict(Attribute("Color").EQ("Red")).TupleChanContext(context.Background(), ch)
+	}()
+	for _ = range ch {
+	}
+	if err := <-errc; err == nil {
+		t.Errorf("TupleChanContext did not return an evaluation error")
+	}
+}
+
+// test of TupleSlice and TupleMap on each of the built in relations
+func TestTupleSliceMap(t *testing.T) {
+	for i, tt := range relationTests() {
+		r := tt.rel()
+		e := reflect.TypeOf(r.Zero())
+		sl := reflect.New(reflect.SliceOf(e))
+		if err := r.TupleSlice(sl.Interface()); err != nil {
+			t.Errorf("%d %s has TupleSlice() => %s", i, r, err)
+		}
+		if n := sl.Elem().Len(); n != tt.expectCard {
+			t.Errorf("%d %s has TupleSlice() with %d tuples, want %d", i, r, n, tt.expectCard)
+		}
+
+		r = tt.rel()
+		m := reflect.New(reflect.MapOf(e, reflect.TypeOf(struct{}{})))
+		if err := r.TupleMap(m.Interface()); err != nil {
+			t.Errorf("%d %s has TupleMap() => %s", i, r, err)
+		}
+		if n := m.Elem().Len(); n != tt.expectCard {
+			t.Errorf("%d %s has TupleMap() with %d tuples, want %d", i, r, n, tt.expectCard)
+		}
+
+		// the wrong types of containers are errors
+		r = tt.rel()
+		var badSlice []struct{}
+		if _, ok := r.TupleSlice(&badSlice).(*ElemError); !ok {
+			t.Errorf("%d %s did not return an ElemError for the wrong slice type", i, r)
+		}
+		if _, ok := r.TupleSlice(sl.Elem().Interface()).(*ContainerError); !ok {
+			t.Errorf("%d %s did not return a ContainerError for a non pointer", i, r)
+		}
+		if _, ok := r.TupleMap(sl.Interface()).(*ContainerError); !ok {
+			t.Errorf("%d %s did not return a ContainerError for a slice given to TupleMap", i, r)
+		}
+		badMap := reflect.New(reflect.MapOf(e, reflect.TypeOf(0)))
+		if err := r.TupleMap(badMap.Interface()); err == nil {
+			t.Errorf("%d %s did not return an error for a non empty map value", i, r)
+		}
+	}
+
+	// duplicates are removed from slice literals
+	var tups []orderTup
+	r := New([]orderTup{{1, 1, 300}, {1, 1, 300}, {1, 2, 200}}, nil)
+	if err := r.TupleSlice(&tups); err != nil || len(tups) != 2 {
+		t.Errorf("TupleSlice() => %v, %v, want 2 distinct tuples", tups, err)
+	}
+
+	// errors during evaluation are returned, and the container is unchanged
+	tups = nil
+	if err := (&errorRel{orderTup{}, 2, nil}).TupleSlice(&tups); err == nil || tups != nil {
+		t.Errorf("TupleSlice() => %v, %v, want an error and no tuples", tups, err)
+	}
+	var m map[orderTup]struct{}
+	if err := (&errorRel{orderTup{}, 2, nil}).TupleMap(&m); err == nil || m != nil {
+		t.Errorf("TupleMap() => %v, %v, want an error and no tuples", m, err)
+	}
+	if err := parts().Project(orderTup{}).TupleSlice(&tups); err == nil {
+		t.Errorf("TupleSlice() did not return a construction error")
+	}
+}
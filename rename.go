@@ -5,6 +5,7 @@
 package rel
 
 import (
+	"context"
 	"reflect"
 )
 
@@ -46,8 +47,9 @@ func (r1 *renameExpr) TupleChan(t interface{}) chan<- struct{} {
 	bcancel := r1.source1.TupleChan(body.Interface())
 
 	// assign the values of the original to the new names in the same
-	// locations
-	n := e2.NumField()
+	// positions, which may be in different fields if any are excluded
+	idx1 := AttributeFields(e1)
+	idx2 := AttributeFields(e2)
 
 	go func(body, res reflect.Value) {
 		// input channels
@@ -97,9 +99,9 @@ func (r1 *renameExpr) TupleChan(t interface{}) chan<- struct{} {
 			}
 
 			tup2 := reflect.Indirect(reflect.New(e2))
-			for i := 0; i < n; i++ {
-				tupf2 := tup2.Field(i)
-				tupf2.Set(tup.Field(i))
+			for i, j := range idx2 {
+				tupf2 := tup2.FieldByIndex(j)
+				tupf2.Set(tup.FieldByIndex(idx1[i]))
 			}
 			resSel.Send = tup2
 			chosen, _, ok = reflect.Select([]reflect.SelectCase{canSel, resSel})
@@ -116,6 +118,22 @@ func (r1 *renameExpr) TupleChan(t interface{}) chan<- struct{} {
 	return cancel
 }
 
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *renameExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *renameExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *renameExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
 // Zero returns the zero value of the relation (a blank tuple)
 func (r1 *renameExpr) Zero() interface{} {
 	return r1.zero
@@ -150,6 +168,25 @@ func (r1 *renameExpr) CKeys() CandKeys {
 	return cKeys2
 }
 
+// SortOrder is the set of attributes that the relation is sorted on
+func (r1 *renameExpr) SortOrder() []Attribute {
+	ord1 := SortOrder(r1.source1)
+	if ord1 == nil {
+		return nil
+	}
+	names1 := Heading(r1.source1)
+	names2 := Heading(r1)
+	ord2 := make([]Attribute, len(ord1))
+	for i, att := range ord1 {
+		for j := range names1 {
+			if names1[j] == att {
+				ord2[i] = names2[j]
+			}
+		}
+	}
+	return ord2
+}
+
 // GoString returns a text representation of the Relation
 func (r1 *renameExpr) GoString() string {
 	return r1.source1.GoString() + ".Rename(" + HeadingString(r1) + ")"
@@ -173,41 +210,120 @@ func (r1 *renameExpr) Project(z2 interface{}) Relation {
 // Restrict creates a new relation with less than or equal cardinality
 // p has to be a func(tup T) bool where tup is a subdomain of the input r.
 func (r1 *renameExpr) Restrict(p Predicate) Relation {
-	return NewRestrict(r1, p)
+	return Rewrite(r1, NewRestrict(r1, p))
 }
 
 // Rename creates a new relation with new column names
 // z2 has to be a struct with the same number of fields as the input relation
 func (r1 *renameExpr) Rename(z2 interface{}) Relation {
-	return NewRename(r1.source1, z2)
+	return Rewrite(r1, NewRename(r1, z2))
 }
 
 // Union creates a new relation by unioning the bodies of both inputs
 func (r1 *renameExpr) Union(r2 Relation) Relation {
-	return NewUnion(r1, r2)
+	return Rewrite(r1, NewUnion(r1, r2))
 }
 
 // Diff creates a new relation by set minusing the two inputs
 func (r1 *renameExpr) Diff(r2 Relation) Relation {
-	return NewDiff(r1, r2)
+	return Rewrite(r1, NewDiff(r1, r2))
 }
 
 // Join creates a new relation by performing a natural join on the inputs
 func (r1 *renameExpr) Join(r2 Relation, zero interface{}) Relation {
-	return NewJoin(r1, r2, zero)
+	return Rewrite(r1, NewJoin(r1, r2, zero))
 }
 
 // GroupBy creates a new relation by grouping and applying a user defined func
 func (r1 *renameExpr) GroupBy(t2, gfcn interface{}) Relation {
-	return NewGroupBy(r1, t2, gfcn)
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
 }
 
 // Map creates a new relation by applying a function to tuples in the source
 func (r1 *renameExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
-	return NewMap(r1, mfcn, ckeystr)
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *renameExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *renameExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *renameExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *renameExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *renameExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *renameExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *renameExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *renameExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *renameExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *renameExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
 }
 
 // Err returns an error encountered during construction or computation
 func (r1 *renameExpr) Err() error {
 	return r1.err
 }
+
+// Op is the operation that produces the relation
+func (r1 *renameExpr) Op() OpKind {
+	return OpRename
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *renameExpr) Children() []Relation {
+	return []Relation{r1.source1}
+}
+
+// Renames is a map from the attributes of the source to their new names
+func (r1 *renameExpr) Renames() map[Attribute]Attribute {
+	names1 := Heading(r1.source1)
+	names2 := Heading(r1)
+	m := make(map[Attribute]Attribute, len(names1))
+	for i, att := range names1 {
+		m[att] = names2[i]
+	}
+	return m
+}
@@ -3,6 +3,7 @@
 package rel
 
 import (
+	"context"
 	"reflect"
 	"runtime"
 	"sync"
@@ -40,6 +41,10 @@ func (r1 *restrictExpr) TupleChan(t interface{}) chan<- struct{} {
 	// transform the channel of tuples from the relation
 	// TODO(jonlawlor): add a mechanism for concurrency to be modified.
 	mc := runtime.GOMAXPROCS(-1)
+	if SortOrder(r1.source1) != nil {
+		// concurrent evaluation would not retain the order of the source
+		mc = 1
+	}
 
 	z1 := r1.source1.Zero()
 	e1 := reflect.TypeOf(z1)
@@ -102,6 +107,22 @@ func (r1 *restrictExpr) TupleChan(t interface{}) chan<- struct{} {
 	return cancel
 }
 
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *restrictExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *restrictExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *restrictExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
 // Zero returns the zero value of the relation (a blank tuple)
 func (r1 *restrictExpr) Zero() interface{} {
 	return r1.source1.Zero()
@@ -112,6 +133,11 @@ func (r1 *restrictExpr) CKeys() CandKeys {
 	return r1.source1.CKeys()
 }
 
+// SortOrder is the set of attributes that the relation is sorted on
+func (r1 *restrictExpr) SortOrder() []Attribute {
+	return SortOrder(r1.source1)
+}
+
 // GoString returns a text representation of the Relation
 func (r1 *restrictExpr) GoString() string {
 	return r1.source1.GoString() + ".Restrict(" + r1.p.String() + ")"
@@ -127,11 +153,7 @@ func (r1 *restrictExpr) String() string {
 // Project can be rewritten if the Predicate can be evaluated on the Project's
 // results.
 func (r1 *restrictExpr) Project(z2 interface{}) Relation {
-	att2 := FieldNames(reflect.TypeOf(z2))
-	if IsSubDomain(r1.p.Domain(), att2) { // the predicate's attributes exist after project
-		return NewRestrict(r1.source1.Project(z2), r1.p)
-	}
-	return NewProject(r1, z2)
+	return Rewrite(r1, NewProject(r1, z2))
 }
 
 // Restrict creates a new relation with less than or equal cardinality
@@ -139,48 +161,115 @@ func (r1 *restrictExpr) Project(z2 interface{}) Relation {
 // Restrict can be rewritten by switching the order of inputs, which may allow
 // some predicates to pass through to source relations.
 func (r1 *restrictExpr) Restrict(p Predicate) Relation {
-	// try reversing the order, which may allow some lower degree restrictions
-	// to pass through
-	return NewRestrict(r1.source1.Restrict(p), r1.p)
+	return Rewrite(r1, NewRestrict(r1, p))
 }
 
 // Rename creates a new relation with new column names
 // z2 has to be a struct with the same number of fields as the input relation
 func (r1 *restrictExpr) Rename(z2 interface{}) Relation {
-	return NewRename(r1, z2)
+	return Rewrite(r1, NewRename(r1, z2))
 }
 
 // Union creates a new relation by unioning the bodies of both inputs
 func (r1 *restrictExpr) Union(r2 Relation) Relation {
-	return NewUnion(r1, r2)
+	return Rewrite(r1, NewUnion(r1, r2))
 }
 
 // Diff creates a new relation by set minusing the two inputs
 func (r1 *restrictExpr) Diff(r2 Relation) Relation {
-	// TODO(jonlawlor): we could apply the predicate to r2 and produce the
-	// same result?
-	return NewDiff(r1, r2)
+	return Rewrite(r1, NewDiff(r1, r2))
 }
 
 // Join creates a new relation by performing a natural join on the inputs
 func (r1 *restrictExpr) Join(r2 Relation, zero interface{}) Relation {
-	// TODO(jonlawlor): we could sometimes apply the predicate to r2 and reduce
-	// the number of comparisons in the join.
-	return NewJoin(r1, r2, zero)
+	return Rewrite(r1, NewJoin(r1, r2, zero))
 }
 
 // GroupBy creates a new relation by grouping and applying a user defined func
 //
 func (r1 *restrictExpr) GroupBy(t2, gfcn interface{}) Relation {
-	return NewGroupBy(r1, t2, gfcn)
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
 }
 
 // Map creates a new relation by applying a function to tuples in the source
 func (r1 *restrictExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
-	return NewMap(r1, mfcn, ckeystr)
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *restrictExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *restrictExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *restrictExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *restrictExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *restrictExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *restrictExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *restrictExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *restrictExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *restrictExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *restrictExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
 }
 
 // Err returns an error encountered during construction or computation
 func (r1 *restrictExpr) Err() error {
 	return r1.err
 }
+
+// Op is the operation that produces the relation
+func (r1 *restrictExpr) Op() OpKind {
+	return OpRestrict
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *restrictExpr) Children() []Relation {
+	return []Relation{r1.source1}
+}
+
+// Predicate is the predicate that tuples have to satisfy
+func (r1 *restrictExpr) Predicate() Predicate {
+	return r1.p
+}
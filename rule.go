@@ -0,0 +1,565 @@
+// rule implements the rewriting of relational expressions.  Each operation on
+// a relation builds a new expression node, and then the rules are tried on it
+// in turn, and the first one which applies replaces it with an equivalent
+// expression which is expected to be cheaper to evaluate, usually by moving
+// a restriction or projection closer to the source relations.
+
+package rel
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Rule is a rewrite of relational expressions into equivalent ones.
+type Rule interface {
+	// Name identifies the rule in traces
+	Name() string
+
+	// Apply returns an expression which is equivalent to n and true if the
+	// rule matches n, or nil and false if it does not.  The result is not
+	// rewritten again, so it should be built with the methods of the
+	// relations it contains, which apply the rules to new expressions, and
+	// with the NewXXX functions where applying the rules could undo the
+	// rewrite.
+	Apply(n Node) (Relation, bool)
+}
+
+// funcRule is a rule which is implemented by a function
+type funcRule struct {
+	name  string
+	apply func(n Node) (Relation, bool)
+}
+
+// NewRule creates a new rule with the given name from a function, which
+// implements its Apply method.
+func NewRule(name string, apply func(n Node) (Relation, bool)) Rule {
+	return &funcRule{name, apply}
+}
+
+// Name identifies the rule in traces
+func (r *funcRule) Name() string {
+	return r.name
+}
+
+// Apply returns an expression which is equivalent to n and true if the rule
+// matches n
+func (r *funcRule) Apply(n Node) (Relation, bool) {
+	return r.apply(n)
+}
+
+// RuleTracer is notified when rules rewrite expressions.
+type RuleTracer interface {
+	// Rewrote is called after rule has rewritten the expression before into
+	// the expression after.  Rewrites of the children of before, which are
+	// performed while the rule is applied, are reported first.
+	Rewrote(rule Rule, before, after Relation)
+}
+
+// RuleEvent is a rewrite recorded by a RuleTrace
+type RuleEvent struct {
+	// Rule is the name of the rule
+	Rule string
+
+	// Before and After are the expressions before and after the rewrite
+	Before, After Relation
+}
+
+// String returns a text representation of the rewrite
+func (e RuleEvent) String() string {
+	return e.Rule + ": " + e.Before.String() + " => " + e.After.String()
+}
+
+// RuleTrace is a RuleTracer which remembers the rewrites.  It is safe for
+// concurrent use.
+type RuleTrace struct {
+	mu     sync.Mutex
+	events []RuleEvent
+}
+
+// Rewrote records a rewrite
+func (t *RuleTrace) Rewrote(rule Rule, before, after Relation) {
+	t.mu.Lock()
+	t.events = append(t.events, RuleEvent{rule.Name(), before, after})
+	t.mu.Unlock()
+}
+
+// Events returns the rewrites that have been recorded, in the order that
+// they were reported.
+func (t *RuleTrace) Events() []RuleEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]RuleEvent(nil), t.events...)
+}
+
+// the rules and tracer which are in use
+var (
+	rulesMu    sync.RWMutex
+	rules      []Rule
+	ruleTracer RuleTracer
+)
+
+func init() {
+	// the default rules refer to the methods of the relations, which refer
+	// to the rules, so they can't be set in the declaration
+	rules = DefaultRules()
+}
+
+// Rules returns the rules which are applied to new expressions, in the order
+// that they are tried.
+func Rules() []Rule {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	return append([]Rule(nil), rules...)
+}
+
+// SetRules replaces the rules which are applied to new expressions, and
+// returns the previous rules.  An empty set of rules disables rewriting.
+// The rules apply to expressions that are built after they are set.
+func SetRules(rs []Rule) []Rule {
+	rs = append([]Rule(nil), rs...)
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	old := rules
+	rules = rs
+	return old
+}
+
+// RegisterRule adds a rule which is applied to new expressions.  Registered
+// rules are tried before the rules that were already in use, so they can
+// take the place of the default rules for the expressions they match.
+func RegisterRule(r Rule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules = append([]Rule{r}, rules...)
+}
+
+// SetRuleTracer sets the tracer which is notified of each rewrite, and
+// returns the previous tracer.  A nil tracer, which is the default, disables
+// tracing.
+func SetRuleTracer(t RuleTracer) RuleTracer {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	old := ruleTracer
+	ruleTracer = t
+	return old
+}
+
+// Rewrite applies the rules to r2, which is a new expression built on r1, as
+// in Rewrite(r1, NewRestrict(r1, p)), and returns the result of the first
+// rule which matches it, or r2 if none do.  It should be used to implement
+// new Relations, so that the rules apply to operations on them.  The rules
+// are not applied to expressions with errors, or if r2 is r1, which happens
+// when the operation would have no effect.
+func Rewrite(r1, r2 Relation) Relation {
+	if r2.Err() != nil {
+		return r2
+	}
+	if sameRelation(r1, r2) {
+		return r2
+	}
+	n, ok := r2.(Node)
+	if !ok {
+		return r2
+	}
+	rulesMu.RLock()
+	rs, tracer := rules, ruleTracer
+	rulesMu.RUnlock()
+	for _, rule := range rs {
+		if r3, ok := rule.Apply(n); ok {
+			if tracer != nil {
+				tracer.Rewrote(rule, r2, r3)
+			}
+			return r3
+		}
+	}
+	return r2
+}
+
+// DefaultRules returns the rules which are in use unless they are replaced
+// by SetRules.  They move restrictions and projections towards the source
+// relations, and merge operations which can be combined.
+func DefaultRules() []Rule {
+	return []Rule{
+		NewRule("MergeProject", mergeProject),
+		NewRule("ProjectRestrict", projectRestrict),
+		NewRule("ProjectUnion", projectUnion),
+		NewRule("ProjectExtend", projectExtend),
+		NewRule("ProjectOrder", projectOrder),
+		NewRule("ProjectSemi", projectSemi),
+		NewRule("RestrictProject", restrictProject),
+		NewRule("RestrictRestrict", restrictRestrict),
+		NewRule("RestrictUnion", restrictUnion),
+		NewRule("RestrictDiff", restrictDiff),
+		NewRule("RestrictJoin", restrictJoin),
+		NewRule("RestrictExtend", restrictExtend),
+		NewRule("RestrictOrder", restrictOrder),
+		NewRule("RestrictSemi", restrictSemi),
+		NewRule("MergeRename", mergeRename),
+		NewRule("RenameUnion", renameUnion),
+		NewRule("RenameDiff", renameDiff),
+		NewRule("RenameOrder", renameOrder),
+		NewRule("SemiUnion", semiUnion),
+		NewRule("MergeOrder", mergeOrder),
+	}
+}
+
+// DiffRestrict and JoinRestrict are rules which are not in the default set,
+// because they add a restriction to the second input of a set difference or
+// join, which only reduces the cost of evaluating it if the restriction can
+// be moved towards the source relations.  They can be added with
+// RegisterRule.
+var (
+	DiffRestrict = NewRule("DiffRestrict", diffRestrict)
+	JoinRestrict = NewRule("JoinRestrict", joinRestrict)
+)
+
+// restrictedBy returns true if r is a restriction by a predicate which is
+// the same as p.  AdHoc predicates hold funcs, which can't be compared, so
+// predicates which contain them are never the same as any other.
+func restrictedBy(r Relation, p Predicate) bool {
+	r1, ok := r.(*restrictExpr)
+	return ok && reflect.DeepEqual(r1.p, p)
+}
+
+// mergeProject replaces a projection of a projection with a single one.
+func mergeProject(n Node) (Relation, bool) {
+	r1, ok := n.(*projectExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*projectExpr)
+	if !ok {
+		return nil, false
+	}
+	return NewProject(s.source1, r1.zero), true
+}
+
+// projectRestrict moves a projection below a restriction if the predicate
+// can be evaluated on the results of the projection.
+func projectRestrict(n Node) (Relation, bool) {
+	r1, ok := n.(*projectExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*restrictExpr)
+	if !ok || !IsSubDomain(s.p.Domain(), FieldNames(reflect.TypeOf(r1.zero))) {
+		return nil, false
+	}
+	return NewRestrict(s.source1.Project(r1.zero), s.p), true
+}
+
+// projectUnion moves a projection into both sides of a union.
+func projectUnion(n Node) (Relation, bool) {
+	r1, ok := n.(*projectExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*unionExpr)
+	if !ok {
+		return nil, false
+	}
+	return NewUnion(s.source1.Project(r1.zero), s.source2.Project(r1.zero)), true
+}
+
+// projectExtend removes an extension if the projection does not keep any of
+// the attributes that it adds.
+func projectExtend(n Node) (Relation, bool) {
+	r1, ok := n.(*projectExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*extendExpr)
+	if !ok || !IsSubDomain(FieldNames(reflect.TypeOf(r1.zero)), Heading(s.source1)) {
+		return nil, false
+	}
+	return s.source1.Project(r1.zero), true
+}
+
+// projectOrder moves a projection below an ordering if the projection keeps
+// the attributes that the tuples are sorted on.
+func projectOrder(n Node) (Relation, bool) {
+	r1, ok := n.(*projectExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*orderExpr)
+	if !ok || !IsSubDomain(s.att, FieldNames(reflect.TypeOf(r1.zero))) {
+		return nil, false
+	}
+	return NewOrder(s.source1.Project(r1.zero), s.att...), true
+}
+
+// projectSemi moves a projection into the first source of a semijoin or
+// semidiff if it keeps the attributes that are compared.
+func projectSemi(n Node) (Relation, bool) {
+	r1, ok := n.(*projectExpr)
+	if !ok {
+		return nil, false
+	}
+	switch s := r1.source1.(type) {
+	case *semiJoinExpr:
+		if semiProjectable(s.source1, s.source2, r1.zero) {
+			return NewSemiJoin(s.source1.Project(r1.zero), s.source2), true
+		}
+	case *semiDiffExpr:
+		if semiProjectable(s.source1, s.source2, r1.zero) {
+			return NewSemiDiff(s.source1.Project(r1.zero), s.source2), true
+		}
+	}
+	return nil, false
+}
+
+// restrictProject moves a restriction below a projection.
+func restrictProject(n Node) (Relation, bool) {
+	r1, ok := n.(*restrictExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*projectExpr)
+	if !ok {
+		return nil, false
+	}
+	return NewProject(s.source1.Restrict(r1.p), s.zero), true
+}
+
+// restrictRestrict switches the order of two restrictions, which may allow
+// the new one to move further towards the source relations.
+func restrictRestrict(n Node) (Relation, bool) {
+	r1, ok := n.(*restrictExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*restrictExpr)
+	if !ok {
+		return nil, false
+	}
+	return NewRestrict(s.source1.Restrict(r1.p), s.p), true
+}
+
+// restrictUnion moves a restriction into both sides of a union.
+func restrictUnion(n Node) (Relation, bool) {
+	r1, ok := n.(*restrictExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*unionExpr)
+	if !ok {
+		return nil, false
+	}
+	return NewUnion(s.source1.Restrict(r1.p), s.source2.Restrict(r1.p)), true
+}
+
+// restrictDiff moves a restriction into both sides of a set difference.
+func restrictDiff(n Node) (Relation, bool) {
+	r1, ok := n.(*restrictExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*diffExpr)
+	if !ok {
+		return nil, false
+	}
+	return NewDiff(s.source1.Restrict(r1.p), s.source2.Restrict(r1.p)), true
+}
+
+// restrictJoin moves a restriction into the sides of a join which have all
+// of the attributes of the predicate.  Compound predicates are decomposed,
+// which covers some theta joins.
+func restrictJoin(n Node) (Relation, bool) {
+	r1, ok := n.(*restrictExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*joinExpr)
+	if !ok {
+		return nil, false
+	}
+	if andPred, ok := r1.p.(AndPred); ok {
+		return s.Restrict(andPred.P1).Restrict(andPred.P2), true
+	}
+
+	dom := r1.p.Domain()
+	h1 := Heading(s.source1)
+	h2 := Heading(s.source2)
+	if IsSubDomain(dom, h1) {
+		if IsSubDomain(dom, h2) {
+			return s.source1.Restrict(r1.p).Join(s.source2.Restrict(r1.p), s.zero), true
+		}
+		return s.source1.Restrict(r1.p).Join(s.source2, s.zero), true
+	}
+	if IsSubDomain(dom, h2) {
+		return s.source1.Join(s.source2.Restrict(r1.p), s.zero), true
+	}
+	return nil, false
+}
+
+// restrictExtend moves a restriction below an extension if the predicate
+// does not depend on the attributes that it adds.
+func restrictExtend(n Node) (Relation, bool) {
+	r1, ok := n.(*restrictExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*extendExpr)
+	if !ok || !IsSubDomain(r1.p.Domain(), Heading(s.source1)) {
+		return nil, false
+	}
+	return NewExtend(s.source1.Restrict(r1.p), s.zero, s.refcn.Interface()), true
+}
+
+// restrictOrder moves a restriction below an ordering.
+func restrictOrder(n Node) (Relation, bool) {
+	r1, ok := n.(*restrictExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*orderExpr)
+	if !ok {
+		return nil, false
+	}
+	return NewOrder(s.source1.Restrict(r1.p), s.att...), true
+}
+
+// restrictSemi moves a restriction into the sources of a semijoin or
+// semidiff.
+func restrictSemi(n Node) (Relation, bool) {
+	r1, ok := n.(*restrictExpr)
+	if !ok {
+		return nil, false
+	}
+	switch s := r1.source1.(type) {
+	case *semiJoinExpr:
+		if s1, s2, ok := semiRestrict(s.source1, s.source2, r1.p); ok {
+			return NewSemiJoin(s1, s2), true
+		}
+	case *semiDiffExpr:
+		if s1, s2, ok := semiRestrict(s.source1, s.source2, r1.p); ok {
+			return NewSemiDiff(s1, s2), true
+		}
+	}
+	return nil, false
+}
+
+// mergeRename replaces a rename of a rename with a single one.
+func mergeRename(n Node) (Relation, bool) {
+	r1, ok := n.(*renameExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*renameExpr)
+	if !ok {
+		return nil, false
+	}
+	return NewRename(s.source1, r1.zero), true
+}
+
+// renameUnion moves a rename into both sides of a union.
+func renameUnion(n Node) (Relation, bool) {
+	r1, ok := n.(*renameExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*unionExpr)
+	if !ok {
+		return nil, false
+	}
+	return NewUnion(s.source1.Rename(r1.zero), s.source2.Rename(r1.zero)), true
+}
+
+// renameDiff moves a rename into both sides of a set difference.
+func renameDiff(n Node) (Relation, bool) {
+	r1, ok := n.(*renameExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*diffExpr)
+	if !ok {
+		return nil, false
+	}
+	return NewDiff(s.source1.Rename(r1.zero), s.source2.Rename(r1.zero)), true
+}
+
+// renameOrder moves a rename below an ordering, and renames the attributes
+// that the tuples are sorted on.
+func renameOrder(n Node) (Relation, bool) {
+	r1, ok := n.(*renameExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*orderExpr)
+	if !ok {
+		return nil, false
+	}
+	names1 := Heading(s.source1)
+	names2 := FieldNames(reflect.TypeOf(r1.zero))
+	att2 := make([]Attribute, len(s.att))
+	for i, att := range s.att {
+		for j := range names1 {
+			if names1[j] == att {
+				att2[i] = names2[j]
+			}
+		}
+	}
+	return NewOrder(s.source1.Rename(r1.zero), att2...), true
+}
+
+// semiUnion moves a semijoin or semidiff into both sides of a union.
+func semiUnion(n Node) (Relation, bool) {
+	switch r1 := n.(type) {
+	case *semiJoinExpr:
+		if s, ok := r1.source1.(*unionExpr); ok {
+			return NewUnion(s.source1.SemiJoin(r1.source2), s.source2.SemiJoin(r1.source2)), true
+		}
+	case *semiDiffExpr:
+		if s, ok := r1.source1.(*unionExpr); ok {
+			return NewUnion(s.source1.SemiDiff(r1.source2), s.source2.SemiDiff(r1.source2)), true
+		}
+	}
+	return nil, false
+}
+
+// mergeOrder replaces an ordering of an ordering with a single one.  Orders
+// which are a prefix of the existing one are removed when they are created,
+// so any other order replaces it.
+func mergeOrder(n Node) (Relation, bool) {
+	r1, ok := n.(*orderExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*orderExpr)
+	if !ok {
+		return nil, false
+	}
+	return NewOrder(s.source1, r1.att...), true
+}
+
+// diffRestrict applies the restriction of the first side of a set difference
+// to the second side, because tuples which do not satisfy it can't be
+// removed from the result.
+func diffRestrict(n Node) (Relation, bool) {
+	r1, ok := n.(*diffExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*restrictExpr)
+	if !ok || restrictedBy(r1.source2, s.p) {
+		return nil, false
+	}
+	return NewDiff(s, r1.source2.Restrict(s.p)), true
+}
+
+// joinRestrict applies the restriction of the first side of a join to the
+// second side if it has all of the attributes of the predicate, because the
+// tuples which do not satisfy it can't match any on the first side.
+func joinRestrict(n Node) (Relation, bool) {
+	r1, ok := n.(*joinExpr)
+	if !ok {
+		return nil, false
+	}
+	s, ok := r1.source1.(*restrictExpr)
+	if !ok || !IsSubDomain(s.p.Domain(), Heading(r1.source2)) || restrictedBy(r1.source2, s.p) {
+		return nil, false
+	}
+	return NewJoin(s, r1.source2.Restrict(s.p), r1.zero), true
+}
@@ -0,0 +1,141 @@
+package rel
+
+import (
+	"testing"
+)
+
+// tests for the rewrite rules
+func TestRules(t *testing.T) {
+	names := make(map[string]bool)
+	for _, r := range DefaultRules() {
+		if names[r.Name()] {
+			t.Errorf("DefaultRules() has duplicate rule %s", r.Name())
+		}
+		names[r.Name()] = true
+	}
+	if rs := Rules(); len(rs) != len(names) {
+		t.Errorf("Rules() has %d rules, want %d", len(rs), len(names))
+	}
+
+	type pcTup struct {
+		PNO   int
+		Color string
+	}
+	type pnoTup struct {
+		PNO int
+	}
+	red := Attribute("Color").EQ("Red")
+
+	var ruleTest = []struct {
+		name         string
+		rules        []Rule
+		rel          func() Relation
+		expectString string
+		expectCard   int
+	}{
+		{"default", DefaultRules(),
+			func() Relation { return parts().Project(pcTup{}).Restrict(red) },
+			"π{PNO, Color}(σ{Color == Red}(Relation(PNO, PName, Color, Weight, City)))", 3},
+		{"none", nil,
+			func() Relation { return parts().Project(pcTup{}).Restrict(red) },
+			"σ{Color == Red}(π{PNO, Color}(Relation(PNO, PName, Color, Weight, City)))", 3},
+		{"diff", []Rule{DiffRestrict},
+			func() Relation { return orders().Restrict(Attribute("Qty").GT(200)).Diff(orders()) },
+			"σ{Qty > 200}(Relation(PNO, SNO, Qty)) − σ{Qty > 200}(Relation(PNO, SNO, Qty))", 0},
+		{"diff restricted", []Rule{DiffRestrict},
+			func() Relation {
+				p := Attribute("Qty").GT(200)
+				return orders().Restrict(p).Diff(orders().Restrict(p))
+			},
+			"σ{Qty > 200}(Relation(PNO, SNO, Qty)) − σ{Qty > 200}(Relation(PNO, SNO, Qty))", 0},
+		{"join", []Rule{JoinRestrict},
+			func() Relation {
+				return parts().Restrict(Attribute("PNO").LT(3)).Join(orders().Project(pnoTup{}), pnoTup{})
+			},
+			"σ{PNO < 3}(Relation(PNO, PName, Color, Weight, City)) ⋈ σ{PNO < 3}(π{PNO}(Relation(PNO, SNO, Qty)))", 2},
+		{"join other domain", []Rule{JoinRestrict},
+			func() Relation { return parts().Restrict(red).Join(orders().Project(pnoTup{}), pnoTup{}) },
+			"σ{Color == Red}(Relation(PNO, PName, Color, Weight, City)) ⋈ π{PNO}(Relation(PNO, SNO, Qty))", 2},
+		// rules apply to the joins which other rules build
+		{"restricted join", []Rule{NewRule("RestrictRestrict", restrictRestrict), NewRule("RestrictJoin", restrictJoin), JoinRestrict},
+			func() Relation {
+				return NewJoin(parts().Restrict(Attribute("PNO").LT(3)), orders().Project(pnoTup{}), pcTup{}).Restrict(red)
+			},
+			"σ{PNO < 3}(σ{Color == Red}(Relation(PNO, PName, Color, Weight, City))) ⋈ σ{PNO < 3}(π{PNO}(Relation(PNO, SNO, Qty)))", 1},
+		// different AdHoc predicates are not the same restriction
+		{"join adhoc", []Rule{JoinRestrict},
+			func() Relation {
+				return parts().Restrict(AdHoc{func(tup pnoTup) bool { return tup.PNO < 3 }}).Join(orders().Project(pnoTup{}).Restrict(AdHoc{func(tup pnoTup) bool { return tup.PNO > 1 }}), pnoTup{})
+			},
+			"σ{func({PNO})}(Relation(PNO, PName, Color, Weight, City)) ⋈ σ{func({PNO})}(σ{func({PNO})}(π{PNO}(Relation(PNO, SNO, Qty))))", 1},
+		{"registered", append([]Rule{NewRule("RemoveRestrict", func(n Node) (Relation, bool) {
+			if n, ok := n.(PredicateNode); ok && n.Predicate().String() == red.String() {
+				return n.Children()[0], true
+			}
+			return nil, false
+		})}, DefaultRules()...),
+			func() Relation { return parts().Restrict(Attribute("PNO").EQ(1)).Restrict(red) },
+			"σ{PNO == 1}(Relation(PNO, PName, Color, Weight, City))", 1},
+	}
+
+	defer SetRules(Rules())
+	for _, tt := range ruleTest {
+		SetRules(tt.rules)
+		r := tt.rel()
+		if err := r.Err(); err != nil {
+			t.Errorf("%s has Err() => %s", tt.name, err.Error())
+			continue
+		}
+		if str := r.String(); str != tt.expectString {
+			t.Errorf("%s has String() => %v, want %v", tt.name, str, tt.expectString)
+		}
+		if card := Card(r); card != tt.expectCard {
+			t.Errorf("%s %s has Card() => %v, want %v", tt.name, tt.expectString, card, tt.expectCard)
+		}
+	}
+}
+
+// tests for the registration and tracing of rules
+func TestRegisterRule(t *testing.T) {
+	defer SetRules(Rules())
+	trace := &RuleTrace{}
+	defer SetRuleTracer(SetRuleTracer(trace))
+
+	removed := 0
+	RegisterRule(NewRule("RemoveProject", func(n Node) (Relation, bool) {
+		if n.Op() != OpProject {
+			return nil, false
+		}
+		if c, ok := n.Children()[0].(Node); !ok || c.Op() != OpRestrict {
+			return nil, false
+		}
+		removed++
+		return n.Children()[0], true
+	}))
+	if rs := Rules(); len(rs) != len(DefaultRules())+1 || rs[0].Name() != "RemoveProject" {
+		t.Errorf("RegisterRule() did not add the rule before the others")
+	}
+
+	type pnoTup struct {
+		PNO int
+	}
+	r := parts().Restrict(Attribute("PNO").EQ(1)).Project(pnoTup{})
+	if removed != 1 || r.String() != "σ{PNO == 1}(Relation(PNO, PName, Color, Weight, City))" {
+		t.Errorf("registered rule was not applied, got %v", r)
+	}
+
+	r = orders().Union(orders()).Restrict(Attribute("Qty").GT(200))
+	want := []string{
+		"RemoveProject: π{PNO}(σ{PNO == 1}(Relation(PNO, PName, Color, Weight, City))) => σ{PNO == 1}(Relation(PNO, PName, Color, Weight, City))",
+		"RestrictUnion: σ{Qty > 200}(Relation(PNO, SNO, Qty) ∪ Relation(PNO, SNO, Qty)) => σ{Qty > 200}(Relation(PNO, SNO, Qty)) ∪ σ{Qty > 200}(Relation(PNO, SNO, Qty))",
+	}
+	events := trace.Events()
+	if len(events) != len(want) {
+		t.Fatalf("trace has %d events, want %d", len(events), len(want))
+	}
+	for i, e := range events {
+		if e.String() != want[i] {
+			t.Errorf("%d has String() => %v, want %v", i, e.String(), want[i])
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package rel
+
+import (
+	"fmt"
+	"testing"
+)
+
+// tests for semidiff
+func TestSemiDiff(t *testing.T) {
+	// suppliers who do not supply part 2
+	r1 := suppliers().SemiDiff(orders().Restrict(Attribute("PNO").EQ(2)))
+	if card := Card(r1); card != 3 {
+		t.Errorf("suppliers.SemiDiff(orders) has Card() => %v, want %v", card, 3)
+	}
+
+	// test the degrees, cardinality, and string representation
+	rel := parts().SemiDiff(orders())
+	type distinctTup struct {
+		PNO   int
+		PName string
+	}
+	type nonDistinctTup struct {
+		PName string
+		City  string
+	}
+	type titleCaseTup struct {
+		Pno    int
+		PName  string
+		Color  string
+		Weight float64
+		City   string
+	}
+	type groupByTup struct {
+		City   string
+		Weight float64
+	}
+	type valTup struct {
+		Weight float64
+	}
+	groupFcn := func(val <-chan valTup) valTup {
+		res := valTup{}
+		for vi := range val {
+			res.Weight += vi.Weight
+		}
+		return res
+	}
+
+	var relTest = []struct {
+		rel          Relation
+		expectString string
+		expectDeg    int
+		expectCard   int
+	}{
+		{rel, "Relation(PNO, PName, Color, Weight, City) ▷ Relation(PNO, SNO, Qty)", 5, 2},
+		{rel.Restrict(Attribute("PNO").EQ(5)), "σ{PNO == 5}(Relation(PNO, PName, Color, Weight, City)) ▷ σ{PNO == 5}(Relation(PNO, SNO, Qty))", 5, 1},
+		{rel.Restrict(Attribute("Color").EQ("Red")), "σ{Color == Red}(Relation(PNO, PName, Color, Weight, City)) ▷ Relation(PNO, SNO, Qty)", 5, 1},
+		{rel.Project(distinctTup{}), "π{PNO, PName}(Relation(PNO, PName, Color, Weight, City)) ▷ Relation(PNO, SNO, Qty)", 2, 2},
+		{rel.Project(nonDistinctTup{}), "π{PName, City}(Relation(PNO, PName, Color, Weight, City) ▷ Relation(PNO, SNO, Qty))", 2, 2},
+		{rel.Rename(titleCaseTup{}), "ρ{Pno, PName, Color, Weight, City}/{PNO, PName, Color, Weight, City}(Relation(PNO, PName, Color, Weight, City) ▷ Relation(PNO, SNO, Qty))", 5, 2},
+		{rel.Diff(parts()), "Relation(PNO, PName, Color, Weight, City) ▷ Relation(PNO, SNO, Qty) − Relation(PNO, PName, Color, Weight, City)", 5, 0},
+		{rel.Union(parts().SemiJoin(orders())), "Relation(PNO, PName, Color, Weight, City) ▷ Relation(PNO, SNO, Qty) ∪ Relation(PNO, PName, Color, Weight, City) ⋉ Relation(PNO, SNO, Qty)", 5, 6},
+		{rel.GroupBy(groupByTup{}, groupFcn), "Relation(PNO, PName, Color, Weight, City) ▷ Relation(PNO, SNO, Qty).GroupBy({City, Weight}->{Weight})", 2, 2},
+		{rel.SemiJoin(parts().Restrict(Attribute("City").EQ("Paris"))), "Relation(PNO, PName, Color, Weight, City) ▷ Relation(PNO, SNO, Qty) ⋉ σ{City == Paris}(Relation(PNO, PName, Color, Weight, City))", 5, 1},
+		{rel.SemiDiff(parts().Restrict(Attribute("City").EQ("Paris"))), "Relation(PNO, PName, Color, Weight, City) ▷ Relation(PNO, SNO, Qty) ▷ σ{City == Paris}(Relation(PNO, PName, Color, Weight, City))", 5, 1},
+		{parts().Union(parts()).SemiDiff(orders()), "Relation(PNO, PName, Color, Weight, City) ▷ Relation(PNO, SNO, Qty) ∪ Relation(PNO, PName, Color, Weight, City) ▷ Relation(PNO, SNO, Qty)", 5, 2},
+		{parts().SemiDiff(suppliers()), "Relation(PNO, PName, Color, Weight, City) ▷ Relation(SNO, SName, Status, City)", 5, 1},
+	}
+
+	for i, tt := range relTest {
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%d has Err() => %s", i, err.Error())
+			continue
+		}
+		if str := tt.rel.String(); str != tt.expectString {
+			t.Errorf("%d has String() => %v, want %v", i, str, tt.expectString)
+		}
+		if deg := Deg(tt.rel); deg != tt.expectDeg {
+			t.Errorf("%d %s has Deg() => %v, want %v", i, tt.expectString, deg, tt.expectDeg)
+		}
+		if card := Card(tt.rel); card != tt.expectCard {
+			t.Errorf("%d %s has Card() => %v, want %v", i, tt.expectString, card, tt.expectCard)
+		}
+	}
+
+	// test cancellation
+	res := make(chan partTup)
+	cancel := rel.TupleChan(res)
+	close(cancel)
+	select {
+	case <-res:
+		t.Errorf("cancel did not end tuple generation")
+	default:
+		// passed test
+	}
+
+	// test errors
+	err := fmt.Errorf("testing error")
+	rel1 := parts().SemiDiff(orders()).(*semiDiffExpr)
+	rel1.err = err
+	rel2 := parts().SemiDiff(orders()).(*semiDiffExpr)
+	rel2.err = err
+	res = make(chan partTup)
+	_ = rel1.TupleChan(res)
+	if _, ok := <-res; ok {
+		t.Errorf("semidiff did not short circuit TupleChan")
+	}
+	errTest := []Relation{
+		rel1.Project(distinctTup{}),
+		rel1.Project(nonDistinctTup{}),
+		rel1.Restrict(Attribute("PNO").EQ(1)),
+		rel1.Rename(titleCaseTup{}),
+		rel1.Union(rel2),
+		rel.Union(rel2),
+		rel1.Diff(rel2),
+		rel.Diff(rel2),
+		rel1.Join(rel2, partTup{}),
+		rel.Join(rel2, partTup{}),
+		rel1.GroupBy(groupByTup{}, groupFcn),
+		rel1.SemiJoin(orders()),
+		rel.SemiJoin(rel2),
+		rel1.SemiDiff(orders()),
+		rel.SemiDiff(rel2),
+	}
+	for i, errRel := range errTest {
+		if errRel.Err() != err {
+			t.Errorf("%d did not short circuit error", i)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+// semijoin implements a semijoin expression in relational algebra, which is
+// called MATCHING by Date.
+
+package rel
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// semiJoinExpr represents the tuples in source1 which have a matching tuple
+// in source2, where tuples match if they have identical values in the
+// attributes that share the same names.  It is equivalent to a join followed
+// by a projection back to the attributes of source1, but it does not have to
+// construct the joined tuples.
+// This is one of the operations which consumes memory.  In addition, no values
+// can be sent before all values from the second source are consumed.
+type semiJoinExpr struct {
+	// source1 is the relation that is filtered
+	source1 Relation
+
+	// source2 is the relation which is compared against
+	source2 Relation
+
+	// err is the first error encountered during construction or evaluation.
+	err error
+}
+
+// semiTupleChan sends the tuples in source1 which either have (if matching is
+// true) or do not have (if matching is false) a tuple in source2 with the
+// same values in their common attributes.  It is used by both the semijoin
+// and semidiff expressions.  When it completes, it returns the first error
+// encountered in the sources to the done func, or it relays cancellation to
+// the sources.
+func semiTupleChan(source1, source2 Relation, matching bool, res reflect.Value, cancel chan struct{}, done func(err error)) {
+	mc := runtime.GOMAXPROCS(-1)
+	if SortOrder(source1) != nil {
+		// concurrent evaluation would not retain the order of the source
+		mc = 1
+	}
+
+	// the attributes that determine matches
+	e1 := reflect.TypeOf(source1.Zero())
+	e2 := reflect.TypeOf(source2.Zero())
+	idx1, idx2, keyType := joinIndex(fieldMap(e1, e2))
+
+	// create channels over the body of the source relations
+	body1 := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e1), 0)
+	bcancel1 := source1.TupleChan(body1.Interface())
+	body2 := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e2), 0)
+	bcancel2 := source2.TupleChan(body2.Interface())
+
+	go func(b1, b2 reflect.Value) {
+		canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+
+		// first pull all of the values from the second relation, because
+		// we need them all before we can determine if a tuple in the first
+		// has a match
+		mem := make(map[interface{}]struct{})
+		inCases := []reflect.SelectCase{canSel, {Dir: reflect.SelectRecv, Chan: b2}}
+		for {
+			chosen, rtup, ok := reflect.Select(inCases)
+			if chosen == 0 || !ok {
+				// cancel channel was closed, or the second source completed
+				break
+			}
+			mem[joinKey(rtup, idx2, keyType)] = struct{}{}
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(mc)
+		for i := 0; i < mc; i++ {
+			go func() {
+				defer wg.Done()
+				// input channels
+				inCases := []reflect.SelectCase{canSel, {Dir: reflect.SelectRecv, Chan: b1}}
+
+				// output channels
+				resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+				for {
+					chosen, rtup, ok := reflect.Select(inCases)
+					if chosen == 0 || !ok {
+						// cancel channel was closed, or the first source
+						// completed
+						return
+					}
+					if _, match := mem[joinKey(rtup, idx1, keyType)]; match != matching {
+						continue
+					}
+					resSel.Send = rtup
+					chosen, _, _ = reflect.Select([]reflect.SelectCase{canSel, resSel})
+					if chosen == 0 {
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		// if we've been cancelled, send it up to the source
+		select {
+		case <-cancel:
+			close(bcancel1)
+			close(bcancel2)
+		default:
+			if err := source1.Err(); err != nil {
+				done(err)
+			} else {
+				done(source2.Err())
+			}
+			res.Close()
+		}
+	}(body1, body2)
+}
+
+// semiRestrict distributes a restriction through a semijoin or semidiff.  If
+// the predicate only depends on the common attributes of the two sources,
+// then it can be applied to both.  Otherwise, if it only depends on the first
+// source's attributes, it can be applied to the first source.  The result is
+// false if the predicate can't be distributed.
+func semiRestrict(source1, source2 Relation, p Predicate) (Relation, Relation, bool) {
+	dom := p.Domain()
+	h1 := Heading(source1)
+	h2 := Heading(source2)
+	if !IsSubDomain(dom, h1) {
+		return source1, source2, false
+	}
+	if IsSubDomain(dom, h2) {
+		return source1.Restrict(p), source2.Restrict(p), true
+	}
+	return source1.Restrict(p), source2, true
+}
+
+// semiProjectable returns true if a projection to z2 can be performed on the
+// first source of a semijoin or semidiff before the comparison, which is
+// possible when the projection retains all of the common attributes.
+func semiProjectable(source1, source2 Relation, z2 interface{}) bool {
+	att2 := FieldNames(reflect.TypeOf(z2))
+	if !IsSubDomain(att2, Heading(source1)) {
+		return false
+	}
+	for name := range AttributeMap(Heading(source1), Heading(source2)) {
+		if !IsSubDomain([]Attribute{name}, att2) {
+			return false
+		}
+	}
+	return true
+}
+
+// TupleChan sends each tuple in the relation to a channel
+func (r1 *semiJoinExpr) TupleChan(t interface{}) chan<- struct{} {
+	cancel := make(chan struct{})
+	// reflect on the channel
+	chv := reflect.ValueOf(t)
+	err := EnsureChan(chv.Type(), r1.Zero())
+	if err != nil {
+		r1.err = err
+		return cancel
+	}
+	if r1.err != nil {
+		chv.Close()
+		return cancel
+	}
+	semiTupleChan(r1.source1, r1.source2, true, chv, cancel, func(err error) {
+		if err != nil {
+			r1.err = err
+		}
+	})
+	return cancel
+}
+
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *semiJoinExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *semiJoinExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *semiJoinExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
+// Zero returns the zero value of the relation (a blank tuple)
+func (r1 *semiJoinExpr) Zero() interface{} {
+	return r1.source1.Zero()
+}
+
+// CKeys is the set of candidate keys in the relation
+func (r1 *semiJoinExpr) CKeys() CandKeys {
+	return r1.source1.CKeys()
+}
+
+// SortOrder is the set of attributes that the relation is sorted on.  The
+// tuples from the first source are sent in the order they are received.
+func (r1 *semiJoinExpr) SortOrder() []Attribute {
+	return SortOrder(r1.source1)
+}
+
+// GoString returns a text representation of the Relation
+func (r1 *semiJoinExpr) GoString() string {
+	return r1.source1.GoString() + ".SemiJoin(" + r1.source2.GoString() + ")"
+}
+
+// String returns a text representation of the Relation
+func (r1 *semiJoinExpr) String() string {
+	return r1.source1.String() + " ⋉ " + r1.source2.String()
+}
+
+// Project creates a new relation with less than or equal degree
+// t2 has to be a new type which is a subdomain of r.
+// Project can be performed before the semijoin if it retains the attributes
+// that the sources have in common.
+func (r1 *semiJoinExpr) Project(z2 interface{}) Relation {
+	return Rewrite(r1, NewProject(r1, z2))
+}
+
+// Restrict creates a new relation with less than or equal cardinality
+// p has to be a func(tup T) bool where tup is a subdomain of the input r.
+// Restrict can be distributed through a semijoin.
+func (r1 *semiJoinExpr) Restrict(p Predicate) Relation {
+	return Rewrite(r1, NewRestrict(r1, p))
+}
+
+// Rename creates a new relation with new column names
+// z2 has to be a struct with the same number of fields as the input relation
+func (r1 *semiJoinExpr) Rename(z2 interface{}) Relation {
+	return Rewrite(r1, NewRename(r1, z2))
+}
+
+// Union creates a new relation by unioning the bodies of both inputs
+func (r1 *semiJoinExpr) Union(r2 Relation) Relation {
+	return Rewrite(r1, NewUnion(r1, r2))
+}
+
+// Diff creates a new relation by set minusing the two inputs
+func (r1 *semiJoinExpr) Diff(r2 Relation) Relation {
+	return Rewrite(r1, NewDiff(r1, r2))
+}
+
+// Join creates a new relation by performing a natural join on the inputs
+func (r1 *semiJoinExpr) Join(r2 Relation, zero interface{}) Relation {
+	return Rewrite(r1, NewJoin(r1, r2, zero))
+}
+
+// GroupBy creates a new relation by grouping and applying a user defined func
+func (r1 *semiJoinExpr) GroupBy(t2, gfcn interface{}) Relation {
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
+}
+
+// Map creates a new relation by applying a function to tuples in the source
+func (r1 *semiJoinExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *semiJoinExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *semiJoinExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *semiJoinExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *semiJoinExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *semiJoinExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *semiJoinExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *semiJoinExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *semiJoinExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *semiJoinExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *semiJoinExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
+}
+
+// Err returns an error encountered during construction or computation
+func (r1 *semiJoinExpr) Err() error {
+	return r1.err
+}
+
+// Op is the operation that produces the relation
+func (r1 *semiJoinExpr) Op() OpKind {
+	return OpSemiJoin
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *semiJoinExpr) Children() []Relation {
+	return []Relation{r1.source1, r1.source2}
+}
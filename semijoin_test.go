@@ -0,0 +1,191 @@
+package rel
+
+import (
+	"fmt"
+	"testing"
+)
+
+// tests for semijoin
+func TestSemiJoin(t *testing.T) {
+	// suppliers who supply part 2
+	r1 := suppliers().SemiJoin(orders().Restrict(Attribute("PNO").EQ(2)))
+	wantString := `rel.New([]struct {
+ SNO    int    
+ SName  string 
+ Status int    
+ City   string 
+}{
+ {1, "Smith", 20, "London", },
+ {2, "Jones", 10, "Paris",  },
+})`
+	if GoString(r1) != wantString {
+		t.Errorf("suppliers.SemiJoin(orders).GoString() = \"%s\", want \"%s\"", GoString(r1), wantString)
+	}
+
+	// test the degrees, cardinality, and string representation
+	rel := parts().SemiJoin(orders())
+	type distinctTup struct {
+		PNO   int
+		PName string
+	}
+	type nonDistinctTup struct {
+		PName string
+		City  string
+	}
+	type titleCaseTup struct {
+		Pno    int
+		PName  string
+		Color  string
+		Weight float64
+		City   string
+	}
+	type joinTup struct {
+		PNO    int
+		PName  string
+		Color  string
+		Weight float64
+		City   string
+		SNO    int
+		Qty    int
+	}
+	type groupByTup struct {
+		City   string
+		Weight float64
+	}
+	type valTup struct {
+		Weight float64
+	}
+	groupFcn := func(val <-chan valTup) valTup {
+		res := valTup{}
+		for vi := range val {
+			res.Weight += vi.Weight
+		}
+		return res
+	}
+	type mapRes struct {
+		PNO     int
+		PName   string
+		Weight2 float64
+	}
+	mapFcn := func(tup1 partTup) mapRes {
+		return mapRes{tup1.PNO, tup1.PName, tup1.Weight / 2}
+	}
+	mapKeys := [][]string{
+		[]string{"PNO"},
+	}
+
+	var relTest = []struct {
+		rel          Relation
+		expectString string
+		expectDeg    int
+		expectCard   int
+	}{
+		{rel, "Relation(PNO, PName, Color, Weight, City) ⋉ Relation(PNO, SNO, Qty)", 5, 4},
+		{rel.Restrict(Attribute("PNO").EQ(1)), "σ{PNO == 1}(Relation(PNO, PName, Color, Weight, City)) ⋉ σ{PNO == 1}(Relation(PNO, SNO, Qty))", 5, 1},
+		{rel.Restrict(Attribute("Color").EQ("Red")), "σ{Color == Red}(Relation(PNO, PName, Color, Weight, City)) ⋉ Relation(PNO, SNO, Qty)", 5, 2},
+		{rel.Project(distinctTup{}), "π{PNO, PName}(Relation(PNO, PName, Color, Weight, City)) ⋉ Relation(PNO, SNO, Qty)", 2, 4},
+		{rel.Project(nonDistinctTup{}), "π{PName, City}(Relation(PNO, PName, Color, Weight, City) ⋉ Relation(PNO, SNO, Qty))", 2, 4},
+		{rel.Rename(titleCaseTup{}), "ρ{Pno, PName, Color, Weight, City}/{PNO, PName, Color, Weight, City}(Relation(PNO, PName, Color, Weight, City) ⋉ Relation(PNO, SNO, Qty))", 5, 4},
+		{rel.Diff(parts()), "Relation(PNO, PName, Color, Weight, City) ⋉ Relation(PNO, SNO, Qty) − Relation(PNO, PName, Color, Weight, City)", 5, 0},
+		{rel.Union(parts()), "Relation(PNO, PName, Color, Weight, City) ⋉ Relation(PNO, SNO, Qty) ∪ Relation(PNO, PName, Color, Weight, City)", 5, 6},
+		{rel.Join(orders(), joinTup{}), "Relation(PNO, PName, Color, Weight, City) ⋉ Relation(PNO, SNO, Qty) ⋈ Relation(PNO, SNO, Qty)", 7, 12},
+		{rel.GroupBy(groupByTup{}, groupFcn), "Relation(PNO, PName, Color, Weight, City) ⋉ Relation(PNO, SNO, Qty).GroupBy({City, Weight}->{Weight})", 2, 3},
+		{rel.Map(mapFcn, mapKeys), "Relation(PNO, PName, Color, Weight, City) ⋉ Relation(PNO, SNO, Qty).Map({PNO, PName, Color, Weight, City}->{PNO, PName, Weight2})", 3, 4},
+		{rel.SemiJoin(orders().Restrict(Attribute("SNO").EQ(1))), "Relation(PNO, PName, Color, Weight, City) ⋉ Relation(PNO, SNO, Qty) ⋉ σ{SNO == 1}(Relation(PNO, SNO, Qty))", 5, 2},
+		{rel.SemiDiff(orders().Restrict(Attribute("SNO").EQ(1))), "Relation(PNO, PName, Color, Weight, City) ⋉ Relation(PNO, SNO, Qty) ▷ σ{SNO == 1}(Relation(PNO, SNO, Qty))", 5, 2},
+		{parts().Union(parts()).SemiJoin(orders()), "Relation(PNO, PName, Color, Weight, City) ⋉ Relation(PNO, SNO, Qty) ∪ Relation(PNO, PName, Color, Weight, City) ⋉ Relation(PNO, SNO, Qty)", 5, 4},
+		{parts().SemiJoin(suppliers()), "Relation(PNO, PName, Color, Weight, City) ⋉ Relation(SNO, SName, Status, City)", 5, 5},
+	}
+
+	for i, tt := range relTest {
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%d has Err() => %s", i, err.Error())
+			continue
+		}
+		if str := tt.rel.String(); str != tt.expectString {
+			t.Errorf("%d has String() => %v, want %v", i, str, tt.expectString)
+		}
+		if deg := Deg(tt.rel); deg != tt.expectDeg {
+			t.Errorf("%d %s has Deg() => %v, want %v", i, tt.expectString, deg, tt.expectDeg)
+		}
+		if card := Card(tt.rel); card != tt.expectCard {
+			t.Errorf("%d %s has Card() => %v, want %v", i, tt.expectString, card, tt.expectCard)
+		}
+	}
+
+	// the candidate keys and sort order are those of the first source
+	r2 := parts().Order("PNO").SemiJoin(orders())
+	if ck := r2.CKeys(); len(ck) != 1 || len(ck[0]) != 1 || ck[0][0] != "PNO" {
+		t.Errorf("%s has CKeys() => %v, want [[PNO]]", r2, ck)
+	}
+	if ord := SortOrder(r2); len(ord) != 1 || ord[0] != "PNO" {
+		t.Errorf("%s has SortOrder() => %v, want [PNO]", r2, ord)
+	}
+
+	// test cancellation
+	res := make(chan partTup)
+	cancel := rel.TupleChan(res)
+	close(cancel)
+	select {
+	case <-res:
+		t.Errorf("cancel did not end tuple generation")
+	default:
+		// passed test
+	}
+
+	// test errors
+	err := fmt.Errorf("testing error")
+	rel1 := parts().SemiJoin(orders()).(*semiJoinExpr)
+	rel1.err = err
+	rel2 := parts().SemiJoin(orders()).(*semiJoinExpr)
+	rel2.err = err
+	res = make(chan partTup)
+	_ = rel1.TupleChan(res)
+	if _, ok := <-res; ok {
+		t.Errorf("semijoin did not short circuit TupleChan")
+	}
+	errTest := []Relation{
+		rel1.Project(distinctTup{}),
+		rel1.Project(nonDistinctTup{}),
+		rel1.Restrict(Attribute("PNO").EQ(1)),
+		rel1.Rename(titleCaseTup{}),
+		rel1.Union(rel2),
+		rel.Union(rel2),
+		rel1.Diff(rel2),
+		rel.Diff(rel2),
+		rel1.Join(rel2, orderTup{}),
+		rel.Join(rel2, orderTup{}),
+		rel1.GroupBy(groupByTup{}, groupFcn),
+		rel1.Map(mapFcn, mapKeys),
+		rel1.SemiJoin(orders()),
+		rel.SemiJoin(rel2),
+		rel1.SemiDiff(orders()),
+		rel.SemiDiff(rel2),
+	}
+	for i, errRel := range errTest {
+		if errRel.Err() != err {
+			t.Errorf("%d did not short circuit error", i)
+		}
+	}
+	// errors from the second source should be reported after evaluation
+	r3 := parts().SemiJoin(&errorRel{orderTup{}, 1, nil})
+	Card(r3)
+	if r3.Err() == nil {
+		t.Errorf("semijoin did not report an error from the second source")
+	}
+}
+
+func BenchmarkSemiJoin(b *testing.B) {
+	exRel1 := New(exampleRelSlice2(1000), [][]string{[]string{"Foo"}})
+	exRel2 := New(exampleRelSlice2(100), [][]string{[]string{"Foo"}})
+
+	r1 := exRel1.SemiJoin(exRel2)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// each iteration produces 100 tuples
+		t := make(chan exTup2)
+		r1.TupleChan(t)
+		for _ = range t {
+		}
+	}
+}
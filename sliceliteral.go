@@ -4,6 +4,7 @@
 package rel
 
 import (
+	"context"
 	"reflect"
 )
 
@@ -58,10 +59,11 @@ func (r1 *sliceLiteral) TupleChan(t interface{}) chan<- struct{} {
 		return cancel
 	}
 
-	// build up a map where each key is one of the tuples.  This consumes
-	// memory.
+	// build up a set of the tuples that have been sent.  This consumes
+	// memory, up to the memory budget.
 	go func(rbody, res reflect.Value) {
-		mem := map[interface{}]struct{}{}
+		mem := newDistinctSet(rbody.Type().Elem())
+		defer mem.close()
 
 		// output channels
 		canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
@@ -70,9 +72,7 @@ func (r1 *sliceLiteral) TupleChan(t interface{}) chan<- struct{} {
 		for i := 0; i < rbody.Len(); i++ {
 			rtup := rbody.Index(i)
 
-			if _, dup := mem[rtup.Interface()]; !dup {
-				mem[rtup.Interface()] = struct{}{}
-
+			if mem.add(rtup) {
 				resSel.Send = rtup
 				chosen, _, _ := reflect.Select([]reflect.SelectCase{canSel, resSel})
 				if chosen == 0 {
@@ -80,11 +80,81 @@ func (r1 *sliceLiteral) TupleChan(t interface{}) chan<- struct{} {
 				}
 			}
 		}
+		// send the tuples that were deferred if the memory budget was
+		// exceeded
+		if !mem.flush(func(tup reflect.Value) bool { return sendTuple(res, cancel, tup) }) && mem.err == nil {
+			return
+		}
+		if mem.err != nil {
+			r1.err = mem.err
+		}
 		res.Close()
 	}(r1.rbody, chv)
 	return cancel
 }
 
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *sliceLiteral) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation.
+// If the source slice is already distinct then it is copied directly,
+// otherwise duplicates are removed.
+func (r1 *sliceLiteral) TupleSlice(t interface{}) error {
+	slv, err := ensurePtr(t)
+	if err != nil {
+		return err
+	}
+	if err := EnsureSlice(slv.Type(), r1.zero); err != nil {
+		return err
+	}
+	if r1.err != nil {
+		return r1.err
+	}
+	n := r1.rbody.Len()
+	if r1.sourceDistinct {
+		res := reflect.MakeSlice(slv.Type(), n, n)
+		reflect.Copy(res, r1.rbody)
+		slv.Set(res)
+		return nil
+	}
+	mem := make(map[interface{}]struct{}, n)
+	res := reflect.MakeSlice(slv.Type(), 0, n)
+	for i := 0; i < n; i++ {
+		rtup := r1.rbody.Index(i)
+		if _, dup := mem[tupleKey(rtup)]; !dup {
+			mem[tupleKey(rtup)] = struct{}{}
+			res = reflect.Append(res, rtup)
+		}
+	}
+	slv.Set(res)
+	return nil
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *sliceLiteral) TupleMap(t interface{}) error {
+	mv, err := ensurePtr(t)
+	if err != nil {
+		return err
+	}
+	if err := EnsureMap(mv.Type(), r1.zero); err != nil {
+		return err
+	}
+	if r1.err != nil {
+		return r1.err
+	}
+	n := r1.rbody.Len()
+	res := reflect.MakeMapWithSize(mv.Type(), n)
+	empty := reflect.ValueOf(struct{}{})
+	for i := 0; i < n; i++ {
+		res.SetMapIndex(r1.rbody.Index(i), empty)
+	}
+	mv.Set(res)
+	return nil
+}
+
 // Zero returns the zero value of the relation (a blank tuple)
 func (r1 *sliceLiteral) Zero() interface{} {
 	return r1.zero
@@ -108,47 +178,116 @@ func (r1 *sliceLiteral) String() string {
 // Project creates a new relation with less than or equal degree
 // t2 has to be a new type which is a subdomain of r.
 func (r1 *sliceLiteral) Project(z2 interface{}) Relation {
-	return NewProject(r1, z2)
+	return Rewrite(r1, NewProject(r1, z2))
 }
 
 // Restrict creates a new relation with less than or equal cardinality
 // p has to be a func(tup T) bool where tup is a subdomain of the input r.
 func (r1 *sliceLiteral) Restrict(p Predicate) Relation {
-	return NewRestrict(r1, p)
+	return Rewrite(r1, NewRestrict(r1, p))
 }
 
 // Rename creates a new relation with new column names
 // z2 has to be a struct with the same number of fields as the input relation
 func (r1 *sliceLiteral) Rename(z2 interface{}) Relation {
-	return NewRename(r1, z2)
+	return Rewrite(r1, NewRename(r1, z2))
 }
 
 // Union creates a new relation by unioning the bodies of both inputs
 func (r1 *sliceLiteral) Union(r2 Relation) Relation {
-	return NewUnion(r1, r2)
+	return Rewrite(r1, NewUnion(r1, r2))
 }
 
 // Diff creates a new relation by set minusing the two inputs
 func (r1 *sliceLiteral) Diff(r2 Relation) Relation {
-	return NewDiff(r1, r2)
+	return Rewrite(r1, NewDiff(r1, r2))
 }
 
 // Join creates a new relation by performing a natural join on the inputs
 func (r1 *sliceLiteral) Join(r2 Relation, zero interface{}) Relation {
-	return NewJoin(r1, r2, zero)
+	return Rewrite(r1, NewJoin(r1, r2, zero))
 }
 
 // GroupBy creates a new relation by grouping and applying a user defined func
 func (r1 *sliceLiteral) GroupBy(t2, gfcn interface{}) Relation {
-	return NewGroupBy(r1, t2, gfcn)
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
 }
 
 // Map creates a new relation by applying a function to tuples in the source
 func (r1 *sliceLiteral) Map(mfcn interface{}, ckeystr [][]string) Relation {
-	return NewMap(r1, mfcn, ckeystr)
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *sliceLiteral) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *sliceLiteral) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *sliceLiteral) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *sliceLiteral) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *sliceLiteral) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *sliceLiteral) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *sliceLiteral) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *sliceLiteral) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *sliceLiteral) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *sliceLiteral) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
 }
 
 // Err returns an error encountered during construction or computation
 func (r1 *sliceLiteral) Err() error {
 	return r1.err
 }
+
+// Op is the operation that produces the relation
+func (r1 *sliceLiteral) Op() OpKind {
+	return OpLiteral
+}
+
+// Children are the relations that the operation is performed on, which is
+// empty for literals
+func (r1 *sliceLiteral) Children() []Relation {
+	return nil
+}
@@ -0,0 +1,543 @@
+// spill implements the memory budget for the operations which have to
+// remember tuples, like union, diff, distinct, and join.  When the budget is
+// exceeded, the remembered tuples are partitioned by hash into temporary
+// files using gob encoding, and the rest of the work is done one partition at
+// a time, so that each partition fits within the budget.
+
+package rel
+
+import (
+	"bufio"
+	"encoding/gob"
+	"hash/maphash"
+	"io"
+	"os"
+	"reflect"
+	"sync/atomic"
+)
+
+// memoryBudget is the maximum number of tuples that each operation holds in
+// memory, or 0 if there is no limit.
+var memoryBudget int64
+
+// SetMemoryBudget sets the maximum number of tuples that each of the
+// operations which remember tuples (union, diff, join, and operations that
+// remove duplicates) will hold in memory before spilling them to temporary
+// files in os.TempDir().  A budget of 0, which is the default, means that
+// there is no limit.  It returns the previous budget.  The budget applies to
+// evaluations that start after it is set.
+func SetMemoryBudget(tuples int) int {
+	return int(atomic.SwapInt64(&memoryBudget, int64(tuples)))
+}
+
+// MemoryBudget returns the maximum number of tuples that each operation will
+// hold in memory, or 0 if there is no limit.
+func MemoryBudget() int {
+	return int(atomic.LoadInt64(&memoryBudget))
+}
+
+const (
+	// spillPartitions is the number of files that tuples are partitioned
+	// into when the memory budget is exceeded.
+	spillPartitions = 16
+
+	// maxSpillLevel is the number of times that a partition can be split
+	// again.  Beyond that, the tuples probably share a key, so splitting them
+	// further would not help, and the budget is ignored.
+	maxSpillLevel = 4
+)
+
+// spill record flags
+const (
+	// spillOld marks tuples that were already processed before they were
+	// spilled, like tuples that have already been sent.
+	spillOld uint8 = 1 << iota
+
+	// spillSide2 marks tuples that came from the second source of a binary
+	// operation.
+	spillSide2
+)
+
+// tupleBudget returns the memory budget for operations on tuples of the
+// given types.  Relations can't be gob encoded, so tuples with
+// relation-valued attributes are always held in memory.
+func tupleBudget(types ...reflect.Type) int {
+	for _, e := range types {
+		if tupleKeyType(e) != e {
+			return 0
+		}
+	}
+	return MemoryBudget()
+}
+
+// overBudget returns true if n tuples held in memory exceed the budget, at
+// the given level of partitioning.
+func overBudget(budget, n, level int) bool {
+	return budget > 0 && n >= budget && level < maxSpillLevel
+}
+
+// sendTuple sends a tuple on res, and returns false if cancel was closed
+// before it could be sent.  It is used to send tuples that were deferred
+// until the spill files are flushed.
+func sendTuple(res reflect.Value, cancel <-chan struct{}, rtup reflect.Value) bool {
+	chosen, _, _ := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)},
+		{Dir: reflect.SelectSend, Chan: res, Send: rtup},
+	})
+	return chosen != 0
+}
+
+// spillFile is a temporary file containing a sequence of flagged tuples.
+type spillFile struct {
+	f   *os.File
+	w   *bufio.Writer
+	enc *gob.Encoder
+}
+
+// newSpillFile creates a new temporary file for tuples.
+func newSpillFile() (*spillFile, error) {
+	f, err := os.CreateTemp("", "rel-spill-")
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	return &spillFile{f, w, gob.NewEncoder(w)}, nil
+}
+
+// write appends a tuple to the file.
+func (sf *spillFile) write(flags uint8, rtup reflect.Value) error {
+	if err := sf.enc.Encode(flags); err != nil {
+		return err
+	}
+	return sf.enc.EncodeValue(rtup)
+}
+
+// records reads the tuples in the file in the order they were written, and
+// calls fn with each of them until it returns false.  The types are the
+// tuple types of the first and second sides.
+func (sf *spillFile) records(types [2]reflect.Type, fn func(flags uint8, rtup reflect.Value) bool) error {
+	if err := sf.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := sf.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(bufio.NewReader(sf.f))
+	for {
+		var flags uint8
+		if err := dec.Decode(&flags); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		side := 0
+		if flags&spillSide2 != 0 {
+			side = 1
+		}
+		ptr := reflect.New(types[side])
+		if err := dec.DecodeValue(ptr); err != nil {
+			return err
+		}
+		if !fn(flags, ptr.Elem()) {
+			return nil
+		}
+	}
+}
+
+// remove closes and deletes the file.
+func (sf *spillFile) remove() {
+	sf.f.Close()
+	os.Remove(sf.f.Name())
+}
+
+// partitions is a set of spill files, where tuples are assigned to files by
+// the hash of a key, so that all tuples with the same key are in the same
+// file.
+type partitions struct {
+	seed  maphash.Seed
+	types [2]reflect.Type
+	files []*spillFile
+}
+
+// newPartitions creates a set of partitions for tuples of the given types.
+// The files are only created when they are written to.
+func newPartitions(types [2]reflect.Type) *partitions {
+	return &partitions{maphash.MakeSeed(), types, make([]*spillFile, spillPartitions)}
+}
+
+// write appends a tuple to the partition for the key.
+func (p *partitions) write(key interface{}, flags uint8, rtup reflect.Value) error {
+	i := maphash.Comparable(p.seed, key) % uint64(len(p.files))
+	if p.files[i] == nil {
+		sf, err := newSpillFile()
+		if err != nil {
+			return err
+		}
+		p.files[i] = sf
+	}
+	return p.files[i].write(flags, rtup)
+}
+
+// drain calls fn with the records in partition i until it returns false,
+// and then removes the partition's file.
+func (p *partitions) drain(i int, fn func(flags uint8, rtup reflect.Value) bool) error {
+	sf := p.files[i]
+	if sf == nil {
+		return nil
+	}
+	err := sf.records(p.types, fn)
+	sf.remove()
+	p.files[i] = nil
+	return err
+}
+
+// remove deletes all of the files.
+func (p *partitions) remove() {
+	for i, sf := range p.files {
+		if sf != nil {
+			sf.remove()
+			p.files[i] = nil
+		}
+	}
+}
+
+// distinctSet remembers the tuples that have been sent by an operation which
+// removes duplicates.  Once the memory budget is exceeded, the remembered
+// tuples are spilled, and any further tuples are deferred until flush.
+type distinctSet struct {
+	e      reflect.Type
+	budget int
+	level  int
+	mem    map[interface{}]struct{}
+	parts  *partitions
+
+	// err is the first error encountered while spilling
+	err error
+}
+
+// newDistinctSet creates an empty set of tuples of type e.
+func newDistinctSet(e reflect.Type) *distinctSet {
+	return &distinctSet{e: e, budget: tupleBudget(e), mem: make(map[interface{}]struct{})}
+}
+
+// add adds a tuple to the set, and returns true if it was not already in the
+// set and it can be sent immediately.
+func (s *distinctSet) add(rtup reflect.Value) bool {
+	return s.insert(0, rtup)
+}
+
+// insert adds a tuple with the given spill flags to the set.
+func (s *distinctSet) insert(flags uint8, rtup reflect.Value) bool {
+	if s.err != nil {
+		return false
+	}
+	key := tupleKey(rtup)
+	if s.parts == nil {
+		if _, dup := s.mem[key]; dup {
+			return false
+		}
+		if !overBudget(s.budget, len(s.mem), s.level) {
+			s.mem[key] = struct{}{}
+			return true
+		}
+		s.spill()
+	}
+	if err := s.parts.write(key, flags, rtup); err != nil && s.err == nil {
+		s.err = err
+	}
+	return false
+}
+
+// spill writes all of the remembered tuples to partitions.
+func (s *distinctSet) spill() {
+	s.parts = newPartitions([2]reflect.Type{s.e, s.e})
+	for key := range s.mem {
+		if err := s.parts.write(key, spillOld, reflect.ValueOf(key)); err != nil {
+			s.err = err
+			break
+		}
+	}
+	s.mem = nil
+}
+
+// flush finds the distinct deferred tuples in each partition, and calls
+// emit with each of them until it returns false.  The result is false if
+// emit returned false or if an error was encountered.
+func (s *distinctSet) flush(emit func(rtup reflect.Value) bool) bool {
+	defer s.close()
+	if s.parts == nil || s.err != nil {
+		return s.err == nil
+	}
+	for i := range s.parts.files {
+		sub := &distinctSet{e: s.e, budget: s.budget, level: s.level + 1, mem: make(map[interface{}]struct{})}
+		cont := true
+		err := s.parts.drain(i, func(flags uint8, rtup reflect.Value) bool {
+			if sub.insert(flags, rtup) && flags&spillOld == 0 {
+				cont = emit(rtup)
+			}
+			return cont && sub.err == nil
+		})
+		if err == nil && cont {
+			cont = sub.flush(emit)
+		}
+		sub.close()
+		if err == nil {
+			err = sub.err
+		}
+		if err != nil {
+			s.err = err
+			return false
+		}
+		if !cont {
+			return false
+		}
+	}
+	return true
+}
+
+// close removes any spill files.
+func (s *distinctSet) close() {
+	if s.parts != nil {
+		s.parts.remove()
+	}
+}
+
+// diffSet remembers the tuples that are removed by a set difference.  Once
+// the memory budget is exceeded, the remembered tuples are spilled, and the
+// tuples which are tested against the set are deferred until flush.  All of
+// the removed tuples have to be added before any are tested.
+type diffSet struct {
+	e      reflect.Type
+	budget int
+	level  int
+	mem    map[interface{}]struct{}
+	parts  *partitions
+
+	// err is the first error encountered while spilling
+	err error
+}
+
+// newDiffSet creates an empty set of tuples of type e.
+func newDiffSet(e reflect.Type) *diffSet {
+	return &diffSet{e: e, budget: tupleBudget(e), mem: make(map[interface{}]struct{})}
+}
+
+// remove adds a tuple to the set of removed tuples.
+func (s *diffSet) remove(rtup reflect.Value) {
+	if s.err != nil {
+		return
+	}
+	key := tupleKey(rtup)
+	if s.parts == nil {
+		if !overBudget(s.budget, len(s.mem), s.level) {
+			s.mem[key] = struct{}{}
+			return
+		}
+		s.parts = newPartitions([2]reflect.Type{s.e, s.e})
+		for k := range s.mem {
+			if s.err = s.parts.write(k, spillSide2, reflect.ValueOf(k)); s.err != nil {
+				return
+			}
+		}
+		s.mem = nil
+	}
+	s.err = s.parts.write(key, spillSide2, rtup)
+}
+
+// keep returns true if the tuple is not in the set of removed tuples, and
+// it can be sent immediately.
+func (s *diffSet) keep(rtup reflect.Value) bool {
+	if s.err != nil {
+		return false
+	}
+	if s.parts != nil {
+		s.err = s.parts.write(tupleKey(rtup), 0, rtup)
+		return false
+	}
+	_, rem := s.mem[tupleKey(rtup)]
+	return !rem
+}
+
+// flush finds the deferred tuples in each partition which are not removed,
+// and calls emit with each of them until it returns false.  The result is
+// false if emit returned false or if an error was encountered.
+func (s *diffSet) flush(emit func(rtup reflect.Value) bool) bool {
+	defer s.close()
+	if s.parts == nil || s.err != nil {
+		return s.err == nil
+	}
+	for i := range s.parts.files {
+		sub := &diffSet{e: s.e, budget: s.budget, level: s.level + 1, mem: make(map[interface{}]struct{})}
+		cont := true
+		err := s.parts.drain(i, func(flags uint8, rtup reflect.Value) bool {
+			if flags&spillSide2 != 0 {
+				sub.remove(rtup)
+			} else if sub.keep(rtup) {
+				cont = emit(rtup)
+			}
+			return cont && sub.err == nil
+		})
+		if err == nil && cont {
+			cont = sub.flush(emit)
+		}
+		sub.close()
+		if err == nil {
+			err = sub.err
+		}
+		if err != nil {
+			s.err = err
+			return false
+		}
+		if !cont {
+			return false
+		}
+	}
+	return true
+}
+
+// close removes any spill files.
+func (s *diffSet) close() {
+	if s.parts != nil {
+		s.parts.remove()
+	}
+}
+
+// joinTable remembers the tuples received from both sources of a symmetric
+// hash join, indexed by their join values.  Once the memory budget is
+// exceeded, the remembered tuples are spilled, and any further tuples are
+// deferred until flush.
+type joinTable struct {
+	types   [2]reflect.Type
+	idx     [2][][]int
+	keyType reflect.Type
+	budget  int
+	level   int
+	mem     [2]map[interface{}][]reflect.Value
+	n       int
+	parts   *partitions
+
+	// err is the first error encountered while spilling
+	err error
+}
+
+// newJoinTable creates an empty table for tuples of type e1 and e2, which
+// are joined on the fields at idx1 and idx2.
+func newJoinTable(e1, e2 reflect.Type, idx1, idx2 [][]int, keyType reflect.Type) *joinTable {
+	return &joinTable{
+		types:   [2]reflect.Type{e1, e2},
+		idx:     [2][][]int{idx1, idx2},
+		keyType: keyType,
+		budget:  tupleBudget(e1, e2),
+		mem:     [2]map[interface{}][]reflect.Value{make(map[interface{}][]reflect.Value), make(map[interface{}][]reflect.Value)},
+	}
+}
+
+// add adds a tuple from the first (side 0) or second (side 1) source to the
+// table, and returns the tuples from the opposite source that have the same
+// join values, which it has to be combined with.
+func (jt *joinTable) add(side int, rtup reflect.Value) []reflect.Value {
+	return jt.insert(side, 0, rtup)
+}
+
+// insert adds a tuple with the given spill flags to the table.
+func (jt *joinTable) insert(side int, flags uint8, rtup reflect.Value) []reflect.Value {
+	if jt.err != nil {
+		return nil
+	}
+	key := joinKey(rtup, jt.idx[side], jt.keyType)
+	if side == 1 {
+		flags |= spillSide2
+	}
+	if jt.parts == nil {
+		if !overBudget(jt.budget, jt.n, jt.level) {
+			jt.mem[side][key] = append(jt.mem[side][key], rtup)
+			jt.n++
+			return jt.mem[1-side][key]
+		}
+		jt.spill()
+	}
+	if err := jt.parts.write(key, flags, rtup); err != nil && jt.err == nil {
+		jt.err = err
+	}
+	return nil
+}
+
+// spill writes all of the remembered tuples to partitions.  They have all
+// been combined with each other already.
+func (jt *joinTable) spill() {
+	jt.parts = newPartitions(jt.types)
+	for side, mem := range jt.mem {
+		flags := spillOld
+		if side == 1 {
+			flags |= spillSide2
+		}
+		for key, tups := range mem {
+			for _, rtup := range tups {
+				if err := jt.parts.write(key, flags, rtup); err != nil {
+					jt.err = err
+					return
+				}
+			}
+		}
+	}
+	jt.mem = [2]map[interface{}][]reflect.Value{}
+}
+
+// flush joins the deferred tuples in each partition, and calls emit with
+// each of the matching pairs of tuples until it returns false.  The result
+// is false if emit returned false or if an error was encountered.
+func (jt *joinTable) flush(emit func(rtup1, rtup2 reflect.Value) bool) bool {
+	defer jt.close()
+	if jt.parts == nil || jt.err != nil {
+		return jt.err == nil
+	}
+	for i := range jt.parts.files {
+		sub := newJoinTable(jt.types[0], jt.types[1], jt.idx[0], jt.idx[1], jt.keyType)
+		sub.budget = jt.budget
+		sub.level = jt.level + 1
+		cont := true
+		err := jt.parts.drain(i, func(flags uint8, rtup reflect.Value) bool {
+			side := 0
+			if flags&spillSide2 != 0 {
+				side = 1
+			}
+			mtups := sub.insert(side, flags&spillOld, rtup)
+			if flags&spillOld != 0 {
+				return sub.err == nil
+			}
+			for _, mtup := range mtups {
+				if side == 0 {
+					cont = emit(rtup, mtup)
+				} else {
+					cont = emit(mtup, rtup)
+				}
+				if !cont {
+					break
+				}
+			}
+			return cont && sub.err == nil
+		})
+		if err == nil && cont {
+			cont = sub.flush(emit)
+		}
+		sub.close()
+		if err == nil {
+			err = sub.err
+		}
+		if err != nil {
+			jt.err = err
+			return false
+		}
+		if !cont {
+			return false
+		}
+	}
+	return true
+}
+
+// close removes any spill files.
+func (jt *joinTable) close() {
+	if jt.parts != nil {
+		jt.parts.remove()
+	}
+}
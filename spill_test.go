@@ -0,0 +1,196 @@
+package rel
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// spillTups creates n tuples, where each distinct tuple is repeated dup times
+func spillTups(n, dup int) []exTup2 {
+	tups := make([]exTup2, n*dup)
+	for i := range tups {
+		tups[i] = exTup2{i % n, "test"}
+	}
+	return tups
+}
+
+// spillFiles returns the number of spill files left in dir
+func spillFiles(t *testing.T, dir string) int {
+	m, err := filepath.Glob(filepath.Join(dir, "rel-spill-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(m)
+}
+
+// tests for operations which exceed the memory budget
+func TestMemoryBudget(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	prev := SetMemoryBudget(10)
+	defer SetMemoryBudget(prev)
+	if b := MemoryBudget(); b != 10 {
+		t.Errorf("MemoryBudget() => %d, want %d", b, 10)
+	}
+
+	type fooTup struct {
+		Foo int
+	}
+	type barTup struct {
+		Bar string
+	}
+	type joinTup struct {
+		Foo int
+		Bar string
+		Qux int
+	}
+	type quxTup struct {
+		Foo int
+		Qux int
+	}
+	quxs := make([]quxTup, 0, 1000)
+	for i := 0; i < 500; i++ {
+		quxs = append(quxs, quxTup{i % 250, i}, quxTup{i % 250, -i - 1})
+	}
+	mod10 := func(tup fooTup) fooTup {
+		return fooTup{tup.Foo % 10}
+	}
+	isEven := func(tup fooTup) bool {
+		return tup.Foo%2 == 0
+	}
+
+	var spillTests = []struct {
+		name string
+		rel  func() Relation
+		card int
+	}{
+		{"slice", func() Relation { return New(spillTups(100, 3), nil) }, 100},
+		{"chan", func() Relation {
+			ch := make(chan exTup2)
+			go func() {
+				for _, tup := range spillTups(100, 3) {
+					ch <- tup
+				}
+				close(ch)
+			}()
+			return New(ch, nil)
+		}, 100},
+		{"union", func() Relation {
+			return New(spillTups(100, 1), [][]string{{"Foo"}}).Union(New(spillTups(150, 1), [][]string{{"Foo"}}))
+		}, 150},
+		{"diff", func() Relation {
+			return New(spillTups(200, 1), [][]string{{"Foo"}}).Diff(New(spillTups(100, 1), [][]string{{"Foo"}}))
+		}, 100},
+		{"diff restrict", func() Relation {
+			r := New(spillTups(200, 1), [][]string{{"Foo"}})
+			return r.Diff(r.Restrict(Attribute("Foo").LT(150)))
+		}, 50},
+		{"project", func() Relation { return New(spillTups(100, 1), [][]string{{"Foo"}}).Project(barTup{}) }, 1},
+		{"map", func() Relation {
+			return New(spillTups(100, 1), [][]string{{"Foo"}}).Project(fooTup{}).Map(mod10, nil)
+		}, 10},
+		{"join", func() Relation {
+			return New(spillTups(200, 1), [][]string{{"Foo"}}).Join(New(quxs, [][]string{{"Qux"}}), joinTup{})
+		}, 800},
+		{"join many", func() Relation {
+			return New(quxs, [][]string{{"Qux"}}).Join(New(quxs, [][]string{{"Qux"}}).Rename(quxTup{}), quxTup{})
+		}, 1000},
+		{"restrict", func() Relation {
+			return New(spillTups(100, 2), nil).Project(fooTup{}).Restrict(AdHoc{isEven})
+		}, 50},
+	}
+	for _, tt := range spillTests {
+		SetMemoryBudget(0)
+		want := tt.rel()
+		tups, errf := Tuples(want)
+		expect := make(map[interface{}]struct{})
+		for tup := range tups {
+			expect[tup] = struct{}{}
+		}
+		if err := errf(); err != nil {
+			t.Errorf("%s without a budget has error %v", tt.name, err)
+		}
+		if len(expect) != tt.card {
+			t.Errorf("%s without a budget has %d tuples, want %d", tt.name, len(expect), tt.card)
+		}
+
+		SetMemoryBudget(10)
+		r := tt.rel()
+		n := 0
+		tups, errf = Tuples(r)
+		for tup := range tups {
+			if _, ok := expect[tup]; !ok {
+				t.Errorf("%s with a budget sent unexpected tuple %v", tt.name, tup)
+			}
+			n++
+		}
+		if err := errf(); err != nil {
+			t.Errorf("%s with a budget has error %v", tt.name, err)
+		}
+		if n != tt.card {
+			t.Errorf("%s with a budget has %d tuples, want %d", tt.name, n, tt.card)
+		}
+		if c := spillFiles(t, dir); c != 0 {
+			t.Errorf("%s left %d spill files", tt.name, c)
+		}
+	}
+
+	// spilled tuples are sent after the rest, so the results of operations
+	// on sorted relations are no longer sorted, and have to be joined
+	// without a merge join
+	SetMemoryBudget(5)
+	sorted := New(spillTups(400, 1), [][]string{{"Foo"}}).Order("Foo")
+	foos := make([]fooTup, 250)
+	for i := range foos {
+		foos[i] = fooTup{i}
+	}
+	var sortTests = []struct {
+		name string
+		rel  Relation
+		card int
+	}{
+		{"diff", sorted.Diff(sorted.Restrict(Attribute("Foo").GE(200))).Join(New(foos, nil).Order("Foo"), exTup2{}), 200},
+		{"project", New(quxs, [][]string{{"Qux"}}).Order("Foo", "Qux").Project(fooTup{}).Join(New(foos, nil).Order("Foo"), fooTup{}), 250},
+	}
+	for _, tt := range sortTests {
+		if card := Card(tt.rel); card != tt.card {
+			t.Errorf("%s with a budget has %d tuples, want %d", tt.name, card, tt.card)
+		}
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%s with a budget has error %v", tt.name, err)
+		}
+	}
+	SetMemoryBudget(10)
+
+	// cancellation while the spilled tuples are sent removes the files
+	r := New(spillTups(100, 3), nil)
+	ch := make(chan exTup2)
+	cancel := r.TupleChan(ch)
+	for i := 0; i < 50; i++ {
+		<-ch
+	}
+	close(cancel)
+	// the files are removed concurrently
+	for i := 0; i < 100 && spillFiles(t, dir) != 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if c := spillFiles(t, dir); c != 0 {
+		t.Errorf("cancellation left %d spill files", c)
+	}
+
+	// tuples which can't be encoded produce an error
+	type anyTup struct {
+		Foo int
+		Val interface{}
+	}
+	anyTups := make([]anyTup, 0, 40)
+	for i := 0; i < 20; i++ {
+		anyTups = append(anyTups, anyTup{i, fooTup{i}}, anyTup{i, fooTup{i}})
+	}
+	r = New(anyTups, nil)
+	Card(r)
+	if err := r.Err(); err == nil {
+		t.Errorf("spilling an unencodable tuple did not produce an error")
+	}
+}
@@ -0,0 +1,514 @@
+// sql implements the translation of relational expressions into SQL queries.
+
+package rel
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Dialect describes the syntax differences between databases that matter to
+// the queries produced by ToSQL.
+type Dialect interface {
+	// Quote returns the identifier quoted for use in a query
+	Quote(ident string) string
+
+	// Placeholder returns the placeholder for the n'th argument of a query,
+	// where the first argument is 1
+	Placeholder(n int) string
+}
+
+// sqlDialect is a Dialect which quotes identifiers with a given character,
+// and which uses either ? or numbered $n placeholders.
+type sqlDialect struct {
+	quote    string
+	numbered bool
+}
+
+// Quote returns the identifier quoted for use in a query
+func (d sqlDialect) Quote(ident string) string {
+	return d.quote + strings.Replace(ident, d.quote, d.quote+d.quote, -1) + d.quote
+}
+
+// Placeholder returns the placeholder for the n'th argument of a query
+func (d sqlDialect) Placeholder(n int) string {
+	if d.numbered {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+var (
+	// ANSI quotes identifiers with double quotes and uses ? placeholders,
+	// which is also accepted by SQLite.
+	ANSI Dialect = sqlDialect{`"`, false}
+
+	// Postgres quotes identifiers with double quotes and uses numbered $1
+	// placeholders.
+	Postgres Dialect = sqlDialect{`"`, true}
+
+	// MySQL quotes identifiers with backticks and uses ? placeholders.
+	MySQL Dialect = sqlDialect{"`", false}
+)
+
+// ToSQL translates a relational expression into a parameterized SQL query,
+// and returns the query along with the arguments for its placeholders.  The
+// base relations of the expression have to be created with Named, and they
+// are referred to by name in the query, with columns that have the same
+// names as their attributes.
+//
+// Project, Restrict, Rename, Join, Union, Diff, and the GroupBy expressions
+// created by Summarize can be translated, along with the comparison
+// predicates combined with And, Or, and Not.  Other relations and
+// predicates, like Map and AdHoc, result in an *UnsupportedError.
+func ToSQL(r Relation, dialect Dialect) (string, []interface{}, error) {
+	if err := r.Err(); err != nil {
+		return "", nil, err
+	}
+	b := &sqlBuilder{}
+	q, err := b.build(r)
+	if err != nil {
+		return "", nil, err
+	}
+	f := q.render()
+	return f.finish(dialect, 0), f.args, nil
+}
+
+// WhereSQL translates a predicate into a condition for the WHERE clause of a
+// parameterized SQL query, and returns the condition along with the
+// arguments for its placeholders.  The attributes of the predicate are
+// columns with the same names.  The placeholders are numbered after the n
+// that are already in the query.  The comparison predicates combined with
+// And, Or, and Not can be translated, and other predicates, like AdHoc,
+// result in an *UnsupportedError.
+func WhereSQL(p Predicate, dialect Dialect, n int) (string, []interface{}, error) {
+	cols := make(map[Attribute]string)
+	for _, att := range p.Domain() {
+		cols[att] = ident(string(att))
+	}
+	f, err := sqlWhere(p, cols)
+	if err != nil {
+		return "", nil, err
+	}
+	return f.finish(dialect, n), f.args, nil
+}
+
+// sqlArg marks the position of an argument in a query under construction,
+// before the placeholders have been numbered.
+const sqlArg = "\x00"
+
+// sqlIdent surrounds identifiers in a query under construction, before they
+// are quoted by the dialect.
+const sqlIdent = "\x01"
+
+// ident returns an identifier which will be quoted
+func ident(name string) string {
+	return sqlIdent + name + sqlIdent
+}
+
+// quoteIdents quotes each of the marked identifiers in s
+func quoteIdents(s string, dialect Dialect) string {
+	parts := strings.Split(s, sqlIdent)
+	for i := 1; i < len(parts); i += 2 {
+		parts[i] = dialect.Quote(parts[i])
+	}
+	return strings.Join(parts, "")
+}
+
+// sqlFrag is a fragment of a query, along with the arguments for the
+// placeholders in it, in the same order.
+type sqlFrag struct {
+	sql  string
+	args []interface{}
+}
+
+// finish numbers the placeholders in the fragment in the order that they
+// appear, after the n that precede it, and quotes its identifiers.
+func (f sqlFrag) finish(dialect Dialect, n int) string {
+	parts := strings.Split(f.sql, sqlArg)
+	var s strings.Builder
+	for i, part := range parts {
+		if i > 0 {
+			s.WriteString(dialect.Placeholder(n + i))
+		}
+		s.WriteString(part)
+	}
+	return quoteIdents(s.String(), dialect)
+}
+
+// sqlCol is a column in the select list of a query
+type sqlCol struct {
+	// expr is the expression for the column's value
+	expr string
+
+	// name is the name of the attribute the column is for
+	name Attribute
+}
+
+// sqlQuery is a query under construction.  Each of the relational operations
+// is merged into the query of its source, unless that would change its
+// meaning, in which case the source becomes a derived table.
+type sqlQuery struct {
+	// distinct is true if duplicate rows have to be removed
+	distinct bool
+
+	// cols is the select list, in the same order as the heading of the
+	// relation
+	cols []sqlCol
+
+	// from is the FROM clause
+	from sqlFrag
+
+	// where are the conditions of the WHERE clause, which are and'ed
+	where []sqlFrag
+
+	// groupBy is the GROUP BY clause
+	groupBy []string
+
+	// set is the whole query if it is a compound query like a UNION, in
+	// which case the rest of the fields are only used for the columns.
+	set sqlFrag
+
+	// table is true if the query selects all of the columns of a table
+	// without any other clauses, so that the table can be used directly
+	table bool
+}
+
+// render returns the query as a fragment
+func (q *sqlQuery) render() sqlFrag {
+	if q.set.sql != "" {
+		return q.set
+	}
+	var s strings.Builder
+	var args []interface{}
+	s.WriteString("SELECT ")
+	if q.distinct {
+		s.WriteString("DISTINCT ")
+	}
+	if len(q.cols) == 0 {
+		// relations with no attributes have at most one tuple, which
+		// exists if the source has any rows
+		s.WriteString("1")
+	}
+	for i, c := range q.cols {
+		if i > 0 {
+			s.WriteString(", ")
+		}
+		s.WriteString(c.expr)
+		if c.expr != ident(string(c.name)) && !strings.HasSuffix(c.expr, "."+ident(string(c.name))) {
+			s.WriteString(" AS " + ident(string(c.name)))
+		}
+	}
+	s.WriteString(" FROM " + q.from.sql)
+	args = append(args, q.from.args...)
+	for i, w := range q.where {
+		if i == 0 {
+			s.WriteString(" WHERE ")
+		} else {
+			s.WriteString(" AND ")
+		}
+		if len(q.where) > 1 && strings.Contains(w.sql, " OR ") {
+			s.WriteString("(" + w.sql + ")")
+		} else {
+			s.WriteString(w.sql)
+		}
+		args = append(args, w.args...)
+	}
+	if len(q.groupBy) > 0 {
+		s.WriteString(" GROUP BY " + strings.Join(q.groupBy, ", "))
+	}
+	return sqlFrag{s.String(), args}
+}
+
+// colExprs returns a map from the attributes of the query to the
+// expressions for their values
+func (q *sqlQuery) colExprs() map[Attribute]string {
+	m := make(map[Attribute]string, len(q.cols))
+	for _, c := range q.cols {
+		m[c.name] = c.expr
+	}
+	return m
+}
+
+// sqlBuilder translates relational expressions into queries
+type sqlBuilder struct {
+	// aliases is the number of table aliases which have been used
+	aliases int
+}
+
+// alias returns a new table alias
+func (b *sqlBuilder) alias() string {
+	b.aliases++
+	return ident("t" + strconv.Itoa(b.aliases))
+}
+
+// derive returns the query as a table in the FROM clause of a new query,
+// along with the alias of the table.
+func (b *sqlBuilder) derive(q *sqlQuery) (sqlFrag, string) {
+	a := b.alias()
+	if q.table {
+		return sqlFrag{q.from.sql + " AS " + a, nil}, a
+	}
+	f := q.render()
+	return sqlFrag{"(" + f.sql + ") AS " + a, f.args}, a
+}
+
+// wrap returns a new query which selects all of the columns of q
+func (b *sqlBuilder) wrap(q *sqlQuery) *sqlQuery {
+	from, a := b.derive(q)
+	cols := make([]sqlCol, len(q.cols))
+	for i, c := range q.cols {
+		cols[i] = sqlCol{a + "." + ident(string(c.name)), c.name}
+	}
+	return &sqlQuery{cols: cols, from: from}
+}
+
+// build translates a relation into a query
+func (b *sqlBuilder) build(r Relation) (*sqlQuery, error) {
+	switch r1 := r.(type) {
+	case *namedExpr:
+		heading := Heading(r1)
+		cols := make([]sqlCol, len(heading))
+		for i, att := range heading {
+			cols[i] = sqlCol{ident(string(att)), att}
+		}
+		return &sqlQuery{cols: cols, from: sqlFrag{ident(r1.name), nil}, table: true}, nil
+
+	case *restrictExpr:
+		q, err := b.build(r1.source1)
+		if err != nil {
+			return nil, err
+		}
+		if q.set.sql != "" || len(q.groupBy) > 0 {
+			q = b.wrap(q)
+		}
+		w, err := sqlWhere(r1.p, q.colExprs())
+		if err != nil {
+			return nil, err
+		}
+		q.where = append(q.where, w)
+		q.table = false
+		return q, nil
+
+	case *projectExpr:
+		q, err := b.build(r1.source1)
+		if err != nil {
+			return nil, err
+		}
+		if q.set.sql != "" || len(q.groupBy) > 0 {
+			q = b.wrap(q)
+		}
+		exprs := q.colExprs()
+		heading := Heading(r1)
+		cols := make([]sqlCol, len(heading))
+		for i, att := range heading {
+			cols[i] = sqlCol{exprs[att], att}
+		}
+		q.cols = cols
+		e1 := reflect.TypeOf(r1.source1.Zero())
+		e2 := reflect.TypeOf(r1.zero)
+		if len(SubsetCandidateKeys(r1.source1.CKeys(), Heading(r1.source1), FieldMap(e1, e2))) == 0 {
+			q.distinct = true
+		}
+		q.table = false
+		return q, nil
+
+	case *renameExpr:
+		q, err := b.build(r1.source1)
+		if err != nil {
+			return nil, err
+		}
+		if q.set.sql != "" {
+			q = b.wrap(q)
+		}
+		heading := Heading(r1)
+		for i := range q.cols {
+			q.cols[i].name = heading[i]
+		}
+		q.table = false
+		return q, nil
+
+	case *joinExpr:
+		q1, err := b.build(r1.source1)
+		if err != nil {
+			return nil, err
+		}
+		q2, err := b.build(r1.source2)
+		if err != nil {
+			return nil, err
+		}
+		from1, a1 := b.derive(q1)
+		from2, a2 := b.derive(q2)
+		h1 := Heading(r1.source1)
+		h2 := Heading(r1.source2)
+		var on []string
+		for _, att := range h1 {
+			if IsSubDomain([]Attribute{att}, h2) {
+				on = append(on, a1+"."+ident(string(att))+" = "+a2+"."+ident(string(att)))
+			}
+		}
+		from := sqlFrag{from1.sql, append(from1.args, from2.args...)}
+		if len(on) == 0 {
+			from.sql += " CROSS JOIN " + from2.sql
+		} else {
+			from.sql += " JOIN " + from2.sql + " ON " + strings.Join(on, " AND ")
+		}
+		heading := Heading(r1)
+		cols := make([]sqlCol, len(heading))
+		for i, att := range heading {
+			a := a2
+			if IsSubDomain([]Attribute{att}, h1) {
+				a = a1
+			}
+			cols[i] = sqlCol{a + "." + ident(string(att)), att}
+		}
+		// the result only has to be made distinct if it does not have all
+		// of the attributes of the sources
+		distinct := !IsSubDomain(unionAttributes(h1, h2), heading)
+		return &sqlQuery{distinct: distinct, cols: cols, from: from}, nil
+
+	case *unionExpr:
+		return b.compound(r1.source1, r1.source2, "UNION")
+
+	case *diffExpr:
+		return b.compound(r1.source1, r1.source2, "EXCEPT")
+
+	case *groupByExpr:
+		s, ok := r1.agg.(*summary)
+		if !ok {
+			return nil, &UnsupportedError{"GroupBy", r1.String()}
+		}
+		q, err := b.build(r1.source1)
+		if err != nil {
+			return nil, err
+		}
+		if q.set.sql != "" || len(q.groupBy) > 0 || q.distinct {
+			q = b.wrap(q)
+		}
+		exprs := q.colExprs()
+		aggs := make(map[Attribute]Aggregate, len(s.aggs))
+		for _, a := range s.aggs {
+			aggs[a.res] = a
+		}
+		heading := Heading(r1)
+		cols := make([]sqlCol, len(heading))
+		var groupBy []string
+		for i, att := range heading {
+			a, isAgg := aggs[att]
+			if !isAgg {
+				cols[i] = sqlCol{exprs[att], att}
+				groupBy = append(groupBy, exprs[att])
+				continue
+			}
+			var expr string
+			switch a.kind {
+			case aggSum:
+				expr = "SUM(" + exprs[a.att] + ")"
+			case aggCount:
+				expr = "COUNT(*)"
+			case aggAvg:
+				expr = "AVG(" + exprs[a.att] + ")"
+			case aggMin:
+				expr = "MIN(" + exprs[a.att] + ")"
+			case aggMax:
+				expr = "MAX(" + exprs[a.att] + ")"
+			case aggCountDistinct:
+				expr = "COUNT(DISTINCT " + exprs[a.att] + ")"
+			}
+			cols[i] = sqlCol{expr, att}
+		}
+		q.cols = cols
+		q.groupBy = groupBy
+		q.table = false
+		return q, nil
+	}
+	return nil, &UnsupportedError{nodeName(r), r.String()}
+}
+
+// compound translates a set operation on two relations into a query
+func (b *sqlBuilder) compound(r1, r2 Relation, op string) (*sqlQuery, error) {
+	q1, err := b.build(r1)
+	if err != nil {
+		return nil, err
+	}
+	q2, err := b.build(r2)
+	if err != nil {
+		return nil, err
+	}
+	// the right side has to be a simple query so that it does not need
+	// parentheses, with its columns in the same order as the left side
+	if q2.set.sql != "" {
+		q2 = b.wrap(q2)
+	}
+	exprs := q2.colExprs()
+	cols := make([]sqlCol, len(q1.cols))
+	for i, c := range q1.cols {
+		cols[i] = sqlCol{exprs[c.name], c.name}
+	}
+	q2.cols = cols
+	q2.distinct = false
+	f1 := q1.render()
+	f2 := q2.render()
+	set := sqlFrag{f1.sql + " " + op + " " + f2.sql, append(f1.args, f2.args...)}
+	return &sqlQuery{cols: q1.cols, set: set}, nil
+}
+
+// nodeName returns the name of the operation which produced a relation
+func nodeName(r Relation) string {
+	if n, ok := r.(Node); ok {
+		return n.Op().String()
+	}
+	return reflect.TypeOf(r).String()
+}
+
+// sqlWhere translates a predicate into a condition, where the attributes have
+// the values of the expressions in cols.
+func sqlWhere(p Predicate, cols map[Attribute]string) (sqlFrag, error) {
+	compare := func(att []Attribute, lit interface{}, op string) sqlFrag {
+		if len(att) == 2 {
+			return sqlFrag{cols[att[0]] + " " + op + " " + cols[att[1]], nil}
+		}
+		return sqlFrag{cols[att[0]] + " " + op + " " + sqlArg, []interface{}{lit}}
+	}
+	combine := func(p1, p2 Predicate, op string) (sqlFrag, error) {
+		w1, err := sqlWhere(p1, cols)
+		if err != nil {
+			return sqlFrag{}, err
+		}
+		w2, err := sqlWhere(p2, cols)
+		if err != nil {
+			return sqlFrag{}, err
+		}
+		return sqlFrag{"(" + w1.sql + ") " + op + " (" + w2.sql + ")", append(w1.args, w2.args...)}, nil
+	}
+	switch p1 := p.(type) {
+	case EQPred:
+		return compare(p1.att, p1.lit, "="), nil
+	case NEPred:
+		return compare(p1.att, p1.lit, "<>"), nil
+	case LTPred:
+		return compare(p1.att, p1.lit, "<"), nil
+	case LEPred:
+		return compare(p1.att, p1.lit, "<="), nil
+	case GTPred:
+		return compare(p1.att, p1.lit, ">"), nil
+	case GEPred:
+		return compare(p1.att, p1.lit, ">="), nil
+	case AndPred:
+		return combine(p1.P1, p1.P2, "AND")
+	case OrPred:
+		return combine(p1.P1, p1.P2, "OR")
+	case NotPred:
+		w, err := sqlWhere(p1.P, cols)
+		if err != nil {
+			return sqlFrag{}, err
+		}
+		return sqlFrag{"NOT (" + w.sql + ")", w.args}, nil
+	case XorPred:
+		return sqlFrag{}, &UnsupportedError{"Xor", p1.String()}
+	case AdHoc:
+		return sqlFrag{}, &UnsupportedError{"AdHoc", p1.String()}
+	}
+	return sqlFrag{}, &UnsupportedError{reflect.TypeOf(p).String(), p.String()}
+}
@@ -0,0 +1,122 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// fakeConnector is a database/sql/driver.Connector for a database which
+// answers one query with a fixed set of rows, and records the queries it
+// receives.  Any other query results in an error.
+type fakeConnector struct {
+	query string
+	args  []driver.Value
+	rows  [][]driver.Value
+
+	mu      sync.Mutex
+	queries []string
+}
+
+// Connect returns a connection to the fake database
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{c}, nil
+}
+
+// Driver returns the fake driver
+func (c *fakeConnector) Driver() driver.Driver {
+	return fakeDriver{c}
+}
+
+// received returns the queries the database has received
+func (c *fakeConnector) received() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.queries...)
+}
+
+type fakeDriver struct {
+	c *fakeConnector
+}
+
+func (d fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{d.c}, nil
+}
+
+type fakeConn struct {
+	c *fakeConnector
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c.c, query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fake: transactions are not supported")
+}
+
+type fakeStmt struct {
+	c     *fakeConnector
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("fake: exec is not supported")
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.c.mu.Lock()
+	s.c.queries = append(s.c.queries, s.query)
+	s.c.mu.Unlock()
+	if s.query != s.c.query {
+		return nil, fmt.Errorf("fake: unexpected query %q", s.query)
+	}
+	if len(args) != 0 || len(s.c.args) != 0 {
+		if !reflect.DeepEqual(args, s.c.args) {
+			return nil, fmt.Errorf("fake: unexpected arguments %v", args)
+		}
+	}
+	var cols []string
+	if len(s.c.rows) > 0 {
+		cols = make([]string, len(s.c.rows[0]))
+	}
+	return &fakeRows{cols, s.c.rows}, nil
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.cols
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,375 @@
+// Package sql implements relations which read their tuples from a table in a
+// database, using the database/sql package.
+//
+// Restrict and Project are pushed down into the query that is sent to the
+// database, as a WHERE clause and a SELECT list, whenever possible.  The
+// comparison predicates, like rel.Attribute("Color").EQ("Red"), can be
+// combined with And, Or, and Not and still be sent to the database.  Other
+// predicates, like rel.AdHoc, are evaluated in go on the results of the
+// query, as are the rest of the relational operations.
+//
+// Columns have the same names as the attributes of the tuples.  The table
+// and column names are quoted, and literal values in predicates are sent as
+// arguments to the query, using the syntax of the rel.Dialect given to New,
+// such as rel.ANSI, rel.Postgres, or rel.MySQL.  Predicates are translated
+// with rel.WhereSQL, the same way as in rel.ToSQL.
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"reflect"
+	"strings"
+
+	"github.com/jonlawlor/rel"
+)
+
+// tableRel is a relation with tuples read from a table in a database
+type tableRel struct {
+	// db is the database that contains the table
+	db *stdsql.DB
+
+	// dialect quotes the identifiers and provides the placeholders in the
+	// query
+	dialect rel.Dialect
+
+	// table is the name of the table, which may also be a view
+	table string
+
+	// set of candidate keys
+	cKeys rel.CandKeys
+
+	// the type of the tuples contained within the relation
+	zero interface{}
+
+	// sourceDistinct indicates if the rows of the table are already distinct
+	// on the selected columns, or if the query has to include DISTINCT
+	sourceDistinct bool
+
+	// preds are the predicates which have been pushed down into the WHERE
+	// clause of the query, which are all and'ed together
+	preds []rel.Predicate
+
+	// err holds the first error encountered during construction or
+	// evaluation.
+	err error
+}
+
+// New creates a new Relation with tuples of the same type as zero, which are
+// read from a table in db.  The table has to have a column with the same
+// name as each of the attributes.  The dialect determines how the table and
+// column names are quoted and which placeholders are used in the query.  See
+// rel.New for a description of the candidate keys.  If no candidate keys are
+// provided, then the query will select distinct rows.
+func New(db *stdsql.DB, dialect rel.Dialect, table string, zero interface{}, ckeys [][]string) rel.Relation {
+	r1 := &tableRel{db: db, dialect: dialect, table: table, zero: zero}
+	if len(ckeys) == 0 {
+		r1.cKeys = rel.DefaultKeys(zero)
+	} else {
+		r1.cKeys = rel.String2CandKeys(ckeys)
+		r1.sourceDistinct = true
+	}
+	rel.OrderCandidateKeys(r1.cKeys)
+	return r1
+}
+
+// query returns the query for the tuples in the relation, along with the
+// arguments for its placeholders
+func (r1 *tableRel) query() (string, []interface{}) {
+	cols := make([]string, 0, rel.Deg(r1))
+	for _, att := range rel.Heading(r1) {
+		cols = append(cols, r1.dialect.Quote(string(att)))
+	}
+	if len(cols) == 0 {
+		// relations with no attributes have at most one tuple, which
+		// exists if the table has any rows
+		cols = append(cols, "1")
+	}
+
+	q := "SELECT "
+	if !r1.sourceDistinct {
+		q += "DISTINCT "
+	}
+	q += strings.Join(cols, ", ") + " FROM " + r1.dialect.Quote(r1.table)
+	var args []interface{}
+	for i, p := range r1.preds {
+		// the predicates were translated when they were pushed down
+		cond, pargs, _ := rel.WhereSQL(p, r1.dialect, len(args))
+		if i == 0 {
+			q += " WHERE " + cond
+		} else {
+			q += " AND " + cond
+		}
+		args = append(args, pargs...)
+	}
+	return q, args
+}
+
+// TupleChan sends each tuple in the relation to a channel
+func (r1 *tableRel) TupleChan(t interface{}) chan<- struct{} {
+	cancel := make(chan struct{})
+	// reflect on the channel
+	chv := reflect.ValueOf(t)
+	err := rel.EnsureChan(chv.Type(), r1.zero)
+	if err != nil {
+		r1.err = err
+		return cancel
+	}
+	if r1.err != nil {
+		chv.Close()
+		return cancel
+	}
+
+	go func(res reflect.Value) {
+		// closing cancel also cancels the query
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+		go func() {
+			select {
+			case <-cancel:
+				stop()
+			case <-ctx.Done():
+			}
+		}()
+
+		q, args := r1.query()
+		rows, err := r1.db.QueryContext(ctx, q, args...)
+		if err != nil {
+			r1.err = err
+			res.Close()
+			return
+		}
+		defer rows.Close()
+
+		e := reflect.TypeOf(r1.zero)
+		fields := rel.AttributeFields(e)
+		dest := make([]interface{}, len(fields))
+		if len(dest) == 0 {
+			dest = append(dest, new(interface{}))
+		}
+
+		// output channels
+		canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+		resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+		for rows.Next() {
+			rtup := reflect.Indirect(reflect.New(e))
+			for i, f := range fields {
+				dest[i] = rtup.FieldByIndex(f).Addr().Interface()
+			}
+			if err := rows.Scan(dest...); err != nil {
+				r1.err = err
+				break
+			}
+			resSel.Send = rtup
+			chosen, _, _ := reflect.Select([]reflect.SelectCase{canSel, resSel})
+			if chosen == 0 {
+				// cancel has been closed, so stop reading
+				return
+			}
+		}
+		select {
+		case <-cancel:
+			// the query was cancelled, which is not an error
+			return
+		default:
+		}
+		if err := rows.Err(); err != nil && r1.err == nil {
+			r1.err = err
+		}
+		res.Close()
+	}(chv)
+	return cancel
+}
+
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *tableRel) TupleChanContext(ctx context.Context, t interface{}) error {
+	return rel.TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *tableRel) TupleSlice(t interface{}) error {
+	return rel.TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *tableRel) TupleMap(t interface{}) error {
+	return rel.TupleMap(r1, t)
+}
+
+// Zero returns the zero value of the relation (a blank tuple)
+func (r1 *tableRel) Zero() interface{} {
+	return r1.zero
+}
+
+// CKeys is the set of candidate keys in the relation
+func (r1 *tableRel) CKeys() rel.CandKeys {
+	return r1.cKeys
+}
+
+// GoString returns a text representation of the Relation
+func (r1 *tableRel) GoString() string {
+	q, _ := r1.query()
+	return "sql.New(" + q + ")"
+}
+
+// String returns a text representation of the Relation
+func (r1 *tableRel) String() string {
+	return "Relation(" + rel.HeadingString(r1) + ")"
+}
+
+// Project creates a new relation with less than or equal degree
+// t2 has to be a new type which is a subdomain of r.
+func (r1 *tableRel) Project(z2 interface{}) rel.Relation {
+	if r1.err != nil {
+		return r1
+	}
+	e1 := reflect.TypeOf(r1.zero)
+	e2 := reflect.TypeOf(z2)
+	if rel.EnsureSubDomain(rel.FieldNames(e2), rel.Heading(r1)) != nil {
+		// let the project expression report the error
+		return rel.Rewrite(r1, rel.NewProject(r1, z2))
+	}
+
+	// the projection becomes the select list of the query
+	r2 := *r1
+	r2.zero = z2
+	r2.cKeys = rel.SubsetCandidateKeys(r1.cKeys, rel.Heading(r1), rel.FieldMap(e1, e2))
+	if len(r2.cKeys) == 0 {
+		r2.cKeys = rel.DefaultKeys(z2)
+		r2.sourceDistinct = false
+	}
+	rel.OrderCandidateKeys(r2.cKeys)
+	return &r2
+}
+
+// Restrict creates a new relation with less than or equal cardinality
+// p has to be a func(tup T) bool where tup is a subdomain of the input r.
+func (r1 *tableRel) Restrict(p rel.Predicate) rel.Relation {
+	if r1.err != nil {
+		return r1
+	}
+	if rel.EnsureSubDomain(p.Domain(), rel.Heading(r1)) != nil {
+		// let the restrict expression report the error
+		return rel.Rewrite(r1, rel.NewRestrict(r1, p))
+	}
+	if p1, ok := p.(rel.AndPred); ok {
+		// each side can be pushed down separately, so that a predicate which
+		// can't be translated does not prevent the other from being sent to
+		// the database
+		return r1.Restrict(p1.P1).Restrict(p1.P2)
+	}
+	if _, _, err := rel.WhereSQL(p, r1.dialect, 0); err != nil {
+		// predicates which can't be translated, like AdHoc, are evaluated
+		// in go
+		return rel.Rewrite(r1, rel.NewRestrict(r1, p))
+	}
+	r2 := *r1
+	r2.preds = append(r1.preds[:len(r1.preds):len(r1.preds)], p)
+	return &r2
+}
+
+// Rename creates a new relation with new column names
+// z2 has to be a struct with the same number of fields as the input relation
+func (r1 *tableRel) Rename(z2 interface{}) rel.Relation {
+	return rel.Rewrite(r1, rel.NewRename(r1, z2))
+}
+
+// Union creates a new relation by unioning the bodies of both inputs
+func (r1 *tableRel) Union(r2 rel.Relation) rel.Relation {
+	return rel.Rewrite(r1, rel.NewUnion(r1, r2))
+}
+
+// Diff creates a new relation by set minusing the two inputs
+func (r1 *tableRel) Diff(r2 rel.Relation) rel.Relation {
+	return rel.Rewrite(r1, rel.NewDiff(r1, r2))
+}
+
+// Join creates a new relation by performing a natural join on the inputs
+func (r1 *tableRel) Join(r2 rel.Relation, zero interface{}) rel.Relation {
+	return rel.Rewrite(r1, rel.NewJoin(r1, r2, zero))
+}
+
+// GroupBy creates a new relation by grouping and applying a user defined func
+func (r1 *tableRel) GroupBy(t2, gfcn interface{}) rel.Relation {
+	return rel.Rewrite(r1, rel.NewGroupBy(r1, t2, gfcn))
+}
+
+// Map creates a new relation by applying a function to tuples in the source
+func (r1 *tableRel) Map(mfcn interface{}, ckeystr [][]string) rel.Relation {
+	return rel.Rewrite(r1, rel.NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *tableRel) Extend(z2, efcn interface{}) rel.Relation {
+	return rel.Rewrite(r1, rel.NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *tableRel) Order(att ...rel.Attribute) rel.Relation {
+	return rel.Rewrite(r1, rel.NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *tableRel) SemiJoin(r2 rel.Relation) rel.Relation {
+	return rel.Rewrite(r1, rel.NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *tableRel) SemiDiff(r2 rel.Relation) rel.Relation {
+	return rel.Rewrite(r1, rel.NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *tableRel) Wrap(z2 interface{}, name rel.Attribute) rel.Relation {
+	return rel.Rewrite(r1, rel.NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *tableRel) Unwrap(name rel.Attribute) rel.Relation {
+	return rel.Rewrite(r1, rel.NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *tableRel) Group(z2 interface{}, name rel.Attribute) rel.Relation {
+	return rel.Rewrite(r1, rel.NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *tableRel) Ungroup(name rel.Attribute) rel.Relation {
+	return rel.Rewrite(r1, rel.NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *tableRel) Divide(r2, per rel.Relation) rel.Relation {
+	return rel.Rewrite(r1, rel.NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *tableRel) TClose() rel.Relation {
+	return rel.Rewrite(r1, rel.NewTClose(r1))
+}
+
+// Err returns an error encountered during construction or computation
+func (r1 *tableRel) Err() error {
+	return r1.err
+}
+
+// Op is the operation that produces the relation, which is a literal
+func (r1 *tableRel) Op() rel.OpKind {
+	return rel.OpLiteral
+}
+
+// Children are the relations that the operation is performed on, which is
+// empty for literals
+func (r1 *tableRel) Children() []rel.Relation {
+	return nil
+}
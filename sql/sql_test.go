@@ -0,0 +1,289 @@
+package sql
+
+import (
+	stdsql "database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"github.com/jonlawlor/rel"
+)
+
+type partTup struct {
+	PNO    int
+	PName  string
+	Color  string
+	Weight float64
+	City   string
+}
+
+type cityTup struct {
+	City string
+}
+
+type colorTup struct {
+	PNO   int
+	Color string
+}
+
+var partTups = []partTup{
+	{1, "Nut", "Red", 12.0, "London"},
+	{2, "Bolt", "Green", 17.0, "Paris"},
+	{3, "Screw", "Blue", 17.0, "Oslo"},
+	{4, "Screw", "Red", 14.0, "London"},
+	{5, "Cam", "Blue", 12.0, "Paris"},
+	{6, "Cog", "Red", 19.0, "London"},
+}
+
+// rowsOf returns the rows the database would return for the given columns
+// of the parts which satisfy the filter, without removing duplicates
+func rowsOf(filter func(partTup) bool, cols ...string) [][]driver.Value {
+	var rows [][]driver.Value
+	for _, tup := range partTups {
+		if !filter(tup) {
+			continue
+		}
+		rtup := reflect.ValueOf(tup)
+		row := make([]driver.Value, len(cols))
+		for i, col := range cols {
+			row[i] = rtup.FieldByName(col).Interface()
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func all(partTup) bool { return true }
+
+const partsQuery = `SELECT "PNO", "PName", "Color", "Weight", "City" FROM "parts"`
+
+func TestPushdown(t *testing.T) {
+	parts := func(db *stdsql.DB) rel.Relation {
+		return New(db, rel.ANSI, "parts", partTup{}, [][]string{{"PNO"}})
+	}
+	heavy := rel.AdHoc{F: func(tup struct{ Weight float64 }) bool {
+		return tup.Weight > 12
+	}}
+	var pushTests = []struct {
+		rel   func(db *stdsql.DB) rel.Relation
+		query string
+		args  []driver.Value
+		rows  [][]driver.Value
+		card  int
+	}{
+		{parts, partsQuery, nil, rowsOf(all, "PNO", "PName", "Color", "Weight", "City"), 6},
+		{
+			func(db *stdsql.DB) rel.Relation {
+				return parts(db).Restrict(rel.Attribute("Color").EQ("Red"))
+			},
+			partsQuery + ` WHERE "Color" = ?`,
+			[]driver.Value{"Red"},
+			rowsOf(func(tup partTup) bool { return tup.Color == "Red" }, "PNO", "PName", "Color", "Weight", "City"),
+			3,
+		},
+		{
+			func(db *stdsql.DB) rel.Relation {
+				return parts(db).Restrict(rel.Attribute("Weight").LT(14.0).Or(rel.Not(rel.Attribute("City").NE("Oslo"))))
+			},
+			partsQuery + ` WHERE ("Weight" < ?) OR (NOT ("City" <> ?))`,
+			[]driver.Value{14.0, "Oslo"},
+			rowsOf(func(tup partTup) bool { return tup.Weight < 14 || tup.City == "Oslo" }, "PNO", "PName", "Color", "Weight", "City"),
+			3,
+		},
+		{
+			func(db *stdsql.DB) rel.Relation {
+				return parts(db).Restrict(rel.Attribute("PNO").GE(2).And(rel.Attribute("PNO").LE(4)).And(rel.Attribute("PName").GT(rel.Attribute("City"))))
+			},
+			partsQuery + ` WHERE "PNO" >= ? AND "PNO" <= ? AND "PName" > "City"`,
+			[]driver.Value{int64(2), int64(4)},
+			rowsOf(func(tup partTup) bool { return tup.PNO >= 2 && tup.PNO <= 4 && tup.PName > tup.City }, "PNO", "PName", "Color", "Weight", "City"),
+			2,
+		},
+		// a projection which loses the candidate key has to be distinct
+		{
+			func(db *stdsql.DB) rel.Relation {
+				return parts(db).Project(cityTup{})
+			},
+			`SELECT DISTINCT "City" FROM "parts"`,
+			nil,
+			[][]driver.Value{{"London"}, {"Paris"}, {"Oslo"}},
+			3,
+		},
+		{
+			func(db *stdsql.DB) rel.Relation {
+				return parts(db).Project(colorTup{}).Restrict(rel.Attribute("Color").EQ("Blue"))
+			},
+			`SELECT "PNO", "Color" FROM "parts" WHERE "Color" = ?`,
+			[]driver.Value{"Blue"},
+			rowsOf(func(tup partTup) bool { return tup.Color == "Blue" }, "PNO", "Color"),
+			2,
+		},
+		// restrictions can also be pushed through projections
+		{
+			func(db *stdsql.DB) rel.Relation {
+				return parts(db).Project(cityTup{}).Restrict(rel.Attribute("City").NE("Paris"))
+			},
+			`SELECT DISTINCT "City" FROM "parts" WHERE "City" <> ?`,
+			[]driver.Value{"Paris"},
+			[][]driver.Value{{"London"}, {"Oslo"}},
+			2,
+		},
+		// AdHoc predicates are evaluated in go
+		{
+			func(db *stdsql.DB) rel.Relation {
+				return parts(db).Restrict(heavy)
+			},
+			partsQuery,
+			nil,
+			rowsOf(all, "PNO", "PName", "Color", "Weight", "City"),
+			4,
+		},
+		// even when they are combined with predicates which can be pushed down
+		{
+			func(db *stdsql.DB) rel.Relation {
+				return parts(db).Restrict(heavy.And(rel.Attribute("Color").EQ("Red")))
+			},
+			partsQuery + ` WHERE "Color" = ?`,
+			[]driver.Value{"Red"},
+			rowsOf(func(tup partTup) bool { return tup.Color == "Red" }, "PNO", "PName", "Color", "Weight", "City"),
+			2,
+		},
+		{
+			func(db *stdsql.DB) rel.Relation {
+				return parts(db).Restrict(heavy).Restrict(rel.Attribute("Color").EQ("Red"))
+			},
+			partsQuery + ` WHERE "Color" = ?`,
+			[]driver.Value{"Red"},
+			rowsOf(func(tup partTup) bool { return tup.Color == "Red" }, "PNO", "PName", "Color", "Weight", "City"),
+			2,
+		},
+		{
+			func(db *stdsql.DB) rel.Relation {
+				return parts(db).Restrict(heavy.Or(rel.Attribute("Color").EQ("Red")))
+			},
+			partsQuery,
+			nil,
+			rowsOf(all, "PNO", "PName", "Color", "Weight", "City"),
+			5,
+		},
+		{
+			func(db *stdsql.DB) rel.Relation {
+				return parts(db).Restrict(rel.Attribute("Color").EQ("Red").Xor(rel.Attribute("City").EQ("London")))
+			},
+			partsQuery,
+			nil,
+			rowsOf(all, "PNO", "PName", "Color", "Weight", "City"),
+			0,
+		},
+		{
+			func(db *stdsql.DB) rel.Relation {
+				return New(db, rel.ANSI, "parts", cityTup{}, nil)
+			},
+			`SELECT DISTINCT "City" FROM "parts"`,
+			nil,
+			[][]driver.Value{{"London"}, {"Paris"}, {"Oslo"}},
+			3,
+		},
+		{
+			func(db *stdsql.DB) rel.Relation {
+				return parts(db).Project(struct{}{})
+			},
+			`SELECT DISTINCT 1 FROM "parts"`,
+			nil,
+			[][]driver.Value{{int64(1)}},
+			1,
+		},
+		// the dialect quotes the identifiers and numbers the placeholders
+		{
+			func(db *stdsql.DB) rel.Relation {
+				return New(db, rel.Postgres, "parts", colorTup{}, [][]string{{"PNO"}}).Restrict(rel.Attribute("PNO").GT(1)).Restrict(rel.Attribute("Color").EQ("Red"))
+			},
+			`SELECT "PNO", "Color" FROM "parts" WHERE "PNO" > $1 AND "Color" = $2`,
+			[]driver.Value{int64(1), "Red"},
+			rowsOf(func(tup partTup) bool { return tup.PNO > 1 && tup.Color == "Red" }, "PNO", "Color"),
+			2,
+		},
+		{
+			func(db *stdsql.DB) rel.Relation {
+				return New(db, rel.MySQL, "order", cityTup{}, nil).Restrict(rel.Attribute("City").EQ("Oslo"))
+			},
+			"SELECT DISTINCT `City` FROM `order` WHERE `City` = ?",
+			[]driver.Value{"Oslo"},
+			[][]driver.Value{{"Oslo"}},
+			1,
+		},
+		{
+			func(db *stdsql.DB) rel.Relation {
+				return New(db, rel.ANSI, `my "parts"`, cityTup{}, nil)
+			},
+			`SELECT DISTINCT "City" FROM "my ""parts"""`,
+			nil,
+			[][]driver.Value{{"London"}, {"Paris"}, {"Oslo"}},
+			3,
+		},
+	}
+	for i, tt := range pushTests {
+		c := &fakeConnector{query: tt.query, args: tt.args, rows: tt.rows}
+		db := stdsql.OpenDB(c)
+		r := tt.rel(db)
+		if card := rel.Card(r); card != tt.card {
+			t.Errorf("%d %v has card %d, want %d", i, r, card, tt.card)
+		}
+		if err := r.Err(); err != nil {
+			t.Errorf("%d %v has error %v", i, r, err)
+		}
+		if q := c.received(); len(q) != 1 || q[0] != tt.query {
+			t.Errorf("%d %v sent queries %q, want %q", i, r, q, tt.query)
+		}
+		db.Close()
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c := &fakeConnector{query: partsQuery, rows: rowsOf(all, "PNO", "PName", "Color", "Weight", "City")}
+	db := stdsql.OpenDB(c)
+	defer db.Close()
+	parts := New(db, rel.ANSI, "parts", partTup{}, [][]string{{"PNO"}})
+
+	// invalid operations are reported by the expressions
+	type orderTup struct {
+		PNO int
+		SNO int
+		Qty int
+	}
+	if r := parts.Project(orderTup{}); r.Err() == nil {
+		t.Errorf("Project() to a different domain did not result in an error")
+	}
+	if r := parts.Restrict(rel.Attribute("Qty").EQ(1)); r.Err() == nil {
+		t.Errorf("Restrict() on a different domain did not result in an error")
+	}
+
+	// as are query and scan errors
+	r := parts.Restrict(rel.Attribute("Color").EQ("Red"))
+	rel.Card(r)
+	if r.Err() == nil {
+		t.Errorf("an unexpected query did not result in an error")
+	}
+	r = New(db, rel.ANSI, "parts", orderTup{}, nil)
+	rel.Card(r)
+	if r.Err() == nil {
+		t.Errorf("an unexpected query did not result in an error")
+	}
+	c.query = partsQuery
+	c.rows = [][]driver.Value{{"x", "Nut", "Red", 12.0, "London"}}
+	rel.Card(parts)
+	if parts.Err() == nil {
+		t.Errorf("a scan error did not result in an error")
+	}
+
+	// cancellation stops the query without an error
+	c.rows = rowsOf(all, "PNO", "PName", "Color", "Weight", "City")
+	parts = New(db, rel.ANSI, "parts", partTup{}, [][]string{{"PNO"}})
+	ch := make(chan partTup)
+	cancel := parts.TupleChan(ch)
+	<-ch
+	close(cancel)
+	if err := parts.Err(); err != nil {
+		t.Errorf("cancellation resulted in error %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package rel
+
+import (
+	"reflect"
+	"testing"
+)
+
+// tests for translating relational expressions into SQL
+func TestToSQL(t *testing.T) {
+	p := Named("parts", parts())
+	s := Named("suppliers", suppliers())
+	o := Named("orders", orders())
+
+	type cityTup struct {
+		City string
+	}
+	type colorTup struct {
+		PNO   int
+		Color string
+	}
+	type renameTup struct {
+		ID     int
+		Name   string
+		Color  string
+		Weight float64
+		City   string
+	}
+	type joinTup struct {
+		PNO    int
+		PName  string
+		Color  string
+		Weight float64
+		City   string
+		SNO    int
+		Qty    int
+	}
+	type nameQtyTup struct {
+		PName string
+		Qty   int
+	}
+	type totalTup struct {
+		PNO      int
+		TotalQty int
+		N        int
+		Avg      float64
+	}
+	type citySupTup struct {
+		SNO  int
+		City string
+	}
+	type cityPartTup struct {
+		PNO  int
+		City string
+	}
+
+	var sqlTests = []struct {
+		in   Relation
+		sql  string
+		args []interface{}
+	}{
+		{p, `SELECT "PNO", "PName", "Color", "Weight", "City" FROM "parts"`, nil},
+		{
+			p.Restrict(Attribute("Color").EQ("Red")),
+			`SELECT "PNO", "PName", "Color", "Weight", "City" FROM "parts" WHERE "Color" = ?`,
+			[]interface{}{"Red"},
+		},
+		{
+			p.Restrict(Attribute("Weight").LT(15.0).Or(Not(Attribute("City").NE("Oslo")))).Restrict(Attribute("PNO").GE(2)),
+			`SELECT "PNO", "PName", "Color", "Weight", "City" FROM "parts" WHERE "PNO" >= ? AND (("Weight" < ?) OR (NOT ("City" <> ?)))`,
+			[]interface{}{2, 15.0, "Oslo"},
+		},
+		{
+			p.Restrict(Attribute("PName").GT(Attribute("City")).And(Attribute("Weight").LE(12.0))),
+			`SELECT "PNO", "PName", "Color", "Weight", "City" FROM "parts" WHERE ("PName" > "City") AND ("Weight" <= ?)`,
+			[]interface{}{12.0},
+		},
+		{p.Project(cityTup{}), `SELECT DISTINCT "City" FROM "parts"`, nil},
+		{
+			p.Project(colorTup{}).Restrict(Attribute("Color").NE("Blue")),
+			`SELECT "PNO", "Color" FROM "parts" WHERE "Color" <> ?`,
+			[]interface{}{"Blue"},
+		},
+		{
+			p.Rename(renameTup{}).Restrict(Attribute("Name").EQ("Nut")),
+			`SELECT "PNO" AS "ID", "PName" AS "Name", "Color", "Weight", "City" FROM "parts" WHERE "PName" = ?`,
+			[]interface{}{"Nut"},
+		},
+		{
+			p.Join(o, joinTup{}),
+			`SELECT "t1"."PNO", "t1"."PName", "t1"."Color", "t1"."Weight", "t1"."City", "t2"."SNO", "t2"."Qty" FROM "parts" AS "t1" JOIN "orders" AS "t2" ON "t1"."PNO" = "t2"."PNO"`,
+			nil,
+		},
+		{
+			p.Restrict(Attribute("Color").EQ("Red")).Join(o.Restrict(Attribute("Qty").GT(250)), joinTup{}).Project(nameQtyTup{}),
+			`SELECT DISTINCT "t1"."PName", "t2"."Qty" FROM (SELECT "PNO", "PName", "Color", "Weight", "City" FROM "parts" WHERE "Color" = ?) AS "t1" JOIN (SELECT "PNO", "SNO", "Qty" FROM "orders" WHERE "Qty" > ?) AS "t2" ON "t1"."PNO" = "t2"."PNO"`,
+			[]interface{}{"Red", 250},
+		},
+		{
+			s.Project(cityTup{}).Union(p.Project(cityTup{})),
+			`SELECT DISTINCT "City" FROM "suppliers" UNION SELECT "City" FROM "parts"`,
+			nil,
+		},
+		{
+			s.Project(citySupTup{}).Rename(cityPartTup{}).Diff(p.Project(cityPartTup{}).Restrict(Attribute("City").EQ("Paris"))),
+			`SELECT "SNO" AS "PNO", "City" FROM "suppliers" EXCEPT SELECT "PNO", "City" FROM "parts" WHERE "City" = ?`,
+			[]interface{}{"Paris"},
+		},
+		{
+			s.Project(cityTup{}).Union(p.Project(cityTup{})).Restrict(Attribute("City").NE("Oslo")),
+			`SELECT DISTINCT "City" FROM "suppliers" WHERE "City" <> ? UNION SELECT "City" FROM "parts" WHERE "City" <> ?`,
+			[]interface{}{"Oslo", "Oslo"},
+		},
+		{
+			Summarize(s.Project(cityTup{}).Union(p.Project(cityTup{})), struct {
+				City string
+				N    int
+			}{}, Count("N")),
+			`SELECT "t1"."City", COUNT(*) AS "N" FROM (SELECT DISTINCT "City" FROM "suppliers" UNION SELECT "City" FROM "parts") AS "t1" GROUP BY "t1"."City"`,
+			nil,
+		},
+		{
+			Summarize(o.Restrict(Attribute("Qty").GE(200)), totalTup{}, Sum("Qty", "TotalQty"), Count("N"), Avg("Qty", "Avg")),
+			`SELECT "PNO", SUM("Qty") AS "TotalQty", COUNT(*) AS "N", AVG("Qty") AS "Avg" FROM "orders" WHERE "Qty" >= ? GROUP BY "PNO"`,
+			[]interface{}{200},
+		},
+		{
+			Summarize(o, totalTup{}, Max("Qty", "TotalQty"), CountDistinct("SNO", "N"), Avg("Qty", "Avg")).Restrict(Attribute("N").GT(1)),
+			`SELECT "t1"."PNO", "t1"."TotalQty", "t1"."N", "t1"."Avg" FROM (SELECT "PNO", MAX("Qty") AS "TotalQty", COUNT(DISTINCT "SNO") AS "N", AVG("Qty") AS "Avg" FROM "orders" GROUP BY "PNO") AS "t1" WHERE "t1"."N" > ?`,
+			[]interface{}{1},
+		},
+	}
+	for i, tt := range sqlTests {
+		q, args, err := ToSQL(tt.in, ANSI)
+		if err != nil {
+			t.Errorf("%d ToSQL(%v) has error %v", i, tt.in, err)
+			continue
+		}
+		if q != tt.sql {
+			t.Errorf("%d ToSQL(%v) =>\n%s\nwant\n%s", i, tt.in, q, tt.sql)
+		}
+		if !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%d ToSQL(%v) has args %v, want %v", i, tt.in, args, tt.args)
+		}
+	}
+
+	// dialects
+	r := p.Restrict(Attribute("Color").EQ("Red").Or(Attribute("City").EQ("Paris")))
+	var dialectTests = []struct {
+		d   Dialect
+		sql string
+	}{
+		{ANSI, `SELECT "PNO", "PName", "Color", "Weight", "City" FROM "parts" WHERE ("Color" = ?) OR ("City" = ?)`},
+		{Postgres, `SELECT "PNO", "PName", "Color", "Weight", "City" FROM "parts" WHERE ("Color" = $1) OR ("City" = $2)`},
+		{MySQL, "SELECT `PNO`, `PName`, `Color`, `Weight`, `City` FROM `parts` WHERE (`Color` = ?) OR (`City` = ?)"},
+	}
+	for _, tt := range dialectTests {
+		if q, _, _ := ToSQL(r, tt.d); q != tt.sql {
+			t.Errorf("ToSQL(%v) =>\n%s\nwant\n%s", r, q, tt.sql)
+		}
+	}
+	if q := ANSI.Quote(`a"b`); q != `"a""b"` {
+		t.Errorf("Quote() => %s, want %s", q, `"a""b"`)
+	}
+
+	// unsupported nodes
+	mapFcn := func(tup partTup) cityTup { return cityTup{tup.City} }
+	heavy := AdHoc{func(tup struct{ Weight float64 }) bool { return tup.Weight > 12 }}
+	var errTests = []struct {
+		in   Relation
+		node string
+	}{
+		{parts(), "Literal"},
+		{p.Map(mapFcn, nil), "Map"},
+		{p.Restrict(heavy), "AdHoc"},
+		{p.Restrict(Attribute("Color").EQ("Red").And(heavy)), "AdHoc"},
+		{p.Restrict(Attribute("Color").EQ("Red").Xor(Attribute("City").EQ("Paris"))), "Xor"},
+		{p.Order("PNO"), "Order"},
+		{p.SemiJoin(o), "SemiJoin"},
+		{p.GroupBy(cityTup{}, func(ch <-chan struct{ Weight float64 }) cityTup { return cityTup{} }), "GroupBy"},
+		{p.Union(Replay(Named("parts", parts()))), "Replay"},
+	}
+	for i, tt := range errTests {
+		_, _, err := ToSQL(tt.in, ANSI)
+		if err, ok := err.(*UnsupportedError); !ok || err.Node != tt.node {
+			t.Errorf("%d ToSQL(%v) => %v, want unsupported %s", i, tt.in, err, tt.node)
+		}
+	}
+	if _, _, err := ToSQL(p.Project(orderTup{}), ANSI); err == nil {
+		t.Errorf("ToSQL() of an invalid relation did not return an error")
+	}
+}
+
+// tests for translating predicates into WHERE clauses
+func TestWhereSQL(t *testing.T) {
+	var whereTests = []struct {
+		p       Predicate
+		dialect Dialect
+		n       int
+		cond    string
+		args    []interface{}
+	}{
+		{Attribute("Color").EQ("Red"), ANSI, 0, `"Color" = ?`, []interface{}{"Red"}},
+		{Attribute("PName").GT(Attribute("City")), MySQL, 0, "`PName` > `City`", nil},
+		{Attribute("PNO").GE(2).And(Not(Attribute("City").NE("Oslo"))), Postgres, 1, `("PNO" >= $2) AND (NOT ("City" <> $3))`, []interface{}{2, "Oslo"}},
+	}
+	for i, tt := range whereTests {
+		cond, args, err := WhereSQL(tt.p, tt.dialect, tt.n)
+		if err != nil {
+			t.Errorf("%d WhereSQL(%v) has error %v", i, tt.p, err)
+			continue
+		}
+		if cond != tt.cond || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%d WhereSQL(%v) => %s %v, want %s %v", i, tt.p, cond, args, tt.cond, tt.args)
+		}
+	}
+	heavy := AdHoc{func(tup struct{ Weight float64 }) bool { return tup.Weight > 12 }}
+	if _, _, err := WhereSQL(heavy, ANSI, 0); err == nil {
+		t.Errorf("WhereSQL(%v) did not return an error", heavy)
+	}
+}
+
+// tests for named relations
+func TestNamed(t *testing.T) {
+	r := Named("parts", parts())
+	if c := Card(r.Restrict(Attribute("Color").EQ("Red"))); c != 3 {
+		t.Errorf("Card() => %d, want %d", c, 3)
+	}
+	if s := r.Restrict(Attribute("Color").EQ("Red")).String(); s != "σ{Color == Red}(parts)" {
+		t.Errorf("String() => %s, want %s", s, "σ{Color == Red}(parts)")
+	}
+	if !reflect.DeepEqual(r.CKeys(), parts().CKeys()) {
+		t.Errorf("CKeys() => %v, want %v", r.CKeys(), parts().CKeys())
+	}
+}
@@ -21,12 +21,18 @@ func goStringTabTable(r Relation) string {
 	// \xff is used as an escape delim; see the tabwriter docs
 	w.Init(s, 1, 1, 1, ' ', tabwriter.StripEscape)
 
-	// create struct slice type information
-	// TODO(jonlawlor): include tags?
+	// create struct slice type information.  Excluded fields are left out,
+	// and fields with a different attribute name keep their rel tag.
+	e := reflect.TypeOf(r.Zero())
 	cn := Heading(r)
-	ct := FieldTypes(reflect.TypeOf(r.Zero()))
-	for i := range cn {
-		fmt.Fprintf(w, "\t\xff%s\xff\t\xff%v\xff\t\n", cn[i], ct[i])
+	idx := AttributeFields(e)
+	for i, j := range idx {
+		f := e.FieldByIndex(j)
+		if Attribute(f.Name) != cn[i] {
+			fmt.Fprintf(w, "\t\xff%s\xff\t\xff%v\xff\t\xff`rel:%q`\xff\n", f.Name, f.Type, cn[i])
+		} else {
+			fmt.Fprintf(w, "\t\xff%s\xff\t\xff%v\xff\t\n", f.Name, f.Type)
+		}
 	}
 	w.Flush()
 	s.WriteString("}{\n")
@@ -36,7 +42,6 @@ func goStringTabTable(r Relation) string {
 	tups := body.Interface()
 	_ = r.TupleChan(tups)
 
-	deg := Deg(r)
 	sourceSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: body}
 	inCases := []reflect.SelectCase{sourceSel}
 
@@ -48,8 +53,13 @@ func goStringTabTable(r Relation) string {
 		// this part might be replacable with some workers that
 		// convert tuples to strings
 		fmt.Fprintf(w, "\t{")
-		for j := 0; j < deg; j++ {
-			f := rtup.Field(j)
+		for _, j := range idx {
+			f := rtup.FieldByIndex(j)
+			if f.Type() == relationType && !f.IsNil() {
+				// relation-valued attributes are written as expressions
+				fmt.Fprintf(w, "\xff%s\xff,\t", f.Interface().(Relation).GoString())
+				continue
+			}
 			switch f.Kind() {
 			case reflect.String:
 				fmt.Fprintf(w, "\xff%q\xff,\t", f)
@@ -105,22 +115,14 @@ func stringTabTable(r Relation) string {
 	fmt.Fprintf(w, "\t|\n")
 
 	// write the body
-	body := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, reflect.TypeOf(r.Zero())), 0)
-	tups := body.Interface()
-	_ = r.TupleChan(tups)
-
-	sourceSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: body}
-	inCases := []reflect.SelectCase{sourceSel}
-
-	for {
-		_, rtup, ok := reflect.Select(inCases)
-		if !ok {
-			break
-		}
+	idx := AttributeFields(reflect.TypeOf(r.Zero()))
+	tups, _ := Tuples(r)
+	for tup := range tups {
+		rtup := reflect.ValueOf(tup)
 		// this part might be replacable with some workers that
 		// convert tuples to strings
-		for j := 0; j < deg; j++ {
-			f := rtup.Field(j)
+		for _, j := range idx {
+			f := rtup.FieldByIndex(j)
 			switch f.Kind() {
 			case reflect.String:
 				fmt.Fprintf(w, "|\t \xff%s\xff ", f)
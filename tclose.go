@@ -0,0 +1,301 @@
+// tclose implements a transitive closure expression, which is called TCLOSE
+// by Date.
+
+package rel
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+)
+
+// tcloseExpr represents the transitive closure of a binary relation.  If the
+// source relation has attributes X and Y of the same type, the result has a
+// tuple {x, y} for every path from x to y through the tuples of the source,
+// so that it contains {x, z} if it contains both {x, y} and {y, z}.  This is
+// useful for hierarchies like a bill of materials, where the source relates
+// each part to its immediate components, and the result relates each part
+// to all of them.
+//
+// The closure is computed with semi-naive iteration: each round joins the
+// tuples which were found in the previous round with the source, and the
+// ones which have not been found before are sent and are used for the next
+// round.  It ends when a round does not find any new tuples, which always
+// happens because there are only finitely many pairs of values.
+// This is one of the operations which consumes memory, because the source
+// and the tuples found so far are kept to compute each round.
+type tcloseExpr struct {
+	// source1 is the relation that is closed
+	source1 Relation
+
+	// err is the first error encountered during construction or evaluation.
+	err error
+}
+
+// tcloseTypes constructs the tuple types which are used to compose the
+// relation with itself.  The left type renames the second attribute of the
+// tuple type e to a new attribute, and the right type renames the first one,
+// so that a join between them is on paths through that attribute.
+func tcloseTypes(e reflect.Type) (left, right reflect.Type) {
+	fs := attributeFields(e)
+	via := "Via"
+	for via == string(fs[0].name) || via == string(fs[1].name) {
+		via += "_"
+	}
+	field := func(i int, name string) reflect.StructField {
+		return reflect.StructField{
+			Name: "F" + strconv.Itoa(i),
+			Type: e.FieldByIndex(fs[i].index).Type,
+			Tag:  reflect.StructTag("rel:" + strconv.Quote(name)),
+		}
+	}
+	left = reflect.StructOf([]reflect.StructField{field(0, string(fs[0].name)), field(1, via)})
+	right = reflect.StructOf([]reflect.StructField{field(0, via), field(1, string(fs[1].name))})
+	return
+}
+
+// TupleChan sends each tuple in the relation to a channel
+func (r1 *tcloseExpr) TupleChan(t interface{}) chan<- struct{} {
+	cancel := make(chan struct{})
+	// reflect on the channel
+	chv := reflect.ValueOf(t)
+	err := EnsureChan(chv.Type(), r1.Zero())
+	if err != nil {
+		r1.err = err
+		return cancel
+	}
+	if r1.err != nil {
+		chv.Close()
+		return cancel
+	}
+
+	e := reflect.TypeOf(r1.Zero())
+	left, right := tcloseTypes(e)
+	zl := reflect.New(left).Elem().Interface()
+	zr := reflect.New(right).Elem().Interface()
+	ckeystr := [][]string{{string(Heading(r1)[0]), string(Heading(r1)[1])}}
+
+	body := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e), 0)
+	bcancel := r1.source1.TupleChan(body.Interface())
+
+	go func(body, res reflect.Value) {
+		canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+		resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+
+		// found has the tuples which have been sent so far
+		found := make(map[interface{}]struct{})
+
+		// receive reads the tuples from a channel, and sends the ones that
+		// have not been found before.  It returns them, or false if the
+		// evaluation was cancelled.
+		receive := func(ch reflect.Value) (reflect.Value, bool) {
+			delta := reflect.MakeSlice(reflect.SliceOf(e), 0, 0)
+			inCases := []reflect.SelectCase{canSel, {Dir: reflect.SelectRecv, Chan: ch}}
+			for {
+				chosen, rtup, ok := reflect.Select(inCases)
+				if chosen == 0 {
+					return delta, false
+				}
+				if !ok {
+					return delta, true
+				}
+				k := tupleKey(rtup)
+				if _, ok := found[k]; ok {
+					continue
+				}
+				found[k] = struct{}{}
+				delta = reflect.Append(delta, rtup)
+				resSel.Send = rtup
+				chosen, _, _ = reflect.Select([]reflect.SelectCase{canSel, resSel})
+				if chosen == 0 {
+					return delta, false
+				}
+			}
+		}
+
+		// the paths of length one are the tuples of the source
+		source, ok := receive(body)
+		if !ok {
+			close(bcancel)
+			return
+		}
+		if err := r1.source1.Err(); err != nil {
+			r1.err = err
+			res.Close()
+			return
+		}
+
+		// each round extends the paths found in the previous one by a tuple
+		// of the source
+		next := New(source.Interface(), ckeystr).Rename(zr)
+		for delta := source; delta.Len() > 0; {
+			step := New(delta.Interface(), ckeystr).Rename(zl).Join(next, r1.Zero())
+			ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e), 0)
+			scancel := step.TupleChan(ch.Interface())
+			if delta, ok = receive(ch); !ok {
+				close(scancel)
+				return
+			}
+			if err := step.Err(); err != nil {
+				r1.err = err
+				break
+			}
+		}
+		res.Close()
+	}(body, chv)
+	return cancel
+}
+
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *tcloseExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *tcloseExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *tcloseExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
+// Zero returns the zero value of the relation (a blank tuple)
+func (r1 *tcloseExpr) Zero() interface{} {
+	return r1.source1.Zero()
+}
+
+// CKeys is the set of candidate keys in the relation.  Even if one of the
+// attributes is a key of the source, a value can reach many others through
+// paths, so the only key of the result is both of its attributes.
+func (r1 *tcloseExpr) CKeys() CandKeys {
+	return DefaultKeys(r1.Zero())
+}
+
+// GoString returns a text representation of the Relation
+func (r1 *tcloseExpr) GoString() string {
+	return r1.source1.GoString() + ".TClose()"
+}
+
+// String returns a text representation of the Relation
+func (r1 *tcloseExpr) String() string {
+	return r1.source1.String() + ".TClose()"
+}
+
+// Project creates a new relation with less than or equal degree
+// t2 has to be a new type which is a subdomain of r.
+func (r1 *tcloseExpr) Project(z2 interface{}) Relation {
+	return Rewrite(r1, NewProject(r1, z2))
+}
+
+// Restrict creates a new relation with less than or equal cardinality
+// p has to be a func(tup T) bool where tup is a subdomain of the input r.
+func (r1 *tcloseExpr) Restrict(p Predicate) Relation {
+	return Rewrite(r1, NewRestrict(r1, p))
+}
+
+// Rename creates a new relation with new column names
+// z2 has to be a struct with the same number of fields as the input relation
+func (r1 *tcloseExpr) Rename(z2 interface{}) Relation {
+	return Rewrite(r1, NewRename(r1, z2))
+}
+
+// Union creates a new relation by unioning the bodies of both inputs
+func (r1 *tcloseExpr) Union(r2 Relation) Relation {
+	return Rewrite(r1, NewUnion(r1, r2))
+}
+
+// Diff creates a new relation by set minusing the two inputs
+func (r1 *tcloseExpr) Diff(r2 Relation) Relation {
+	return Rewrite(r1, NewDiff(r1, r2))
+}
+
+// Join creates a new relation by performing a natural join on the inputs
+func (r1 *tcloseExpr) Join(r2 Relation, zero interface{}) Relation {
+	return Rewrite(r1, NewJoin(r1, r2, zero))
+}
+
+// GroupBy creates a new relation by grouping and applying a user defined func
+func (r1 *tcloseExpr) GroupBy(t2, gfcn interface{}) Relation {
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
+}
+
+// Map creates a new relation by applying a function to tuples in the source
+func (r1 *tcloseExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *tcloseExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *tcloseExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *tcloseExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *tcloseExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *tcloseExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *tcloseExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *tcloseExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *tcloseExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *tcloseExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *tcloseExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
+}
+
+// Err returns an error encountered during construction or computation
+func (r1 *tcloseExpr) Err() error {
+	return r1.err
+}
+
+// Op is the operation that produces the relation
+func (r1 *tcloseExpr) Op() OpKind {
+	return OpTClose
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *tcloseExpr) Children() []Relation {
+	return []Relation{r1.source1}
+}
@@ -0,0 +1,168 @@
+package rel
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// tests for transitive closure
+func TestTClose(t *testing.T) {
+	// parts that are contained in other parts through any number of levels
+	rel := bom().TClose()
+
+	type viaTup struct {
+		From int `rel:"Via"`
+		To   int
+	}
+	cycle := New([]bomTup{{1, 2}, {2, 3}, {3, 1}}, nil)
+	chain := func(n int) Relation {
+		ch := make(chan bomTup)
+		go func() {
+			for i := 0; i < n; i++ {
+				ch <- bomTup{i, i + 1}
+			}
+			close(ch)
+		}()
+		return New(ch, [][]string{{"Major", "Minor"}})
+	}
+
+	// test the degrees, cardinality, and string representation
+	var relTest = []struct {
+		rel          Relation
+		expectString string
+		expectDeg    int
+		expectCard   int
+	}{
+		{rel, "Relation(Major, Minor).TClose()", 2, 11},
+		{rel.Restrict(Attribute("Major").EQ(1)), "σ{Major == 1}(Relation(Major, Minor).TClose())", 2, 5},
+		{rel.Restrict(Attribute("Minor").EQ(6)), "σ{Minor == 6}(Relation(Major, Minor).TClose())", 2, 3},
+		{rel.TClose(), "Relation(Major, Minor).TClose().TClose()", 2, 11},
+		{bom().Restrict(Attribute("Major").GT(2)).TClose(), "σ{Major > 2}(Relation(Major, Minor)).TClose()", 2, 2},
+		{cycle.TClose(), "", 2, 9},
+		{chain(10).TClose(), "", 2, 55},
+		{New([]bomTup{}, nil).TClose(), "", 2, 0},
+		{New([]viaTup{{1, 2}, {2, 3}}, nil).TClose(), "Relation(Via, To).TClose()", 2, 3},
+		{rel.Union(bom()), "", 2, 11},
+		{rel.Diff(bom()), "", 2, 5},
+	}
+
+	for i, tt := range relTest {
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%d has Err() => %s", i, err.Error())
+			continue
+		}
+		if tt.expectString != "" {
+			if str := tt.rel.String(); str != tt.expectString {
+				t.Errorf("%d has String() => %v, want %v", i, str, tt.expectString)
+			}
+		}
+		if deg := Deg(tt.rel); deg != tt.expectDeg {
+			t.Errorf("%d %s has Deg() => %v, want %v", i, tt.rel, deg, tt.expectDeg)
+		}
+		if card := Card(tt.rel); card != tt.expectCard {
+			t.Errorf("%d %s has Card() => %v, want %v", i, tt.rel, card, tt.expectCard)
+		}
+	}
+
+	// the closure has a tuple for each path through the source
+	want := New([]bomTup{
+		{1, 2}, {1, 3}, {1, 4}, {1, 5}, {1, 6},
+		{2, 3}, {2, 4}, {2, 5}, {2, 6},
+		{3, 5},
+		{4, 6},
+	}, nil)
+	if !Equal(rel, want) {
+		t.Errorf("%s is not equal to %s", rel, want)
+	}
+
+	// the only candidate key is both attributes, even if the source has a
+	// smaller one
+	tree := New([]bomTup{{1, 2}, {1, 3}, {2, 4}}, [][]string{{"Minor"}})
+	if ck := tree.TClose().CKeys(); !reflect.DeepEqual(ck, CandKeys{{"Major", "Minor"}}) {
+		t.Errorf("%s has CKeys() => %v, want [[Major Minor]]", tree.TClose(), ck)
+	}
+
+	// test construction errors
+	type mixedTup struct {
+		PNO   int
+		PName string
+	}
+	constructTest := []struct {
+		rel Relation
+		err error
+	}{
+		{parts().TClose(), &DegreeError{}},
+		{parts().Project(struct{ PNO int }{}).TClose(), &DegreeError{}},
+		{parts().Project(mixedTup{}).TClose(), &AttributeTypeError{}},
+	}
+	for i, tt := range constructTest {
+		err := tt.rel.Err()
+		if err == nil {
+			t.Errorf("%d did not produce a construction error", i)
+			continue
+		}
+		if reflect.TypeOf(err) != reflect.TypeOf(tt.err) {
+			t.Errorf("%d has Err() => %T, want %T", i, err, tt.err)
+		}
+	}
+
+	// test cancellation
+	res := make(chan bomTup)
+	cancel := rel.TupleChan(res)
+	close(cancel)
+	select {
+	case <-res:
+		t.Errorf("cancel did not end tuple generation")
+	default:
+		// passed test
+	}
+
+	// test cancellation after the source tuples have been sent, while the
+	// closure is being computed
+	res = make(chan bomTup)
+	cancel = chain(100).TClose().TupleChan(res)
+	for i := 0; i < 150; i++ {
+		<-res
+	}
+	close(cancel)
+	select {
+	case <-res:
+		t.Errorf("cancel did not end tuple generation")
+	default:
+		// passed test
+	}
+
+	// test errors
+	err := fmt.Errorf("testing error")
+	rel1 := bom().TClose().(*tcloseExpr)
+	rel1.err = err
+	res = make(chan bomTup)
+	_ = rel1.TupleChan(res)
+	if _, ok := <-res; ok {
+		t.Errorf("tclose did not short circuit TupleChan")
+	}
+	errTest := []Relation{
+		rel1.Project(struct{ Major int }{}),
+		rel1.Restrict(Attribute("Major").EQ(1)),
+		rel1.Union(rel),
+		rel.Union(rel1),
+		rel1.Diff(rel),
+		rel1.Join(bom(), bomTup{}),
+		rel1.SemiJoin(bom()),
+		rel1.TClose(),
+		(&errorRel{bomTup{}, 1, err}).TClose(),
+	}
+	for i, errRel := range errTest {
+		if errRel.Err() != err {
+			t.Errorf("%d did not short circuit error", i)
+		}
+	}
+
+	// errors in the source are reported after evaluation
+	r2 := (&errorRel{bomTup{}, 1, nil}).TClose()
+	Card(r2)
+	if r2.Err() == nil {
+		t.Errorf("tclose did not pass along the source error")
+	}
+}
@@ -0,0 +1,72 @@
+// tuples provides iterators over the bodies of relations, which can be used
+// with range loops instead of channels.
+
+package rel
+
+import (
+	"iter"
+	"reflect"
+)
+
+// Tuples returns an iterator over the tuples in the relation, and a func
+// which returns the error encountered during the most recent iteration, if
+// any.  The relation is evaluated each time the iterator is used.  If the
+// loop over the iterator is exited early, the evaluation is cancelled, which
+// is not an error.
+//
+//	tups, errf := rel.Tuples(r)
+//	for tup := range tups {
+//		...
+//	}
+//	if err := errf(); err != nil {
+//		...
+//	}
+func Tuples(r Relation) (iter.Seq[interface{}], func() error) {
+	var err error
+	seq := func(yield func(interface{}) bool) {
+		if err = r.Err(); err != nil {
+			return
+		}
+		body := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, reflect.TypeOf(r.Zero())), 0)
+		cancel := r.TupleChan(body.Interface())
+		for {
+			rtup, ok := body.Recv()
+			if !ok {
+				break
+			}
+			if !yield(rtup.Interface()) {
+				close(cancel)
+				return
+			}
+		}
+		err = r.Err()
+	}
+	return seq, func() error { return err }
+}
+
+// TuplesOf is like Tuples, except that the tuples are provided as type T.  If
+// the relation does not have tuples of type T, the iterator is empty and the
+// error func returns an ElemError.
+func TuplesOf[T any](r Relation) (iter.Seq[T], func() error) {
+	var err error
+	var z T
+	if e1, e2 := reflect.TypeOf(r.Zero()), reflect.TypeOf(z); e1 != e2 {
+		err = &ElemError{e2, e1}
+		return func(yield func(T) bool) {}, func() error { return err }
+	}
+	seq := func(yield func(T) bool) {
+		if err = r.Err(); err != nil {
+			return
+		}
+		ch := make(chan T)
+		cancel := r.TupleChan(ch)
+		for tup := range ch {
+			if !yield(tup) {
+				close(cancel)
+				return
+			}
+		}
+		err = r.Err()
+	}
+	return seq, func() error { return err }
+}
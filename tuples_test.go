@@ -0,0 +1,116 @@
+package rel
+
+import (
+	"reflect"
+	"testing"
+)
+
+// tests for the iterators over relation bodies
+func TestTuples(t *testing.T) {
+	var tupleTests = []struct {
+		rel  Relation
+		card int
+	}{
+		{parts(), 6},
+		{parts().Restrict(Attribute("Color").EQ("Red")), 3},
+		{parts().Order("Weight"), 6},
+		{orders().SemiJoin(suppliers()), 11},
+		{New(map[orderTup]struct{}{{1, 1, 300}: {}}, nil), 1},
+	}
+	for i, tt := range tupleTests {
+		tups, errf := Tuples(tt.rel)
+		n := 0
+		for tup := range tups {
+			if z := tt.rel.Zero(); reflect.TypeOf(tup) != reflect.TypeOf(z) {
+				t.Errorf("%d Tuples() => %T, want %T", i, tup, z)
+			}
+			n++
+		}
+		if err := errf(); err != nil {
+			t.Errorf("%d Tuples() has error %v", i, err)
+		}
+		if n != tt.card {
+			t.Errorf("%d Tuples() => %d tuples, want %d", i, n, tt.card)
+		}
+
+		// the iterator can be used again
+		n = 0
+		for _ = range tups {
+			n++
+		}
+		if n != tt.card {
+			t.Errorf("%d Tuples() => %d tuples the second time, want %d", i, n, tt.card)
+		}
+
+		// breaking out of the loop cancels the evaluation, which is not an
+		// error
+		for _ = range tups {
+			break
+		}
+		if err := errf(); err != nil {
+			t.Errorf("%d Tuples() has error %v after cancellation", i, err)
+		}
+	}
+
+	// errors during evaluation are returned after the loop
+	tups, errf := Tuples(&errorRel{partTup{}, 2, nil})
+	n := 0
+	for _ = range tups {
+		n++
+	}
+	if err := errf(); err == nil || n != 2 {
+		t.Errorf("Tuples() => %d tuples and %v, want %d and an error", n, err, 2)
+	}
+
+	// as are errors during construction
+	tups, errf = Tuples(parts().Project(orderTup{}))
+	for _ = range tups {
+		t.Errorf("Tuples() sent a tuple from an invalid relation")
+	}
+	if err := errf(); err == nil {
+		t.Errorf("Tuples() did not return a construction error")
+	}
+}
+
+func TestTuplesOf(t *testing.T) {
+	tups, errf := TuplesOf[partTup](parts().Restrict(Attribute("Color").EQ("Red")))
+	n := 0
+	for tup := range tups {
+		if tup.Color != "Red" {
+			t.Errorf("TuplesOf() => %v, which does not satisfy the restriction", tup)
+		}
+		n++
+	}
+	if err := errf(); err != nil || n != 3 {
+		t.Errorf("TuplesOf() => %d tuples and %v, want %d and nil", n, err, 3)
+	}
+
+	// breaking out of the loop cancels the evaluation
+	tups, errf = TuplesOf[partTup](parts().Order("PNO"))
+	for tup := range tups {
+		if tup.PNO != 1 {
+			t.Errorf("TuplesOf() => %v first, want PNO 1", tup)
+		}
+		break
+	}
+	if err := errf(); err != nil {
+		t.Errorf("TuplesOf() has error %v after cancellation", err)
+	}
+
+	// the wrong tuple type is an error
+	otups, errf := TuplesOf[orderTup](parts())
+	for _ = range otups {
+		t.Errorf("TuplesOf() sent a tuple of the wrong type")
+	}
+	if _, ok := errf().(*ElemError); !ok {
+		t.Errorf("TuplesOf() => %v, want an ElemError", errf())
+	}
+
+	// errors during evaluation are returned after the loop
+	tups, errf = TuplesOf[partTup](&errorRel{partTup{}, 1, nil})
+	for _ = range tups {
+	}
+	if err := errf(); err == nil {
+		t.Errorf("TuplesOf() did not return an evaluation error")
+	}
+}
@@ -0,0 +1,253 @@
+// typed implements a generic layer on top of the reflection based relations,
+// so that the types of tuples can be checked at compile time.
+
+package rel
+
+import (
+	"context"
+	"iter"
+	"reflect"
+)
+
+// Typed is a relation with tuples of type T.  It wraps a Relation, so all of
+// the query rewrites of the underlying relation still apply, but the tuple
+// types of its inputs and results are checked by the compiler instead of at
+// runtime.
+//
+// Operations which do not change the tuple type are methods of Typed, and
+// operations which do are functions, because methods can't have type
+// parameters.
+type Typed[T any] struct {
+	// r is the underlying relation, which has tuples of type T
+	r Relation
+
+	// err is set if the underlying relation does not have tuples of type T
+	err error
+}
+
+// Of creates a new Typed relation from a slice of tuples, with the given
+// candidate keys.  See New for a description of the candidate keys.
+func Of[T any](tups []T, ckeystr [][]string) Typed[T] {
+	return From[T](New(tups, ckeystr))
+}
+
+// From creates a new Typed relation from a Relation.  If the tuples of the
+// Relation are not of type T, then the result will have non-nil Err().
+func From[T any](r Relation) Typed[T] {
+	if r.Err() != nil {
+		return Typed[T]{r, nil}
+	}
+	var z T
+	e1 := reflect.TypeOf(r.Zero())
+	e2 := reflect.TypeOf(z)
+	if e1 != e2 {
+		return Typed[T]{r, &ElemError{e2, e1}}
+	}
+	return Typed[T]{r, nil}
+}
+
+// Relation returns the underlying Relation
+func (r1 Typed[T]) Relation() Relation {
+	return r1.r
+}
+
+// Err returns an error encountered during construction or computation
+func (r1 Typed[T]) Err() error {
+	if r1.err != nil {
+		return r1.err
+	}
+	return r1.r.Err()
+}
+
+// CKeys is the set of candidate keys in the relation
+func (r1 Typed[T]) CKeys() CandKeys {
+	return r1.r.CKeys()
+}
+
+// String returns a text representation of the Relation
+func (r1 Typed[T]) String() string {
+	return r1.r.String()
+}
+
+// GoString returns a text representation of the Relation
+func (r1 Typed[T]) GoString() string {
+	return r1.r.GoString()
+}
+
+// All returns an iterator over the tuples in the relation.  If an error is
+// encountered, either during construction or evaluation, it is provided as
+// the last element of the sequence, along with a zero tuple.  If the loop
+// over the sequence is exited early, the evaluation is cancelled.
+func (r1 Typed[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var z T
+		if r1.err != nil {
+			yield(z, r1.err)
+			return
+		}
+		tups, errf := TuplesOf[T](r1.r)
+		for tup := range tups {
+			if !yield(tup, nil) {
+				return
+			}
+		}
+		if err := errf(); err != nil {
+			yield(z, err)
+		}
+	}
+}
+
+// Slice returns all of the tuples in the relation, or the first error
+// encountered.
+func (r1 Typed[T]) Slice() ([]T, error) {
+	if r1.err != nil {
+		return nil, r1.err
+	}
+	var tups []T
+	if err := r1.r.TupleSlice(&tups); err != nil {
+		return nil, err
+	}
+	return tups, nil
+}
+
+// TupleChanContext sends each tuple in the relation to ch, and returns when
+// they have all been sent or the context is done.  See
+// Relation.TupleChanContext.
+func (r1 Typed[T]) TupleChanContext(ctx context.Context, ch chan<- T) error {
+	if err := r1.Err(); err != nil {
+		close(ch)
+		return err
+	}
+	// the underlying relation requires a bidirectional channel
+	body := make(chan T)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- r1.r.TupleChanContext(ctx, body)
+	}()
+	for tup := range body {
+		select {
+		case ch <- tup:
+		case <-ctx.Done():
+			// the underlying relation stops as well, so wait for it to
+			// finish before returning
+			close(ch)
+			for range body {
+			}
+			<-errc
+			return ctx.Err()
+		}
+	}
+	close(ch)
+	return <-errc
+}
+
+// Restrict creates a new relation with the tuples that satisfy the predicate
+func (r1 Typed[T]) Restrict(p Predicate) Typed[T] {
+	return r1.same(r1.r.Restrict(p))
+}
+
+// Union creates a new relation by unioning the bodies of both inputs
+func (r1 Typed[T]) Union(r2 Typed[T]) Typed[T] {
+	return r1.same(r1.r.Union(r2.r))
+}
+
+// Diff creates a new relation by set minusing the two inputs
+func (r1 Typed[T]) Diff(r2 Typed[T]) Typed[T] {
+	return r1.same(r1.r.Diff(r2.r))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 Typed[T]) SemiJoin(r2 Relation) Typed[T] {
+	return r1.same(r1.r.SemiJoin(r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 Typed[T]) SemiDiff(r2 Relation) Typed[T] {
+	return r1.same(r1.r.SemiDiff(r2))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 Typed[T]) Order(att ...Attribute) Typed[T] {
+	return r1.same(r1.r.Order(att...))
+}
+
+// same wraps the result of an operation which does not change the tuple type
+func (r1 Typed[T]) same(r2 Relation) Typed[T] {
+	if r1.err != nil {
+		return r1
+	}
+	return From[T](r2)
+}
+
+// Project creates a new relation with tuples of type U, which has to have a
+// subset of the attributes of T.
+func Project[T, U any](r1 Typed[T]) Typed[U] {
+	if r1.err != nil {
+		return Typed[U]{r1.r, r1.err}
+	}
+	var z U
+	r2 := r1.r.Project(z)
+	if r2.Err() == nil && reflect.TypeOf(r2.Zero()) != reflect.TypeOf(z) {
+		// the projection did not remove any attributes, so it was not
+		// performed, but the tuples still have to be converted to type U.
+		r2 = &projectExpr{r1.r, z, nil}
+	}
+	return From[U](r2)
+}
+
+// Rename creates a new relation with tuples of type U, which has to have the
+// same number of attributes as T.  The attributes are renamed in order.
+func Rename[T, U any](r1 Typed[T]) Typed[U] {
+	if r1.err != nil {
+		return Typed[U]{r1.r, r1.err}
+	}
+	var z U
+	return From[U](r1.r.Rename(z))
+}
+
+// Join creates a new relation by performing a natural join on the inputs,
+// with tuples of type V, which has to have a subset of the attributes of T
+// and U.
+func Join[T, U, V any](r1 Typed[T], r2 Typed[U]) Typed[V] {
+	if r1.err != nil {
+		return Typed[V]{r1.r, r1.err}
+	}
+	if r2.err != nil {
+		return Typed[V]{r2.r, r2.err}
+	}
+	var z V
+	return From[V](r1.r.Join(r2.r, z))
+}
+
+// Map creates a new relation by applying a function to the tuples in r1.
+// See Relation.Map for a description of the candidate keys.
+func Map[T, U any](r1 Typed[T], mfcn func(T) U, ckeystr [][]string) Typed[U] {
+	if r1.err != nil {
+		return Typed[U]{r1.r, r1.err}
+	}
+	return From[U](r1.r.Map(mfcn, ckeystr))
+}
+
+// Extend creates a new relation with tuples of type V by adding the
+// attributes of U, which are computed from the tuples of r1.  V has to have
+// all of the attributes of T and U.
+func Extend[T, U, V any](r1 Typed[T], efcn func(T) U) Typed[V] {
+	if r1.err != nil {
+		return Typed[V]{r1.r, r1.err}
+	}
+	var z V
+	return From[V](r1.r.Extend(z, efcn))
+}
+
+// SummarizeTyped creates a new relation with tuples of type U by grouping
+// the tuples of r1 and computing aggregates over each of the groups.  See
+// Summarize.
+func SummarizeTyped[T, U any](r1 Typed[T], aggs ...Aggregate) Typed[U] {
+	if r1.err != nil {
+		return Typed[U]{r1.r, r1.err}
+	}
+	var z U
+	return From[U](Summarize(r1.r, z, aggs...))
+}
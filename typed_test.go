@@ -0,0 +1,187 @@
+package rel
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// tests for the generic relations
+func TestTyped(t *testing.T) {
+	typedParts := From[partTup](parts())
+	typedOrders := From[orderTup](orders())
+
+	type pnoTup struct {
+		PNO int
+	}
+	type allTup struct {
+		PNO    int
+		PName  string
+		Color  string
+		Weight float64
+		City   string
+	}
+	type titleCaseTup struct {
+		Pno    int
+		PName  string
+		Color  string
+		Weight float64
+		City   string
+	}
+	type joinTup struct {
+		PNO    int
+		PName  string
+		Color  string
+		Weight float64
+		City   string
+		SNO    int
+		Qty    int
+	}
+	type heavyTup struct {
+		IsHeavy bool
+	}
+	type extTup struct {
+		PNO     int
+		PName   string
+		Color   string
+		Weight  float64
+		City    string
+		IsHeavy bool
+	}
+	type cityTup struct {
+		City string
+		N    int
+	}
+	isHeavy := func(tup partTup) heavyTup {
+		return heavyTup{tup.Weight > 15}
+	}
+	halfWeight := func(tup partTup) partTup {
+		tup.Weight /= 2
+		return tup
+	}
+	red := Attribute("Color").EQ("Red")
+
+	var typedTests = []struct {
+		name   string
+		rel    interface{ Err() error }
+		expect string
+		card   int
+	}{
+		{"of", Of([]orderTup{{1, 1, 300}, {1, 2, 200}}, [][]string{{"PNO", "SNO"}}), "Relation(PNO, SNO, Qty)", 2},
+		{"from", typedParts, "Relation(PNO, PName, Color, Weight, City)", 6},
+		{"restrict", typedParts.Restrict(red), "σ{Color == Red}(Relation(PNO, PName, Color, Weight, City))", 3},
+		{"union", typedParts.Union(typedParts.Restrict(red)), "Relation(PNO, PName, Color, Weight, City) ∪ σ{Color == Red}(Relation(PNO, PName, Color, Weight, City))", 6},
+		{"diff", typedParts.Diff(typedParts.Restrict(red)), "Relation(PNO, PName, Color, Weight, City) − σ{Color == Red}(Relation(PNO, PName, Color, Weight, City))", 3},
+		{"semijoin", typedParts.SemiJoin(orders()), "Relation(PNO, PName, Color, Weight, City) ⋉ Relation(PNO, SNO, Qty)", 4},
+		{"semidiff", typedParts.SemiDiff(orders()), "Relation(PNO, PName, Color, Weight, City) ▷ Relation(PNO, SNO, Qty)", 2},
+		{"order", typedParts.Order("Weight"), "τ{Weight}(Relation(PNO, PName, Color, Weight, City))", 6},
+		{"project", Project[partTup, pnoTup](typedParts), "π{PNO}(Relation(PNO, PName, Color, Weight, City))", 6},
+		{"project all", Project[partTup, allTup](typedParts), "π{PNO, PName, Color, Weight, City}(Relation(PNO, PName, Color, Weight, City))", 6},
+		{"rename", Rename[partTup, titleCaseTup](typedParts), "ρ{Pno, PName, Color, Weight, City}/{PNO, PName, Color, Weight, City}(Relation(PNO, PName, Color, Weight, City))", 6},
+		{"join", Join[partTup, orderTup, joinTup](typedParts, typedOrders), "Relation(PNO, PName, Color, Weight, City) ⋈ Relation(PNO, SNO, Qty)", 12},
+		{"map", Map(typedParts, halfWeight, [][]string{{"PNO"}}), "Relation(PNO, PName, Color, Weight, City).Map({PNO, PName, Color, Weight, City}->{PNO, PName, Color, Weight, City})", 6},
+		{"extend", Extend[partTup, heavyTup, extTup](typedParts, isHeavy), "Relation(PNO, PName, Color, Weight, City).Extend({PNO, PName, Color, Weight, City}->{IsHeavy})", 6},
+		{"summarize", SummarizeTyped[partTup, cityTup](typedParts, Count("N")), "Relation(PNO, PName, Color, Weight, City).Summarize({City}->{Count() as N})", 3},
+	}
+	for _, tt := range typedTests {
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%s has Err() => %v", tt.name, err)
+			continue
+		}
+		if str := tt.rel.(interface{ String() string }).String(); str != tt.expect {
+			t.Errorf("%s has String() => %q, want %q", tt.name, str, tt.expect)
+		}
+		if c := Card(tt.rel.(interface{ Relation() Relation }).Relation()); c != tt.card {
+			t.Errorf("%s has Card() => %d, want %d", tt.name, c, tt.card)
+		}
+	}
+}
+
+func TestTypedAll(t *testing.T) {
+	// all of the tuples are sent
+	n := 0
+	for tup, err := range From[partTup](parts()).Restrict(Attribute("Color").EQ("Red")).All() {
+		if err != nil {
+			t.Fatalf("All() => %v", err)
+		}
+		if tup.Color != "Red" {
+			t.Errorf("All() => %v, which does not satisfy the restriction", tup)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("All() sent %d tuples, want %d", n, 3)
+	}
+
+	// breaking out of the loop cancels the evaluation
+	n = 0
+	for _, err := range From[partTup](parts()).Order("Weight").All() {
+		if err != nil {
+			t.Fatalf("All() => %v", err)
+		}
+		n++
+		if n == 2 {
+			break
+		}
+	}
+
+	// evaluation errors are provided last
+	var last error
+	n = 0
+	for _, err := range From[partTup](&errorRel{partTup{}, 2, nil}).All() {
+		last = err
+		n++
+	}
+	if last == nil || n != 3 {
+		t.Errorf("All() => %d tuples and last error %v, want %d and an error", n, last, 3)
+	}
+
+	// so are construction errors
+	r := From[orderTup](parts())
+	if r.Err() == nil {
+		t.Errorf("From did not return an error for the wrong tuple type")
+	}
+	if _, err := r.Slice(); err == nil {
+		t.Errorf("Slice() did not return an error for the wrong tuple type")
+	}
+	if err := Project[orderTup, partTup](r).Err(); err == nil {
+		t.Errorf("Project did not retain the error from its source")
+	}
+
+	tups, err := Of([]orderTup{{1, 1, 300}, {1, 2, 200}}, nil).Order("SNO").Slice()
+	if err != nil || len(tups) != 2 || tups[0].SNO != 1 || tups[1].SNO != 2 {
+		t.Errorf("Slice() => %v, %v", tups, err)
+	}
+
+	// TupleChanContext works with typed channels
+	ch := make(chan orderTup)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- From[orderTup](orders()).TupleChanContext(context.Background(), ch)
+	}()
+	n = 0
+	for _ = range ch {
+		n++
+	}
+	if err := <-errc; err != nil || n != 12 {
+		t.Errorf("TupleChanContext() => %d tuples and %v, want %d and nil", n, err, 12)
+	}
+
+	// it returns when the context is cancelled, even if the tuples are no
+	// longer being received
+	ctx, cancel := context.WithCancel(context.Background())
+	ch = make(chan orderTup)
+	go func() {
+		errc <- From[orderTup](orders()).TupleChanContext(ctx, ch)
+	}()
+	<-ch
+	cancel()
+	select {
+	case err := <-errc:
+		if err != context.Canceled {
+			t.Errorf("TupleChanContext() => %v after cancel, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("TupleChanContext() did not return after cancel")
+	}
+}
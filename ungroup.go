@@ -0,0 +1,387 @@
+// ungroup implements an ungroup expression, which replaces a relation-valued
+// attribute with the attributes of the relations that it holds.
+
+package rel
+
+import (
+	"context"
+	"reflect"
+)
+
+// ungroupExpr is a type that represents replacing a relation-valued attribute
+// in each tuple of a source relation with the tuples of the relation it
+// holds, which results in one tuple for each of them.  Date calls this
+// operation UNGROUP, and it is the inverse of GROUP.  Tuples which hold an
+// empty relation result in no tuples.
+type ungroupExpr struct {
+	// the input relation
+	source1 Relation
+
+	// zero is the resulting relation tuple type, which is constructed from
+	// the fields of the source tuple type and the relation-valued attribute.
+	zero interface{}
+
+	// name is the relation-valued attribute which is ungrouped
+	name Attribute
+
+	// inner is an empty relation with the heading and candidate keys of the
+	// relations held in the relation-valued attribute
+	inner Relation
+
+	// err is the first error encountered during construction or evaluation
+	err error
+}
+
+// ungroupType constructs the tuple type with the attributes of the tuple type
+// e1, where the relation-valued attribute name is replaced by the attributes
+// of the tuple type ei.
+func ungroupType(e1, ei reflect.Type, name Attribute) reflect.Type {
+	var fields []reflect.StructField
+	for _, f := range attributeFields(e1) {
+		if f.name != name {
+			fields = append(fields, e1.FieldByIndex(f.index))
+			continue
+		}
+		for _, idx := range AttributeFields(ei) {
+			fields = append(fields, ei.FieldByIndex(idx))
+		}
+	}
+	return tupleType(fields)
+}
+
+// ungrouped returns the attributes of the relations held in the
+// relation-valued attribute
+func (r1 *ungroupExpr) ungrouped() []Attribute {
+	if r1.inner != nil {
+		return Heading(r1.inner)
+	}
+	return nil
+}
+
+// distinct returns true if the ungrouped tuples are already distinct, which
+// is the case if the source has a candidate key without the relation-valued
+// attribute.  Otherwise, two source tuples which only differ in their
+// relations could both hold the same tuple.
+func (r1 *ungroupExpr) distinct() bool {
+	for _, ck := range r1.source1.CKeys() {
+		if !IsSubDomain([]Attribute{r1.name}, ck) {
+			return true
+		}
+	}
+	return false
+}
+
+// TupleChan sends each tuple in the relation to a channel
+func (r1 *ungroupExpr) TupleChan(t interface{}) chan<- struct{} {
+	cancel := make(chan struct{})
+	// reflect on the channel
+	chv := reflect.ValueOf(t)
+	err := EnsureChan(chv.Type(), r1.zero)
+	if err != nil {
+		r1.err = err
+		return cancel
+	}
+	if r1.err != nil {
+		chv.Close()
+		return cancel
+	}
+
+	e1 := reflect.TypeOf(r1.source1.Zero())
+	e2 := reflect.TypeOf(r1.zero)
+	outerMap := fieldMap(e1, e2)
+	delete(outerMap, r1.name)
+	nameIdx := attributeField(e1, r1.name)
+	ungrouped := r1.ungrouped()
+	var mem *distinctSet
+	if !r1.distinct() {
+		mem = newDistinctSet(e2)
+	}
+
+	body := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e1), 0)
+	bcancel := r1.source1.TupleChan(body.Interface())
+
+	go func(body, res reflect.Value) {
+		// input channels
+		sourceSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: body}
+		canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+		inCases := []reflect.SelectCase{canSel, sourceSel}
+
+		// output channels
+		resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+
+		// fail stops the evaluation because of an error in one of the
+		// relations held by the source
+		fail := func(err error) {
+			r1.err = err
+			close(bcancel)
+			res.Close()
+		}
+		for {
+			chosen, tup, ok := reflect.Select(inCases)
+			if chosen == 0 {
+				// cancel has been closed, so close the source as well
+				close(bcancel)
+				return
+			}
+			if !ok {
+				// source channel was closed
+				break
+			}
+			r, _ := tup.FieldByIndex(nameIdx).Interface().(Relation)
+			if r == nil {
+				// a missing relation is treated as an empty one
+				continue
+			}
+			if err := r.Err(); err != nil {
+				fail(err)
+				return
+			}
+			if err := EnsureSameDomain(Heading(r), ungrouped); err != nil {
+				fail(err)
+				return
+			}
+			ei := reflect.TypeOf(r.Zero())
+			innerMap := fieldMap(ei, e2)
+			for a, fm := range innerMap {
+				if ti, t2 := ei.FieldByIndex(fm.I).Type, e2.FieldByIndex(fm.J).Type; ti != t2 {
+					fail(&AttributeTypeError{a, t2, ti})
+					return
+				}
+			}
+			ibody := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, ei), 0)
+			icancel := r.TupleChan(ibody.Interface())
+			innerCases := []reflect.SelectCase{canSel, {Dir: reflect.SelectRecv, Chan: ibody}}
+			for {
+				chosen, itup, ok := reflect.Select(innerCases)
+				if chosen == 0 {
+					close(icancel)
+					close(bcancel)
+					return
+				}
+				if !ok {
+					break
+				}
+				tup2 := reflect.Indirect(reflect.New(e2))
+				for _, fm := range outerMap {
+					tup2.FieldByIndex(fm.J).Set(tup.FieldByIndex(fm.I))
+				}
+				for _, fm := range innerMap {
+					tup2.FieldByIndex(fm.J).Set(itup.FieldByIndex(fm.I))
+				}
+				if mem != nil && !mem.add(tup2) {
+					continue
+				}
+				resSel.Send = tup2
+				chosen, _, _ = reflect.Select([]reflect.SelectCase{canSel, resSel})
+				if chosen == 0 {
+					close(icancel)
+					close(bcancel)
+					return
+				}
+			}
+			if err := r.Err(); err != nil {
+				fail(err)
+				return
+			}
+		}
+		if mem != nil {
+			// send the tuples that were deferred if the memory budget was
+			// exceeded
+			if !mem.flush(func(tup reflect.Value) bool { return sendTuple(res, cancel, tup) }) && mem.err == nil {
+				return
+			}
+			if mem.err != nil {
+				r1.err = mem.err
+			}
+		}
+		if err := r1.source1.Err(); err != nil {
+			r1.err = err
+		}
+		res.Close()
+	}(body, chv)
+	return cancel
+}
+
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *ungroupExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *ungroupExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *ungroupExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
+// Zero returns the zero value of the relation (a blank tuple)
+func (r1 *ungroupExpr) Zero() interface{} {
+	return r1.zero
+}
+
+// CKeys is the set of candidate keys in the relation.  Each key of the
+// source without the relation-valued attribute identifies a single
+// relation, so it can be combined with each of the keys of that relation.
+func (r1 *ungroupExpr) CKeys() CandKeys {
+	var cKeys CandKeys
+	for _, ck1 := range r1.source1.CKeys() {
+		if IsSubDomain([]Attribute{r1.name}, ck1) {
+			continue
+		}
+		for _, cki := range r1.inner.CKeys() {
+			ck2 := append(append([]Attribute(nil), ck1...), cki...)
+			cKeys = append(cKeys, ck2)
+		}
+	}
+	if len(cKeys) == 0 {
+		return DefaultKeys(r1.zero)
+	}
+	OrderCandidateKeys(cKeys)
+	return cKeys
+}
+
+// SortOrder is the set of attributes that the relation is sorted on.  The
+// order of the source is retained up to the ungrouped attribute, unless
+// duplicates have to be removed while a memory budget is in effect, in which
+// case the tuples that were spilled to disk are sent after the rest.
+func (r1 *ungroupExpr) SortOrder() []Attribute {
+	if !r1.distinct() && tupleBudget(reflect.TypeOf(r1.zero)) > 0 {
+		return nil
+	}
+	return orderPrefix(SortOrder(r1.source1), Heading(r1))
+}
+
+// GoString returns a text representation of the Relation
+func (r1 *ungroupExpr) GoString() string {
+	return r1.source1.GoString() + ".Ungroup(\"" + string(r1.name) + "\")"
+}
+
+// String returns a text representation of the Relation
+func (r1 *ungroupExpr) String() string {
+	return r1.source1.String() + ".Ungroup(" + string(r1.name) + "->{" + attributeString(r1.ungrouped()) + "})"
+}
+
+// Project creates a new relation with less than or equal degree
+// t2 has to be a new type which is a subdomain of r.
+func (r1 *ungroupExpr) Project(z2 interface{}) Relation {
+	return Rewrite(r1, NewProject(r1, z2))
+}
+
+// Restrict creates a new relation with less than or equal cardinality
+// p has to be a func(tup T) bool where tup is a subdomain of the input r.
+func (r1 *ungroupExpr) Restrict(p Predicate) Relation {
+	return Rewrite(r1, NewRestrict(r1, p))
+}
+
+// Rename creates a new relation with new column names
+// z2 has to be a struct with the same number of fields as the input relation
+func (r1 *ungroupExpr) Rename(z2 interface{}) Relation {
+	return Rewrite(r1, NewRename(r1, z2))
+}
+
+// Union creates a new relation by unioning the bodies of both inputs
+func (r1 *ungroupExpr) Union(r2 Relation) Relation {
+	return Rewrite(r1, NewUnion(r1, r2))
+}
+
+// Diff creates a new relation by set minusing the two inputs
+func (r1 *ungroupExpr) Diff(r2 Relation) Relation {
+	return Rewrite(r1, NewDiff(r1, r2))
+}
+
+// Join creates a new relation by performing a natural join on the inputs
+func (r1 *ungroupExpr) Join(r2 Relation, zero interface{}) Relation {
+	return Rewrite(r1, NewJoin(r1, r2, zero))
+}
+
+// GroupBy creates a new relation by grouping and applying a user defined func
+func (r1 *ungroupExpr) GroupBy(t2, gfcn interface{}) Relation {
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
+}
+
+// Map creates a new relation by applying a function to tuples in the source
+func (r1 *ungroupExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *ungroupExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *ungroupExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *ungroupExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *ungroupExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *ungroupExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *ungroupExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *ungroupExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *ungroupExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *ungroupExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *ungroupExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
+}
+
+// Err returns an error encountered during construction or computation
+func (r1 *ungroupExpr) Err() error {
+	return r1.err
+}
+
+// Op is the operation that produces the relation
+func (r1 *ungroupExpr) Op() OpKind {
+	return OpUngroup
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *ungroupExpr) Children() []Relation {
+	return []Relation{r1.source1}
+}
+
+// Grouped is the relation-valued attribute which is ungrouped
+func (r1 *ungroupExpr) Grouped() Attribute {
+	return r1.name
+}
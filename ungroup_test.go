@@ -0,0 +1,208 @@
+package rel
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tests for ungroup op
+func TestUngroup(t *testing.T) {
+	type ordersTup struct {
+		Orders Relation
+	}
+	type qtyTup struct {
+		PNO int
+		Qty int
+	}
+	type pnoTup struct {
+		PNO int
+	}
+	type joinTup struct {
+		SNO    int
+		Orders Relation
+		SName  string
+		Status int
+		City   string
+	}
+	type productTup struct {
+		SNO    int
+		Orders Relation
+		PNO    int
+	}
+	asOrder := func(tup orderTup) orderTup {
+		return tup
+	}
+	grouped := func() Relation {
+		return orders().Group(supplierOrdersTup{}, "Orders")
+	}
+	// literals have a nil relation in their zero tuple
+	literal := func() Relation {
+		return New([]supplierOrdersTup{
+			{1, New([]qtyTup{{1, 300}, {2, 200}}, nil)},
+			{2, New([]qtyTup{{1, 300}}, nil)},
+			{3, nil},
+		}, nil)
+	}
+
+	// test the degrees, cardinality, and string representation
+	rel := grouped().Ungroup("Orders")
+	var relTest = []struct {
+		rel          Relation
+		expectString string
+		expectDeg    int
+		expectCard   int
+	}{
+		{rel, "Relation(PNO, SNO, Qty).Group({PNO, Qty}->Orders).Ungroup(Orders->{PNO, Qty})", 3, 12},
+		{grouped().Restrict(Attribute("SNO").EQ(2)).Ungroup("Orders"), "σ{SNO == 2}(Relation(PNO, SNO, Qty).Group({PNO, Qty}->Orders)).Ungroup(Orders->{PNO, Qty})", 3, 4},
+		{rel.Restrict(Attribute("Qty").GT(300)), "σ{Qty > 300}(Relation(PNO, SNO, Qty).Group({PNO, Qty}->Orders).Ungroup(Orders->{PNO, Qty}))", 3, 3},
+		{rel.Map(asOrder, [][]string{{"PNO", "SNO"}}).Diff(orders()), "", 3, 0},
+		{grouped().Project(ordersTup{}).Ungroup("Orders"), "", 2, 10},
+		{grouped().Join(parts().Project(pnoTup{}), productTup{}).Restrict(Attribute("SNO").EQ(1)), "", 3, 6},
+		{New([]supplierOrdersTup{{1, nil}}, nil).Group(struct{ R Relation }{}, "R").Ungroup("R"), "", 2, 1},
+		{UngroupOf(literal(), "Orders", qtyTup{}), "Relation(SNO, Orders).Ungroup(Orders->{PNO, Qty})", 3, 3},
+		{UngroupOf(grouped(), "Orders", qtyTup{}), "", 3, 12},
+	}
+
+	for i, tt := range relTest {
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%d has Err() => %s", i, err.Error())
+			continue
+		}
+		if tt.expectString != "" {
+			if str := tt.rel.String(); str != tt.expectString {
+				t.Errorf("%d has String() => %v, want %v", i, str, tt.expectString)
+			}
+		}
+		if deg := Deg(tt.rel); deg != tt.expectDeg {
+			t.Errorf("%d %s has Deg() => %v, want %v", i, tt.rel, deg, tt.expectDeg)
+		}
+		if card := Card(tt.rel); card != tt.expectCard {
+			t.Errorf("%d %s has Card() => %v, want %v", i, tt.rel, card, tt.expectCard)
+		}
+	}
+
+	// the heading has the attributes of the groups in place of the
+	// relation-valued one
+	if h := Heading(rel); !reflect.DeepEqual(h, []Attribute{"SNO", "PNO", "Qty"}) {
+		t.Errorf("%s has Heading() => %v", rel, h)
+	}
+
+	// the keys of the source are combined with the keys of the groups
+	if ck := rel.CKeys(); !reflect.DeepEqual(ck, CandKeys{{"PNO", "SNO"}}) {
+		t.Errorf("%s has CKeys() => %v, want [[PNO SNO]]", rel, ck)
+	}
+	if ck := grouped().Project(ordersTup{}).Ungroup("Orders").CKeys(); !reflect.DeepEqual(ck, CandKeys{{"PNO", "Qty"}}) {
+		t.Errorf("ungroup has CKeys() => %v, want [[PNO Qty]]", ck)
+	}
+
+	// the relation-valued attribute is retained by projections and joins
+	// even though their tuple types don't hold relations
+	r := grouped().Join(suppliers(), joinTup{}).Ungroup("Orders").Project(orderTup{})
+	if want := orders().SemiJoin(suppliers()); !Equal(r, want) {
+		t.Errorf("%s is not equal to %s", r, want)
+	}
+
+	// test construction errors
+	type notRelTup struct {
+		SNO    int
+		Orders []orderTup
+	}
+	constructTest := []struct {
+		rel Relation
+		err error
+	}{
+		{grouped().Ungroup("Parts"), &AttributeSubsetError{}},
+		{New([]notRelTup{{1, nil}}, nil).Ungroup("Orders"), &AttributeTypeError{}},
+		{New([]supplierOrdersTup{{1, New([]qtyTup{{1, 200}}, nil)}}, nil).Ungroup("Orders"), &HeadingError{}},
+		{UngroupOf(literal(), "Orders", nil), &HeadingError{}},
+		{UngroupOf(literal(), "Orders", 1), &HeadingError{}},
+		{UngroupOf(literal(), "Orders", orderTup{}), &AttributeConflictError{}},
+		{UngroupOf(New([]notRelTup{{1, nil}}, nil), "Orders", qtyTup{}), &AttributeTypeError{}},
+		{grouped().Join(parts().Project(pnoTup{}), productTup{}).Ungroup("Orders"), &AttributeConflictError{}},
+	}
+	for i, tt := range constructTest {
+		err := tt.rel.Err()
+		if err == nil {
+			t.Errorf("%d did not produce a construction error", i)
+			continue
+		}
+		if reflect.TypeOf(err) != reflect.TypeOf(tt.err) {
+			t.Errorf("%d has Err() => %T, want %T", i, err, tt.err)
+		}
+	}
+
+	// relations with a different heading than the zero tuple's are an
+	// evaluation error
+	r2 := grouped().Union(New([]supplierOrdersTup{{7, New([]pnoTup{{1}}, nil)}}, nil)).Ungroup("Orders")
+	if err := r2.Err(); err != nil {
+		t.Fatal(err)
+	}
+	Card(r2)
+	if _, ok := r2.Err().(*DomainMismatchError); !ok {
+		t.Errorf("ungroup has Err() => %v, want a DomainMismatchError", r2.Err())
+	}
+
+	// as are relations with attributes of a different type
+	type strQtyTup struct {
+		PNO int
+		Qty string
+	}
+	r4 := UngroupOf(New([]supplierOrdersTup{{1, New([]strQtyTup{{1, "200"}}, nil)}}, nil), "Orders", qtyTup{})
+	if err := r4.Err(); err != nil {
+		t.Fatal(err)
+	}
+	Card(r4)
+	if _, ok := r4.Err().(*AttributeTypeError); !ok {
+		t.Errorf("ungroup has Err() => %v, want an AttributeTypeError", r4.Err())
+	}
+
+	// the order of the source is not retained if the tuples may be spilled
+	r3 := UngroupOf(literal().Order("SNO"), "Orders", qtyTup{})
+	if ord := SortOrder(r3); !reflect.DeepEqual(ord, []Attribute{"SNO"}) {
+		t.Errorf("%s has SortOrder() => %v, want [SNO]", r3, ord)
+	}
+	prev := SetMemoryBudget(5)
+	if ord := SortOrder(r3); ord != nil {
+		t.Errorf("%s has SortOrder() => %v with a memory budget, want nil", r3, ord)
+	}
+	SetMemoryBudget(prev)
+
+	// the error for an unknown heading says how to provide it
+	if err := literal().Ungroup("Orders").Err(); err == nil || !strings.Contains(err.Error(), "UngroupOf") {
+		t.Errorf("ungroup of a literal has Err() => %v, want it to mention UngroupOf", err)
+	}
+
+	// test cancellation
+	res := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, reflect.TypeOf(rel.Zero())), 0)
+	cancel := rel.TupleChan(res.Interface())
+	close(cancel)
+	if _, ok := res.TryRecv(); ok {
+		t.Errorf("cancel did not end tuple generation")
+	}
+
+	// test errors
+	err := fmt.Errorf("testing error")
+	r1 := grouped().Ungroup("Orders").(*ungroupExpr)
+	r1.err = err
+	res = reflect.MakeChan(reflect.ChanOf(reflect.BothDir, reflect.TypeOf(r1.Zero())), 0)
+	_ = r1.TupleChan(res.Interface())
+	if _, ok := res.Recv(); ok {
+		t.Errorf("ungroup did not short circuit TupleChan")
+	}
+	errTest := []Relation{
+		r1.Project(pnoTup{}),
+		r1.Restrict(Attribute("SNO").EQ(1)),
+		r1.Union(rel),
+		r1.Diff(rel),
+		r1.SemiJoin(orders()),
+		r1.SemiDiff(orders()),
+		r1.Group(supplierOrdersTup{}, "Orders"),
+	}
+	for i, errRel := range errTest {
+		if errRel.Err() != err {
+			t.Errorf("%d did not short circuit error", i)
+		}
+	}
+}
@@ -3,6 +3,7 @@
 package rel
 
 import (
+	"context"
 	"reflect"
 	"runtime"
 	"sync"
@@ -38,13 +39,13 @@ func (r1 *unionExpr) TupleChan(t interface{}) chan<- struct{} {
 	// amount of concurrency?
 	mc := runtime.GOMAXPROCS(-1)
 
-	var mu sync.Mutex
-	mem := make(map[interface{}]struct{})
-
 	// tuples in both sides should have the same type, checked during
 	// construction
 	e := reflect.TypeOf(r1.source1.Zero())
 
+	var mu sync.Mutex
+	mem := newDistinctSet(e)
+
 	// create channels over the body of the source relations
 	body1 := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e), 0)
 	bcancel1 := r1.source1.TupleChan(body1.Interface())
@@ -57,13 +58,21 @@ func (r1 *unionExpr) TupleChan(t interface{}) chan<- struct{} {
 		wg.Wait()
 		select {
 		case <-cancel:
+			mem.close()
 			close(bcancel1)
 			close(bcancel2)
 		default:
+			// send the tuples that were deferred if the memory budget was
+			// exceeded
+			if !mem.flush(func(tup reflect.Value) bool { return sendTuple(res, cancel, tup) }) && mem.err == nil {
+				return
+			}
 			if err := r1.source1.Err(); err != nil {
 				r1.err = err
 			} else if err := r1.source2.Err(); err != nil {
 				r1.err = err
+			} else if mem.err != nil {
+				r1.err = mem.err
 			}
 			res.Close()
 		}
@@ -97,8 +106,7 @@ func (r1 *unionExpr) TupleChan(t interface{}) chan<- struct{} {
 
 				// otherwise we've received a new value from one of the sources
 				mu.Lock()
-				if _, dup := mem[tup.Interface()]; !dup {
-					mem[tup.Interface()] = struct{}{}
+				if mem.add(tup) {
 					mu.Unlock()
 					resSel.Send = tup
 					chosen, _, ok = reflect.Select([]reflect.SelectCase{canSel, resSel})
@@ -115,6 +123,22 @@ func (r1 *unionExpr) TupleChan(t interface{}) chan<- struct{} {
 	return cancel
 }
 
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *unionExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *unionExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *unionExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
 // Zero returns the zero value of the relation (a blank tuple)
 func (r1 *unionExpr) Zero() interface{} {
 	return r1.source1.Zero()
@@ -188,51 +212,121 @@ func (r1 *unionExpr) String() string {
 // t2 has to be a new type which is a subdomain of r.
 // Project can distribute over a union.
 func (r1 *unionExpr) Project(z2 interface{}) Relation {
-	return NewUnion(r1.source1.Project(z2), r1.source2.Project(z2))
+	return Rewrite(r1, NewProject(r1, z2))
 }
 
 // Restrict creates a new relation with less than or equal cardinality
 // p has to be a func(tup interface{}) bool where tup is a subdomain of the input r.
 // Restrict can distribute over a union.
 func (r1 *unionExpr) Restrict(p Predicate) Relation {
-	return NewUnion(r1.source1.Restrict(p), r1.source2.Restrict(p))
+	return Rewrite(r1, NewRestrict(r1, p))
 }
 
 // Rename creates a new relation with new column names
 // z2 has to be a struct with the same number of fields as the input relation
 // rename can distribute over a union.
 func (r1 *unionExpr) Rename(z2 interface{}) Relation {
-	return NewUnion(r1.source1.Rename(z2), r1.source2.Rename(z2))
+	return Rewrite(r1, NewRename(r1, z2))
 }
 
 // Union creates a new relation by unioning the bodies of both inputs
 func (r1 *unionExpr) Union(r2 Relation) Relation {
 	// It might be useful to define a multiple union?  There would be a memory
 	// benefit in some cases.
-	return NewUnion(r1, r2)
+	return Rewrite(r1, NewUnion(r1, r2))
 }
 
 // Diff creates a new relation by set minusing the two inputs
 func (r1 *unionExpr) Diff(r2 Relation) Relation {
-	return NewDiff(r1, r2)
+	return Rewrite(r1, NewDiff(r1, r2))
 }
 
 // Join creates a new relation by performing a natural join on the inputs
 func (r1 *unionExpr) Join(r2 Relation, zero interface{}) Relation {
-	return NewJoin(r1, r2, zero)
+	return Rewrite(r1, NewJoin(r1, r2, zero))
 }
 
 // GroupBy creates a new relation by grouping and applying a user defined func
 func (r1 *unionExpr) GroupBy(t2, gfcn interface{}) Relation {
-	return NewGroupBy(r1, t2, gfcn)
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
 }
 
 // Map creates a new relation by applying a function to tuples in the source
 func (r1 *unionExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
-	return NewMap(r1, mfcn, ckeystr)
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *unionExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *unionExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+// SemiJoin can distribute over a union.
+func (r1 *unionExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+// SemiDiff can distribute over a union.
+func (r1 *unionExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *unionExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *unionExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *unionExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *unionExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *unionExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *unionExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
 }
 
 // Err returns an error encountered during construction or computation
 func (r1 *unionExpr) Err() error {
 	return r1.err
 }
+
+// Op is the operation that produces the relation
+func (r1 *unionExpr) Op() OpKind {
+	return OpUnion
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *unionExpr) Children() []Relation {
+	return []Relation{r1.source1, r1.source2}
+}
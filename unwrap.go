@@ -0,0 +1,293 @@
+// unwrap implements an unwrap expression, which replaces a tuple-valued
+// attribute of each tuple with the attributes that it holds.
+
+package rel
+
+import (
+	"context"
+	"reflect"
+)
+
+// unwrapExpr is a type that represents replacing a tuple-valued attribute in
+// each tuple of a source relation with the attributes of the tuples it
+// holds.  Date calls this operation UNWRAP, and it is the inverse of WRAP.
+// Each source tuple results in exactly one tuple, so no duplicates can
+// occur.
+type unwrapExpr struct {
+	// the input relation
+	source1 Relation
+
+	// zero is the resulting relation tuple type, which is constructed from
+	// the fields of the source tuple type and the tuple-valued attribute.
+	zero interface{}
+
+	// name is the tuple-valued attribute which is unwrapped
+	name Attribute
+
+	// err is the first error encountered during construction or evaluation
+	err error
+}
+
+// unwrapType constructs the tuple type with the attributes of the tuple type
+// e1, where the tuple-valued attribute name is replaced by its attributes.
+func unwrapType(e1 reflect.Type, name Attribute) reflect.Type {
+	var fields []reflect.StructField
+	for _, f := range attributeFields(e1) {
+		sf := e1.FieldByIndex(f.index)
+		if f.name != name {
+			fields = append(fields, sf)
+			continue
+		}
+		for _, idx := range AttributeFields(sf.Type) {
+			fields = append(fields, sf.Type.FieldByIndex(idx))
+		}
+	}
+	return tupleType(fields)
+}
+
+// unwrapKeys replaces the attribute name in each of the candidate keys with
+// the attributes that it holds.
+func unwrapKeys(cKeys1 CandKeys, unwrapped []Attribute, name Attribute) CandKeys {
+	cKeys2 := make(CandKeys, len(cKeys1))
+	for i, ck1 := range cKeys1 {
+		for _, att := range ck1 {
+			if att == name {
+				cKeys2[i] = append(cKeys2[i], unwrapped...)
+			} else {
+				cKeys2[i] = append(cKeys2[i], att)
+			}
+		}
+	}
+	OrderCandidateKeys(cKeys2)
+	return cKeys2
+}
+
+// unwrapped returns the attributes held in the tuple-valued attribute
+func (r1 *unwrapExpr) unwrapped() []Attribute {
+	e1 := reflect.TypeOf(r1.source1.Zero())
+	return FieldNames(e1.FieldByIndex(attributeField(e1, r1.name)).Type)
+}
+
+// TupleChan sends each tuple in the relation to a channel
+func (r1 *unwrapExpr) TupleChan(t interface{}) chan<- struct{} {
+	cancel := make(chan struct{})
+	// reflect on the channel
+	chv := reflect.ValueOf(t)
+	err := EnsureChan(chv.Type(), r1.zero)
+	if err != nil {
+		r1.err = err
+		return cancel
+	}
+	if r1.err != nil {
+		chv.Close()
+		return cancel
+	}
+
+	e1 := reflect.TypeOf(r1.source1.Zero())
+	e2 := reflect.TypeOf(r1.zero)
+	// the result is the flat type, so the roles in the map are reversed
+	fMap := wrapMap(e2, e1, r1.name)
+
+	body := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e1), 0)
+	bcancel := r1.source1.TupleChan(body.Interface())
+
+	go func(body, res reflect.Value) {
+		// input channels
+		sourceSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: body}
+		canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+		inCases := []reflect.SelectCase{canSel, sourceSel}
+
+		// output channels
+		resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+		for {
+			chosen, tup, ok := reflect.Select(inCases)
+			if chosen == 0 {
+				// cancel has been closed, so close the source as well
+				close(bcancel)
+				return
+			}
+			if !ok {
+				// source channel was closed
+				break
+			}
+			tup2 := reflect.Indirect(reflect.New(e2))
+			for _, fm := range fMap {
+				tup2.FieldByIndex(fm.I).Set(tup.FieldByIndex(fm.J))
+			}
+			resSel.Send = tup2
+			chosen, _, _ = reflect.Select([]reflect.SelectCase{canSel, resSel})
+			if chosen == 0 {
+				close(bcancel)
+				return
+			}
+		}
+		if err := r1.source1.Err(); err != nil {
+			r1.err = err
+		}
+		res.Close()
+	}(body, chv)
+	return cancel
+}
+
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *unwrapExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *unwrapExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *unwrapExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
+// Zero returns the zero value of the relation (a blank tuple)
+func (r1 *unwrapExpr) Zero() interface{} {
+	return r1.zero
+}
+
+// CKeys is the set of candidate keys in the relation
+func (r1 *unwrapExpr) CKeys() CandKeys {
+	return unwrapKeys(r1.source1.CKeys(), r1.unwrapped(), r1.name)
+}
+
+// SortOrder is the set of attributes that the relation is sorted on.  The
+// order of the source is retained up to the unwrapped attribute.
+func (r1 *unwrapExpr) SortOrder() []Attribute {
+	return orderPrefix(SortOrder(r1.source1), Heading(r1))
+}
+
+// GoString returns a text representation of the Relation
+func (r1 *unwrapExpr) GoString() string {
+	return r1.source1.GoString() + ".Unwrap(\"" + string(r1.name) + "\")"
+}
+
+// String returns a text representation of the Relation
+func (r1 *unwrapExpr) String() string {
+	return r1.source1.String() + ".Unwrap(" + string(r1.name) + "->{" + attributeString(r1.unwrapped()) + "})"
+}
+
+// Project creates a new relation with less than or equal degree
+// t2 has to be a new type which is a subdomain of r.
+func (r1 *unwrapExpr) Project(z2 interface{}) Relation {
+	return Rewrite(r1, NewProject(r1, z2))
+}
+
+// Restrict creates a new relation with less than or equal cardinality
+// p has to be a func(tup T) bool where tup is a subdomain of the input r.
+func (r1 *unwrapExpr) Restrict(p Predicate) Relation {
+	return Rewrite(r1, NewRestrict(r1, p))
+}
+
+// Rename creates a new relation with new column names
+// z2 has to be a struct with the same number of fields as the input relation
+func (r1 *unwrapExpr) Rename(z2 interface{}) Relation {
+	return Rewrite(r1, NewRename(r1, z2))
+}
+
+// Union creates a new relation by unioning the bodies of both inputs
+func (r1 *unwrapExpr) Union(r2 Relation) Relation {
+	return Rewrite(r1, NewUnion(r1, r2))
+}
+
+// Diff creates a new relation by set minusing the two inputs
+func (r1 *unwrapExpr) Diff(r2 Relation) Relation {
+	return Rewrite(r1, NewDiff(r1, r2))
+}
+
+// Join creates a new relation by performing a natural join on the inputs
+func (r1 *unwrapExpr) Join(r2 Relation, zero interface{}) Relation {
+	return Rewrite(r1, NewJoin(r1, r2, zero))
+}
+
+// GroupBy creates a new relation by grouping and applying a user defined func
+func (r1 *unwrapExpr) GroupBy(t2, gfcn interface{}) Relation {
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
+}
+
+// Map creates a new relation by applying a function to tuples in the source
+func (r1 *unwrapExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *unwrapExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *unwrapExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *unwrapExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *unwrapExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *unwrapExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *unwrapExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *unwrapExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *unwrapExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *unwrapExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *unwrapExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
+}
+
+// Err returns an error encountered during construction or computation
+func (r1 *unwrapExpr) Err() error {
+	return r1.err
+}
+
+// Op is the operation that produces the relation
+func (r1 *unwrapExpr) Op() OpKind {
+	return OpUnwrap
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *unwrapExpr) Children() []Relation {
+	return []Relation{r1.source1}
+}
+
+// Wrapped is the tuple-valued attribute which is unwrapped
+func (r1 *unwrapExpr) Wrapped() Attribute {
+	return r1.name
+}
@@ -0,0 +1,188 @@
+package rel
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// tests for unwrap op
+func TestUnwrap(t *testing.T) {
+	type placeTup struct {
+		Color string
+		City  string
+	}
+	type nestedTup struct {
+		PNO    int
+		PName  string
+		Weight float64
+		Place  placeTup
+	}
+	type pnoTup struct {
+		PNO int
+	}
+	type cityTup struct {
+		City string
+	}
+	type placeOnlyTup struct {
+		Place placeTup
+	}
+	type joinTup struct {
+		PNO    int
+		PName  string
+		Weight float64
+		Color  string
+		City   string
+		SNO    int
+		Qty    int
+	}
+	asPart := func(tup partTup) partTup {
+		return tup
+	}
+	nested := func() Relation {
+		return New([]nestedTup{
+			{1, "Nut", 12.0, placeTup{"Red", "London"}},
+			{2, "Bolt", 17.0, placeTup{"Green", "Paris"}},
+			{3, "Screw", 17.0, placeTup{"Blue", "Oslo"}},
+			{4, "Screw", 14.0, placeTup{"Red", "London"}},
+			{5, "Cam", 12.0, placeTup{"Blue", "Paris"}},
+			{6, "Cog", 19.0, placeTup{"Red", "London"}},
+		}, [][]string{
+			[]string{"PNO"},
+		})
+	}
+
+	// test the degrees, cardinality, and string representation
+	rel := nested().Unwrap("Place")
+	var relTest = []struct {
+		rel          Relation
+		expectString string
+		expectDeg    int
+		expectCard   int
+	}{
+		{rel, "Relation(PNO, PName, Weight, Place).Unwrap(Place->{Color, City})", 5, 6},
+		{rel.Restrict(Attribute("City").EQ("London")), "σ{City == London}(Relation(PNO, PName, Weight, Place).Unwrap(Place->{Color, City}))", 5, 3},
+		{rel.Project(cityTup{}), "π{City}(Relation(PNO, PName, Weight, Place).Unwrap(Place->{Color, City}))", 1, 3},
+		{rel.Map(asPart, [][]string{{"PNO"}}), "", 5, 6},
+		{rel.Map(asPart, [][]string{{"PNO"}}).Diff(parts()), "", 5, 0},
+		{rel.Join(orders(), joinTup{}), "", 7, 12},
+		{rel.SemiDiff(orders()), "", 5, 2},
+		{parts().Wrap(nestedTup{}, "Place").Unwrap("Place").Map(asPart, nil).Union(parts()), "", 5, 6},
+	}
+
+	for i, tt := range relTest {
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%d has Err() => %s", i, err.Error())
+			continue
+		}
+		if tt.expectString != "" {
+			if str := tt.rel.String(); str != tt.expectString {
+				t.Errorf("%d has String() => %v, want %v", i, str, tt.expectString)
+			}
+		}
+		if deg := Deg(tt.rel); deg != tt.expectDeg {
+			t.Errorf("%d %s has Deg() => %v, want %v", i, tt.rel, deg, tt.expectDeg)
+		}
+		if card := Card(tt.rel); card != tt.expectCard {
+			t.Errorf("%d %s has Card() => %v, want %v", i, tt.rel, card, tt.expectCard)
+		}
+	}
+
+	// the heading has the unwrapped attributes in place of the tuple-valued one
+	if h := Heading(rel); !reflect.DeepEqual(h, []Attribute{"PNO", "PName", "Weight", "Color", "City"}) {
+		t.Errorf("%s has Heading() => %v", rel, h)
+	}
+
+	// the candidate keys have the tuple-valued attribute replaced
+	if ck := nested().Project(placeOnlyTup{}).Unwrap("Place").CKeys(); !reflect.DeepEqual(ck, CandKeys{{"City", "Color"}}) {
+		t.Errorf("unwrap has CKeys() => %v, want [[City Color]]", ck)
+	}
+
+	// wrapping and then unwrapping results in the same tuples
+	var tups []partTup
+	if err := parts().Wrap(nestedTup{}, "Place").Unwrap("Place").Map(asPart, nil).Order("PNO").TupleSlice(&tups); err != nil {
+		t.Fatal(err)
+	}
+	var want []partTup
+	if err := parts().Order("PNO").TupleSlice(&want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tups, want) {
+		t.Errorf("unwrap has tuples %v, want %v", tups, want)
+	}
+
+	// fields with the same name are given a suffix, but keep their tags
+	type innerTup struct {
+		N    int `rel:"B"`
+		Name string
+	}
+	type outerTup struct {
+		N     int `rel:"A"`
+		Inner innerTup
+	}
+	e := reflect.TypeOf(New([]outerTup{{1, innerTup{2, "x"}}}, nil).Unwrap("Inner").Zero())
+	if e.NumField() != 3 || e.Field(1).Name != "N2" || e.Field(1).Tag.Get("rel") != "B" {
+		t.Errorf("unwrap has tuple type %v", e)
+	}
+
+	// test construction errors
+	type notStructTup struct {
+		PNO   int
+		Place string
+	}
+	type conflictTup struct {
+		City  string
+		Place placeTup
+	}
+	constructTest := []struct {
+		rel Relation
+		err error
+	}{
+		{nested().Unwrap("Loc"), &AttributeSubsetError{}},
+		{New([]notStructTup{{1, "London"}}, nil).Unwrap("Place"), &KindError{}},
+		{New([]conflictTup{{"London", placeTup{"Red", "Paris"}}}, nil).Unwrap("Place"), &AttributeConflictError{}},
+	}
+	for i, tt := range constructTest {
+		err := tt.rel.Err()
+		if err == nil {
+			t.Errorf("%d did not produce a construction error", i)
+			continue
+		}
+		if reflect.TypeOf(err) != reflect.TypeOf(tt.err) {
+			t.Errorf("%d has Err() => %T, want %T", i, err, tt.err)
+		}
+	}
+
+	// test cancellation
+	res := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, reflect.TypeOf(rel.Zero())), 0)
+	cancel := rel.TupleChan(res.Interface())
+	close(cancel)
+	if _, ok := res.TryRecv(); ok {
+		t.Errorf("cancel did not end tuple generation")
+	}
+
+	// test errors
+	err := fmt.Errorf("testing error")
+	r1 := nested().Unwrap("Place").(*unwrapExpr)
+	r1.err = err
+	res = reflect.MakeChan(reflect.ChanOf(reflect.BothDir, reflect.TypeOf(r1.Zero())), 0)
+	_ = r1.TupleChan(res.Interface())
+	if _, ok := res.Recv(); ok {
+		t.Errorf("unwrap did not short circuit TupleChan")
+	}
+	errTest := []Relation{
+		r1.Project(pnoTup{}),
+		r1.Restrict(Attribute("City").EQ("London")),
+		r1.Union(rel),
+		r1.Diff(rel),
+		r1.Join(orders(), joinTup{}),
+		r1.SemiJoin(orders()),
+		r1.SemiDiff(orders()),
+		r1.Wrap(nestedTup{}, "Place"),
+	}
+	for i, errRel := range errTest {
+		if errRel.Err() != err {
+			t.Errorf("%d did not short circuit error", i)
+		}
+	}
+}
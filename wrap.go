@@ -0,0 +1,302 @@
+// wrap implements a wrap expression, which gathers some of the attributes of
+// each tuple into a single tuple-valued attribute.
+
+package rel
+
+import (
+	"context"
+	"reflect"
+)
+
+// wrapExpr is a type that represents replacing a set of attributes in each
+// tuple of a source relation with a tuple-valued attribute, which holds the
+// values of those attributes.  Date calls this operation WRAP.  Each source
+// tuple results in exactly one tuple, so no duplicates can occur.
+type wrapExpr struct {
+	// the input relation
+	source1 Relation
+
+	// zero is the resulting relation tuple type
+	zero interface{}
+
+	// name is the tuple-valued attribute which holds the wrapped attributes
+	name Attribute
+
+	// err is the first error encountered during construction or evaluation
+	err error
+}
+
+// wrapMap creates a map from the attributes of the flat tuple type to the
+// attributes of the nested tuple type, where the attributes of the
+// tuple-valued attribute name are treated as attributes of the nested tuple
+// type.  The values have the index sequences of the attributes in the flat
+// type in I, and in the nested type in J.
+func wrapMap(flat, nested reflect.Type, name Attribute) map[Attribute]fieldIndex {
+	w := attributeField(nested, name)
+	m := fieldMap(flat, nested)
+	delete(m, name)
+	for att, fm := range fieldMap(flat, nested.FieldByIndex(w).Type) {
+		m[att] = fieldIndex{fm.I, append(append([]int(nil), w...), fm.J...)}
+	}
+	return m
+}
+
+// wrapKeys replaces the attributes in wrapped in each of the candidate keys
+// with the attribute name.  If a key contained any of the wrapped attributes,
+// then the key containing name instead is still unique.
+func wrapKeys(cKeys1 CandKeys, wrapped []Attribute, name Attribute) CandKeys {
+	var cKeys2 CandKeys
+KeyLoop:
+	for _, ck1 := range cKeys1 {
+		var ck2 []Attribute
+		hasName := false
+		for _, att := range ck1 {
+			if IsSubDomain([]Attribute{att}, wrapped) {
+				if !hasName {
+					ck2 = append(ck2, name)
+					hasName = true
+				}
+			} else {
+				ck2 = append(ck2, att)
+			}
+		}
+		OrderCandidateKeys(CandKeys{ck2})
+		for _, ck := range cKeys2 {
+			if len(ck) == len(ck2) && IsSubDomain(ck, ck2) {
+				continue KeyLoop
+			}
+		}
+		cKeys2 = append(cKeys2, ck2)
+	}
+	OrderCandidateKeys(cKeys2)
+	return cKeys2
+}
+
+// wrapped returns the attributes held in the tuple-valued attribute
+func (r1 *wrapExpr) wrapped() []Attribute {
+	e2 := reflect.TypeOf(r1.zero)
+	return FieldNames(e2.FieldByIndex(attributeField(e2, r1.name)).Type)
+}
+
+// TupleChan sends each tuple in the relation to a channel
+func (r1 *wrapExpr) TupleChan(t interface{}) chan<- struct{} {
+	cancel := make(chan struct{})
+	// reflect on the channel
+	chv := reflect.ValueOf(t)
+	err := EnsureChan(chv.Type(), r1.zero)
+	if err != nil {
+		r1.err = err
+		return cancel
+	}
+	if r1.err != nil {
+		chv.Close()
+		return cancel
+	}
+
+	e1 := reflect.TypeOf(r1.source1.Zero())
+	e2 := reflect.TypeOf(r1.zero)
+	fMap := wrapMap(e1, e2, r1.name)
+
+	body := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e1), 0)
+	bcancel := r1.source1.TupleChan(body.Interface())
+
+	go func(body, res reflect.Value) {
+		// input channels
+		sourceSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: body}
+		canSel := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancel)}
+		inCases := []reflect.SelectCase{canSel, sourceSel}
+
+		// output channels
+		resSel := reflect.SelectCase{Dir: reflect.SelectSend, Chan: res}
+		for {
+			chosen, tup, ok := reflect.Select(inCases)
+			if chosen == 0 {
+				// cancel has been closed, so close the source as well
+				close(bcancel)
+				return
+			}
+			if !ok {
+				// source channel was closed
+				break
+			}
+			tup2 := reflect.Indirect(reflect.New(e2))
+			for _, fm := range fMap {
+				tup2.FieldByIndex(fm.J).Set(tup.FieldByIndex(fm.I))
+			}
+			resSel.Send = tup2
+			chosen, _, _ = reflect.Select([]reflect.SelectCase{canSel, resSel})
+			if chosen == 0 {
+				close(bcancel)
+				return
+			}
+		}
+		if err := r1.source1.Err(); err != nil {
+			r1.err = err
+		}
+		res.Close()
+	}(body, chv)
+	return cancel
+}
+
+// TupleChanContext sends each tuple in the relation to a channel, and returns
+// when they have all been sent or the context is done
+func (r1 *wrapExpr) TupleChanContext(ctx context.Context, t interface{}) error {
+	return TupleChanContext(ctx, r1, t)
+}
+
+// TupleSlice sets the slice pointed to by t to the tuples in the relation
+func (r1 *wrapExpr) TupleSlice(t interface{}) error {
+	return TupleSlice(r1, t)
+}
+
+// TupleMap sets the map pointed to by t to the tuples in the relation
+func (r1 *wrapExpr) TupleMap(t interface{}) error {
+	return TupleMap(r1, t)
+}
+
+// Zero returns the zero value of the relation (a blank tuple)
+func (r1 *wrapExpr) Zero() interface{} {
+	return r1.zero
+}
+
+// CKeys is the set of candidate keys in the relation
+func (r1 *wrapExpr) CKeys() CandKeys {
+	return wrapKeys(r1.source1.CKeys(), r1.wrapped(), r1.name)
+}
+
+// SortOrder is the set of attributes that the relation is sorted on.  The
+// order of the source is retained up to the first wrapped attribute.
+func (r1 *wrapExpr) SortOrder() []Attribute {
+	return orderPrefix(SortOrder(r1.source1), Heading(r1))
+}
+
+// GoString returns a text representation of the Relation
+func (r1 *wrapExpr) GoString() string {
+	return goStringTabTable(r1)
+}
+
+// String returns a text representation of the Relation
+func (r1 *wrapExpr) String() string {
+	return r1.source1.String() + ".Wrap({" + attributeString(r1.wrapped()) + "}->" + string(r1.name) + ")"
+}
+
+// Project creates a new relation with less than or equal degree
+// t2 has to be a new type which is a subdomain of r.
+func (r1 *wrapExpr) Project(z2 interface{}) Relation {
+	return Rewrite(r1, NewProject(r1, z2))
+}
+
+// Restrict creates a new relation with less than or equal cardinality
+// p has to be a func(tup T) bool where tup is a subdomain of the input r.
+func (r1 *wrapExpr) Restrict(p Predicate) Relation {
+	return Rewrite(r1, NewRestrict(r1, p))
+}
+
+// Rename creates a new relation with new column names
+// z2 has to be a struct with the same number of fields as the input relation
+func (r1 *wrapExpr) Rename(z2 interface{}) Relation {
+	return Rewrite(r1, NewRename(r1, z2))
+}
+
+// Union creates a new relation by unioning the bodies of both inputs
+func (r1 *wrapExpr) Union(r2 Relation) Relation {
+	return Rewrite(r1, NewUnion(r1, r2))
+}
+
+// Diff creates a new relation by set minusing the two inputs
+func (r1 *wrapExpr) Diff(r2 Relation) Relation {
+	return Rewrite(r1, NewDiff(r1, r2))
+}
+
+// Join creates a new relation by performing a natural join on the inputs
+func (r1 *wrapExpr) Join(r2 Relation, zero interface{}) Relation {
+	return Rewrite(r1, NewJoin(r1, r2, zero))
+}
+
+// GroupBy creates a new relation by grouping and applying a user defined func
+func (r1 *wrapExpr) GroupBy(t2, gfcn interface{}) Relation {
+	return Rewrite(r1, NewGroupBy(r1, t2, gfcn))
+}
+
+// Map creates a new relation by applying a function to tuples in the source
+func (r1 *wrapExpr) Map(mfcn interface{}, ckeystr [][]string) Relation {
+	return Rewrite(r1, NewMap(r1, mfcn, ckeystr))
+}
+
+// Extend creates a new relation by adding attributes computed from the
+// existing ones
+func (r1 *wrapExpr) Extend(z2, efcn interface{}) Relation {
+	return Rewrite(r1, NewExtend(r1, z2, efcn))
+}
+
+// Order creates a new relation with tuples sorted on the input attributes
+func (r1 *wrapExpr) Order(att ...Attribute) Relation {
+	return Rewrite(r1, NewOrder(r1, att...))
+}
+
+// SemiJoin creates a new relation with the tuples of the input that match
+// tuples in r2
+func (r1 *wrapExpr) SemiJoin(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiJoin(r1, r2))
+}
+
+// SemiDiff creates a new relation with the tuples of the input that do not
+// match tuples in r2
+func (r1 *wrapExpr) SemiDiff(r2 Relation) Relation {
+	return Rewrite(r1, NewSemiDiff(r1, r2))
+}
+
+// Wrap creates a new relation with some of the attributes of the input
+// gathered into a tuple-valued attribute
+func (r1 *wrapExpr) Wrap(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewWrap(r1, z2, name))
+}
+
+// Unwrap creates a new relation with the attributes of a tuple-valued
+// attribute of the input in place of it
+func (r1 *wrapExpr) Unwrap(name Attribute) Relation {
+	return Rewrite(r1, NewUnwrap(r1, name))
+}
+
+// Group creates a new relation with some of the attributes of the input
+// gathered into a relation-valued attribute
+func (r1 *wrapExpr) Group(z2 interface{}, name Attribute) Relation {
+	return Rewrite(r1, NewGroup(r1, z2, name))
+}
+
+// Ungroup creates a new relation with the attributes of the relations held
+// in a relation-valued attribute of the input in place of it
+func (r1 *wrapExpr) Ungroup(name Attribute) Relation {
+	return Rewrite(r1, NewUngroup(r1, name))
+}
+
+// Divide creates a new relation with the tuples of the input that are
+// related by per to every tuple in r2
+func (r1 *wrapExpr) Divide(r2, per Relation) Relation {
+	return Rewrite(r1, NewDivide(r1, r2, per))
+}
+
+// TClose creates a new relation with the transitive closure of the input
+func (r1 *wrapExpr) TClose() Relation {
+	return Rewrite(r1, NewTClose(r1))
+}
+
+// Err returns an error encountered during construction or computation
+func (r1 *wrapExpr) Err() error {
+	return r1.err
+}
+
+// Op is the operation that produces the relation
+func (r1 *wrapExpr) Op() OpKind {
+	return OpWrap
+}
+
+// Children are the relations that the operation is performed on
+func (r1 *wrapExpr) Children() []Relation {
+	return []Relation{r1.source1}
+}
+
+// Wrapped is the tuple-valued attribute which holds the wrapped attributes
+func (r1 *wrapExpr) Wrapped() Attribute {
+	return r1.name
+}
@@ -0,0 +1,200 @@
+package rel
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// tests for wrap op
+func TestWrap(t *testing.T) {
+	type placeTup struct {
+		Color string
+		City  string
+	}
+	type wrapTup struct {
+		PNO    int
+		PName  string
+		Weight float64
+		Place  placeTup
+	}
+	type keyTup struct {
+		PNO int
+		SNO int
+	}
+	type orderWrapTup struct {
+		Key keyTup
+		Qty int
+	}
+	type pnoTup struct {
+		PNO int
+	}
+	type placeOnlyTup struct {
+		Place placeTup
+	}
+	type titleCaseTup struct {
+		Pno    int
+		Pname  string
+		Weight float64
+		Place  placeTup
+	}
+
+	// test the degrees, cardinality, and string representation
+	rel := parts().Wrap(wrapTup{}, "Place")
+	var relTest = []struct {
+		rel          Relation
+		expectString string
+		expectDeg    int
+		expectCard   int
+	}{
+		{rel, "Relation(PNO, PName, Color, Weight, City).Wrap({Color, City}->Place)", 4, 6},
+		{orders().Wrap(orderWrapTup{}, "Key"), "Relation(PNO, SNO, Qty).Wrap({PNO, SNO}->Key)", 2, 12},
+		{rel.Restrict(Attribute("PNO").EQ(1)), "σ{PNO == 1}(Relation(PNO, PName, Color, Weight, City).Wrap({Color, City}->Place))", 4, 1},
+		{rel.Restrict(Attribute("Place").EQ(placeTup{"Red", "London"})), "", 4, 3},
+		{rel.Project(pnoTup{}), "", 1, 6},
+		{rel.Project(placeOnlyTup{}), "", 1, 4},
+		{rel.Rename(titleCaseTup{}), "", 4, 6},
+		{rel.Union(rel), "", 4, 6},
+		{rel.Diff(rel.Restrict(Attribute("PNO").GT(4))), "", 4, 4},
+		{rel.SemiJoin(orders()), "", 4, 4},
+		{rel.Unwrap("Place"), "Relation(PNO, PName, Color, Weight, City).Wrap({Color, City}->Place).Unwrap(Place->{Color, City})", 5, 6},
+	}
+
+	for i, tt := range relTest {
+		if err := tt.rel.Err(); err != nil {
+			t.Errorf("%d has Err() => %s", i, err.Error())
+			continue
+		}
+		if tt.expectString != "" {
+			if str := tt.rel.String(); str != tt.expectString {
+				t.Errorf("%d has String() => %v, want %v", i, str, tt.expectString)
+			}
+		}
+		if deg := Deg(tt.rel); deg != tt.expectDeg {
+			t.Errorf("%d %s has Deg() => %v, want %v", i, tt.rel, deg, tt.expectDeg)
+		}
+		if card := Card(tt.rel); card != tt.expectCard {
+			t.Errorf("%d %s has Card() => %v, want %v", i, tt.rel, card, tt.expectCard)
+		}
+	}
+
+	// the candidate keys have the wrapped attributes replaced by the new one
+	if ck := rel.CKeys(); !reflect.DeepEqual(ck, CandKeys{{"PNO"}}) {
+		t.Errorf("%s has CKeys() => %v, want [[PNO]]", rel, ck)
+	}
+	if ck := orders().Wrap(orderWrapTup{}, "Key").CKeys(); !reflect.DeepEqual(ck, CandKeys{{"Key"}}) {
+		t.Errorf("orders wrap has CKeys() => %v, want [[Key]]", ck)
+	}
+
+	// the wrapped attributes hold the values of the source
+	var tups []wrapTup
+	if err := rel.Restrict(Attribute("PNO").LT(3)).Order("PNO").TupleSlice(&tups); err != nil {
+		t.Fatal(err)
+	}
+	want := []wrapTup{
+		{1, "Nut", 12.0, placeTup{"Red", "London"}},
+		{2, "Bolt", 17.0, placeTup{"Green", "Paris"}},
+	}
+	if !reflect.DeepEqual(tups, want) {
+		t.Errorf("%s has tuples %v, want %v", rel, tups, want)
+	}
+
+	// test construction errors
+	type missingTup struct {
+		PNO   int
+		PName string
+		Place placeTup
+	}
+	type notStructTup struct {
+		PNO    int
+		PName  string
+		Color  string
+		Weight float64
+		Place  string
+	}
+	type conflictTup struct {
+		PNO    int
+		PName  string
+		Color  string
+		Weight float64
+		Place  placeTup
+	}
+	type badPlaceTup struct {
+		Color string
+		Town  string
+	}
+	type badTup struct {
+		PNO    int
+		PName  string
+		Weight float64
+		Place  badPlaceTup
+	}
+	type typePlaceTup struct {
+		Color int
+		City  string
+	}
+	type typeTup struct {
+		PNO    int
+		PName  string
+		Weight float64
+		Place  typePlaceTup
+	}
+	constructTest := []struct {
+		rel Relation
+		err error
+	}{
+		{parts().Wrap(wrapTup{}, "Loc"), &AttributeSubsetError{}},
+		{parts().Wrap(missingTup{}, "Place"), &DomainMismatchError{}},
+		{parts().Wrap(notStructTup{}, "Place"), &KindError{}},
+		{parts().Wrap(conflictTup{}, "Place"), &AttributeConflictError{}},
+		{parts().Wrap(badTup{}, "Place"), &DomainMismatchError{}},
+		{parts().Wrap(typeTup{}, "Place"), &AttributeTypeError{}},
+	}
+	for i, tt := range constructTest {
+		err := tt.rel.Err()
+		if err == nil {
+			t.Errorf("%d did not produce a construction error", i)
+			continue
+		}
+		if reflect.TypeOf(err) != reflect.TypeOf(tt.err) {
+			t.Errorf("%d has Err() => %T, want %T", i, err, tt.err)
+		}
+	}
+
+	// test cancellation
+	res := make(chan wrapTup)
+	cancel := rel.TupleChan(res)
+	close(cancel)
+	select {
+	case <-res:
+		t.Errorf("cancel did not end tuple generation")
+	default:
+		// passed test
+	}
+
+	// test errors
+	err := fmt.Errorf("testing error")
+	r1 := parts().Wrap(wrapTup{}, "Place").(*wrapExpr)
+	r1.err = err
+	res = make(chan wrapTup)
+	_ = r1.TupleChan(res)
+	if _, ok := <-res; ok {
+		t.Errorf("wrap did not short circuit TupleChan")
+	}
+	errTest := []Relation{
+		r1.Project(pnoTup{}),
+		r1.Restrict(Attribute("PNO").EQ(1)),
+		r1.Rename(titleCaseTup{}),
+		r1.Union(rel),
+		rel.Union(r1),
+		r1.Diff(rel),
+		r1.SemiJoin(orders()),
+		r1.SemiDiff(orders()),
+		r1.Unwrap("Place"),
+	}
+	for i, errRel := range errTest {
+		if errRel.Err() != err {
+			t.Errorf("%d did not short circuit error", i)
+		}
+	}
+}